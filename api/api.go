@@ -30,6 +30,31 @@ func (c ChargeMode) String() string {
 	return string(c)
 }
 
+// BatteryMode is the home battery operation mode
+type BatteryMode int
+
+// Battery modes
+const (
+	BatteryUnknown BatteryMode = iota
+	BatteryNormal              // charge and discharge as controlled by the battery system
+	BatteryHold                // prevent discharging
+	BatteryCharge              // charge from grid
+)
+
+// String implements Stringer
+func (m BatteryMode) String() string {
+	switch m {
+	case BatteryNormal:
+		return "normal"
+	case BatteryHold:
+		return "hold"
+	case BatteryCharge:
+		return "charge"
+	default:
+		return "unknown"
+	}
+}
+
 // ChargeStatus is the EV's charging status from A to F
 type ChargeStatus string
 
@@ -56,6 +81,9 @@ type ActionConfig struct {
 	MaxCurrent *float64    `mapstructure:"maxCurrent,omitempty"` // Maximum Current
 	MinSoC     *int        `mapstructure:"minSoC,omitempty"`     // Minimum SoC
 	TargetSoC  *int        `mapstructure:"targetSoC,omitempty"`  // Target SoC
+	Phases     *int        `mapstructure:"phases,omitempty"`     // Enabled phases
+	Window     *string     `mapstructure:"window,omitempty"`     // Allowed charging time window (hh:mm-hh:mm)
+	TargetTime *string     `mapstructure:"targetTime,omitempty"` // Default target charge time of day (hh:mm)
 }
 
 // String implements Stringer and returns the ActionConfig as comma-separated key:value string
@@ -90,6 +118,11 @@ type Battery interface {
 	SoC() (float64, error)
 }
 
+// BatteryController optionally allows to control home battery (dis)charging behaviour
+type BatteryController interface {
+	SetBatteryMode(BatteryMode) error
+}
+
 // ChargeState provides current charging status
 type ChargeState interface {
 	Status() (ChargeStatus, error)
@@ -197,6 +230,36 @@ type Tariff interface {
 	CurrentPrice() (float64, error) // EUR/kWh, CHF/kWh, ...
 }
 
+// Rate is a tariff price slot
+type Rate struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Price float64   `json:"price"` // EUR/kWh, CHF/kWh, ...
+}
+
+// Rates is a slice of tariff price slots
+type Rates []Rate
+
+// TariffRates provides current and future tariff price slots
+type TariffRates interface {
+	Rates() (Rates, error)
+}
+
+// ForecastSlot is a predicted solar production slot
+type ForecastSlot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Power float64   `json:"power"` // W
+}
+
+// ForecastSlots is a slice of predicted solar production slots
+type ForecastSlots []ForecastSlot
+
+// Forecast provides predicted solar production
+type Forecast interface {
+	Forecast() (ForecastSlots, error)
+}
+
 type WebController interface {
 	WebControl(*mux.Router)
 }
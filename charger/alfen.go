@@ -88,23 +88,34 @@ func NewAlfen(uri string, slaveID uint8) (api.Charger, error) {
 		conn: conn,
 	}
 
-	go wb.heartbeat()
+	done := make(chan struct{})
+	util.OnRelease(func() { close(done) })
+
+	go wb.heartbeat(done)
 
 	return wb, err
 }
 
 // heartbeat implements the api.ChargerEx interface
-func (wb *Alfen) heartbeat() {
-	for range time.NewTicker(time.Minute).C {
-		wb.mu.Lock()
-		var curr float64
-		if wb.enabled {
-			curr = wb.curr
-		}
-		wb.mu.Unlock()
-
-		if err := wb.setCurrent(curr); err != nil {
-			wb.log.ERROR.Println("heartbeat:", err)
+func (wb *Alfen) heartbeat(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			wb.mu.Lock()
+			var curr float64
+			if wb.enabled {
+				curr = wb.curr
+			}
+			wb.mu.Unlock()
+
+			if err := wb.setCurrent(curr); err != nil {
+				wb.log.ERROR.Println("heartbeat:", err)
+			}
+		case <-done:
+			return
 		}
 	}
 }
@@ -12,6 +12,7 @@ import (
 	"github.com/evcc-io/evcc/core/loadpoint"
 	"github.com/evcc-io/evcc/server"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 )
 
 const maxIdRequestTimespan = time.Second * 120
@@ -55,6 +56,10 @@ func NewEEBusFromConfig(other map[string]interface{}) (api.Charger, error) {
 func NewEEBus(ski string, forcePVLimits bool) (*EEBus, error) {
 	log := util.NewLogger("eebus")
 
+	if err := record.Unrecorded(record.EEBus, ski); err != nil {
+		return nil, err
+	}
+
 	if server.EEBusInstance == nil {
 		return nil, errors.New("eebus not configured")
 	}
@@ -10,6 +10,7 @@ import (
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/charger/keba"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 )
 
 // https://www.keba.com/file/downloads/e-mobility/KeContact_P20_P30_UDP_ProgrGuide_en.pdf
@@ -75,6 +76,10 @@ func NewKebaFromConfig(other map[string]interface{}) (api.Charger, error) {
 func NewKeba(uri, serial string, rfid RFID, timeout time.Duration) (*Keba, error) {
 	log := util.NewLogger("keba")
 
+	if err := record.Unrecorded(record.UDP, uri); err != nil {
+		return nil, err
+	}
+
 	if keba.Instance == nil {
 		var err error
 		keba.Instance, err = keba.New(log)
@@ -102,6 +107,7 @@ func NewKeba(uri, serial string, rfid RFID, timeout time.Duration) (*Keba, error
 	}
 
 	keba.Instance.Subscribe(serial, c.recv)
+	util.OnRelease(func() { keba.Instance.Unsubscribe(serial, c.recv) })
 
 	return c, err
 }
@@ -75,6 +75,16 @@ func (l *Listener) Subscribe(addr string, c chan<- UDPMsg) {
 	l.clients[addr] = c
 }
 
+// Unsubscribe removes the client's subscription unless replaced by another subscriber
+func (l *Listener) Unsubscribe(addr string, c chan<- UDPMsg) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if l.clients[addr] == c {
+		delete(l.clients, addr)
+	}
+}
+
 func (l *Listener) listen() {
 	b := make([]byte, udpBufferSize)
 
@@ -10,6 +10,7 @@ import (
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/charger/nrgble"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 	"github.com/godbus/dbus/v5"
 	"github.com/lunixbochs/struc"
 	"github.com/muka/go-bluetooth/bluez/profile/adapter"
@@ -60,6 +61,10 @@ func NewNRGKickBLEFromConfig(other map[string]interface{}) (api.Charger, error)
 func NewNRGKickBLE(device, mac string, pin int) (*NRGKickBLE, error) {
 	logger := util.NewLogger("nrg-bt")
 
+	if err := record.Unrecorded(record.Bluetooth, mac); err != nil {
+		return nil, err
+	}
+
 	ainfo, err := hw.GetAdapter(device)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,291 @@
+package charger
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/charger/ocpp"
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/remotetrigger"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// OCPP charger implementation. Charge points connect to evcc acting as OCPP 1.6J central system.
+type OCPP struct {
+	log     *util.Logger
+	cs      *ocpp.CS
+	cp      *ocpp.CP
+	timeout time.Duration
+	done    chan struct{}
+
+	mu      sync.Mutex
+	current float64
+	enabled bool
+	applied bool // charging profile has been applied and is restored on reconnect
+}
+
+const ocppProfileId = 1
+
+func init() {
+	registry.Add("ocpp", NewOCPPFromConfig)
+}
+
+// NewOCPPFromConfig creates an OCPP charger from generic config
+func NewOCPPFromConfig(other map[string]interface{}) (api.Charger, error) {
+	cc := struct {
+		StationId string
+		Connector int
+		Timeout   time.Duration
+	}{
+		Connector: 1,
+		Timeout:   time.Minute,
+	}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if cc.StationId == "" {
+		return nil, errors.New("missing stationid")
+	}
+
+	cs, err := ocpp.Instance()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewOCPP(cs, cc.StationId, cc.Connector, cc.Timeout)
+}
+
+// NewOCPP creates OCPP charger
+func NewOCPP(cs *ocpp.CS, id string, connector int, timeout time.Duration) (*OCPP, error) {
+	log := util.NewLogger("ocpp-" + id)
+
+	cp := ocpp.NewChargePoint(log, id, connector, 2*timeout)
+	if err := cs.Register(id, cp); err != nil {
+		return nil, err
+	}
+
+	c := &OCPP{
+		log:     log,
+		cs:      cs,
+		cp:      cp,
+		timeout: timeout,
+		done:    make(chan struct{}),
+	}
+
+	util.OnRelease(func() {
+		cs.Unregister(id, cp)
+		close(c.done)
+	})
+
+	go c.run()
+
+	return c, nil
+}
+
+// run sets up the charge point whenever it connects or reconnects until released
+func (c *OCPP) run() {
+	for {
+		select {
+		case <-c.cp.Connects():
+			c.setup()
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// setup configures meter values, requests the status and restores the charging profile
+func (c *OCPP) setup() {
+	for key, val := range map[string]string{
+		"MeterValuesSampledData":   "Power.Active.Import,Energy.Active.Import.Register,Current.Import",
+		"MeterValueSampleInterval": "10",
+	} {
+		if err := c.changeConfiguration(key, val); err != nil {
+			c.log.WARN.Printf("configure %s: %v", key, err)
+		}
+	}
+
+	rc := make(chan error, 1)
+	err := c.cs.TriggerMessage(c.cp.ID(), func(resp *remotetrigger.TriggerMessageConfirmation, err error) {
+		if err == nil && resp.Status != remotetrigger.TriggerMessageStatusAccepted {
+			err = fmt.Errorf("trigger status notification: %s", resp.Status)
+		}
+		rc <- err
+	}, core.StatusNotificationFeatureName, func(request *remotetrigger.TriggerMessageRequest) {
+		connector := c.cp.Connector()
+		request.ConnectorId = &connector
+	})
+
+	if err := c.wait(err, rc); err != nil {
+		c.log.WARN.Println(err)
+	}
+
+	c.mu.Lock()
+	applied, current := c.applied, c.current
+	if !c.enabled {
+		current = 0
+	}
+	c.mu.Unlock()
+
+	if applied {
+		if err := c.setChargingProfile(current); err != nil {
+			c.log.WARN.Printf("restore charging profile: %v", err)
+		}
+	}
+}
+
+// wait waits for the asynchronous charge point response
+func (c *OCPP) wait(err error, rc chan error) error {
+	if err == nil {
+		select {
+		case err = <-rc:
+		case <-time.After(c.timeout):
+			err = errors.New("timeout")
+		}
+	}
+
+	return err
+}
+
+func (c *OCPP) changeConfiguration(key, val string) error {
+	rc := make(chan error, 1)
+	err := c.cs.ChangeConfiguration(c.cp.ID(), func(resp *core.ChangeConfigurationConfirmation, err error) {
+		if err == nil && resp.Status != core.ConfigurationStatusAccepted && resp.Status != core.ConfigurationStatusRebootRequired {
+			err = fmt.Errorf("%s", resp.Status)
+		}
+		rc <- err
+	}, key, val)
+
+	return c.wait(err, rc)
+}
+
+// setChargingProfile limits the connector's current using a default transaction profile.
+// The profile is relative to the transaction start as absolute profiles require a start schedule.
+func (c *OCPP) setChargingProfile(current float64) error {
+	profile := types.NewChargingProfile(
+		ocppProfileId, 0,
+		types.ChargingProfilePurposeTxDefaultProfile,
+		types.ChargingProfileKindRelative,
+		types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, current)),
+	)
+
+	rc := make(chan error, 1)
+	err := c.cs.SetChargingProfile(c.cp.ID(), func(resp *smartcharging.SetChargingProfileConfirmation, err error) {
+		if err == nil && resp.Status != smartcharging.ChargingProfileStatusAccepted {
+			err = fmt.Errorf("set charging profile: %s", resp.Status)
+		}
+		rc <- err
+	}, c.cp.Connector(), profile)
+
+	return c.wait(err, rc)
+}
+
+// Status implements the api.Charger interface
+func (c *OCPP) Status() (api.ChargeStatus, error) {
+	return c.cp.Status()
+}
+
+// Enabled implements the api.Charger interface
+func (c *OCPP) Enabled() (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.enabled, nil
+}
+
+// Enable implements the api.Charger interface
+func (c *OCPP) Enable(enable bool) error {
+	c.mu.Lock()
+	var current float64
+	if enable {
+		current = c.current
+	}
+	c.mu.Unlock()
+
+	err := c.setChargingProfile(current)
+	if err == nil {
+		c.mu.Lock()
+		c.enabled = enable
+		c.applied = true
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+// MaxCurrent implements the api.Charger interface
+func (c *OCPP) MaxCurrent(current int64) error {
+	return c.MaxCurrentMillis(float64(current))
+}
+
+var _ api.ChargerEx = (*OCPP)(nil)
+
+// MaxCurrentMillis implements the api.ChargerEx interface
+func (c *OCPP) MaxCurrentMillis(current float64) error {
+	c.mu.Lock()
+	enabled := c.enabled
+	c.mu.Unlock()
+
+	var err error
+	if enabled {
+		err = c.setChargingProfile(current)
+	}
+
+	if err == nil {
+		c.mu.Lock()
+		c.current = current
+		c.mu.Unlock()
+	}
+
+	return err
+}
+
+var _ api.Meter = (*OCPP)(nil)
+
+// CurrentPower implements the api.Meter interface
+func (c *OCPP) CurrentPower() (float64, error) {
+	if status, err := c.cp.Status(); err != nil || status != api.StatusC {
+		return 0, err
+	}
+
+	return c.cp.Measurement(types.MeasurandPowerActiveImport, "")
+}
+
+var _ api.MeterEnergy = (*OCPP)(nil)
+
+// TotalEnergy implements the api.MeterEnergy interface
+func (c *OCPP) TotalEnergy() (float64, error) {
+	f, err := c.cp.Measurement(types.MeasurandEnergyActiveImportRegister, "")
+	return f / 1e3, err
+}
+
+var _ api.MeterCurrent = (*OCPP)(nil)
+
+// Currents implements the api.MeterCurrent interface
+func (c *OCPP) Currents() (float64, float64, float64, error) {
+	var currents [3]float64
+
+	for i, phase := range []types.Phase{types.PhaseL1, types.PhaseL2, types.PhaseL3} {
+		f, err := c.cp.Measurement(types.MeasurandCurrentImport, phase)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+
+		currents[i] = f
+	}
+
+	return currents[0], currents[1], currents[2], nil
+}
+
+var _ api.Identifier = (*OCPP)(nil)
+
+// Identify implements the api.Identifier interface
+func (c *OCPP) Identify() (string, error) {
+	return c.cp.IdTag(), nil
+}
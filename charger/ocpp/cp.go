@@ -0,0 +1,264 @@
+package ocpp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// CP is a charge point connector as seen by the central system
+type CP struct {
+	mu        sync.Mutex
+	log       *util.Logger
+	id        string
+	connector int
+
+	connected    bool
+	connectC     chan struct{} // closed on first connection
+	connects     chan struct{} // signals every connection
+	status       *core.StatusNotificationRequest
+	measurements map[string]types.SampledValue
+	meterUpdated time.Time
+	timeout      time.Duration
+
+	txnId int    // active transaction id
+	idTag string // id tag of current authorization or transaction
+}
+
+// NewChargePoint creates a charge point connector. Meter values older than timeout are considered outdated.
+func NewChargePoint(log *util.Logger, id string, connector int, timeout time.Duration) *CP {
+	return &CP{
+		log:          log,
+		id:           id,
+		connector:    connector,
+		connectC:     make(chan struct{}),
+		connects:     make(chan struct{}, 1),
+		measurements: make(map[string]types.SampledValue),
+		timeout:      timeout,
+	}
+}
+
+// ID returns the charge point id
+func (cp *CP) ID() string {
+	return cp.id
+}
+
+// Connector returns the charge point connector id
+func (cp *CP) Connector() int {
+	return cp.connector
+}
+
+func (cp *CP) connect(connected bool) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	// signal first connection
+	if connected && cp.connectC != nil {
+		close(cp.connectC)
+		cp.connectC = nil
+	}
+
+	// signal every connection, a pending signal is not repeated
+	if connected {
+		select {
+		case cp.connects <- struct{}{}:
+		default:
+		}
+	}
+
+	cp.connected = connected
+}
+
+// Connected returns if the charge point is connected
+func (cp *CP) Connected() bool {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.connected
+}
+
+// HasConnected returns a channel that is closed once the charge point has connected
+func (cp *CP) HasConnected() <-chan struct{} {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if cp.connectC == nil {
+		c := make(chan struct{})
+		close(c)
+		return c
+	}
+
+	return cp.connectC
+}
+
+// Connects returns a channel receiving a signal whenever the charge point connects or reconnects
+func (cp *CP) Connects() <-chan struct{} {
+	return cp.connects
+}
+
+// Authorize handles authorization requests. The id tag is used for identification even if it is rejected.
+func (cp *CP) Authorize(idTag string, status types.AuthorizationStatus) *types.IdTagInfo {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.log.DEBUG.Printf("authorize: %s (%s)", idTag, status)
+	cp.idTag = idTag
+
+	return types.NewIdTagInfo(status)
+}
+
+// StatusNotification handles status notifications
+func (cp *CP) StatusNotification(request *core.StatusNotificationRequest) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.log.TRACE.Printf("status: %s (%s)", request.Status, request.ErrorCode)
+	cp.status = request
+
+	// identification ends with the vehicle leaving
+	if request.Status == core.ChargePointStatusAvailable {
+		cp.idTag = ""
+	}
+}
+
+// MeterValues handles meter values
+func (cp *CP) MeterValues(values []types.MeterValue) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.updateMeterValues(values)
+}
+
+func (cp *CP) updateMeterValues(values []types.MeterValue) {
+	for _, meterValue := range values {
+		for _, sample := range meterValue.SampledValue {
+			measurand := sample.Measurand
+			if measurand == "" {
+				measurand = types.MeasurandEnergyActiveImportRegister
+			}
+
+			cp.measurements[measurementKey(measurand, sample.Phase)] = sample
+		}
+	}
+
+	cp.meterUpdated = time.Now()
+}
+
+// StartTransaction handles transaction start. The id tag is used for identification even if it is rejected.
+func (cp *CP) StartTransaction(request *core.StartTransactionRequest, txnId int, status types.AuthorizationStatus) *types.IdTagInfo {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.log.DEBUG.Printf("start transaction: %d (%s %s)", txnId, request.IdTag, status)
+
+	cp.txnId = txnId
+	cp.idTag = request.IdTag
+
+	return types.NewIdTagInfo(status)
+}
+
+// StopTransaction handles transaction stop
+func (cp *CP) StopTransaction(request *core.StopTransactionRequest) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if request.TransactionId != cp.txnId {
+		return
+	}
+
+	cp.log.DEBUG.Printf("stop transaction: %d (%s)", request.TransactionId, request.Reason)
+
+	cp.txnId = 0
+	cp.updateMeterValues(request.TransactionData)
+}
+
+// TransactionID returns the active transaction id
+func (cp *CP) TransactionID() int {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.txnId
+}
+
+// IdTag returns the id tag of the current authorization or transaction
+func (cp *CP) IdTag() string {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	return cp.idTag
+}
+
+// Status returns the connector's charge status
+func (cp *CP) Status() (api.ChargeStatus, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if !cp.connected {
+		return api.StatusNone, fmt.Errorf("charge point not connected: %s", cp.id)
+	}
+
+	if cp.status == nil {
+		return api.StatusNone, api.ErrMustRetry
+	}
+
+	if cp.status.ErrorCode != core.NoError {
+		return api.StatusNone, fmt.Errorf("%s: %s", cp.status.ErrorCode, cp.status.Info)
+	}
+
+	switch cp.status.Status {
+	case core.ChargePointStatusAvailable, core.ChargePointStatusUnavailable, core.ChargePointStatusReserved:
+		return api.StatusA, nil
+	case core.ChargePointStatusPreparing, core.ChargePointStatusSuspendedEV, core.ChargePointStatusSuspendedEVSE, core.ChargePointStatusFinishing:
+		return api.StatusB, nil
+	case core.ChargePointStatusCharging:
+		return api.StatusC, nil
+	case core.ChargePointStatusFaulted:
+		return api.StatusF, nil
+	default:
+		return api.StatusNone, fmt.Errorf("invalid status: %s", cp.status.Status)
+	}
+}
+
+// Measurement returns the latest sampled value converted to base units (W, Wh, A, V).
+// Returns api.ErrNotAvailable if the measurand has not been received.
+func (cp *CP) Measurement(measurand types.Measurand, phase types.Phase) (float64, error) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	sample, ok := cp.measurements[measurementKey(measurand, phase)]
+	if !ok && phase != "" {
+		// accept line-to-neutral values for phase measurements
+		sample, ok = cp.measurements[measurementKey(measurand, phase+"-N")]
+	}
+
+	if !ok {
+		return 0, api.ErrNotAvailable
+	}
+
+	if cp.timeout > 0 && time.Since(cp.meterUpdated) > cp.timeout {
+		return 0, fmt.Errorf("outdated meter values: %v", cp.meterUpdated.Round(time.Second))
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(sample.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid meter value %s: %w", sample.Value, err)
+	}
+
+	switch sample.Unit {
+	case types.UnitOfMeasureKW, types.UnitOfMeasureKWh, types.UnitOfMeasureKVA, types.UnitOfMeasureKvar, types.UnitOfMeasureKvarh:
+		f *= 1e3
+	}
+
+	return f, nil
+}
+
+func measurementKey(measurand types.Measurand, phase types.Phase) string {
+	if phase == "" {
+		return string(measurand)
+	}
+	return string(measurand) + "@" + string(phase)
+}
@@ -0,0 +1,259 @@
+package ocpp
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/util"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// CS is the OCPP central system dispatching charge point messages to registered charge points
+type CS struct {
+	mu  sync.Mutex
+	log *util.Logger
+	ocpp16.CentralSystem
+	cps    map[string]map[int]*CP // charge point connectors by station id and connector id
+	tokens rfid.Tokens
+	txnId  int
+}
+
+// NewCS creates an OCPP central system authorizing the whitelisted id tags. Call Start to listen for charge point connections.
+func NewCS(log *util.Logger, tokens rfid.Tokens) *CS {
+	cs := &CS{
+		log:           log,
+		CentralSystem: ocpp16.NewCentralSystem(nil, nil),
+		cps:           make(map[string]map[int]*CP),
+		tokens:        tokens,
+	}
+
+	cs.SetCoreHandler(cs)
+	cs.SetNewChargePointHandler(cs.NewChargePoint)
+	cs.SetChargePointDisconnectedHandler(cs.ChargePointDisconnected)
+
+	go cs.errorHandler(cs.Errors())
+
+	return cs
+}
+
+// errorHandler logs error channel
+func (cs *CS) errorHandler(errC <-chan error) {
+	for err := range errC {
+		cs.log.ERROR.Println(err)
+	}
+}
+
+// Register registers a charge point connector with the central system
+func (cs *CS) Register(id string, cp *CP) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.cps[id][cp.Connector()]; ok {
+		return fmt.Errorf("duplicate charge point: %s connector %d", id, cp.Connector())
+	}
+
+	if cs.cps[id] == nil {
+		cs.cps[id] = make(map[int]*CP)
+	}
+
+	cs.cps[id][cp.Connector()] = cp
+
+	return nil
+}
+
+// Unregister removes the charge point connector if it is still registered
+func (cs *CS) Unregister(id string, cp *CP) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.cps[id][cp.Connector()] != cp {
+		return
+	}
+
+	delete(cs.cps[id], cp.Connector())
+
+	if len(cs.cps[id]) == 0 {
+		delete(cs.cps, id)
+	}
+}
+
+// chargePoints returns the registered connectors of a charge point
+func (cs *CS) chargePoints(id string) ([]*CP, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	connectors, ok := cs.cps[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown charge point: %s", id)
+	}
+
+	res := make([]*CP, 0, len(connectors))
+	for _, cp := range connectors {
+		res = append(res, cp)
+	}
+
+	return res, nil
+}
+
+// connectorByID returns a registered charge point connector
+func (cs *CS) connectorByID(id string, connector int) (*CP, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if _, ok := cs.cps[id]; !ok {
+		return nil, fmt.Errorf("unknown charge point: %s", id)
+	}
+
+	cp, ok := cs.cps[id][connector]
+	if !ok {
+		return nil, fmt.Errorf("unknown connector: %s connector %d", id, connector)
+	}
+
+	return cp, nil
+}
+
+// NewChargePoint handles new charge point connections
+func (cs *CS) NewChargePoint(chargePoint ocpp16.ChargePointConnection) {
+	cps, err := cs.chargePoints(chargePoint.ID())
+	if err != nil {
+		cs.log.ERROR.Printf("connect: %v", err)
+		return
+	}
+
+	cs.log.DEBUG.Printf("charge point connected: %s", chargePoint.ID())
+	for _, cp := range cps {
+		cp.connect(true)
+	}
+}
+
+// ChargePointDisconnected handles charge point disconnects
+func (cs *CS) ChargePointDisconnected(chargePoint ocpp16.ChargePointConnection) {
+	cps, err := cs.chargePoints(chargePoint.ID())
+	if err != nil {
+		return
+	}
+
+	cs.log.DEBUG.Printf("charge point disconnected: %s", chargePoint.ID())
+	for _, cp := range cps {
+		cp.connect(false)
+	}
+}
+
+// nextTransactionID returns a unique transaction id
+func (cs *CS) nextTransactionID() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.txnId++
+	return cs.txnId
+}
+
+// core profile handlers
+
+// authorization returns the whitelist status of the id tag
+func (cs *CS) authorization(idTag string) types.AuthorizationStatus {
+	if _, ok := cs.tokens.Lookup(idTag); ok {
+		return types.AuthorizationStatusAccepted
+	}
+	return types.AuthorizationStatusInvalid
+}
+
+// OnAuthorize implements the core.CentralSystemHandler interface. Id tags not contained in the whitelist are rejected.
+// Authorization requests don't specify the connector, the id tag is assigned to all connectors without transaction.
+func (cs *CS) OnAuthorize(id string, request *core.AuthorizeRequest) (*core.AuthorizeConfirmation, error) {
+	cps, err := cs.chargePoints(id)
+	if err != nil {
+		return nil, err
+	}
+
+	status := cs.authorization(request.IdTag)
+	for _, cp := range cps {
+		if cp.TransactionID() == 0 {
+			cp.Authorize(request.IdTag, status)
+		}
+	}
+
+	return core.NewAuthorizationConfirmation(types.NewIdTagInfo(status)), nil
+}
+
+// OnBootNotification implements the core.CentralSystemHandler interface
+func (cs *CS) OnBootNotification(id string, request *core.BootNotificationRequest) (*core.BootNotificationConfirmation, error) {
+	if _, err := cs.chargePoints(id); err != nil {
+		return nil, err
+	}
+
+	cs.log.DEBUG.Printf("boot notification: %s (%s %s)", id, request.ChargePointVendor, request.ChargePointModel)
+
+	return core.NewBootNotificationConfirmation(types.NewDateTime(time.Now()), int(heartbeatInterval.Seconds()), core.RegistrationStatusAccepted), nil
+}
+
+// OnDataTransfer implements the core.CentralSystemHandler interface
+func (cs *CS) OnDataTransfer(id string, request *core.DataTransferRequest) (*core.DataTransferConfirmation, error) {
+	return core.NewDataTransferConfirmation(core.DataTransferStatusRejected), nil
+}
+
+// OnHeartbeat implements the core.CentralSystemHandler interface
+func (cs *CS) OnHeartbeat(id string, request *core.HeartbeatRequest) (*core.HeartbeatConfirmation, error) {
+	return core.NewHeartbeatConfirmation(types.NewDateTime(time.Now())), nil
+}
+
+// OnMeterValues implements the core.CentralSystemHandler interface
+func (cs *CS) OnMeterValues(id string, request *core.MeterValuesRequest) (*core.MeterValuesConfirmation, error) {
+	cp, err := cs.connectorByID(id, request.ConnectorId)
+	if err != nil {
+		// values of connector 0 refer to the whole charge point
+		cs.log.TRACE.Printf("meter values: %v", err)
+		return core.NewMeterValuesConfirmation(), nil
+	}
+
+	cp.MeterValues(request.MeterValue)
+
+	return core.NewMeterValuesConfirmation(), nil
+}
+
+// OnStatusNotification implements the core.CentralSystemHandler interface
+func (cs *CS) OnStatusNotification(id string, request *core.StatusNotificationRequest) (*core.StatusNotificationConfirmation, error) {
+	cp, err := cs.connectorByID(id, request.ConnectorId)
+	if err != nil {
+		// status of connector 0 refers to the whole charge point
+		cs.log.TRACE.Printf("status notification: %v", err)
+		return core.NewStatusNotificationConfirmation(), nil
+	}
+
+	cp.StatusNotification(request)
+
+	return core.NewStatusNotificationConfirmation(), nil
+}
+
+// OnStartTransaction implements the core.CentralSystemHandler interface. Transactions of id tags not contained
+// in the whitelist are rejected, also if the charge point skipped authorization or started offline.
+func (cs *CS) OnStartTransaction(id string, request *core.StartTransactionRequest) (*core.StartTransactionConfirmation, error) {
+	cp, err := cs.connectorByID(id, request.ConnectorId)
+	if err != nil {
+		return nil, err
+	}
+
+	txnId := cs.nextTransactionID()
+	info := cp.StartTransaction(request, txnId, cs.authorization(request.IdTag))
+
+	return core.NewStartTransactionConfirmation(info, txnId), nil
+}
+
+// OnStopTransaction implements the core.CentralSystemHandler interface
+func (cs *CS) OnStopTransaction(id string, request *core.StopTransactionRequest) (*core.StopTransactionConfirmation, error) {
+	// stop requests don't specify the connector, connectors ignore foreign transactions
+	cps, err := cs.chargePoints(id)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cp := range cps {
+		cp.StopTransaction(request)
+	}
+
+	return core.NewStopTransactionConfirmation(), nil
+}
@@ -0,0 +1,331 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/util"
+	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+var errNotImplemented = errors.New("not implemented")
+
+// ocppSimulator is a minimal OCPP charge point recording charging profile limits
+type ocppSimulator struct {
+	mu    sync.Mutex
+	limit float64
+}
+
+func (s *ocppSimulator) Limit() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+func (s *ocppSimulator) OnChangeAvailability(request *core.ChangeAvailabilityRequest) (*core.ChangeAvailabilityConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnChangeConfiguration(request *core.ChangeConfigurationRequest) (*core.ChangeConfigurationConfirmation, error) {
+	return core.NewChangeConfigurationConfirmation(core.ConfigurationStatusAccepted), nil
+}
+
+func (s *ocppSimulator) OnClearCache(request *core.ClearCacheRequest) (*core.ClearCacheConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnDataTransfer(request *core.DataTransferRequest) (*core.DataTransferConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnGetConfiguration(request *core.GetConfigurationRequest) (*core.GetConfigurationConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (*core.RemoteStartTransactionConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnRemoteStopTransaction(request *core.RemoteStopTransactionRequest) (*core.RemoteStopTransactionConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnReset(request *core.ResetRequest) (*core.ResetConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnUnlockConnector(request *core.UnlockConnectorRequest) (*core.UnlockConnectorConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnSetChargingProfile(request *smartcharging.SetChargingProfileRequest) (*smartcharging.SetChargingProfileConfirmation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.limit = request.ChargingProfile.ChargingSchedule.ChargingSchedulePeriod[0].Limit
+
+	return smartcharging.NewSetChargingProfileConfirmation(smartcharging.ChargingProfileStatusAccepted), nil
+}
+
+func (s *ocppSimulator) OnClearChargingProfile(request *smartcharging.ClearChargingProfileRequest) (*smartcharging.ClearChargingProfileConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func (s *ocppSimulator) OnGetCompositeSchedule(request *smartcharging.GetCompositeScheduleRequest) (*smartcharging.GetCompositeScheduleConfirmation, error) {
+	return nil, errNotImplemented
+}
+
+func freePort(t *testing.T) int {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port
+}
+
+func TestCentralSystem(t *testing.T) {
+	port := freePort(t)
+
+	cs := NewCS(util.NewLogger("ocpp"), rfid.Tokens{{ID: "TAG", User: "alice"}})
+	go cs.Start(port, "/{ws}")
+
+	chargePoint := NewChargePoint(util.NewLogger("sim"), "sim", 1, time.Minute)
+	if err := cs.Register("sim", chargePoint); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cs.Register("sim", chargePoint); err == nil {
+		t.Error("expected duplicate registration error")
+	}
+
+	second := NewChargePoint(util.NewLogger("sim"), "sim", 2, time.Minute)
+	if err := cs.Register("sim", second); err != nil {
+		t.Fatal(err)
+	}
+
+	sim := new(ocppSimulator)
+	cp := ocpp16.NewChargePoint("sim", nil, nil)
+	cp.SetCoreHandler(sim)
+	cp.SetSmartChargingHandler(sim)
+
+	// wait for central system to listen
+	var err error
+	for i := 0; ; i++ {
+		if err = cp.Start(fmt.Sprintf("ws://127.0.0.1:%d", port)); err == nil {
+			break
+		}
+		if i == 50 {
+			t.Fatal(err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	defer cp.Stop()
+
+	select {
+	case <-chargePoint.HasConnected():
+	case <-time.After(5 * time.Second):
+		t.Fatal("charge point not connected")
+	}
+
+	if _, err := cp.BootNotification("sim", "evcc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cp.StatusNotification(1, core.NoError, core.ChargePointStatusCharging); err != nil {
+		t.Fatal(err)
+	}
+
+	// unknown id tags are rejected but identified
+	if res, err := cp.Authorize("unknown"); err != nil || res.IdTagInfo.Status != types.AuthorizationStatusInvalid {
+		t.Errorf("expected unknown tag rejected: %v", err)
+	}
+
+	if id := chargePoint.IdTag(); id != "unknown" {
+		t.Errorf("id tag: %s", id)
+	}
+
+	if res, err := cp.Authorize("tag"); err != nil || res.IdTagInfo.Status != types.AuthorizationStatusAccepted {
+		t.Errorf("expected whitelisted tag accepted: %v", err)
+	}
+
+	sample := func(measurand types.Measurand, phase types.Phase, unit types.UnitOfMeasure, value string) types.SampledValue {
+		return types.SampledValue{Measurand: measurand, Phase: phase, Unit: unit, Value: value}
+	}
+
+	if _, err := cp.MeterValues(1, []types.MeterValue{{
+		Timestamp: types.NewDateTime(time.Now()),
+		SampledValue: []types.SampledValue{
+			sample(types.MeasurandPowerActiveImport, "", types.UnitOfMeasureKW, "3.6"),
+			sample(types.MeasurandEnergyActiveImportRegister, "", types.UnitOfMeasureWh, "1234"),
+			sample(types.MeasurandCurrentImport, types.PhaseL1, types.UnitOfMeasureA, "16"),
+			sample(types.MeasurandCurrentImport, types.PhaseL2N, types.UnitOfMeasureA, "15"),
+		},
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := chargePoint.Status(); err != nil || status != api.StatusC {
+		t.Errorf("status: %v %v", status, err)
+	}
+
+	for _, tc := range []struct {
+		measurand types.Measurand
+		phase     types.Phase
+		value     float64
+	}{
+		{types.MeasurandPowerActiveImport, "", 3600},
+		{types.MeasurandEnergyActiveImportRegister, "", 1234},
+		{types.MeasurandCurrentImport, types.PhaseL1, 16},
+		{types.MeasurandCurrentImport, types.PhaseL2, 15},
+	} {
+		if f, err := chargePoint.Measurement(tc.measurand, tc.phase); err != nil || f != tc.value {
+			t.Errorf("%s %s: expected %v, got %v %v", tc.measurand, tc.phase, tc.value, f, err)
+		}
+	}
+
+	if _, err := chargePoint.Measurement(types.MeasurandCurrentImport, types.PhaseL3); !errors.Is(err, api.ErrNotAvailable) {
+		t.Errorf("expected not available, got %v", err)
+	}
+
+	if id := chargePoint.IdTag(); id != "tag" {
+		t.Errorf("id tag: %s", id)
+	}
+
+	// transactions of unknown id tags are rejected
+	res, err := cp.StartTransaction(1, "unknown", 0, types.NewDateTime(time.Now()))
+	if err != nil || res.IdTagInfo.Status != types.AuthorizationStatusInvalid {
+		t.Errorf("expected unknown tag transaction rejected: %v", err)
+	}
+
+	if _, err := cp.StopTransaction(0, types.NewDateTime(time.Now()), res.TransactionId); err != nil {
+		t.Fatal(err)
+	}
+
+	// transactions
+	res, err = cp.StartTransaction(1, "tag", 0, types.NewDateTime(time.Now()))
+	if err != nil || res.IdTagInfo.Status != types.AuthorizationStatusAccepted {
+		t.Fatalf("expected whitelisted tag transaction accepted: %v", err)
+	}
+
+	if txn := chargePoint.TransactionID(); txn == 0 || txn != res.TransactionId {
+		t.Errorf("transaction: %d", txn)
+	}
+
+	// second connector of the same charge point
+	if res, err := cp.StartTransaction(2, "tag", 0, types.NewDateTime(time.Now())); err != nil || res.TransactionId != second.TransactionID() {
+		t.Errorf("second connector transaction: %d %v", second.TransactionID(), err)
+	}
+
+	if _, err := cp.StopTransaction(1234, types.NewDateTime(time.Now()), res.TransactionId); err != nil {
+		t.Fatal(err)
+	}
+
+	if second.TransactionID() == 0 {
+		t.Error("second connector transaction stopped")
+	}
+
+	if txn := chargePoint.TransactionID(); txn != 0 {
+		t.Errorf("transaction not stopped: %d", txn)
+	}
+
+	// charging profile
+	profile := types.NewChargingProfile(1, 0,
+		types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindAbsolute,
+		types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, 10)),
+	)
+
+	rc := make(chan error, 1)
+	if err := cs.SetChargingProfile("sim", func(resp *smartcharging.SetChargingProfileConfirmation, err error) {
+		rc <- err
+	}, 1, profile); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-rc:
+		if err != nil || sim.Limit() != 10 {
+			t.Errorf("charging profile: limit %v %v", sim.Limit(), err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("charging profile timeout")
+	}
+
+	// status available ends identification
+	if _, err := cp.StatusNotification(1, core.NoError, core.ChargePointStatusAvailable); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := chargePoint.Status(); err != nil || status != api.StatusA || chargePoint.IdTag() != "" {
+		t.Errorf("status: %v %v %s", status, err, chargePoint.IdTag())
+	}
+}
+
+func TestInstancePortInUse(t *testing.T) {
+	l, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	Configure(l.Addr().(*net.TCPAddr).Port, nil)
+
+	if _, err := Instance(); err == nil {
+		t.Error("expected port in use error")
+	}
+}
+
+func TestConnects(t *testing.T) {
+	cp := NewChargePoint(util.NewLogger("sim"), "sim", 1, time.Minute)
+
+	// every connection is signalled
+	for i := 0; i < 2; i++ {
+		cp.connect(true)
+		cp.connect(false)
+
+		select {
+		case <-cp.Connects():
+		default:
+			t.Fatalf("connection %d not signalled", i+1)
+		}
+	}
+
+	select {
+	case <-cp.Connects():
+		t.Error("unexpected connection signal")
+	default:
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	cs := NewCS(util.NewLogger("ocpp"), nil)
+
+	cp := NewChargePoint(util.NewLogger("sim"), "sim", 1, time.Minute)
+	if err := cs.Register("sim", cp); err != nil {
+		t.Fatal(err)
+	}
+
+	// stale instances don't remove their replacement
+	replacement := NewChargePoint(util.NewLogger("sim"), "sim", 1, time.Minute)
+	cs.Unregister("sim", cp)
+
+	if err := cs.Register("sim", replacement); err != nil {
+		t.Fatal(err)
+	}
+
+	cs.Unregister("sim", cp)
+
+	if res, err := cs.connectorByID("sim", 1); err != nil || res != replacement {
+		t.Errorf("expected replacement registered: %v", err)
+	}
+}
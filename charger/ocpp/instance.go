@@ -0,0 +1,62 @@
+package ocpp
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
+)
+
+// Port is the central system's default websocket port. Charge points connect to ws://<evcc>:<port>/<station id>
+const Port = 8887
+
+const heartbeatInterval = time.Minute
+
+var (
+	mu       sync.Mutex
+	port     = Port
+	tokens   rfid.Tokens
+	instance *CS
+)
+
+// Configure sets the central system's port and the whitelist of id tags authorized by the central system.
+// It must be called before the central system is started.
+func Configure(p int, whitelist rfid.Tokens) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if p != 0 {
+		port = p
+	}
+	tokens = whitelist
+}
+
+// Instance returns the central system, starting it on first use
+func Instance() (*CS, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if instance != nil {
+		return instance, nil
+	}
+
+	if err := record.Unrecorded(record.OCPP, fmt.Sprintf(":%d", port)); err != nil {
+		return nil, err
+	}
+
+	// fail early if the port is not available, later errors are logged
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		return nil, fmt.Errorf("central system: %w", err)
+	}
+	_ = ln.Close()
+
+	instance = NewCS(util.NewLogger("ocpp"), tokens)
+	go instance.Start(port, "/{ws}")
+
+	return instance, nil
+}
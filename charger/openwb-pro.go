@@ -65,15 +65,26 @@ func NewOpenWBPro(uri string) (*OpenWBPro, error) {
 		current: 6, // 6A defined value
 	}
 
-	go wb.hearbeat(log)
+	done := make(chan struct{})
+	util.OnRelease(func() { close(done) })
+
+	go wb.hearbeat(log, done)
 
 	return wb, nil
 }
 
-func (wb *OpenWBPro) hearbeat(log *util.Logger) {
-	for range time.NewTicker(30 * time.Second).C {
-		if _, err := wb.get(); err != nil {
-			log.ERROR.Printf("heartbeat: %v", err)
+func (wb *OpenWBPro) hearbeat(log *util.Logger, done <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := wb.get(); err != nil {
+				log.ERROR.Printf("heartbeat: %v", err)
+			}
+		case <-done:
+			return
 		}
 	}
 }
@@ -119,13 +119,24 @@ func NewOpenWB(log *util.Logger, mqttconf mqtt.Config, id int, topic string, p1p
 	}
 
 	// heartbeat
+	done := make(chan struct{})
+	util.OnRelease(func() { close(done) })
+
 	go func() {
 		heartbeatS := provider.NewMqtt(log, client, fmt.Sprintf("%s/set/isss/%s", topic, openwb.SlaveHeartbeatTopic),
 			timeout).WithRetained().IntSetter("heartbeat")
 
-		for range time.NewTicker(openwb.HeartbeatInterval).C {
-			if err := heartbeatS(1); err != nil {
-				log.ERROR.Printf("heartbeat: %v", err)
+		ticker := time.NewTicker(openwb.HeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := heartbeatS(1); err != nil {
+					log.ERROR.Printf("heartbeat: %v", err)
+				}
+			case <-done:
+				return
 			}
 		}
 	}()
@@ -13,6 +13,7 @@ import (
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/charger/tplink"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 )
 
 // TPLink charger implementation
@@ -180,6 +181,10 @@ func (c *TPLink) execCmd(cmd string, res interface{}) error {
 	binary.BigEndian.PutUint32(buf.Bytes(), uint32(buf.Len()-4))
 
 	// open connection via TP-Link Smart Home Protocol
+	if err := record.Unrecorded(record.TCP, c.uri); err != nil {
+		return err
+	}
+
 	conn, err := net.DialTimeout("tcp", c.uri, 5*time.Second)
 	if err != nil {
 		return err
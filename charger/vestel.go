@@ -99,16 +99,27 @@ func NewVestel(uri string, slaveID uint8) (*Vestel, error) {
 		return nil, fmt.Errorf("could not set failsafe timeout: %v", err)
 	}
 
-	go wb.heartbeat()
+	done := make(chan struct{})
+	util.OnRelease(func() { close(done) })
+
+	go wb.heartbeat(done)
 
 	return wb, nil
 }
 
 // heartbeat implements the api.ChargerEx interface
-func (wb *Vestel) heartbeat() {
-	for range time.NewTicker(time.Minute).C {
-		if _, err := wb.conn.WriteSingleRegister(vestelRegAlive, 1); err != nil {
-			wb.log.ERROR.Println("heartbeat:", err)
+func (wb *Vestel) heartbeat(done <-chan struct{}) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := wb.conn.WriteSingleRegister(vestelRegAlive, 1); err != nil {
+				wb.log.ERROR.Println("heartbeat:", err)
+			}
+		case <-done:
+			return
 		}
 	}
 }
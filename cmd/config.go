@@ -3,15 +3,18 @@ package cmd
 import (
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/dustin/go-humanize"
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/charger"
+	"github.com/evcc-io/evcc/core/rfid"
 	"github.com/evcc-io/evcc/meter"
 	"github.com/evcc-io/evcc/provider/mqtt"
 	"github.com/evcc-io/evcc/push"
 	"github.com/evcc-io/evcc/server"
+	"github.com/evcc-io/evcc/server/access"
 	autoauth "github.com/evcc-io/evcc/server/auth"
 	"github.com/evcc-io/evcc/util"
 	"github.com/evcc-io/evcc/vehicle"
@@ -30,20 +33,30 @@ type config struct {
 	Mqtt         mqttConfig
 	Javascript   map[string]interface{}
 	Influx       server.InfluxConfig
+	Database     dbConfig
+	Savings      savingsConfig
+	Runtime      runtimeConfig
 	EEBus        map[string]interface{}
 	HEMS         typedConfig
 	Messaging    messagingConfig
 	Meters       []qualifiedConfig
 	Chargers     []qualifiedConfig
 	Vehicles     []qualifiedConfig
+	Tokens       rfid.Tokens
+	OCPP         ocppConfig
+	Auth         access.Config
+	TLS          server.TLSConfig
 	Tariffs      tariffConfig
+	Forecast     typedConfig
 	Site         map[string]interface{}
 	LoadPoints   []map[string]interface{}
+	Consumers    []map[string]interface{}
 }
 
 type mqttConfig struct {
 	mqtt.Config `mapstructure:",squash"`
 	Topic       string
+	Discovery   string // Home Assistant discovery prefix
 }
 
 func (conf *mqttConfig) RootTopic() string {
@@ -53,6 +66,26 @@ func (conf *mqttConfig) RootTopic() string {
 	return "evcc"
 }
 
+type dbConfig struct {
+	Type string
+	Dsn  string
+}
+
+type savingsConfig struct {
+	Store    string
+	File     string
+	Interval time.Duration
+}
+
+type runtimeConfig struct {
+	Store string
+	File  string
+}
+
+type ocppConfig struct {
+	Port int
+}
+
 type qualifiedConfig struct {
 	Name, Type string
 	Other      map[string]interface{} `mapstructure:",remain"`
@@ -76,9 +109,12 @@ type tariffConfig struct {
 
 // ConfigProvider provides configuration items
 type ConfigProvider struct {
+	mu       sync.RWMutex
 	meters   map[string]api.Meter
 	chargers map[string]api.Charger
 	vehicles map[string]api.Vehicle
+	releases map[string]func() // release device resources by "<class>/<name>"
+	tokens   rfid.Tokens
 	visited  map[string]bool
 	auth     *util.AuthCollection
 }
@@ -89,6 +125,9 @@ func (cp *ConfigProvider) TrackVisitors() {
 
 // Meter provides meters by name
 func (cp *ConfigProvider) Meter(name string) api.Meter {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
 	if meter, ok := cp.meters[name]; ok {
 		// track duplicate usage https://github.com/evcc-io/evcc/issues/1744
 		if cp.visited != nil {
@@ -106,6 +145,9 @@ func (cp *ConfigProvider) Meter(name string) api.Meter {
 
 // Charger provides chargers by name
 func (cp *ConfigProvider) Charger(name string) api.Charger {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
 	if charger, ok := cp.chargers[name]; ok {
 		return charger
 	}
@@ -115,6 +157,9 @@ func (cp *ConfigProvider) Charger(name string) api.Charger {
 
 // Vehicle provides vehicles by name
 func (cp *ConfigProvider) Vehicle(name string) api.Vehicle {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
 	if vehicle, ok := cp.vehicles[name]; ok {
 		return vehicle
 	}
@@ -122,6 +167,106 @@ func (cp *ConfigProvider) Vehicle(name string) api.Vehicle {
 	return nil
 }
 
+// Meters provides all meters by name
+func (cp *ConfigProvider) Meters() map[string]api.Meter {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	res := make(map[string]api.Meter, len(cp.meters))
+	for k, v := range cp.meters {
+		res[k] = v
+	}
+	return res
+}
+
+// Chargers provides all chargers by name
+func (cp *ConfigProvider) Chargers() map[string]api.Charger {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	res := make(map[string]api.Charger, len(cp.chargers))
+	for k, v := range cp.chargers {
+		res[k] = v
+	}
+	return res
+}
+
+// Vehicles provides all vehicles by name
+func (cp *ConfigProvider) Vehicles() map[string]api.Vehicle {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	res := make(map[string]api.Vehicle, len(cp.vehicles))
+	for k, v := range cp.vehicles {
+		res[k] = v
+	}
+	return res
+}
+
+// setRelease releases the resources of the device's previous instance and stores the current's
+func (cp *ConfigProvider) setRelease(id string, release func()) {
+	if cp.releases == nil {
+		cp.releases = make(map[string]func())
+	}
+
+	if prev, ok := cp.releases[id]; ok {
+		prev()
+	}
+
+	if release == nil {
+		delete(cp.releases, id)
+		return
+	}
+	cp.releases[id] = release
+}
+
+// SetMeter adds or replaces the named meter, releasing the previous instance. A nil meter removes it.
+func (cp *ConfigProvider) SetMeter(name string, meter api.Meter, release func()) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.setRelease("meter/"+name, release)
+
+	if meter == nil {
+		delete(cp.meters, name)
+		return
+	}
+	cp.meters[name] = meter
+}
+
+// SetCharger adds or replaces the named charger, releasing the previous instance. A nil charger removes it.
+func (cp *ConfigProvider) SetCharger(name string, charger api.Charger, release func()) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.setRelease("charger/"+name, release)
+
+	if charger == nil {
+		delete(cp.chargers, name)
+		return
+	}
+	cp.chargers[name] = charger
+}
+
+// SetVehicle adds or replaces the named vehicle, releasing the previous instance. A nil vehicle removes it.
+func (cp *ConfigProvider) SetVehicle(name string, vehicle api.Vehicle, release func()) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	cp.setRelease("vehicle/"+name, release)
+
+	if vehicle == nil {
+		delete(cp.vehicles, name)
+		return
+	}
+	cp.vehicles[name] = vehicle
+}
+
+// Tokens provides the RFID token whitelist
+func (cp *ConfigProvider) Tokens() rfid.Tokens {
+	return cp.tokens
+}
+
 func (cp *ConfigProvider) configure(conf config) error {
 	err := cp.configureMeters(conf)
 	if err == nil {
@@ -130,9 +275,30 @@ func (cp *ConfigProvider) configure(conf config) error {
 	if err == nil {
 		err = cp.configureVehicles(conf)
 	}
+	if err == nil {
+		err = cp.configureTokens(conf)
+	}
 	return err
 }
 
+func (cp *ConfigProvider) configureTokens(conf config) error {
+	if err := conf.Tokens.Validate(); err != nil {
+		return fmt.Errorf("cannot create tokens: %w", err)
+	}
+
+	for _, token := range conf.Tokens {
+		if token.Vehicle != "" {
+			if _, ok := cp.vehicles[token.Vehicle]; !ok {
+				return fmt.Errorf("cannot create token %s: invalid vehicle: %s", token.ID, token.Vehicle)
+			}
+		}
+	}
+
+	cp.tokens = conf.Tokens
+
+	return nil
+}
+
 func (cp *ConfigProvider) configureMeters(conf config) error {
 	cp.meters = make(map[string]api.Meter)
 	for id, cc := range conf.Meters {
@@ -140,7 +306,11 @@ func (cp *ConfigProvider) configureMeters(conf config) error {
 			return fmt.Errorf("cannot create %s meter: missing name", humanize.Ordinal(id+1))
 		}
 
-		m, err := meter.NewFromConfig(cc.Type, cc.Other)
+		var m api.Meter
+		release, err := util.CaptureReleases(func() (err error) {
+			m, err = meter.NewFromConfig(cc.Type, cc.Other)
+			return err
+		})
 		if err != nil {
 			err = fmt.Errorf("cannot create meter '%s': %w", cc.Name, err)
 			return err
@@ -151,6 +321,7 @@ func (cp *ConfigProvider) configureMeters(conf config) error {
 		}
 
 		cp.meters[cc.Name] = m
+		cp.setRelease("meter/"+cc.Name, release)
 	}
 
 	return nil
@@ -163,7 +334,11 @@ func (cp *ConfigProvider) configureChargers(conf config) error {
 			return fmt.Errorf("cannot create %s charger: missing name", humanize.Ordinal(id+1))
 		}
 
-		c, err := charger.NewFromConfig(cc.Type, cc.Other)
+		var c api.Charger
+		release, err := util.CaptureReleases(func() (err error) {
+			c, err = charger.NewFromConfig(cc.Type, cc.Other)
+			return err
+		})
 		if err != nil {
 			err = fmt.Errorf("cannot create charger '%s': %w", cc.Name, err)
 			return err
@@ -174,6 +349,7 @@ func (cp *ConfigProvider) configureChargers(conf config) error {
 		}
 
 		cp.chargers[cc.Name] = c
+		cp.setRelease("charger/"+cc.Name, release)
 	}
 
 	return nil
@@ -186,7 +362,11 @@ func (cp *ConfigProvider) configureVehicles(conf config) error {
 			return fmt.Errorf("cannot create %s vehicle: missing name", humanize.Ordinal(id+1))
 		}
 
-		v, err := vehicle.NewFromConfig(cc.Type, cc.Other)
+		var v api.Vehicle
+		release, err := util.CaptureReleases(func() (err error) {
+			v, err = vehicle.NewFromConfig(cc.Type, cc.Other)
+			return err
+		})
 		if err != nil {
 			// wrap any created errors to prevent fatals
 			v, _ = wrapper.New(v, err)
@@ -197,6 +377,7 @@ func (cp *ConfigProvider) configureVehicles(conf config) error {
 		}
 
 		cp.vehicles[cc.Name] = v
+		cp.setRelease("vehicle/"+cc.Name, release)
 	}
 
 	return nil
@@ -219,7 +400,7 @@ func (cp *ConfigProvider) webControl(httpd *server.HTTPd, paramC chan<- util.Par
 	cp.auth = util.NewAuthCollection(paramC)
 
 	// TODO make evccURI configurable, add warnings for any network/ localhost
-	evccURI := fmt.Sprintf("http://%s", httpd.Addr)
+	evccURI := fmt.Sprintf("%s://%s", httpd.Scheme(), httpd.Addr)
 	authURI := fmt.Sprintf("%s/oauth", evccURI)
 
 	var id int
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/evcc-io/evcc/cmd/configfile"
+	"github.com/evcc-io/evcc/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// configMigrateCmd represents the config migrate command
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate [file]",
+	Short: "Rewrite deprecated configuration",
+	Long: `Migrate rewrites deprecated configuration:
+
+  - provider type: is renamed to source:
+  - loadpoint meters: charge: is replaced by meter:
+  - loadpoint onIdentify: is moved to the loadpoint's vehicles
+
+The original file is kept as backup with .bak extension.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runConfigMigrate,
+}
+
+func init() {
+	configCmd.AddCommand(configMigrateCmd)
+	configMigrateCmd.Flags().Bool("dry-run", false, "Print migrated configuration instead of writing it")
+}
+
+func runConfigMigrate(cmd *cobra.Command, args []string) {
+	util.LogLevel(viper.GetString("log"), viper.GetStringMapString("levels"))
+
+	file, err := configFileArg(args)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	fi, err := os.Stat(file)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	res, changes, err := configfile.Migrate(file, b)
+	if err != nil {
+		log.FATAL.Fatalf("failed migrating %s: %v", file, err)
+	}
+
+	for _, c := range changes {
+		fmt.Fprintln(os.Stderr, c)
+	}
+
+	if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+		fmt.Print(string(res))
+		return
+	}
+
+	if string(res) == string(b) {
+		fmt.Fprintf(os.Stderr, "%s: nothing to migrate\n", file)
+		return
+	}
+
+	if err := os.WriteFile(file+".bak", b, fi.Mode()); err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	if err := os.WriteFile(file, res, fi.Mode()); err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	fmt.Fprintf(os.Stderr, "%s: migrated, original saved as %s.bak\n", file, file)
+}
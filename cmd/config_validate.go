@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/evcc-io/evcc/charger"
+	"github.com/evcc-io/evcc/cmd/configfile"
+	"github.com/evcc-io/evcc/meter"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
+	"github.com/evcc-io/evcc/vehicle"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// ConfigSchema is the configuration's json schema, provided by the main package
+var ConfigSchema []byte
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Validate and migrate the configuration file",
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate [file]",
+	Short: "Validate configuration file without connecting to devices",
+	Long: `Validate checks the configuration file against the configuration schema, creates all
+meters, chargers and vehicles with device traffic disabled and checks loadpoints and
+device references. All problems are reported with file and line.`,
+	Args: cobra.MaximumNArgs(1),
+	Run:  runConfigValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	configCmd.AddCommand(configValidateCmd)
+	configValidateCmd.Flags().Duration("timeout", 10*time.Second, "Maximum duration of device creation")
+}
+
+// configFileArg returns the configuration file from the arguments or the --config flag
+func configFileArg(args []string) (string, error) {
+	if len(args) > 0 {
+		return args[0], nil
+	}
+
+	if cfgFile == "" {
+		return "", errors.New("missing evcc config")
+	}
+
+	return cfgFile, nil
+}
+
+func runConfigValidate(cmd *cobra.Command, args []string) {
+	util.LogLevel(viper.GetString("log"), viper.GetStringMapString("levels"))
+
+	file, err := configFileArg(args)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	schema, err := configfile.NewSchema(ConfigSchema)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	// don't touch any hardware
+	if err := record.Offline(); err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	v := configfile.Validator{
+		Schema: schema,
+		Config: func() interface{} { return new(config) },
+		Factories: map[string]configfile.Factory{
+			"meters": func(typ string, other map[string]interface{}) (interface{}, error) {
+				return meter.NewFromConfig(typ, other)
+			},
+			"chargers": func(typ string, other map[string]interface{}) (interface{}, error) {
+				return charger.NewFromConfig(typ, other)
+			},
+			"vehicles": func(typ string, other map[string]interface{}) (interface{}, error) {
+				return vehicle.NewFromConfig(typ, other)
+			},
+		},
+		Timeout: timeout,
+	}
+
+	problems := v.Validate(file, b)
+	for _, p := range problems {
+		fmt.Println(p)
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%d problem(s) found\n", len(problems))
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s: ok\n", file)
+}
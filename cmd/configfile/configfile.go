@@ -0,0 +1,228 @@
+// Package configfile validates and migrates yaml configuration files without creating devices
+package configfile
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a configuration problem at a position of the configuration file
+type Problem struct {
+	File string
+	Line int
+	Path string // configuration path, e.g. loadpoints[0].mode
+	Msg  string
+}
+
+func (p Problem) String() string {
+	var pos string
+	if p.Line > 0 {
+		pos = fmt.Sprintf("%s:%d", p.File, p.Line)
+	} else {
+		pos = p.File
+	}
+
+	if p.Path == "" {
+		return fmt.Sprintf("%s: %s", pos, p.Msg)
+	}
+
+	return fmt.Sprintf("%s: %s: %s", pos, p.Path, p.Msg)
+}
+
+// problems collects problems of a single file
+type problems struct {
+	file string
+	list []Problem
+}
+
+func (p *problems) add(node *yaml.Node, path string, format string, a ...interface{}) {
+	var line int
+	if node != nil {
+		line = node.Line
+	}
+
+	p.list = append(p.list, Problem{File: p.file, Line: line, Path: path, Msg: fmt.Sprintf(format, a...)})
+}
+
+// sorted returns the problems in file order. Problems reported more than once for the same position are dropped.
+func (p *problems) sorted() []Problem {
+	sort.SliceStable(p.list, func(i, j int) bool {
+		return p.list[i].Line < p.list[j].Line
+	})
+
+	res := make([]Problem, 0, len(p.list))
+	seen := make(map[string]bool)
+
+	for _, pr := range p.list {
+		key := fmt.Sprintf("%d %s", pr.Line, strings.ToLower(pr.Path))
+		if !seen[key] {
+			res = append(res, pr)
+		}
+		seen[key] = true
+	}
+
+	return res
+}
+
+var yamlLine = regexp.MustCompile(`line (\d+)`)
+
+// parse parses the yaml document. Syntax errors are returned as problem.
+func parse(file string, b []byte) (*yaml.Node, *Problem) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		var line int
+		if m := yamlLine.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+
+		return nil, &Problem{File: file, Line: line, Msg: err.Error()}
+	}
+
+	// empty document
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.MappingNode}, nil
+	}
+
+	return resolve(doc.Content[0]), nil
+}
+
+// resolve resolves aliases
+func resolve(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// lookup returns the key and value nodes of the mapping's key. Keys are matched case-insensitively like mapstructure does.
+func lookup(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node = resolve(node); node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			return node.Content[i], resolve(node.Content[i+1])
+		}
+	}
+
+	return nil, nil
+}
+
+// value returns the value node of the mapping's key
+func value(node *yaml.Node, key string) *yaml.Node {
+	_, v := lookup(node, key)
+	return v
+}
+
+// items returns the sequence's items
+func items(node *yaml.Node) []*yaml.Node {
+	if node = resolve(node); node == nil || node.Kind != yaml.SequenceNode {
+		return nil
+	}
+
+	res := make([]*yaml.Node, 0, len(node.Content))
+	for _, n := range node.Content {
+		res = append(res, resolve(n))
+	}
+
+	return res
+}
+
+// scalar returns the node's scalar value
+func scalar(node *yaml.Node) string {
+	if node = resolve(node); node == nil || node.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return node.Value
+}
+
+// remove removes the key from the mapping
+func remove(node *yaml.Node, key string) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if strings.EqualFold(node.Content[i].Value, key) {
+			node.Content = append(node.Content[:i], node.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// providerConfigs calls fn for all nested mappings below the device, site or loadpoint node that may hold provider configurations
+func providerConfigs(node *yaml.Node, path string, fn func(node *yaml.Node, path string)) {
+	node = resolve(node)
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			child := resolve(node.Content[i+1])
+			childPath := path + "." + node.Content[i].Value
+
+			if child != nil && child.Kind == yaml.MappingNode {
+				fn(child, childPath)
+			}
+
+			providerConfigs(child, childPath, fn)
+		}
+
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			child = resolve(child)
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+
+			if child.Kind == yaml.MappingNode {
+				fn(child, childPath)
+			}
+
+			providerConfigs(child, childPath, fn)
+		}
+	}
+}
+
+// deprecatedProviderType returns the key node and plugin type if the mapping is a provider configuration using the deprecated type attribute
+func deprecatedProviderType(node *yaml.Node, types []string) (*yaml.Node, string) {
+	if k, _ := lookup(node, "source"); k != nil {
+		return nil, ""
+	}
+
+	k, v := lookup(node, "type")
+	if k == nil {
+		return nil, ""
+	}
+
+	typ := strings.ToLower(scalar(v))
+	for _, t := range types {
+		if typ == t {
+			return k, typ
+		}
+	}
+
+	return nil, ""
+}
+
+// sections are the top-level sections whose nested mappings may hold provider configurations
+var sections = []string{"meters", "chargers", "vehicles", "consumers", "site", "loadpoints"}
+
+// sectionItems calls fn for each item of the section. The site section is its only item.
+func sectionItems(doc *yaml.Node, section string, fn func(node *yaml.Node, path string)) {
+	node := value(doc, section)
+	if node == nil {
+		return
+	}
+
+	if node.Kind == yaml.MappingNode {
+		fn(node, section)
+		return
+	}
+
+	for i, item := range items(node) {
+		fn(item, fmt.Sprintf("%s[%d]", section, i))
+	}
+}
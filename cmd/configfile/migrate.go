@@ -0,0 +1,181 @@
+package configfile
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/evcc-io/evcc/provider"
+	"gopkg.in/yaml.v3"
+)
+
+// Migrate rewrites deprecated configuration. It returns the migrated document and the applied changes.
+// Changes that cannot be migrated automatically are returned as problems and left untouched.
+func Migrate(file string, b []byte) ([]byte, []Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	if len(doc.Content) == 0 {
+		return b, nil, nil
+	}
+
+	root := resolve(doc.Content[0])
+	p := &problems{file: file}
+
+	changed := migrateProviderTypes(p, root)
+	changed = migrateChargeMeters(p, root) || changed
+	changed = migrateOnIdentify(p, root) || changed
+
+	if !changed {
+		return b, p.sorted(), nil
+	}
+
+	var buf bytes.Buffer
+
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), p.sorted(), enc.Close()
+}
+
+// migrateProviderTypes renames the providers' deprecated type attribute to source
+func migrateProviderTypes(p *problems, doc *yaml.Node) (changed bool) {
+	types := provider.Types()
+
+	for _, section := range sections {
+		sectionItems(doc, section, func(item *yaml.Node, path string) {
+			providerConfigs(item, path, func(node *yaml.Node, path string) {
+				if k, typ := deprecatedProviderType(node, types); k != nil {
+					p.add(k, join(path, k.Value), "type: %s migrated to source: %s", typ, typ)
+					k.Value = "source"
+					changed = true
+				}
+			})
+		})
+	}
+
+	return changed
+}
+
+// migrateChargeMeters replaces the loadpoints' deprecated meters.charge with meter
+func migrateChargeMeters(p *problems, doc *yaml.Node) (changed bool) {
+	for i, item := range items(value(doc, "loadpoints")) {
+		path := fmt.Sprintf("loadpoints[%d]", i)
+
+		mk, meters := lookup(item, "meters")
+		ck, charge := lookup(meters, "charge")
+		if ck == nil {
+			continue
+		}
+
+		if k, _ := lookup(item, "meter"); k != nil {
+			p.add(ck, join(path, "meters.charge"), "cannot migrate, must not have meter and meters.charge both")
+			continue
+		}
+
+		p.add(ck, join(path, "meters.charge"), "migrated to meter: %s", charge.Value)
+
+		remove(meters, "charge")
+		changed = true
+
+		// replace meters by meter if empty
+		if len(meters.Content) == 0 {
+			mk.Value = "meter"
+			for j := 0; j+1 < len(item.Content); j += 2 {
+				if item.Content[j] == mk {
+					item.Content[j+1] = charge
+				}
+			}
+			continue
+		}
+
+		item.Content = append(item.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "meter"}, charge)
+	}
+
+	return changed
+}
+
+// migrateOnIdentify moves the loadpoints' deprecated onIdentify to the loadpoints' vehicles.
+// Without vehicles assigned to the loadpoint, a single loadpoint's onIdentify applies to all vehicles.
+// Vehicle onIdentify settings take precedence.
+func migrateOnIdentify(p *problems, doc *yaml.Node) (changed bool) {
+	lps := items(value(doc, "loadpoints"))
+
+	vehicles := make(map[string]*yaml.Node)
+	var names []string
+
+	for _, item := range items(value(doc, "vehicles")) {
+		if name := scalar(value(item, "name")); name != "" {
+			vehicles[name] = item
+			names = append(names, name)
+		}
+	}
+
+	for i, item := range lps {
+		path := fmt.Sprintf("loadpoints[%d]", i)
+
+		k, onIdentify := lookup(item, "onIdentify")
+		if k == nil {
+			continue
+		}
+
+		var refs []string
+		if name := scalar(value(item, "vehicle")); name != "" {
+			refs = append(refs, name)
+		}
+		for _, node := range items(value(item, "vehicles")) {
+			refs = append(refs, scalar(node))
+		}
+
+		if len(refs) == 0 && len(lps) == 1 {
+			refs = names
+		}
+
+		if len(refs) == 0 || onIdentify == nil || onIdentify.Kind != yaml.MappingNode {
+			p.add(k, join(path, k.Value), "cannot migrate, assign vehicles to the loadpoint and move onIdentify to the vehicles")
+			continue
+		}
+
+		var missing bool
+		for _, ref := range refs {
+			if vehicles[ref] == nil {
+				p.add(k, join(path, k.Value), "cannot migrate, undefined vehicle %s", ref)
+				missing = true
+			}
+		}
+
+		if missing {
+			continue
+		}
+
+		for _, ref := range refs {
+			vehicle := vehicles[ref]
+
+			_, target := lookup(vehicle, "onIdentify")
+			if target == nil || target.Kind != yaml.MappingNode {
+				target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+				remove(vehicle, "onIdentify")
+				vehicle.Content = append(vehicle.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "onIdentify"}, target)
+			}
+
+			for j := 0; j+1 < len(onIdentify.Content); j += 2 {
+				if sk, _ := lookup(target, onIdentify.Content[j].Value); sk == nil {
+					target.Content = append(target.Content, onIdentify.Content[j], onIdentify.Content[j+1])
+				}
+			}
+		}
+
+		p.add(k, join(path, k.Value), "migrated to onIdentify of vehicle %s", strings.Join(refs, ", "))
+
+		remove(item, "onIdentify")
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,103 @@
+package configfile
+
+import (
+	"strings"
+	"testing"
+)
+
+const deprecated = `meters:
+- name: grid
+  type: custom
+  power:
+    type: mqtt # grid power
+    topic: grid/power
+  currents:
+  - type: calc
+    add:
+    - type: const
+      value: 1
+vehicles:
+- name: ev
+  type: tesla
+  onIdentify:
+    targetSoC: 90
+loadpoints:
+- charger: wallbox
+  meters:
+    charge: charge
+  onIdentify:
+    mode: pv
+    targetSoC: 80
+`
+
+const migrated = `meters:
+  - name: grid
+    type: custom
+    power:
+      source: mqtt # grid power
+      topic: grid/power
+    currents:
+      - source: calc
+        add:
+          - type: const
+            value: 1
+vehicles:
+  - name: ev
+    type: tesla
+    onIdentify:
+      targetSoC: 90
+      mode: pv
+loadpoints:
+  - charger: wallbox
+    meter: charge
+`
+
+func TestMigrate(t *testing.T) {
+	res, changes, err := Migrate("evcc.yaml", []byte(deprecated))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(res) != migrated {
+		t.Errorf("expected\n%s\ngot\n%s", migrated, res)
+	}
+
+	var msgs []string
+	for _, c := range changes {
+		msgs = append(msgs, c.String())
+	}
+
+	expect := []string{
+		"evcc.yaml:5: meters[0].power.type: type: mqtt migrated to source: mqtt",
+		"evcc.yaml:8: meters[0].currents[0].type: type: calc migrated to source: calc",
+		"evcc.yaml:20: loadpoints[0].meters.charge: migrated to meter: charge",
+		"evcc.yaml:21: loadpoints[0].onIdentify: migrated to onIdentify of vehicle ev",
+	}
+
+	if strings.Join(msgs, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(msgs, "\n"))
+	}
+
+	// idempotent
+	if res2, changes, err := Migrate("evcc.yaml", res); err != nil || len(changes) != 0 || string(res2) != string(res) {
+		t.Errorf("expected no changes, got %v %v", changes, err)
+	}
+}
+
+func TestMigrateUnassigned(t *testing.T) {
+	conf := `loadpoints:
+- charger: a
+  onIdentify:
+    mode: pv
+- charger: b
+`
+
+	res, changes, err := Migrate("evcc.yaml", []byte(conf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(res) != conf || len(changes) != 1 || !strings.Contains(changes[0].Msg, "cannot migrate") {
+		t.Errorf("expected unchanged config, got %v\n%s", changes, res)
+	}
+}
@@ -0,0 +1,245 @@
+package configfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema validates yaml documents against the subset of json schema used by evcc's schema.json:
+// type, properties, additionalProperties, required, enum, items, minItems, uniqueItems, pattern and local $ref.
+// Like the configuration decoder, keys are matched case-insensitively, scalars are weakly typed and empty values are valid.
+type Schema struct {
+	root map[string]interface{}
+}
+
+// NewSchema parses the json schema
+func NewSchema(b []byte) (*Schema, error) {
+	var root map[string]interface{}
+	if err := json.Unmarshal(b, &root); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+
+	return &Schema{root: root}, nil
+}
+
+// Validate validates the document's root node
+func (s *Schema) Validate(file string, node *yaml.Node) []Problem {
+	p := &problems{file: file}
+	s.validate(p, node, s.root, "")
+	return p.sorted()
+}
+
+// ref resolves a local reference like #/definitions/duration
+func (s *Schema) ref(ref string) (map[string]interface{}, error) {
+	var res interface{} = s.root
+
+	for _, seg := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		m, ok := res.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid schema reference: %s", ref)
+		}
+
+		if res, ok = m[seg]; !ok {
+			return nil, fmt.Errorf("invalid schema reference: %s", ref)
+		}
+	}
+
+	m, ok := res.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid schema reference: %s", ref)
+	}
+
+	return m, nil
+}
+
+func join(path, key string) string {
+	if path == "" || key == "" {
+		return path + key
+	}
+	return path + "." + key
+}
+
+func (s *Schema) validate(p *problems, node *yaml.Node, schema map[string]interface{}, path string) {
+	// empty values are decoded as zero values
+	if node = resolve(node); node == nil || node.Tag == "!!null" {
+		return
+	}
+
+	if ref, ok := schema["$ref"].(string); ok {
+		res, err := s.ref(ref)
+		if err != nil {
+			p.add(node, path, "%v", err)
+			return
+		}
+
+		s.validate(p, node, res, path)
+	}
+
+	if typ, ok := schema["type"].(string); ok && !hasType(node, typ) {
+		p.add(node, path, "expected %s, got %s", typ, kind(node))
+		return
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		var found bool
+		for _, e := range enum {
+			if node.Kind == yaml.ScalarNode && strings.EqualFold(node.Value, fmt.Sprint(e)) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			vals := make([]string, 0, len(enum))
+			for _, e := range enum {
+				vals = append(vals, fmt.Sprint(e))
+			}
+			p.add(node, path, "invalid value %q, expected one of %s", node.Value, strings.Join(vals, ", "))
+		}
+	}
+
+	if pattern, ok := schema["pattern"].(string); ok && node.Kind == yaml.ScalarNode {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			p.add(node, path, "invalid schema pattern: %v", err)
+		} else if !re.MatchString(node.Value) {
+			p.add(node, path, "invalid value %q, must match %s", node.Value, pattern)
+		}
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		s.validateMapping(p, node, schema, path)
+	case yaml.SequenceNode:
+		s.validateSequence(p, node, schema, path)
+	}
+}
+
+func (s *Schema) validateMapping(p *problems, node *yaml.Node, schema map[string]interface{}, path string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			if k, _ := lookup(node, fmt.Sprint(r)); k == nil {
+				p.add(node, path, "missing %s", r)
+			}
+		}
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, val := node.Content[i], node.Content[i+1]
+
+		// merge keys
+		if key.Value == "<<" {
+			continue
+		}
+
+		var prop interface{}
+		for name, ps := range props {
+			if strings.EqualFold(name, key.Value) {
+				prop = ps
+				break
+			}
+		}
+
+		if prop == nil {
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					p.add(key, join(path, key.Value), "unknown key")
+				}
+				continue
+			case map[string]interface{}:
+				prop = additional
+			default:
+				continue
+			}
+		}
+
+		if ps, ok := prop.(map[string]interface{}); ok {
+			s.validate(p, val, ps, join(path, key.Value))
+		}
+	}
+}
+
+func (s *Schema) validateSequence(p *problems, node *yaml.Node, schema map[string]interface{}, path string) {
+	if min, ok := schema["minItems"].(float64); ok && len(node.Content) < int(min) {
+		p.add(node, path, "expected at least %d items", int(min))
+	}
+
+	if unique, ok := schema["uniqueItems"].(bool); ok && unique {
+		seen := make(map[string]bool)
+		for _, item := range node.Content {
+			if item = resolve(item); item.Kind != yaml.ScalarNode {
+				continue
+			}
+
+			if seen[item.Value] {
+				p.add(item, path, "duplicate item %q", item.Value)
+			}
+			seen[item.Value] = true
+		}
+	}
+
+	if itemSchema, ok := schema["items"].(map[string]interface{}); ok {
+		for i, item := range node.Content {
+			s.validate(p, item, itemSchema, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+}
+
+// hasType checks the node's json schema type. Scalars are weakly typed like the configuration decoder.
+func hasType(node *yaml.Node, typ string) bool {
+	switch typ {
+	case "object":
+		return node.Kind == yaml.MappingNode
+	case "array":
+		return node.Kind == yaml.SequenceNode
+	}
+
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	switch typ {
+	case "string":
+		return true
+	case "integer":
+		_, err := strconv.ParseInt(node.Value, 0, 64)
+		return err == nil
+	case "number":
+		_, err := strconv.ParseFloat(node.Value, 64)
+		return err == nil
+	case "boolean":
+		_, err := strconv.ParseBool(node.Value)
+		return err == nil
+	}
+
+	return true
+}
+
+// kind describes the node's type for error messages
+func kind(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	}
+
+	switch node.Tag {
+	case "!!int":
+		return "integer"
+	case "!!float":
+		return "number"
+	case "!!bool":
+		return "boolean"
+	}
+
+	return "string"
+}
@@ -0,0 +1,371 @@
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evcc-io/evcc/core"
+	"github.com/evcc-io/evcc/provider"
+	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
+	"github.com/mitchellh/mapstructure"
+	"gopkg.in/yaml.v3"
+)
+
+// Factory creates a device from its type and configuration
+type Factory func(typ string, other map[string]interface{}) (interface{}, error)
+
+// Validator validates configuration files
+type Validator struct {
+	Schema    *Schema            // optional json schema
+	Config    func() interface{} // optional decode target of the whole configuration
+	Factories map[string]Factory // device factories by section, i.e. meters, chargers and vehicles
+	Timeout   time.Duration      // maximum duration of device creation
+}
+
+// Validate reports all problems of the configuration file.
+// Devices are expected to be created offline, see record.Offline and record.Unrecorded.
+// Failing device traffic is not considered a problem.
+func (v *Validator) Validate(file string, b []byte) []Problem {
+	doc, perr := parse(file, b)
+	if perr != nil {
+		return []Problem{*perr}
+	}
+
+	p := &problems{file: file}
+
+	if v.Schema != nil {
+		p.list = append(p.list, v.Schema.Validate(file, doc)...)
+	}
+
+	if v.Config != nil {
+		var other map[string]interface{}
+		if err := doc.Decode(&other); err != nil {
+			p.add(doc, "", "%v", err)
+		} else if err := util.DecodeOther(other, v.Config()); err != nil {
+			decodeProblems(p, doc, "", err)
+		}
+	}
+
+	names := make(map[string]map[string]bool)
+	for _, section := range []string{"meters", "chargers", "vehicles"} {
+		names[section] = v.devices(p, doc, section)
+	}
+
+	v.site(p, doc, names["meters"])
+	v.loadpoints(p, doc, names)
+	v.deprecations(p, doc)
+
+	return p.sorted()
+}
+
+var (
+	index     = regexp.MustCompile(`^(.*)\[(\d+)\]$`)
+	fieldName = regexp.MustCompile(`^(error decoding )?'([^']*)'(.*)$`)
+)
+
+// locate returns the node and path of the decoder's field name like soc.poll or plans[0]
+func locate(node *yaml.Node, name string) (*yaml.Node, string) {
+	if name == "" {
+		return node, ""
+	}
+
+	res := node
+	var path string
+
+	for _, seg := range strings.Split(name, ".") {
+		i := -1
+		if m := index.FindStringSubmatch(seg); m != nil {
+			seg = m[1]
+			i, _ = strconv.Atoi(m[2])
+		}
+
+		k, v := lookup(res, seg)
+		if k == nil {
+			return node, name
+		}
+
+		res, path = v, join(path, k.Value)
+
+		if list := items(res); i >= 0 && i < len(list) {
+			res, path = list[i], fmt.Sprintf("%s[%d]", path, i)
+		}
+	}
+
+	return res, path
+}
+
+// decodeProblems reports decode errors at the position of the affected keys
+func decodeProblems(p *problems, node *yaml.Node, path string, err error) {
+	const invalidKeys = "has invalid keys: "
+
+	var merr *mapstructure.Error
+	if !errors.As(err, &merr) {
+		p.add(node, path, "%v", err)
+		return
+	}
+
+	for _, msg := range merr.Errors {
+		// messages contain the quoted field name
+		var name string
+		if m := fieldName.FindStringSubmatch(msg); m != nil {
+			name = m[2]
+			msg = strings.TrimSpace(m[1] + m[3])
+		}
+
+		target, field := locate(node, name)
+		field = join(path, field)
+
+		if strings.HasPrefix(msg, invalidKeys) {
+			for _, key := range strings.Split(strings.TrimPrefix(msg, invalidKeys), ", ") {
+				k, _ := lookup(target, key)
+				if k == nil {
+					k = target
+				} else {
+					key = k.Value
+				}
+				p.add(k, join(field, key), "unknown key")
+			}
+			continue
+		}
+
+		p.add(target, field, "%s", msg)
+	}
+}
+
+// offline checks if the error is caused by creating the device offline. Not all devices wrap errors.
+func offline(err error) bool {
+	return errors.Is(err, record.ErrNotRecorded) || strings.Contains(err.Error(), record.ErrNotRecorded.Error())
+}
+
+// create creates the device. Devices panicking or blocking beyond the timeout are reported.
+func (v *Validator) create(factory Factory, typ string, other map[string]interface{}) error {
+	timeout := v.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+
+	errC := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				errC <- fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		_, err := factory(typ, other)
+		errC <- err
+	}()
+
+	select {
+	case err := <-errC:
+		if err != nil && !offline(err) {
+			return err
+		}
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timeout: not created within %v", timeout)
+	}
+}
+
+// devices validates the section's devices and returns their names
+func (v *Validator) devices(p *problems, doc *yaml.Node, section string) map[string]bool {
+	res := make(map[string]bool)
+
+	for i, item := range items(value(doc, section)) {
+		path := fmt.Sprintf("%s[%d]", section, i)
+
+		var dev struct {
+			Name, Type string
+			Other      map[string]interface{} `mapstructure:",remain"`
+		}
+
+		var other map[string]interface{}
+		if err := item.Decode(&other); err != nil {
+			p.add(item, path, "%v", err)
+			continue
+		}
+
+		if err := util.DecodeOther(other, &dev); err != nil {
+			decodeProblems(p, item, path, err)
+			continue
+		}
+
+		if dev.Name == "" {
+			p.add(item, path, "missing name")
+		} else if res[dev.Name] {
+			p.add(item, path, "duplicate name %s", dev.Name)
+		}
+		res[dev.Name] = true
+
+		factory, ok := v.Factories[section]
+		if !ok || dev.Type == "" {
+			continue
+		}
+
+		if err := v.create(factory, dev.Type, dev.Other); err != nil {
+			p.add(item, join(path, dev.Name), "%v", err)
+		}
+	}
+
+	return res
+}
+
+// site validates the site's meter references
+func (v *Validator) site(p *problems, doc *yaml.Node, meters map[string]bool) {
+	refs := value(value(doc, "site"), "meters")
+
+	for _, key := range []string{"grid", "pv", "battery"} {
+		if node := value(refs, key); node != nil {
+			v.ref(p, node, "site.meters."+key, "meter", meters)
+		}
+	}
+
+	for _, key := range []string{"pvs", "batteries"} {
+		for i, node := range items(value(refs, key)) {
+			v.ref(p, node, fmt.Sprintf("site.meters.%s[%d]", key, i), "meter", meters)
+		}
+	}
+}
+
+// ref validates a device reference
+func (v *Validator) ref(p *problems, node *yaml.Node, path, class string, names map[string]bool) {
+	if name := scalar(node); name != "" && !names[name] {
+		p.add(node, path, "undefined %s %s", class, name)
+	}
+}
+
+// loadpoints validates the loadpoints like core.NewLoadPointFromConfig without creating them
+func (v *Validator) loadpoints(p *problems, doc *yaml.Node, names map[string]map[string]bool) {
+	// meters must not be used more than once
+	used := make(map[string]bool)
+	use := func(node *yaml.Node, path string) {
+		if name := scalar(node); name != "" {
+			if used[name] {
+				p.add(node, path, "duplicate usage of meter %s", name)
+			}
+			used[name] = true
+		}
+	}
+
+	refs := value(value(doc, "site"), "meters")
+	for _, key := range []string{"grid", "pv", "battery"} {
+		use(value(refs, key), "site.meters."+key)
+	}
+	for _, key := range []string{"pvs", "batteries"} {
+		for i, node := range items(value(refs, key)) {
+			use(node, fmt.Sprintf("site.meters.%s[%d]", key, i))
+		}
+	}
+
+	lps := value(doc, "loadpoints")
+	if lps == nil {
+		p.add(doc, "", "missing loadpoints")
+	}
+
+	for i, item := range items(lps) {
+		path := fmt.Sprintf("loadpoints[%d]", i)
+
+		if k, _ := lookup(item, "charger"); k == nil {
+			p.add(item, path, "missing charger")
+		} else {
+			v.ref(p, value(item, "charger"), join(path, "charger"), "charger", names["chargers"])
+		}
+
+		meter, meters := value(item, "meter"), value(value(item, "meters"), "charge")
+		if meter != nil && meters != nil {
+			p.add(meters, join(path, "meters.charge"), "must not have meter and meters.charge both")
+		}
+
+		for _, ref := range []struct {
+			key  string
+			node *yaml.Node
+		}{{"meter", meter}, {"meters.charge", meters}} {
+			if ref.node != nil {
+				v.ref(p, ref.node, join(path, ref.key), "meter", names["meters"])
+				use(ref.node, join(path, ref.key))
+			}
+		}
+
+		if node := value(item, "vehicle"); node != nil {
+			v.ref(p, node, join(path, "vehicle"), "vehicle", names["vehicles"])
+		}
+
+		for j, node := range items(value(item, "vehicles")) {
+			v.ref(p, node, fmt.Sprintf("%s.vehicles[%d]", path, j), "vehicle", names["vehicles"])
+		}
+
+		var other map[string]interface{}
+		if err := item.Decode(&other); err != nil {
+			p.add(item, path, "%v", err)
+			continue
+		}
+
+		lp := core.NewLoadPoint(util.NewLogger(fmt.Sprintf("lp-%d", i+1)))
+		if err := util.DecodeOther(other, lp); err != nil {
+			decodeProblems(p, item, path, err)
+			continue
+		}
+
+		if err := lp.Plans.Validate(); err != nil {
+			p.add(value(item, "plans"), join(path, "plans"), "%v", err)
+		}
+
+		if lp.MinCurrent == 0 {
+			k, _ := lookup(item, "minCurrent")
+			p.add(k, join(path, "minCurrent"), "must not be zero")
+		}
+
+		if lp.MaxCurrent <= lp.MinCurrent {
+			k, _ := lookup(item, "maxCurrent")
+			if k == nil {
+				k, _ = lookup(item, "minCurrent")
+			}
+			p.add(k, join(path, "maxCurrent"), "must be larger than minCurrent")
+		}
+	}
+
+	for i, item := range items(value(doc, "consumers")) {
+		if node := value(item, "meter"); node != nil {
+			path := fmt.Sprintf("consumers[%d].meter", i)
+			v.ref(p, node, path, "meter", names["meters"])
+			use(node, path)
+		}
+	}
+}
+
+// deprecations reports deprecated configuration that can be migrated
+func (v *Validator) deprecations(p *problems, doc *yaml.Node) {
+	types := provider.Types()
+
+	for _, section := range sections {
+		sectionItems(doc, section, func(item *yaml.Node, path string) {
+			providerConfigs(item, path, func(node *yaml.Node, path string) {
+				if k, typ := deprecatedProviderType(node, types); k != nil {
+					p.add(k, join(path, k.Value), "type: %s is deprecated, use source: %s (evcc config migrate)", typ, typ)
+				}
+			})
+		})
+	}
+
+	for i, item := range items(value(doc, "loadpoints")) {
+		path := fmt.Sprintf("loadpoints[%d]", i)
+
+		if k, _ := lookup(value(item, "meters"), "charge"); k != nil {
+			p.add(k, join(path, "meters.charge"), "deprecated, use meter (evcc config migrate)")
+		}
+
+		if k, _ := lookup(item, "onIdentify"); k != nil {
+			p.add(k, join(path, k.Value), "deprecated, use vehicle onIdentify (evcc config migrate)")
+		}
+
+		if k, _ := lookup(item, "onDisconnect"); k != nil {
+			p.add(k, join(path, k.Value), "deprecated, use resetOnDisconnect")
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package configfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util/record"
+)
+
+const invalid = `meters:
+- name: grid
+  type: custom
+  power:
+    type: js
+- name: grid
+  type: offline
+- name: pv
+  type: broken
+- name: battery
+  type: panic
+- name: aux
+  type: blocking
+chargers:
+- name: wallbox
+  type: custom
+site:
+  meters:
+    grid: grid
+    pv: missing
+loadpoints:
+- charger: wallbox
+  meter: grid
+  mode: fast
+  soc:
+    poll:
+      foo: bar
+- charger: other
+  meters:
+    charge: pv
+  minCurrent: 16
+  onIdentify:
+    mode: pv
+`
+
+func TestValidate(t *testing.T) {
+	b, err := os.ReadFile("../../schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := NewSchema(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	factory := func(typ string, other map[string]interface{}) (interface{}, error) {
+		switch typ {
+		case "offline":
+			return nil, fmt.Errorf("cannot connect: %w", record.ErrNotRecorded)
+		case "broken":
+			return nil, errors.New("invalid model")
+		case "panic":
+			panic("nil map")
+		case "blocking":
+			<-done
+			return nil, nil
+		default:
+			return nil, nil
+		}
+	}
+
+	v := Validator{
+		Schema:    schema,
+		Factories: map[string]Factory{"meters": factory, "chargers": factory},
+		Timeout:   10 * time.Millisecond,
+	}
+
+	var res []string
+	for _, p := range v.Validate("evcc.yaml", []byte(invalid)) {
+		res = append(res, p.String())
+	}
+
+	expect := []string{
+		"evcc.yaml:5: meters[0].power.type: type: js is deprecated, use source: js (evcc config migrate)",
+		"evcc.yaml:6: meters[1]: duplicate name grid",
+		"evcc.yaml:8: meters[2].pv: invalid model",
+		"evcc.yaml:10: meters[3].battery: panic: nil map",
+		"evcc.yaml:12: meters[4].aux: timeout: not created within 10ms",
+		"evcc.yaml:20: site.meters.pv: undefined meter missing",
+		"evcc.yaml:23: loadpoints[0].meter: duplicate usage of meter grid",
+		"evcc.yaml:24: loadpoints[0].mode: invalid value \"fast\", expected one of off, now, pv, minpv",
+		"evcc.yaml:27: loadpoints[0].soc.poll.foo: unknown key",
+		"evcc.yaml:28: loadpoints[1].charger: undefined charger other",
+		"evcc.yaml:30: loadpoints[1].meters.charge: deprecated, use meter (evcc config migrate)",
+		"evcc.yaml:31: loadpoints[1].maxCurrent: must be larger than minCurrent",
+		"evcc.yaml:32: loadpoints[1].onIdentify: deprecated, use vehicle onIdentify (evcc config migrate)",
+	}
+
+	if strings.Join(res, "\n") != strings.Join(expect, "\n") {
+		t.Errorf("expected\n%s\ngot\n%s", strings.Join(expect, "\n"), strings.Join(res, "\n"))
+	}
+}
+
+// TestValidateDist validates the sample configuration against the schema
+func TestValidateDist(t *testing.T) {
+	b, err := os.ReadFile("../../schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := NewSchema(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err = os.ReadFile("../../evcc.dist.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, perr := parse("evcc.dist.yaml", b)
+	if perr != nil {
+		t.Fatal(perr)
+	}
+
+	for _, p := range schema.Validate("evcc.dist.yaml", doc) {
+		t.Error(p)
+	}
+}
+
+func TestValidateSyntax(t *testing.T) {
+	res := new(Validator).Validate("evcc.yaml", []byte("site:\n  title: Home\n meters: foo\n"))
+	if len(res) != 1 || res[0].Line != 2 {
+		t.Errorf("expected syntax error in line 2, got %v", res)
+	}
+}
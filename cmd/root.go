@@ -14,11 +14,14 @@ import (
 
 	"github.com/evcc-io/evcc/cmd/shutdown"
 	"github.com/evcc-io/evcc/server"
+	"github.com/evcc-io/evcc/server/apiv2"
 	"github.com/evcc-io/evcc/server/updater"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/metrics"
 	"github.com/evcc-io/evcc/util/pipe"
 	"github.com/evcc-io/evcc/util/sponsor"
 	"github.com/grandcat/zeroconf"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/spf13/cobra"
@@ -29,8 +32,8 @@ var (
 	log     = util.NewLogger("main")
 	cfgFile string
 
-	ignoreErrors = []string{"warn", "error", "fatal"} // don't add to cache
-	ignoreMqtt   = []string{"auth", "releaseNotes"}   // excessive size may crash certain brokers
+	ignoreErrors = []string{"warn", "error", "fatal"}          // don't add to cache
+	ignoreMqtt   = []string{"auth", "releaseNotes", "devices"} // excessive size may crash certain brokers
 )
 
 // rootCmd represents the base command when called without any subcommands
@@ -98,6 +101,20 @@ func init() {
 		"Expose pprof profiles",
 	)
 	bind(rootCmd, "profile")
+
+	rootCmd.PersistentFlags().String(
+		"record",
+		"",
+		"Record redacted device traffic to file",
+	)
+	bind(rootCmd, "record")
+
+	rootCmd.PersistentFlags().String(
+		"replay",
+		"",
+		"Replay device traffic from recording instead of connecting to devices",
+	)
+	bind(rootCmd, "replay")
 }
 
 // initConfig reads in config file and ENV variables if set
@@ -163,6 +180,18 @@ func run(cmd *cobra.Command, args []string) {
 		log.FATAL.Fatal(err)
 	}
 
+	// setup access control
+	acc, err := configureAuth(conf.Auth)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	// setup runtime configuration
+	store, err := configureRuntime(&conf)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
 	// setup loadpoints
 	cp.TrackVisitors() // track duplicate usage
 
@@ -171,6 +200,11 @@ func run(cmd *cobra.Command, args []string) {
 		log.FATAL.Fatal(err)
 	}
 
+	// setup savings persistence
+	if err := configureSavings(conf.Savings, site); err != nil {
+		log.FATAL.Fatal(err)
+	}
+
 	// start broadcasting values
 	tee := &util.Tee{}
 
@@ -185,18 +219,34 @@ func run(cmd *cobra.Command, args []string) {
 
 	// setup mqtt publisher
 	if conf.Mqtt.Broker != "" {
-		publisher := server.NewMQTT(conf.Mqtt.RootTopic())
+		publisher := server.NewMQTT(conf.Mqtt.RootTopic(), acc.MQTTRole(), conf.Mqtt.Discovery)
 		go publisher.Run(site, pipe.NewDropper(ignoreMqtt...).Pipe(tee.Attach()))
+		shutdown.Register(publisher.Shutdown)
 	}
 
 	// create webserver
 	socketHub := server.NewSocketHub()
 	httpd := server.NewHTTPd(uri, site, socketHub, cache)
+	httpd.Router().Use(acc.Handler)
+
+	var configurator apiv2.Configurator
+	if store != nil {
+		configurator = configureRuntimeManager(store, conf, site)
+	}
+	apiv2.Register(httpd.Router(), site, cache, cp, configurator, conf.Auth.Origins)
+
+	// setup https
+	if conf.TLS.Enable {
+		if err := configureTLS(conf.TLS, httpd); err != nil {
+			log.FATAL.Fatal(err)
+		}
+	}
 
 	// announce webserver on mDNS
 	if _, port, err := net.SplitHostPort(uri); err == nil {
 		if portInt, err := strconv.Atoi(port); err == nil {
-			if zc, err := zeroconf.RegisterProxy("evcc Website", "_http._tcp", "local.", portInt, "evcc", nil, []string{}, nil); err == nil {
+			service := fmt.Sprintf("_%s._tcp", httpd.Scheme())
+			if zc, err := zeroconf.RegisterProxy("evcc Website", service, "local.", portInt, "evcc", nil, []string{}, nil); err == nil {
 				shutdown.Register(zc.Shutdown)
 			} else {
 				log.ERROR.Printf("mDNS announcement: %s", err)
@@ -206,6 +256,7 @@ func run(cmd *cobra.Command, args []string) {
 
 	// metrics
 	if viper.GetBool("metrics") {
+		prometheus.MustRegister(metrics.NewCollector(cache))
 		httpd.Router().Handle("/metrics", promhttp.Handler())
 	}
 
@@ -285,5 +336,9 @@ func run(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}()
 
-	log.FATAL.Println(httpd.ListenAndServe())
+	if httpd.TLSConfig != nil {
+		log.FATAL.Println(httpd.ListenAndServeTLS("", ""))
+	} else {
+		log.FATAL.Println(httpd.ListenAndServe())
+	}
 }
@@ -1,26 +1,39 @@
 package cmd
 
 import (
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"math/rand"
+	"path/filepath"
 	"strconv"
+	"strings"
 	"time"
 
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/charger"
+	"github.com/evcc-io/evcc/charger/ocpp"
 	"github.com/evcc-io/evcc/cmd/shutdown"
 	"github.com/evcc-io/evcc/core"
+	"github.com/evcc-io/evcc/core/configstore"
 	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/forecast"
 	"github.com/evcc-io/evcc/hems"
+	"github.com/evcc-io/evcc/meter"
 	"github.com/evcc-io/evcc/provider/javascript"
 	"github.com/evcc-io/evcc/provider/mqtt"
 	"github.com/evcc-io/evcc/push"
 	"github.com/evcc-io/evcc/server"
+	"github.com/evcc-io/evcc/server/access"
+	"github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/tariff"
 	"github.com/evcc-io/evcc/util"
 	"github.com/evcc-io/evcc/util/pipe"
+	"github.com/evcc-io/evcc/util/record"
+	"github.com/evcc-io/evcc/util/selfsigned"
 	"github.com/evcc-io/evcc/util/sponsor"
+	"github.com/evcc-io/evcc/vehicle"
 	"github.com/spf13/viper"
 	"golang.org/x/text/currency"
 )
@@ -45,8 +58,11 @@ func loadConfigFile(cfgFile string) (conf config, err error) {
 }
 
 func configureEnvironment(conf config) (err error) {
+	// setup device traffic recording
+	err = configureRecording(viper.GetString("record"), viper.GetString("replay"))
+
 	// setup sponsorship
-	if conf.SponsorToken != "" {
+	if err == nil && conf.SponsorToken != "" {
 		err = sponsor.ConfigureSponsorship(conf.SponsorToken)
 	}
 
@@ -65,9 +81,50 @@ func configureEnvironment(conf config) (err error) {
 		err = configureEEBus(conf.EEBus)
 	}
 
+	// setup OCPP central system, started on first use
+	if err == nil {
+		ocpp.Configure(conf.OCPP.Port, conf.Tokens)
+	}
+
+	// setup session database
+	if err == nil && conf.Database.Dsn != "" {
+		err = configureDB(conf.Database)
+	}
+
 	return
 }
 
+// setup recording or replay of device traffic
+func configureRecording(recordFile, replayFile string) error {
+	switch {
+	case recordFile != "" && replayFile != "":
+		return errors.New("cannot record and replay at the same time")
+
+	case recordFile != "":
+		if err := record.Record(recordFile); err != nil {
+			return fmt.Errorf("failed configuring recording: %w", err)
+		}
+		log.WARN.Println("recording device traffic to", recordFile)
+
+	case replayFile != "":
+		if err := record.Replay(replayFile); err != nil {
+			return fmt.Errorf("failed configuring replay: %w", err)
+		}
+		log.WARN.Println("replaying device traffic from", replayFile)
+
+	default:
+		return nil
+	}
+
+	shutdown.Register(func() {
+		if err := record.Close(); err != nil {
+			log.ERROR.Println(err)
+		}
+	})
+
+	return nil
+}
+
 // setup influx database
 func configureDatabase(conf server.InfluxConfig, loadPoints []loadpoint.API, in <-chan util.Param) {
 	influx := server.NewInfluxClient(
@@ -91,6 +148,179 @@ func configureDatabase(conf server.InfluxConfig, loadPoints []loadpoint.API, in
 	go influx.Run(loadPoints, in)
 }
 
+// setup session database
+func configureDB(conf dbConfig) error {
+	typ := conf.Type
+	if typ == "" {
+		typ = "sqlite"
+	}
+
+	var err error
+	if db.Instance, err = db.New(typ, conf.Dsn); err != nil {
+		return fmt.Errorf("failed configuring database: %w", err)
+	}
+
+	return nil
+}
+
+// setup savings persistence
+func configureSavings(conf savingsConfig, site *core.Site) error {
+	interval := conf.Interval
+	if interval == 0 {
+		interval = 5 * time.Minute
+	}
+
+	var store core.SavingsStore
+
+	switch strings.ToLower(conf.Store) {
+	case "":
+		return nil
+
+	case "db":
+		if db.Instance == nil {
+			return errors.New("failed configuring savings: database not configured")
+		}
+
+		var err error
+		if store, err = core.NewSavingsDBStore(db.Instance); err != nil {
+			return fmt.Errorf("failed configuring savings: %w", err)
+		}
+
+	case "file":
+		if conf.File == "" {
+			return errors.New("failed configuring savings: missing file")
+		}
+
+		file, err := util.ExpandHome(conf.File)
+		if err != nil {
+			return fmt.Errorf("failed configuring savings: %w", err)
+		}
+
+		store = core.NewSavingsFileStore(file)
+
+	default:
+		return fmt.Errorf("failed configuring savings: invalid store: %s", conf.Store)
+	}
+
+	if err := site.RestoreSavings(store, interval); err != nil {
+		return fmt.Errorf("failed restoring savings: %w", err)
+	}
+
+	shutdown.Register(site.PersistSavings)
+
+	return nil
+}
+
+// setup runtime configuration persistence. Persisted devices and loadpoints take precedence over the config file.
+func configureRuntime(conf *config) (configstore.Store, error) {
+	var store configstore.Store
+
+	switch strings.ToLower(conf.Runtime.Store) {
+	case "":
+		return nil, nil
+
+	case "db":
+		if db.Instance == nil {
+			return nil, errors.New("failed configuring runtime: database not configured")
+		}
+
+		var err error
+		if store, err = configstore.NewDBStore(db.Instance); err != nil {
+			return nil, fmt.Errorf("failed configuring runtime: %w", err)
+		}
+
+	case "file":
+		if conf.Runtime.File == "" {
+			return nil, errors.New("failed configuring runtime: missing file")
+		}
+
+		file, err := util.ExpandHome(conf.Runtime.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed configuring runtime: %w", err)
+		}
+
+		store = configstore.NewFileStore(file)
+
+	default:
+		return nil, fmt.Errorf("failed configuring runtime: invalid store: %s", conf.Runtime.Store)
+	}
+
+	res, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed loading runtime configuration: %w", err)
+	}
+
+	// seed from config file
+	if res == nil {
+		if err := store.Save(runtimeFromConfig(*conf)); err != nil {
+			return nil, fmt.Errorf("failed saving runtime configuration: %w", err)
+		}
+
+		return store, nil
+	}
+
+	log.INFO.Println("using persisted runtime configuration of devices and loadpoints")
+
+	conf.Meters = qualifiedFromRuntime(res.Meters)
+	conf.Chargers = qualifiedFromRuntime(res.Chargers)
+	conf.Vehicles = qualifiedFromRuntime(res.Vehicles)
+	conf.LoadPoints = res.LoadPoints
+
+	return store, nil
+}
+
+// runtimeFromConfig converts the config file's devices and loadpoints into runtime configuration
+func runtimeFromConfig(conf config) configstore.Config {
+	devices := func(qc []qualifiedConfig) []configstore.Device {
+		res := make([]configstore.Device, 0, len(qc))
+		for _, cc := range qc {
+			other, _ := configstore.Normalize(cc.Other).(map[string]interface{})
+			res = append(res, configstore.Device{Name: cc.Name, Type: cc.Type, Other: other})
+		}
+		return res
+	}
+
+	res := configstore.Config{
+		Meters:     devices(conf.Meters),
+		Chargers:   devices(conf.Chargers),
+		Vehicles:   devices(conf.Vehicles),
+		LoadPoints: make([]map[string]interface{}, 0, len(conf.LoadPoints)),
+	}
+
+	for _, lpc := range conf.LoadPoints {
+		other, _ := configstore.Normalize(lpc).(map[string]interface{})
+		res.LoadPoints = append(res.LoadPoints, other)
+	}
+
+	return res
+}
+
+// qualifiedFromRuntime converts runtime device configuration into config file devices
+func qualifiedFromRuntime(devices []configstore.Device) []qualifiedConfig {
+	res := make([]qualifiedConfig, 0, len(devices))
+	for _, dev := range devices {
+		res = append(res, qualifiedConfig{Name: dev.Name, Type: dev.Type, Other: dev.Other})
+	}
+	return res
+}
+
+// setup runtime configuration api
+func configureRuntimeManager(store configstore.Store, conf config, site *core.Site) *configstore.Manager {
+	factories := map[configstore.Class]configstore.Factory{
+		configstore.Meter: func(typ string, other map[string]interface{}) (interface{}, error) {
+			return meter.NewFromConfig(typ, other)
+		},
+		configstore.Charger: func(typ string, other map[string]interface{}) (interface{}, error) {
+			return charger.NewFromConfig(typ, other)
+		},
+		configstore.Vehicle: func(typ string, other map[string]interface{}) (interface{}, error) {
+			return vehicle.NewFromConfig(typ, other)
+		},
+	}
+
+	return configstore.New(store, runtimeFromConfig(conf), cp, site, factories)
+}
+
 // setup mqtt
 func configureMQTT(conf mqttConfig) error {
 	log := util.NewLogger("mqtt")
@@ -183,26 +413,101 @@ func configureTariffs(conf tariffConfig) (tariff.Tariffs, error) {
 	return *tariffs, err
 }
 
+func configureForecast(conf typedConfig) (api.Forecast, error) {
+	if conf.Type == "" {
+		return nil, nil
+	}
+
+	res, err := forecast.NewFromConfig(conf.Type, conf.Other)
+	if err != nil {
+		err = fmt.Errorf("failed configuring forecast: %w", err)
+	}
+
+	return res, err
+}
+
+// setup https using the configured or a self-signed certificate stored next to the config file
+func configureTLS(conf server.TLSConfig, httpd *server.HTTPd) error {
+	var cert tls.Certificate
+	var err error
+
+	if conf.Cert != "" || conf.Key != "" {
+		cert, err = tls.LoadX509KeyPair(conf.Cert, conf.Key)
+	} else {
+		dir := "."
+		if cfgFile != "" {
+			dir = filepath.Dir(cfgFile)
+		}
+
+		var created bool
+		conf.Cert, conf.Key = filepath.Join(dir, "evcc.crt"), filepath.Join(dir, "evcc.key")
+		if cert, created, err = selfsigned.LoadOrCreate(conf.Cert, conf.Key); created {
+			log.INFO.Printf("created self-signed certificate: %s", conf.Cert)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed configuring tls: %w", err)
+	}
+
+	httpd.TLSConfig = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if conf.Redirect != "" {
+		go func() {
+			log.ERROR.Printf("https redirect: %v", server.RedirectHTTPS(conf.Redirect, httpd.Addr))
+		}()
+	}
+
+	return nil
+}
+
+// setup access control
+func configureAuth(conf access.Config) (*access.Access, error) {
+	res, err := access.New(conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed configuring auth: %w", err)
+	}
+
+	if res.Enabled() {
+		log.INFO.Printf("access control enabled, mqtt setters limited to role %s", res.MQTTRole())
+	}
+
+	return res, nil
+}
+
 func configureSiteAndLoadpoints(conf config) (site *core.Site, err error) {
 	if err = cp.configure(conf); err == nil {
 		var loadPoints []*core.LoadPoint
 		loadPoints, err = configureLoadPoints(conf, cp)
 
+		var consumers []*core.Consumer
+		if err == nil {
+			consumers, err = configureConsumers(conf, cp)
+		}
+
 		var tariffs tariff.Tariffs
 		if err == nil {
 			tariffs, err = configureTariffs(conf.Tariffs)
 		}
 
+		var forecast api.Forecast
 		if err == nil {
-			site, err = configureSite(conf.Site, cp, loadPoints, tariffs)
+			forecast, err = configureForecast(conf.Forecast)
+		}
+
+		if err == nil {
+			site, err = configureSite(conf.Site, cp, loadPoints, consumers, tariffs, forecast)
 		}
 	}
 
 	return site, err
 }
 
-func configureSite(conf map[string]interface{}, cp *ConfigProvider, loadPoints []*core.LoadPoint, tariffs tariff.Tariffs) (*core.Site, error) {
-	site, err := core.NewSiteFromConfig(log, cp, conf, loadPoints, tariffs)
+func configureSite(conf map[string]interface{}, cp *ConfigProvider, loadPoints []*core.LoadPoint, consumers []*core.Consumer, tariffs tariff.Tariffs, forecast api.Forecast) (*core.Site, error) {
+	site, err := core.NewSiteFromConfig(log, cp, conf, loadPoints, consumers, tariffs, forecast)
 	if err != nil {
 		return nil, fmt.Errorf("failed configuring site: %w", err)
 	}
@@ -211,17 +516,11 @@ func configureSite(conf map[string]interface{}, cp *ConfigProvider, loadPoints [
 }
 
 func configureLoadPoints(conf config, cp *ConfigProvider) (loadPoints []*core.LoadPoint, err error) {
-	lpInterfaces, ok := viper.AllSettings()["loadpoints"].([]interface{})
-	if !ok || len(lpInterfaces) == 0 {
+	if len(conf.LoadPoints) == 0 {
 		return nil, errors.New("missing loadpoints")
 	}
 
-	for id, lpcI := range lpInterfaces {
-		var lpc map[string]interface{}
-		if err := util.DecodeOther(lpcI, &lpc); err != nil {
-			return nil, fmt.Errorf("failed decoding loadpoint configuration: %w", err)
-		}
-
+	for id, lpc := range conf.LoadPoints {
 		log := util.NewLogger("lp-" + strconv.Itoa(id+1))
 		lp, err := core.NewLoadPointFromConfig(log, cp, lpc)
 		if err != nil {
@@ -233,3 +532,17 @@ func configureLoadPoints(conf config, cp *ConfigProvider) (loadPoints []*core.Lo
 
 	return loadPoints, nil
 }
+
+func configureConsumers(conf config, cp *ConfigProvider) (consumers []*core.Consumer, err error) {
+	for id, cc := range conf.Consumers {
+		log := util.NewLogger("consumer-" + strconv.Itoa(id+1))
+		c, err := core.NewConsumerFromConfig(log, cp, cc)
+		if err != nil {
+			return nil, fmt.Errorf("failed configuring consumer: %w", err)
+		}
+
+		consumers = append(consumers, c)
+	}
+
+	return consumers, nil
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/evcc-io/evcc/core/simulator"
+	"github.com/evcc-io/evcc/server"
+	"github.com/evcc-io/evcc/util"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate <scenario>",
+	Short: "Simulate the control loop with modelled meters, chargers and vehicles",
+	Long: `Simulate runs the site's control loop against modelled meters, chargers and vehicles
+on accelerated time and prints a timeline report of the resulting control changes.
+
+Example scenario:
+
+  start: 2022-06-21T00:00:00+02:00
+  duration: 24h
+  interval: 30s
+  site:
+    residualPower: 100
+  pv:
+    peak: 9000 # synthetic clear sky profile, or file: pv.csv with time,power columns
+  home:
+    power: 500
+  battery:
+    capacity: 10 # kWh
+    soc: 50
+    power: 5000
+  loadpoints:
+  - loadpoint:
+      mode: pv
+    charger:
+      switchable: true
+      switchDelay: 1m
+    vehicle:
+      title: e-Golf
+      capacity: 36 # kWh
+      soc: 20
+      arrive: 7h
+      depart: 18h`,
+	Args: cobra.ExactArgs(1),
+	Run:  runSimulate,
+}
+
+func init() {
+	rootCmd.AddCommand(simulateCmd)
+	simulateCmd.Flags().Duration("resolution", 15*time.Minute, "Timeline resolution")
+	simulateCmd.Flags().String("csv", "", "Write the full timeline to csv file")
+}
+
+func runSimulate(cmd *cobra.Command, args []string) {
+	util.LogLevel(viper.GetString("log"), viper.GetStringMapString("levels"))
+	log.INFO.Printf("evcc %s", server.FormattedVersion())
+
+	b, err := os.ReadFile(args[0])
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	var other map[string]interface{}
+	if err := yaml.Unmarshal(b, &other); err != nil {
+		log.FATAL.Fatalf("failed decoding scenario: %v", err)
+	}
+
+	var conf simulator.Config
+	if err := util.DecodeOther(other, &conf); err != nil {
+		log.FATAL.Fatalf("failed decoding scenario: %v", err)
+	}
+
+	sim, err := simulator.New(conf)
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	res := sim.Run()
+
+	resolution, err := cmd.Flags().GetDuration("resolution")
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	err = res.WriteEvents(os.Stdout)
+	if err == nil {
+		fmt.Println()
+		err = res.WriteTimeline(os.Stdout, resolution)
+	}
+	if err == nil {
+		fmt.Println()
+		err = res.WriteSummary(os.Stdout)
+	}
+	if err != nil {
+		log.FATAL.Fatal(err)
+	}
+
+	if file, _ := cmd.Flags().GetString("csv"); file != "" {
+		f, err := os.Create(file)
+		if err != nil {
+			log.FATAL.Fatal(err)
+		}
+		defer f.Close()
+
+		if err := res.WriteCSV(f); err != nil {
+			log.FATAL.Fatal(err)
+		}
+	}
+}
@@ -33,7 +33,7 @@ import (
 		}
 {{- end -}}
 
-func {{.Function}}(base {{.BaseType}}{{range ordered}}, {{.VarName}} func() {{slice .Signature 7}}{{end}}) {{.ReturnType}} {
+func {{.Function}}(base {{.BaseType}}{{range ordered}}, {{.VarName}} {{.Signature}}{{end}}) {{.ReturnType}} {
 {{- $basetype := .BaseType}}
 {{- $shortbase := .ShortBase}}
 {{- $prefix := .Function}}
@@ -57,7 +57,7 @@ type {{$prefix}}{{.ShortType}}Impl struct {
 }
 
 func (impl *{{$prefix}}{{.ShortType}}Impl) {{.Function}}{{slice .Signature 4}} {
-	return impl.{{.VarName}}()
+	return impl.{{.VarName}}({{.Params}})
 }
 
 {{end}}
@@ -0,0 +1,221 @@
+package core
+
+import (
+	"math"
+	"sort"
+)
+
+// phaseCurrents are per-phase currents L1..L3 in A
+type phaseCurrents [3]float64
+
+// currentDemand describes a loadpoint's participation in site load management
+type currentDemand struct {
+	active     bool    // loadpoint is connected and may charge
+	priority   int     // higher priority demands are served first
+	phases     int     // phases used by the loadpoint, assumed to be connected starting at L1
+	minCurrent float64 // current below which charging is not possible
+	maxCurrent float64 // maximum current the loadpoint may use
+}
+
+// powerDemand describes a loadpoint's participation in pv surplus distribution
+type powerDemand struct {
+	active   bool    // loadpoint is connected and in pv mode
+	enabled  bool    // loadpoint is currently charging and preferred over idle loadpoints
+	priority int     // higher priority demands are served first
+	minPower float64 // power below which charging is not possible
+	maxPower float64 // maximum power the loadpoint may use
+	discrete bool    // demand takes either min or max power, nothing in between
+}
+
+// priorityGroups returns indices grouped by descending priority while preserving order within each group
+func priorityGroups(priorities []int) [][]int {
+	idx := make([]int, len(priorities))
+	for i := range idx {
+		idx[i] = i
+	}
+
+	sort.SliceStable(idx, func(i, j int) bool {
+		return priorities[idx[i]] > priorities[idx[j]]
+	})
+
+	var res [][]int
+	for i, id := range idx {
+		if i == 0 || priorities[id] != priorities[idx[i-1]] {
+			res = append(res, nil)
+		}
+		res[len(res)-1] = append(res[len(res)-1], id)
+	}
+
+	return res
+}
+
+// allocateCurrents distributes the per-phase current and power budget across demands by priority.
+// Within each priority group every active demand first receives its minimum current as long as the
+// budget permits. Remaining budget is then shared equally up to each demand's maximum current before
+// the next group is served. Demands that cannot receive their minimum current are allocated zero.
+func allocateCurrents(budget phaseCurrents, power float64, demands []currentDemand) []float64 {
+	res := make([]float64, len(demands))
+
+	// available current for demand given the remaining budget
+	available := func(d currentDemand) float64 {
+		current := math.Inf(1)
+		for p := 0; p < d.phases && p < len(budget); p++ {
+			current = math.Min(current, budget[p])
+		}
+		current = math.Min(current, powerToCurrent(power, d.phases))
+		return math.Max(current, 0)
+	}
+
+	// take current from the remaining budget
+	consume := func(d currentDemand, current float64) {
+		for p := 0; p < d.phases && p < len(budget); p++ {
+			budget[p] -= current
+		}
+		power -= current * float64(d.phases) * Voltage
+	}
+
+	priorities := make([]int, len(demands))
+	for i, d := range demands {
+		priorities[i] = d.priority
+	}
+
+	for _, group := range priorityGroups(priorities) {
+		// minimum current first
+		var members []int
+		for _, i := range group {
+			if d := demands[i]; d.active && d.phases > 0 && available(d) >= d.minCurrent {
+				res[i] = d.minCurrent
+				consume(d, d.minCurrent)
+				members = append(members, i)
+			}
+		}
+
+		// then share remaining budget equally up to max current
+		for len(members) > 0 {
+			var phaseUsers phaseCurrents
+			var phaseSum float64
+			for _, i := range members {
+				for p := 0; p < demands[i].phases && p < len(phaseUsers); p++ {
+					phaseUsers[p]++
+				}
+				phaseSum += float64(demands[i].phases)
+			}
+
+			// largest equal increment that keeps all members within budget
+			share := power / (phaseSum * Voltage)
+			for p, users := range phaseUsers {
+				if users > 0 {
+					share = math.Min(share, budget[p]/users)
+				}
+			}
+			for _, i := range members {
+				share = math.Min(share, demands[i].maxCurrent-res[i])
+			}
+
+			if share <= 1e-9 {
+				break
+			}
+
+			var remaining []int
+			for _, i := range members {
+				res[i] += share
+				consume(demands[i], share)
+
+				if demands[i].maxCurrent-res[i] > 1e-9 && available(demands[i]) > 1e-9 {
+					remaining = append(remaining, i)
+				}
+			}
+			members = remaining
+		}
+	}
+
+	return res
+}
+
+// allocatePower distributes available pv surplus power across demands by priority.
+// Within each priority group, charging demands are preferred over idle ones to receive their
+// minimum power. Remaining power is then shared equally up to each demand's maximum power,
+// discrete demands step up to their maximum power only if the remainder suffices,
+// before the next group is served. If no demand of a group can be started, the remainder
+// is offered to its first demand to allow regular pv enable/disable hysteresis to apply.
+func allocatePower(power float64, demands []powerDemand) []float64 {
+	res := make([]float64, len(demands))
+	power = math.Max(power, 0)
+
+	priorities := make([]int, len(demands))
+	for i, d := range demands {
+		priorities[i] = d.priority
+	}
+
+	for _, group := range priorityGroups(priorities) {
+		var candidates []int
+		for _, i := range group {
+			if demands[i].active {
+				candidates = append(candidates, i)
+			}
+		}
+
+		// charging loadpoints first
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return demands[candidates[i]].enabled && !demands[candidates[j]].enabled
+		})
+
+		// minimum power first
+		var members []int
+		for _, i := range candidates {
+			if d := demands[i]; power >= d.minPower {
+				res[i] = d.minPower
+				power -= d.minPower
+				members = append(members, i)
+			}
+		}
+
+		if len(members) == 0 {
+			if len(candidates) > 0 {
+				res[candidates[0]] = power
+				power = 0
+			}
+			continue
+		}
+
+		// discrete demands don't share
+		var continuous, discrete []int
+		for _, i := range members {
+			if demands[i].discrete {
+				discrete = append(discrete, i)
+			} else {
+				continuous = append(continuous, i)
+			}
+		}
+		members = continuous
+
+		// then share remaining power equally up to max power
+		for len(members) > 0 && power > 1e-9 {
+			share := power / float64(len(members))
+			for _, i := range members {
+				share = math.Min(share, demands[i].maxPower-res[i])
+			}
+
+			var remaining []int
+			for _, i := range members {
+				res[i] += share
+				power -= share
+
+				if demands[i].maxPower-res[i] > 1e-9 {
+					remaining = append(remaining, i)
+				}
+			}
+			members = remaining
+		}
+
+		// step up discrete demands if remaining power suffices
+		for _, i := range discrete {
+			if step := demands[i].maxPower - res[i]; power >= step {
+				res[i] += step
+				power -= step
+			}
+		}
+	}
+
+	return res
+}
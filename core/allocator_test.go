@@ -0,0 +1,163 @@
+package core
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPriorityGroups(t *testing.T) {
+	res := priorityGroups([]int{0, 2, 0, 1, 2})
+
+	expect := [][]int{{1, 4}, {3}, {0, 2}}
+	if len(res) != len(expect) {
+		t.Fatalf("expected %v, got %v", expect, res)
+	}
+
+	for i := range expect {
+		if len(res[i]) != len(expect[i]) {
+			t.Fatalf("expected %v, got %v", expect, res)
+		}
+		for j := range expect[i] {
+			if res[i][j] != expect[i][j] {
+				t.Fatalf("expected %v, got %v", expect, res)
+			}
+		}
+	}
+}
+
+func TestAllocateCurrents(t *testing.T) {
+	Voltage = 230 // V
+
+	inf := math.Inf(1)
+
+	tc := []struct {
+		name    string
+		budget  phaseCurrents
+		power   float64
+		demands []currentDemand
+		res     []float64
+	}{
+		{"unlimited", phaseCurrents{inf, inf, inf}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{16, 16}},
+		{"inactive", phaseCurrents{35, 35, 35}, inf, []currentDemand{
+			{false, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{0, 16}},
+		{"fuse shared equally", phaseCurrents{35, 35, 35}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{8.75, 8.75, 8.75, 8.75}},
+		{"fuse shared by priority", phaseCurrents{35, 35, 35}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 1, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{9.5, 16, 9.5}},
+		{"minimum not available", phaseCurrents{20, 20, 20}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{20.0 / 3, 20.0 / 3, 20.0 / 3, 0}},
+		{"lower priority throttled first", phaseCurrents{20, 20, 20}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 1, 3, 6, 16},
+			{true, 1, 3, 6, 16},
+		}, []float64{0, 10, 10}},
+		{"single phase uses L1", phaseCurrents{10, 35, 35}, inf, []currentDemand{
+			{true, 0, 1, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{10, 0}},
+		{"power limit", phaseCurrents{inf, inf, inf}, 3 * 230 * 20, []currentDemand{
+			{true, 0, 3, 6, 16},
+			{true, 0, 3, 6, 16},
+		}, []float64{10, 10}},
+		{"no budget", phaseCurrents{0, 0, 0}, inf, []currentDemand{
+			{true, 0, 3, 6, 16},
+		}, []float64{0}},
+	}
+
+	for _, tc := range tc {
+		t.Run(tc.name, func(t *testing.T) {
+			res := allocateCurrents(tc.budget, tc.power, tc.demands)
+
+			for i := range tc.res {
+				if math.Abs(res[i]-tc.res[i]) > 1e-6 {
+					t.Errorf("expected %v, got %v", tc.res, res)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAllocatePower(t *testing.T) {
+	tc := []struct {
+		name    string
+		power   float64
+		demands []powerDemand
+		res     []float64
+	}{
+		{"single", 5000, []powerDemand{
+			{true, false, 0, 1400, 11000, false},
+		}, []float64{5000}},
+		{"inactive", 5000, []powerDemand{
+			{false, false, 0, 1400, 11000, false},
+			{true, false, 0, 1400, 11000, false},
+		}, []float64{0, 5000}},
+		{"equal share", 8000, []powerDemand{
+			{true, true, 0, 1400, 11000, false},
+			{true, true, 0, 1400, 11000, false},
+		}, []float64{4000, 4000}},
+		{"equal share capped", 8000, []powerDemand{
+			{true, true, 0, 1400, 3000, false},
+			{true, true, 0, 1400, 11000, false},
+		}, []float64{3000, 5000}},
+		{"higher priority first", 8000, []powerDemand{
+			{true, true, 0, 1400, 11000, false},
+			{true, true, 1, 1400, 11000, false},
+		}, []float64{0, 8000}},
+		{"lower priority keeps remainder", 8000, []powerDemand{
+			{true, true, 0, 1400, 11000, false},
+			{true, true, 1, 1400, 6000, false},
+		}, []float64{2000, 6000}},
+		{"charging loadpoint preferred", 5000, []powerDemand{
+			{true, false, 0, 4140, 11000, false},
+			{true, true, 0, 4140, 11000, false},
+		}, []float64{0, 5000}},
+		{"remainder offered to first", 3000, []powerDemand{
+			{true, false, 0, 4140, 11000, false},
+			{true, true, 0, 4140, 11000, false},
+		}, []float64{0, 3000}},
+		{"discrete min", 3000, []powerDemand{
+			{true, false, 0, 1500, 4000, true},
+		}, []float64{1500}},
+		{"discrete max", 5000, []powerDemand{
+			{true, false, 0, 1500, 4000, true},
+			{true, false, 0, 1400, 11000, false},
+		}, []float64{1500, 3500}},
+		{"discrete max after continuous", 8000, []powerDemand{
+			{true, false, 0, 1500, 4000, true},
+			{true, false, 0, 1400, 3000, false},
+		}, []float64{4000, 3000}},
+		{"no surplus", -1000, []powerDemand{
+			{true, true, 0, 4140, 11000, false},
+		}, []float64{0}},
+	}
+
+	for _, tc := range tc {
+		t.Run(tc.name, func(t *testing.T) {
+			res := allocatePower(tc.power, tc.demands)
+
+			for i := range tc.res {
+				if math.Abs(res[i]-tc.res[i]) > 1e-6 {
+					t.Errorf("expected %v, got %v", tc.res, res)
+					break
+				}
+			}
+		})
+	}
+}
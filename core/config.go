@@ -1,10 +1,14 @@
 package core
 
-import "github.com/evcc-io/evcc/api"
+import (
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/rfid"
+)
 
 // configProvider gives access to configuration repository
 type configProvider interface {
 	Meter(string) api.Meter
 	Charger(string) api.Charger
 	Vehicle(string) api.Vehicle
+	Tokens() rfid.Tokens
 }
@@ -0,0 +1,660 @@
+package configstore
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/util"
+)
+
+// Class is a device class
+type Class string
+
+// Device classes
+const (
+	Meter   Class = "meter"
+	Charger Class = "charger"
+	Vehicle Class = "vehicle"
+)
+
+// Errors of rejected changes
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrExists       = errors.New("already exists")
+	ErrInUse        = errors.New("in use")
+	ErrNotSupported = errors.New("not supported at runtime")
+)
+
+// Factory creates a device from its type and configuration. Resources held by the device are registered using util.OnRelease.
+type Factory func(typ string, other map[string]interface{}) (interface{}, error)
+
+// Devices is the repository of instantiated devices by name
+type Devices interface {
+	Meters() map[string]api.Meter
+	Chargers() map[string]api.Charger
+	Vehicles() map[string]api.Vehicle
+	Tokens() rfid.Tokens
+	// setters replace the named device and release the previous instance's resources, nil removes the device
+	SetMeter(name string, meter api.Meter, release func())
+	SetCharger(name string, charger api.Charger, release func())
+	SetVehicle(name string, vehicle api.Vehicle, release func())
+}
+
+// Site is the running site
+type Site interface {
+	LoadPoints() []loadpoint.API
+	MeterRefs() []string
+	ReplaceMeter(name string, meter api.Meter)
+	ReplaceCharger(name string, charger api.Charger)
+	ReplaceVehicle(name string, vehicle api.Vehicle)
+	SetLoadPointDevices(id int, devices core.LoadPointDevices) error
+}
+
+// Manager validates runtime configuration changes, persists them and applies them to the running site.
+// Devices are re-instantiated and replaced between control cycles. Adding or removing loadpoints is not supported.
+type Manager struct {
+	mu        sync.Mutex
+	log       *util.Logger
+	store     Store
+	conf      Config
+	devices   Devices
+	site      Site
+	factories map[Class]Factory
+	restart   bool
+}
+
+// New creates a runtime configuration manager for the running configuration
+func New(store Store, conf Config, devices Devices, site Site, factories map[Class]Factory) *Manager {
+	return &Manager{
+		log:       util.NewLogger("config"),
+		store:     store,
+		conf:      conf,
+		devices:   devices,
+		site:      site,
+		factories: factories,
+	}
+}
+
+// RestartRequired returns true if persisted changes can only be applied by restarting
+func (m *Manager) RestartRequired() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.restart
+}
+
+// devices returns the configured devices of the class
+func (c *Config) devices(class Class) (*[]Device, error) {
+	switch class {
+	case Meter:
+		return &c.Meters, nil
+	case Charger:
+		return &c.Chargers, nil
+	case Vehicle:
+		return &c.Vehicles, nil
+	default:
+		return nil, fmt.Errorf("invalid class: %s", class)
+	}
+}
+
+// index returns the device's position or -1 if not configured
+func index(list []Device, name string) int {
+	for i, d := range list {
+		if d.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Devices returns the configured devices of the class
+func (m *Manager) Devices(class Class) ([]Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list, err := m.conf.devices(class)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Device{}, *list...), nil
+}
+
+// Device returns the named device configuration
+func (m *Manager) Device(class Class, name string) (Device, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list, err := m.conf.devices(class)
+	if err != nil {
+		return Device{}, err
+	}
+
+	i := index(*list, name)
+	if i < 0 {
+		return Device{}, fmt.Errorf("%s %s: %w", class, name, ErrNotFound)
+	}
+
+	return (*list)[i], nil
+}
+
+// instantiate creates the device using the class's registry and returns the function releasing its resources
+func (m *Manager) instantiate(class Class, dev Device) (interface{}, func(), error) {
+	if dev.Name == "" {
+		return nil, nil, errors.New("missing name")
+	}
+
+	factory, ok := m.factories[class]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid class: %s", class)
+	}
+
+	var res interface{}
+	release, err := util.CaptureReleases(func() (err error) {
+		res, err = factory(dev.Type, dev.Other)
+		return err
+	})
+	if err != nil {
+		release()
+		return nil, nil, fmt.Errorf("cannot create %s '%s': %w", class, dev.Name, err)
+	}
+
+	return res, release, nil
+}
+
+// save persists the configuration and makes it current
+func (m *Manager) save(conf Config) error {
+	if err := m.store.Save(conf); err != nil {
+		return fmt.Errorf("failed saving configuration: %w", err)
+	}
+
+	m.conf = conf
+
+	return nil
+}
+
+// clone returns a copy of the configuration for modification
+func (m *Manager) clone() Config {
+	return Config{
+		Meters:     append([]Device{}, m.conf.Meters...),
+		Chargers:   append([]Device{}, m.conf.Chargers...),
+		Vehicles:   append([]Device{}, m.conf.Vehicles...),
+		LoadPoints: append([]map[string]interface{}{}, m.conf.LoadPoints...),
+	}
+}
+
+// set makes the instantiated device available by name and releases the instance it replaces
+func (m *Manager) set(class Class, name string, dev interface{}, release func()) {
+	switch class {
+	case Meter:
+		meter, _ := dev.(api.Meter)
+		m.devices.SetMeter(name, meter, release)
+	case Charger:
+		charger, _ := dev.(api.Charger)
+		m.devices.SetCharger(name, charger, release)
+	case Vehicle:
+		vehicle, _ := dev.(api.Vehicle)
+		m.devices.SetVehicle(name, vehicle, release)
+	}
+}
+
+// CreateDevice validates, persists and instantiates a new device
+func (m *Manager) CreateDevice(class Class, dev Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conf := m.clone()
+
+	list, err := conf.devices(class)
+	if err != nil {
+		return err
+	}
+
+	if index(*list, dev.Name) >= 0 {
+		return fmt.Errorf("%s %s: %w", class, dev.Name, ErrExists)
+	}
+
+	instance, release, err := m.instantiate(class, dev)
+	if err != nil {
+		return err
+	}
+
+	*list = append(*list, dev)
+
+	if err := m.save(conf); err != nil {
+		release()
+		return err
+	}
+
+	m.set(class, dev.Name, instance, release)
+	m.log.INFO.Printf("%s created: %s", class, dev.Name)
+
+	return nil
+}
+
+// UpdateDevice validates and persists the device configuration and replaces the running device
+func (m *Manager) UpdateDevice(class Class, name string, dev Device) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if dev.Name == "" {
+		dev.Name = name
+	}
+	if dev.Name != name {
+		return errors.New("cannot rename device")
+	}
+
+	conf := m.clone()
+
+	list, err := conf.devices(class)
+	if err != nil {
+		return err
+	}
+
+	i := index(*list, name)
+	if i < 0 {
+		return fmt.Errorf("%s %s: %w", class, name, ErrNotFound)
+	}
+
+	// the replacement may claim the same exclusive resources like registrations or ports
+	prev := (*list)[i]
+	m.release(class, name)
+
+	instance, release, err := m.instantiate(class, dev)
+	if err != nil {
+		m.restore(class, prev)
+		return err
+	}
+
+	(*list)[i] = dev
+
+	if err := m.save(conf); err != nil {
+		release()
+		m.restore(class, prev)
+		return err
+	}
+
+	m.replace(class, name, instance, release)
+	m.log.INFO.Printf("%s updated: %s", class, name)
+
+	return nil
+}
+
+// release releases the resources of the running instance while it remains in use until replaced
+func (m *Manager) release(class Class, name string) {
+	switch class {
+	case Meter:
+		m.devices.SetMeter(name, m.devices.Meters()[name], nil)
+	case Charger:
+		m.devices.SetCharger(name, m.devices.Chargers()[name], nil)
+	case Vehicle:
+		m.devices.SetVehicle(name, m.devices.Vehicles()[name], nil)
+	}
+}
+
+// restore re-creates the released device from its previous configuration
+func (m *Manager) restore(class Class, dev Device) {
+	instance, release, err := m.instantiate(class, dev)
+	if err != nil {
+		m.log.ERROR.Printf("%s %s: cannot restore: %v", class, dev.Name, err)
+		return
+	}
+
+	m.replace(class, dev.Name, instance, release)
+}
+
+// replace replaces the device used by the site and makes it available by name
+func (m *Manager) replace(class Class, name string, dev interface{}, release func()) {
+	switch class {
+	case Meter:
+		m.site.ReplaceMeter(name, dev.(api.Meter))
+	case Charger:
+		m.site.ReplaceCharger(name, dev.(api.Charger))
+	case Vehicle:
+		m.site.ReplaceVehicle(name, dev.(api.Vehicle))
+	}
+
+	m.set(class, name, dev, release)
+}
+
+// DeleteDevice removes an unused device
+func (m *Manager) DeleteDevice(class Class, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	conf := m.clone()
+
+	list, err := conf.devices(class)
+	if err != nil {
+		return err
+	}
+
+	i := index(*list, name)
+	if i < 0 {
+		return fmt.Errorf("%s %s: %w", class, name, ErrNotFound)
+	}
+
+	if user, ok := m.usedBy(class, name); ok {
+		return fmt.Errorf("%s %s: %w by %s", class, name, ErrInUse, user)
+	}
+
+	*list = append((*list)[:i], (*list)[i+1:]...)
+
+	if err := m.save(conf); err != nil {
+		return err
+	}
+
+	m.set(class, name, nil, nil)
+	m.log.INFO.Printf("%s deleted: %s", class, name)
+
+	return nil
+}
+
+// usedBy returns the site, loadpoint or token referencing the device
+func (m *Manager) usedBy(class Class, name string) (string, bool) {
+	if class == Meter {
+		for _, ref := range m.site.MeterRefs() {
+			if ref == name {
+				return "site", true
+			}
+		}
+	}
+
+	for id, other := range m.conf.LoadPoints {
+		refs, err := decodeRefs(other)
+		if err != nil {
+			continue
+		}
+
+		if refs.uses(class, name) {
+			return "loadpoint " + strconv.Itoa(id+1), true
+		}
+	}
+
+	if class == Vehicle {
+		for _, token := range m.devices.Tokens() {
+			if token.Vehicle == name {
+				return "token " + token.ID, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// refs are a loadpoint's device references
+type refs struct {
+	Charger  string
+	Meter    string
+	Meters   struct{ Charge string }
+	Vehicle  string
+	Vehicles []string
+	Other    map[string]interface{} `mapstructure:",remain"`
+}
+
+func decodeRefs(other map[string]interface{}) (refs, error) {
+	var res refs
+	err := util.DecodeOther(other, &res)
+	return res, err
+}
+
+func (r refs) meter() string {
+	if r.Meter != "" {
+		return r.Meter
+	}
+	return r.Meters.Charge
+}
+
+func (r refs) vehicles() []string {
+	if r.Vehicle != "" {
+		return []string{r.Vehicle}
+	}
+	return r.Vehicles
+}
+
+func (r refs) uses(class Class, name string) bool {
+	switch class {
+	case Meter:
+		return r.meter() == name
+	case Charger:
+		return r.Charger == name
+	case Vehicle:
+		for _, ref := range r.vehicles() {
+			if ref == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LoadPoints returns the loadpoint configurations
+func (m *Manager) LoadPoints() []map[string]interface{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]map[string]interface{}{}, m.conf.LoadPoints...)
+}
+
+// LoadPoint returns the configuration of the loadpoint by its 0-based id
+func (m *Manager) LoadPoint(id int) (map[string]interface{}, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 0 || id >= len(m.conf.LoadPoints) {
+		return nil, fmt.Errorf("loadpoint %d: %w", id+1, ErrNotFound)
+	}
+
+	return m.conf.LoadPoints[id], nil
+}
+
+// decodeSettings decodes the loadpoint's settings onto the defaults without creating its devices or stores
+func decodeSettings(id int, other map[string]interface{}) (*core.LoadPoint, error) {
+	lp := core.NewLoadPoint(util.NewLogger("lp-" + strconv.Itoa(id+1)))
+	if err := util.DecodeOther(other, lp); err != nil {
+		return nil, err
+	}
+
+	if err := lp.Plans.Validate(); err != nil {
+		return nil, fmt.Errorf("plans: %w", err)
+	}
+
+	return lp, nil
+}
+
+// validateLoadPoint checks the loadpoint's references and decodes its settings
+func (m *Manager) validateLoadPoint(id int, other map[string]interface{}) (refs, *core.LoadPoint, error) {
+	r, err := decodeRefs(other)
+	if err != nil {
+		return r, nil, err
+	}
+
+	if r.Charger == "" {
+		return r, nil, errors.New("missing charger")
+	}
+	if _, ok := m.devices.Chargers()[r.Charger]; !ok {
+		return r, nil, fmt.Errorf("charger %s: %w", r.Charger, ErrNotFound)
+	}
+
+	if name := r.meter(); name != "" {
+		if _, ok := m.devices.Meters()[name]; !ok {
+			return r, nil, fmt.Errorf("meter %s: %w", name, ErrNotFound)
+		}
+
+		// meters must not be shared (https://github.com/evcc-io/evcc/issues/1744)
+		if user, ok := m.usedBy(Meter, name); ok && user != "loadpoint "+strconv.Itoa(id+1) {
+			return r, nil, fmt.Errorf("meter %s: %w by %s", name, ErrInUse, user)
+		}
+	}
+
+	if r.Vehicle != "" && len(r.Vehicles) > 0 {
+		return r, nil, errors.New("cannot have vehicle and vehicles both")
+	}
+
+	for _, name := range r.vehicles() {
+		if _, ok := m.devices.Vehicles()[name]; !ok {
+			return r, nil, fmt.Errorf("vehicle %s: %w", name, ErrNotFound)
+		}
+	}
+
+	lp, err := decodeSettings(id, other)
+	if err == nil && lp.Authorization && len(m.devices.Tokens()) == 0 {
+		err = errors.New("authorization requires tokens")
+	}
+
+	return r, lp, err
+}
+
+// CreateLoadPoint is not supported, loadpoints can only be added to the site on startup
+func (m *Manager) CreateLoadPoint(other map[string]interface{}) (int, error) {
+	return 0, fmt.Errorf("create loadpoint: %w", ErrNotSupported)
+}
+
+// runtimeKeys are loadpoint settings applied without restart
+var runtimeKeys = []string{"charger", "meter", "vehicle", "vehicles", "mode", "phases", "mincurrent", "maxcurrent", "priority", "soc"}
+
+// staticSettings returns the settings requiring restart
+func staticSettings(other map[string]interface{}) (map[string]interface{}, error) {
+	res := make(map[string]interface{})
+
+	for k, v := range other {
+		key := strings.ToLower(k)
+
+		if key == "soc" {
+			var soc core.SoCConfig
+			if err := util.DecodeOther(v, &soc); err != nil {
+				return nil, err
+			}
+
+			res[key] = []interface{}{soc.Poll, soc.Estimate}
+			continue
+		}
+
+		var runtime bool
+		for _, rk := range runtimeKeys {
+			runtime = runtime || rk == key
+		}
+
+		if !runtime {
+			res[key] = v
+		}
+	}
+
+	return res, nil
+}
+
+// UpdateLoadPoint validates and persists the loadpoint configuration and applies it to the running loadpoint.
+// Devices, mode, phases, currents, priority and soc limits are applied immediately, other changes after restart.
+func (m *Manager) UpdateLoadPoint(id int, other map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id < 0 || id >= len(m.conf.LoadPoints) {
+		return fmt.Errorf("loadpoint %d: %w", id+1, ErrNotFound)
+	}
+
+	r, lp, err := m.validateLoadPoint(id, other)
+	if err != nil {
+		return err
+	}
+
+	// decode previous settings for detecting changes
+	prev, err := decodeSettings(id, m.conf.LoadPoints[id])
+	if err != nil {
+		return err
+	}
+
+	// settings not applied at runtime require restart
+	oldStatic, err := staticSettings(m.conf.LoadPoints[id])
+	if err != nil {
+		return err
+	}
+
+	newStatic, err := staticSettings(other)
+	if err != nil {
+		return err
+	}
+
+	conf := m.clone()
+	conf.LoadPoints[id] = other
+
+	if err := m.save(conf); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(oldStatic, newStatic) {
+		m.restart = true
+		m.log.INFO.Printf("loadpoint %d updated, restart required", id+1)
+	}
+
+	lps := m.site.LoadPoints()
+	if id >= len(lps) {
+		// created since start
+		return nil
+	}
+
+	devices := core.LoadPointDevices{
+		ChargerRef:  r.Charger,
+		Charger:     m.devices.Chargers()[r.Charger],
+		MeterRef:    r.meter(),
+		VehiclesRef: r.vehicles(),
+	}
+
+	if devices.MeterRef != "" {
+		devices.Meter = m.devices.Meters()[devices.MeterRef]
+	}
+
+	for _, name := range devices.VehiclesRef {
+		devices.Vehicles = append(devices.Vehicles, m.devices.Vehicles()[name])
+	}
+
+	if err := m.site.SetLoadPointDevices(id, devices); err != nil {
+		return err
+	}
+
+	return applySettings(lps[id], prev, lp)
+}
+
+// applySettings applies changed runtime settings to the running loadpoint
+func applySettings(lp loadpoint.API, prev, next *core.LoadPoint) error {
+	if next.Mode != prev.Mode {
+		lp.SetMode(next.Mode)
+	}
+
+	if next.Phases != prev.Phases {
+		if err := lp.SetPhases(next.Phases); err != nil {
+			return err
+		}
+	}
+
+	if next.MinCurrent != prev.MinCurrent {
+		lp.SetMinCurrent(next.MinCurrent)
+	}
+	if next.MaxCurrent != prev.MaxCurrent {
+		lp.SetMaxCurrent(next.MaxCurrent)
+	}
+
+	if next.Priority != prev.Priority {
+		lp.SetPriority(next.Priority)
+	}
+
+	if next.SoC.Target != prev.SoC.Target {
+		lp.SetTargetSoC(next.SoC.Target)
+	}
+	if next.SoC.Min != prev.SoC.Min {
+		lp.SetMinSoC(next.SoC.Min)
+	}
+
+	return nil
+}
+
+// DeleteLoadPoint is not supported, loadpoints can only be removed from the site on startup
+func (m *Manager) DeleteLoadPoint(id int) error {
+	return fmt.Errorf("delete loadpoint %d: %w", id+1, ErrNotSupported)
+}
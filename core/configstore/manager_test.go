@@ -0,0 +1,250 @@
+package configstore
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/mock"
+	"github.com/evcc-io/evcc/util"
+	"github.com/golang/mock/gomock"
+)
+
+var _ Site = (*core.Site)(nil)
+
+type devices struct {
+	meters   map[string]api.Meter
+	chargers map[string]api.Charger
+	vehicles map[string]api.Vehicle
+	releases map[string]func()
+}
+
+// provider provides the devices for creating the running loadpoint
+type provider struct {
+	*devices
+}
+
+func (p *provider) Meter(name string) api.Meter     { return p.meters[name] }
+func (p *provider) Charger(name string) api.Charger { return p.chargers[name] }
+func (p *provider) Vehicle(name string) api.Vehicle { return p.vehicles[name] }
+
+func (d *devices) Meters() map[string]api.Meter     { return d.meters }
+func (d *devices) Chargers() map[string]api.Charger { return d.chargers }
+func (d *devices) Vehicles() map[string]api.Vehicle { return d.vehicles }
+func (d *devices) Tokens() rfid.Tokens              { return nil }
+
+// release releases the named device's previous instance
+func (d *devices) release(name string, release func()) {
+	if prev := d.releases[name]; prev != nil {
+		prev()
+	}
+	d.releases[name] = release
+}
+
+func (d *devices) SetMeter(name string, meter api.Meter, release func()) {
+	d.release(name, release)
+	if meter == nil {
+		delete(d.meters, name)
+		return
+	}
+	d.meters[name] = meter
+}
+
+func (d *devices) SetCharger(name string, charger api.Charger, release func()) {
+	d.release(name, release)
+	if charger == nil {
+		delete(d.chargers, name)
+		return
+	}
+	d.chargers[name] = charger
+}
+
+func (d *devices) SetVehicle(name string, vehicle api.Vehicle, release func()) {
+	d.release(name, release)
+	if vehicle == nil {
+		delete(d.vehicles, name)
+		return
+	}
+	d.vehicles[name] = vehicle
+}
+
+type site struct {
+	lps      []loadpoint.API
+	replaced map[string]interface{}
+	devices  map[int]core.LoadPointDevices
+}
+
+func (s *site) LoadPoints() []loadpoint.API { return s.lps }
+func (s *site) MeterRefs() []string         { return []string{"grid"} }
+
+func (s *site) ReplaceMeter(name string, meter api.Meter)       { s.replaced[name] = meter }
+func (s *site) ReplaceCharger(name string, charger api.Charger) { s.replaced[name] = charger }
+func (s *site) ReplaceVehicle(name string, vehicle api.Vehicle) { s.replaced[name] = vehicle }
+
+func (s *site) SetLoadPointDevices(id int, devices core.LoadPointDevices) error {
+	s.devices[id] = devices
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	released := make(map[interface{}]bool)
+	var claimed bool
+
+	factory := func(typ string, other map[string]interface{}) (interface{}, error) {
+		var dev interface{}
+		switch typ {
+		case "meter":
+			dev = mock.NewMockMeter(ctrl)
+		case "charger":
+			dev = mock.NewMockCharger(ctrl)
+		case "exclusive":
+			if claimed {
+				return nil, errors.New("duplicate registration")
+			}
+			claimed = true
+			util.OnRelease(func() { claimed = false })
+			dev = mock.NewMockCharger(ctrl)
+		default:
+			return nil, errors.New("invalid type")
+		}
+
+		util.OnRelease(func() { released[dev] = true })
+
+		return dev, nil
+	}
+
+	d := &devices{
+		meters:   map[string]api.Meter{"grid": mock.NewMockMeter(ctrl)},
+		chargers: map[string]api.Charger{"wallbox": mock.NewMockCharger(ctrl)},
+		vehicles: map[string]api.Vehicle{},
+		releases: make(map[string]func()),
+	}
+
+	conf := Config{
+		Meters:     []Device{{Name: "grid", Type: "meter"}},
+		Chargers:   []Device{{Name: "wallbox", Type: "charger"}},
+		LoadPoints: []map[string]interface{}{{"title": "Garage", "charger": "wallbox", "mode": "off"}},
+	}
+
+	lp, err := core.NewLoadPointFromConfig(util.NewLogger("foo"), &provider{d}, conf.LoadPoints[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &site{
+		lps:      []loadpoint.API{lp},
+		replaced: make(map[string]interface{}),
+		devices:  make(map[int]core.LoadPointDevices),
+	}
+
+	store := NewFileStore(filepath.Join(t.TempDir(), "config.json"))
+	m := New(store, conf, d, s, map[Class]Factory{Meter: factory, Charger: factory})
+
+	// devices
+	if err := m.CreateDevice(Meter, Device{Name: "charge", Type: "meter"}); err != nil || d.meters["charge"] == nil {
+		t.Fatalf("meter not created: %v", err)
+	}
+
+	if err := m.CreateDevice(Meter, Device{Name: "charge", Type: "meter"}); !errors.Is(err, ErrExists) {
+		t.Errorf("expected %v, got %v", ErrExists, err)
+	}
+
+	if err := m.CreateDevice(Meter, Device{Name: "pv", Type: "foo"}); err == nil || d.meters["pv"] != nil {
+		t.Error("expected invalid type error")
+	}
+
+	if err := m.UpdateDevice(Charger, "wallbox", Device{Type: "charger"}); err != nil || s.replaced["wallbox"] != d.chargers["wallbox"] {
+		t.Errorf("charger not replaced: %v", err)
+	}
+
+	// replaced instances are released
+	replaced := d.chargers["wallbox"]
+	if err := m.UpdateDevice(Charger, "wallbox", Device{Type: "charger"}); err != nil || !released[replaced] || released[d.chargers["wallbox"]] {
+		t.Errorf("replaced charger not released: %v", err)
+	}
+
+	// exclusive resources are released before creating the replacement
+	if err := m.UpdateDevice(Charger, "wallbox", Device{Type: "exclusive"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.UpdateDevice(Charger, "wallbox", Device{Type: "exclusive"}); err != nil {
+		t.Errorf("exclusive charger not replaced: %v", err)
+	}
+
+	// failed replacements restore the previous device
+	if err := m.UpdateDevice(Charger, "wallbox", Device{Type: "foo"}); err == nil {
+		t.Error("expected invalid type error")
+	}
+
+	if !claimed || s.replaced["wallbox"] != d.chargers["wallbox"] || released[d.chargers["wallbox"]] {
+		t.Error("previous charger not restored")
+	}
+
+	if err := m.CreateDevice(Meter, Device{Name: "unused", Type: "meter"}); err != nil {
+		t.Fatal(err)
+	}
+
+	unused := d.meters["unused"]
+	if err := m.DeleteDevice(Meter, "unused"); err != nil || !released[unused] {
+		t.Errorf("deleted meter not released: %v", err)
+	}
+
+	if err := m.UpdateDevice(Charger, "foo", Device{Type: "charger"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := m.DeleteDevice(Meter, "grid"); !errors.Is(err, ErrInUse) {
+		t.Errorf("expected %v, got %v", ErrInUse, err)
+	}
+
+	if err := m.DeleteDevice(Charger, "wallbox"); !errors.Is(err, ErrInUse) {
+		t.Errorf("expected %v, got %v", ErrInUse, err)
+	}
+
+	// loadpoints
+	if err := m.UpdateLoadPoint(0, map[string]interface{}{"title": "Garage", "charger": "missing"}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected %v, got %v", ErrNotFound, err)
+	}
+
+	if err := m.UpdateLoadPoint(0, map[string]interface{}{"title": "Garage", "charger": "wallbox", "meter": "charge", "mode": "pv"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if devs := s.devices[0]; devs.MeterRef != "charge" || devs.Meter != d.meters["charge"] || devs.Charger != d.chargers["wallbox"] {
+		t.Errorf("unexpected loadpoint devices: %+v", devs)
+	}
+
+	if lp.GetMode() != api.ModePV || m.RestartRequired() {
+		t.Errorf("expected mode applied without restart, got %s", lp.GetMode())
+	}
+
+	if err := m.UpdateLoadPoint(0, map[string]interface{}{"title": "Carport", "charger": "wallbox", "meter": "charge", "mode": "pv"}); err != nil || !m.RestartRequired() {
+		t.Errorf("expected restart required: %v", err)
+	}
+
+	// loadpoints are not added or removed at runtime
+	if _, err := m.CreateLoadPoint(map[string]interface{}{"title": "Carport", "charger": "wallbox"}); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected %v, got %v", ErrNotSupported, err)
+	}
+
+	if err := m.DeleteLoadPoint(0); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("expected %v, got %v", ErrNotSupported, err)
+	}
+
+	// persisted
+	res, err := store.Load()
+	if err != nil || res == nil {
+		t.Fatalf("missing config: %v", err)
+	}
+
+	if len(res.Meters) != 2 || len(res.LoadPoints) != 1 || res.LoadPoints[0]["title"] != "Carport" {
+		t.Errorf("unexpected config: %+v", res)
+	}
+}
@@ -0,0 +1,198 @@
+// Package configstore persists the runtime configuration of devices and loadpoints and applies changes to the running site
+package configstore
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Device is a named device configuration
+type Device struct {
+	Name  string
+	Type  string
+	Other map[string]interface{}
+}
+
+// MarshalJSON implements the json.Marshaler interface. Device attributes are flattened like in the yaml configuration.
+func (d Device) MarshalJSON() ([]byte, error) {
+	res := make(map[string]interface{}, len(d.Other)+2)
+	for k, v := range d.Other {
+		res[k] = v
+	}
+
+	res["name"] = d.Name
+	res["type"] = d.Type
+
+	return json.Marshal(res)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface
+func (d *Device) UnmarshalJSON(b []byte) error {
+	var res map[string]interface{}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return err
+	}
+
+	*d = Device{Other: make(map[string]interface{})}
+
+	for k, v := range res {
+		switch strings.ToLower(k) {
+		case "name", "type":
+			s, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("invalid %s: %v", k, v)
+			}
+
+			if strings.EqualFold(k, "name") {
+				d.Name = s
+			} else {
+				d.Type = s
+			}
+
+		default:
+			d.Other[k] = v
+		}
+	}
+
+	return nil
+}
+
+// Config is the runtime configuration
+type Config struct {
+	Meters     []Device                 `json:"meters"`
+	Chargers   []Device                 `json:"chargers"`
+	Vehicles   []Device                 `json:"vehicles"`
+	LoadPoints []map[string]interface{} `json:"loadpoints"`
+}
+
+// Store persists the runtime configuration
+type Store interface {
+	// Load returns the persisted configuration. The configuration is nil if nothing has been persisted yet.
+	Load() (*Config, error)
+	Save(Config) error
+}
+
+// fileStore persists the configuration as json file
+type fileStore struct {
+	file string
+}
+
+// NewFileStore creates a file-based configuration store
+func NewFileStore(file string) Store {
+	return &fileStore{file: file}
+}
+
+func (s *fileStore) Load() (*Config, error) {
+	b, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res Config
+	err = json.Unmarshal(b, &res)
+
+	return &res, err
+}
+
+func (s *fileStore) Save(conf Config) error {
+	b, err := json.MarshalIndent(conf, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), os.ModePerm); err != nil {
+		return err
+	}
+
+	// write atomically to prevent corruption on crash
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.file)
+}
+
+// configRecord is the single database row holding the configuration
+type configRecord struct {
+	ID     uint `gorm:"primarykey"`
+	Config string
+}
+
+func (configRecord) TableName() string {
+	return "config"
+}
+
+// dbStore persists the configuration in the database
+type dbStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a database-backed configuration store
+func NewDBStore(db *gorm.DB) (Store, error) {
+	err := db.AutoMigrate(new(configRecord))
+	return &dbStore{db: db}, err
+}
+
+func (s *dbStore) Load() (*Config, error) {
+	var rec configRecord
+
+	err := s.db.First(&rec, 1).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res Config
+	err = json.Unmarshal([]byte(rec.Config), &res)
+
+	return &res, err
+}
+
+func (s *dbStore) Save(conf Config) error {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Save(&configRecord{ID: 1, Config: string(b)}).Error
+}
+
+// Normalize converts nested yaml maps to json-compatible string maps
+func Normalize(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			res[fmt.Sprintf("%v", k)] = Normalize(v)
+		}
+		return res
+
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			res[k] = Normalize(v)
+		}
+		return res
+
+	case []interface{}:
+		res := make([]interface{}, len(t))
+		for i, v := range t {
+			res[i] = Normalize(v)
+		}
+		return res
+	}
+
+	return v
+}
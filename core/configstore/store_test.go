@@ -0,0 +1,82 @@
+package configstore
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDeviceJSON(t *testing.T) {
+	d := Device{Name: "grid", Type: "modbus", Other: map[string]interface{}{"uri": "localhost:502", "id": float64(1)}}
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expect := `{"id":1,"name":"grid","type":"modbus","uri":"localhost:502"}`; string(b) != expect {
+		t.Errorf("expected %s, got %s", expect, b)
+	}
+
+	var res Device
+	if err := json.Unmarshal(b, &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, res) {
+		t.Errorf("expected %+v, got %+v", d, res)
+	}
+
+	if err := json.Unmarshal([]byte(`{"name":1}`), &res); err == nil {
+		t.Error("expected invalid name error")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store := NewFileStore(filepath.Join(t.TempDir(), "config.json"))
+
+	if conf, err := store.Load(); err != nil || conf != nil {
+		t.Fatalf("unexpected config: %v %v", conf, err)
+	}
+
+	conf := Config{
+		Meters:     []Device{{Name: "grid", Type: "custom", Other: map[string]interface{}{"power": map[string]interface{}{"source": "mqtt"}}}},
+		Chargers:   []Device{},
+		Vehicles:   []Device{},
+		LoadPoints: []map[string]interface{}{{"charger": "wallbox", "mode": "pv"}},
+	}
+
+	if err := store.Save(conf); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := store.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(conf, *res) {
+		t.Errorf("expected %+v, got %+v", conf, *res)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	in := map[string]interface{}{
+		"power": map[interface{}]interface{}{"source": "script", "timeout": 3},
+		"list":  []interface{}{map[interface{}]interface{}{1: "one"}},
+	}
+
+	expect := map[string]interface{}{
+		"power": map[string]interface{}{"source": "script", "timeout": 3},
+		"list":  []interface{}{map[string]interface{}{"1": "one"}},
+	}
+
+	if res := Normalize(in); !reflect.DeepEqual(expect, res) {
+		t.Errorf("expected %v, got %v", expect, res)
+	}
+
+	if _, err := json.Marshal(Normalize(in)); err != nil {
+		t.Error(err)
+	}
+}
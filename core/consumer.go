@@ -0,0 +1,249 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/provider"
+	"github.com/evcc-io/evcc/util"
+)
+
+// Consumer types
+const (
+	consumerSwitch     = "switch"     // on/off load with nominal power
+	consumerModulating = "modulating" // load with adjustable power
+	consumerSGReady    = "sgready"    // SG-Ready heat pump
+)
+
+// SG-Ready operating states
+const (
+	sgReadyBlocked int64 = iota + 1 // operation blocked by grid operator
+	sgReadyNormal                   // normal operation
+	sgReadyBoost                    // recommended on
+	sgReadyForce                    // forced on
+)
+
+// Consumer is a switchable or power-modulated load like a heat pump or heating rod consuming pv surplus
+type Consumer struct {
+	log   *util.Logger
+	clock clock.Clock // mockable time
+
+	Title      string        `mapstructure:"title"`      // UI title
+	Type       string        `mapstructure:"type"`       // switch, modulating or sgready
+	Priority   int           `mapstructure:"priority"`   // Shares pv surplus with loadpoints by priority
+	Power      float64       `mapstructure:"power"`      // Switch: nominal power, SG-Ready: boost power
+	ForcePower float64       `mapstructure:"forcePower"` // SG-Ready: force on power (0 to disable)
+	MinPower   float64       `mapstructure:"minPower"`   // Modulating: minimum power
+	MaxPower   float64       `mapstructure:"maxPower"`   // Modulating: maximum power
+	MinOnTime  time.Duration `mapstructure:"minOnTime"`  // Minimum time before switching off
+	MinOffTime time.Duration `mapstructure:"minOffTime"` // Minimum time before switching on
+	MeterRef   string        `mapstructure:"meter"`      // Consumption meter reference
+
+	Enable   *provider.Config `mapstructure:"enable"`   // Switch: bool setter
+	SetPower *provider.Config `mapstructure:"setpower"` // Modulating: int setter (W)
+	SGReady  *provider.Config `mapstructure:"sgready"`  // SG-Ready: int setter (1-4)
+
+	meter       api.Meter
+	enableS     func(bool) error
+	setPowerS   func(int64) error
+	setSGReadyS func(int64) error
+
+	updated  bool      // Initial state has been applied
+	blocked  bool      // SG-Ready operation blocked by grid operator curtailment
+	power    float64   // Current power setpoint, 0 if off
+	state    int64     // Current SG-Ready state
+	switched time.Time // Last on/off switching time
+}
+
+// NewConsumerFromConfig creates a new consumer
+func NewConsumerFromConfig(log *util.Logger, cp configProvider, other map[string]interface{}) (*Consumer, error) {
+	c := &Consumer{
+		log:   log,
+		clock: clock.New(),
+	}
+
+	if err := util.DecodeOther(other, c); err != nil {
+		return nil, err
+	}
+
+	var err error
+
+	switch c.Type = strings.ToLower(c.Type); c.Type {
+	case consumerSwitch:
+		if c.Enable == nil || c.Power <= 0 {
+			return nil, errors.New("switch requires enable and power")
+		}
+		c.enableS, err = provider.NewBoolSetterFromConfig("enable", *c.Enable)
+
+	case consumerModulating:
+		if c.SetPower == nil || c.MaxPower <= 0 || c.MinPower > c.MaxPower {
+			return nil, errors.New("modulating requires setpower and valid minPower/maxPower")
+		}
+		c.setPowerS, err = provider.NewIntSetterFromConfig("setpower", *c.SetPower)
+
+	case consumerSGReady:
+		if c.SGReady == nil || c.Power <= 0 || (c.ForcePower > 0 && c.ForcePower < c.Power) {
+			return nil, errors.New("sgready requires sgready and power, forcePower must exceed power")
+		}
+		c.setSGReadyS, err = provider.NewIntSetterFromConfig("sgready", *c.SGReady)
+
+	default:
+		return nil, fmt.Errorf("invalid type: %s", c.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if c.MeterRef != "" {
+		c.meter = cp.Meter(c.MeterRef)
+	}
+
+	return c, nil
+}
+
+// on returns true if the consumer is switched on or boosted
+func (c *Consumer) on() bool {
+	return c.power > 0
+}
+
+// powerRange returns the minimum and maximum power the consumer may take from the pv surplus
+func (c *Consumer) powerRange() (float64, float64) {
+	switch c.Type {
+	case consumerModulating:
+		return c.MinPower, c.MaxPower
+	case consumerSGReady:
+		return c.Power, math.Max(c.Power, c.ForcePower)
+	default:
+		return c.Power, c.Power
+	}
+}
+
+// demand returns the consumer's participation in pv surplus distribution
+func (c *Consumer) demand() powerDemand {
+	minPower, maxPower := c.powerRange()
+
+	return powerDemand{
+		active:   !c.blocked,
+		enabled:  c.on(),
+		priority: c.Priority,
+		minPower: minPower,
+		maxPower: maxPower,
+		discrete: c.Type == consumerSGReady,
+	}
+}
+
+// consumption returns the consumer's current power, measured if a meter is configured
+func (c *Consumer) consumption() float64 {
+	if c.meter != nil {
+		power, err := c.meter.CurrentPower()
+		if err == nil {
+			return power
+		}
+
+		c.log.ERROR.Printf("%s meter: %v", c.Title, err)
+	}
+
+	return c.power
+}
+
+// holding returns true if the minimum on or off time prevents switching
+func (c *Consumer) holding(on bool) bool {
+	if on == c.on() || c.switched.IsZero() {
+		return false
+	}
+
+	hold := c.MinOffTime
+	if c.on() {
+		hold = c.MinOnTime
+	}
+
+	return c.clock.Since(c.switched) < hold
+}
+
+// Update applies the consumer's share of pv surplus
+func (c *Consumer) Update(share float64) {
+	minPower, maxPower := c.powerRange()
+
+	power := math.Min(share, maxPower)
+	if power < minPower {
+		power = 0
+	}
+
+	if c.holding(power > 0) {
+		if c.on() {
+			// keep running at least at minimum power
+			power = math.Max(power, minPower)
+		} else {
+			power = 0
+		}
+	}
+
+	var err error
+
+	switch c.Type {
+	case consumerSwitch:
+		if !c.updated || (power > 0) != c.on() {
+			err = c.enableS(power > 0)
+		}
+
+	case consumerModulating:
+		if !c.updated || power != c.power {
+			err = c.setPowerS(int64(power))
+		}
+
+	case consumerSGReady:
+		// power follows the state's nominal power
+		state := sgReadyNormal
+		switch {
+		case c.blocked:
+			state, power = sgReadyBlocked, 0
+		case c.ForcePower > 0 && power >= c.ForcePower:
+			state, power = sgReadyForce, c.ForcePower
+		case power > 0:
+			state, power = sgReadyBoost, c.Power
+		}
+
+		if state != c.state {
+			if err = c.setSGReadyS(state); err == nil {
+				c.state = state
+			}
+		}
+	}
+
+	if err != nil {
+		c.log.ERROR.Printf("%s: %v", c.Title, err)
+		return
+	}
+
+	if (power > 0) != c.on() {
+		c.log.DEBUG.Printf("%s: switched on: %v (surplus share %.0fW)", c.Title, power > 0, share)
+		c.switched = c.clock.Now()
+	}
+
+	c.updated = true
+	c.power = power
+}
+
+// consumerStatus is the consumer's published state
+type consumerStatus struct {
+	Title string  `json:"title"`
+	Power float64 `json:"power"`
+	On    bool    `json:"on"`
+	State int64   `json:"sgReady,omitempty"`
+}
+
+// status returns the consumer's published state
+func (c *Consumer) status() consumerStatus {
+	return consumerStatus{
+		Title: c.Title,
+		Power: c.power,
+		On:    c.on(),
+		State: c.state,
+	}
+}
@@ -0,0 +1,114 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/util"
+)
+
+func TestConsumerSwitch(t *testing.T) {
+	clck := clock.NewMock()
+
+	var enabled []bool
+	c := &Consumer{
+		log:        util.NewLogger("foo"),
+		clock:      clck,
+		Type:       consumerSwitch,
+		Power:      2000,
+		MinOnTime:  10 * time.Minute,
+		MinOffTime: 5 * time.Minute,
+		enableS: func(enable bool) error {
+			enabled = append(enabled, enable)
+			return nil
+		},
+	}
+
+	tc := []struct {
+		wait  time.Duration
+		share float64
+		on    bool
+	}{
+		{0, 1000, false},              // initial state
+		{0, 2500, true},               // surplus exceeds power
+		{time.Minute, 0, true},        // min on time
+		{10 * time.Minute, 0, false},  // min on time elapsed
+		{time.Minute, 3000, false},    // min off time
+		{5 * time.Minute, 3000, true}, // min off time elapsed
+	}
+
+	for _, tc := range tc {
+		clck.Add(tc.wait)
+		c.Update(tc.share)
+
+		if c.on() != tc.on {
+			t.Errorf("%+v: expected on %v, got %v", tc, tc.on, c.on())
+		}
+	}
+
+	if expect := []bool{false, true, false, true}; !reflect.DeepEqual(enabled, expect) {
+		t.Errorf("expected %v, got %v", expect, enabled)
+	}
+}
+
+func TestConsumerSGReady(t *testing.T) {
+	var state int64
+	c := &Consumer{
+		log:        util.NewLogger("foo"),
+		clock:      clock.NewMock(),
+		Type:       consumerSGReady,
+		Power:      1500,
+		ForcePower: 4000,
+		setSGReadyS: func(s int64) error {
+			state = s
+			return nil
+		},
+	}
+
+	for _, tc := range []struct {
+		share float64
+		state int64
+		power float64
+	}{
+		{1000, sgReadyNormal, 0},
+		{2000, sgReadyBoost, 1500},
+		{5000, sgReadyForce, 4000},
+		{0, sgReadyNormal, 0},
+	} {
+		c.Update(tc.share)
+
+		if state != tc.state || c.power != tc.power {
+			t.Errorf("%+v: expected state %d at %.0fW, got %d at %.0fW", tc, tc.state, tc.power, state, c.power)
+		}
+	}
+}
+
+func TestConsumerSurplusPriority(t *testing.T) {
+	consumer := func(priority int) *Consumer {
+		return &Consumer{
+			log:      util.NewLogger("foo"),
+			clock:    clock.NewMock(),
+			Type:     consumerModulating,
+			Priority: priority,
+			MinPower: 500,
+			MaxPower: 3000,
+			setPowerS: func(int64) error {
+				return nil
+			},
+		}
+	}
+
+	heater, rod := consumer(1), consumer(0)
+	site := &Site{
+		log:       util.NewLogger("foo"),
+		consumers: []*Consumer{heater, rod},
+	}
+
+	site.distributeSurplus(nil, nil, -4000)
+
+	if heater.power != 3000 || rod.power != 1000 {
+		t.Errorf("expected 3000W/1000W, got %.0fW/%.0fW", heater.power, rod.power)
+	}
+}
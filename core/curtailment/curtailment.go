@@ -0,0 +1,96 @@
+package curtailment
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Period is a single grid operator curtailment period
+type Period struct {
+	ID       uint      `json:"id" gorm:"primarykey"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`      // end, last update while active
+	Limit    float64   `json:"limit"`    // charge power limit (W)
+	MaxPower float64   `json:"maxPower"` // highest total charge power during the period (W)
+}
+
+// Update records the total charge power
+func (p *Period) Update(power float64) {
+	if power > p.MaxPower {
+		p.MaxPower = power
+	}
+}
+
+// Periods is a list of curtailment periods
+type Periods []Period
+
+var csvHeader = []string{"Start", "End", "Limit (W)", "Max Power (W)"}
+
+// WriteCsv writes periods as csv including header
+func (t Periods) WriteCsv(w io.Writer) error {
+	ww := csv.NewWriter(w)
+
+	if err := ww.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, p := range t {
+		if err := ww.Write(p.csvRecord()); err != nil {
+			return err
+		}
+	}
+
+	ww.Flush()
+
+	return ww.Error()
+}
+
+func (p Period) csvRecord() []string {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 0, 64)
+	}
+
+	return []string{
+		formatTime(p.Start),
+		formatTime(p.End),
+		formatFloat(p.Limit),
+		formatFloat(p.MaxPower),
+	}
+}
+
+// DB is a SQL database storage service for curtailment periods
+type DB struct {
+	db *gorm.DB
+}
+
+// NewStore creates a curtailment period store
+func NewStore(db *gorm.DB) (*DB, error) {
+	err := db.AutoMigrate(new(Period))
+
+	return &DB{
+		db: db,
+	}, err
+}
+
+// Persist creates or updates a period
+func (s *DB) Persist(period *Period) error {
+	return s.db.Save(period).Error
+}
+
+// All returns all curtailment periods ordered by start time
+func All(db *gorm.DB) (Periods, error) {
+	var res Periods
+	err := db.Order("start").Find(&res).Error
+	return res, err
+}
@@ -3,18 +3,21 @@ package core
 import (
 	"sync/atomic"
 	"time"
+
+	"github.com/benbjohnson/clock"
 )
 
 // Health is a health checker that needs regular updates to stay healthy
 type Health struct {
 	locker  uint32 // mutex
+	clock   clock.Clock
 	updated time.Time
 	timeout time.Duration
 }
 
 // NewHealth creates new health checker
 func NewHealth(timeout time.Duration) (health *Health) {
-	return &Health{timeout: timeout}
+	return &Health{clock: clock.New(), timeout: timeout}
 }
 
 // Healthy returns health status based on last update timestamp
@@ -28,7 +31,7 @@ func (health *Health) Healthy() bool {
 	for time.Since(start) < time.Second {
 		if atomic.CompareAndSwapUint32(&health.locker, 0, 1) {
 			defer atomic.StoreUint32(&health.locker, 0)
-			return time.Since(health.updated) < health.timeout
+			return health.clock.Since(health.updated) < health.timeout
 		}
 
 		time.Sleep(50 * time.Millisecond)
@@ -47,7 +50,7 @@ func (health *Health) Update() {
 
 	for time.Since(start) < time.Second {
 		if atomic.CompareAndSwapUint32(&health.locker, 0, 1) {
-			health.updated = time.Now()
+			health.updated = health.clock.Now()
 			atomic.StoreUint32(&health.locker, 0)
 			return
 		}
@@ -2,6 +2,7 @@ package core
 
 import (
 	"github.com/avast/retry-go/v3"
+	"github.com/evcc-io/evcc/util/metrics"
 )
 
 var (
@@ -15,6 +16,11 @@ var (
 	Voltage float64
 )
 
+// deviceRetryOptions returns the default retry options counting the device's retries
+func deviceRetryOptions(device string) []retry.Option {
+	return append([]retry.Option{metrics.OnRetry(device)}, retryOptions...)
+}
+
 // powerToCurrent is a helper function to convert power to per-phase current
 func powerToCurrent(power float64, phases int) float64 {
 	if Voltage == 0 {
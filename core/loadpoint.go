@@ -11,17 +11,22 @@ import (
 
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/core/session"
 	"github.com/evcc-io/evcc/core/soc"
 	"github.com/evcc-io/evcc/core/wrapper"
 	"github.com/evcc-io/evcc/provider"
 	"github.com/evcc-io/evcc/push"
+	"github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/metrics"
 	"github.com/thoas/go-funk"
 
 	evbus "github.com/asaskevich/EventBus"
 	"github.com/avast/retry-go/v3"
 	"github.com/benbjohnson/clock"
 	"github.com/cjrd/allocate"
+	"github.com/fatih/structs"
 )
 
 const (
@@ -85,6 +90,7 @@ type ThresholdConfig struct {
 // SoC needs and power availability.
 type LoadPoint struct {
 	clock    clock.Clock       // mockable time
+	settle   time.Duration     // meter settle time after applying settings
 	bus      evbus.Bus         // event bus
 	pushChan chan<- push.Event // notifications
 	uiChan   chan<- util.Param // client push messages
@@ -109,34 +115,54 @@ type LoadPoint struct {
 	OnIdentify_       interface{} `mapstructure:"onIdentify"`
 	Enable, Disable   ThresholdConfig
 	ResetOnDisconnect bool `mapstructure:"resetOnDisconnect"`
+	Authorization     bool `mapstructure:"authorization"` // Require whitelisted RFID token for charging
 	onDisconnect      api.ActionConfig
 
-	MinCurrent    float64       // PV mode: start current	Min+PV mode: min current
-	MaxCurrent    float64       // Max allowed current. Physically ensured by the charger
-	GuardDuration time.Duration // charger enable/disable minimum holding time
-
-	enabled                bool      // Charger enabled state
-	activePhases           int       // Charger active phases as used by vehicle
-	chargeCurrent          float64   // Charger current limit
-	guardUpdated           time.Time // Charger enabled/disabled timestamp
-	socUpdated             time.Time // SoC updated timestamp (poll: connected)
-	vehicleConnected       time.Time // Vehicle connected timestamp
+	Priority      int             // Higher priority loadpoints are served first by site power and current distribution
+	Plans         loadpoint.Plans // Recurring weekly target charge plans
+	MinCurrent    float64         // PV mode: start current	Min+PV mode: min current
+	MaxCurrent    float64         // Max allowed current. Physically ensured by the charger
+	GuardDuration time.Duration   // charger enable/disable minimum holding time
+
+	enabled                bool        // Charger enabled state
+	activePhases           int         // Charger active phases as used by vehicle
+	chargeCurrent          float64     // Charger current limit
+	currentLimit           float64     // Site load management current limit
+	currentLimited         bool        // Site load management current limit active
+	chargeWindow           *timeWindow // Allowed charging time window
+	profileApplied         bool        // Vehicle profile has been applied
+	planTime               time.Time   // Target time of last armed charge plan
+	authorized             bool        // RFID token authorized for current session
+	user                   string      // User of authorized token
+	guardUpdated           time.Time   // Charger enabled/disabled timestamp
+	socUpdated             time.Time   // SoC updated timestamp (poll: connected)
+	vehicleConnected       time.Time   // Vehicle connected timestamp
 	vehicleConnectedTicker *clock.Ticker
 	vehicleID              string
 
 	charger     api.Charger
 	chargeTimer api.ChargeTimer
 	chargeRater api.ChargeRater
-
-	chargeMeter  api.Meter     // Charger usage meter
-	vehicle      api.Vehicle   // Currently active vehicle
-	vehicles     []api.Vehicle // Assigned vehicles
+	chargerSubs []subscription // Event handlers of the charger's meter, rater and timer helpers
+
+	chargeMeter  api.Meter              // Charger usage meter
+	vehicle      api.Vehicle            // Currently active vehicle
+	selected     api.Vehicle            // Vehicle selected via api, activated on next update
+	vehicles     []api.Vehicle          // Assigned vehicles
+	tokens       rfid.Tokens            // Authorized RFID tokens
+	tokenVehicle map[string]api.Vehicle // Vehicles of authorized RFID tokens
 	socEstimator *soc.Estimator
 	socTimer     *soc.Timer
+	forecast     api.Forecast // Predicted pv surplus
+
+	db      *session.DB      // Charging session history
+	session *session.Session // Current charging session
+	plans   *planStore       // Charge plans set at runtime
 
 	// cached state
 	status         api.ChargeStatus       // Charger status
 	remoteDemand   loadpoint.RemoteDemand // External status demand
+	remoteLimit    float64                // External max current limit, zero if unlimited
 	chargePower    float64                // Charging power
 	chargeCurrents []float64              // Phase currents
 	connectedTime  time.Time              // Time when vehicle was connected
@@ -148,6 +174,7 @@ type LoadPoint struct {
 	vehicleSoc              float64       // Vehicle SoC
 	chargeDuration          time.Duration // Charge duration
 	chargedEnergy           float64       // Charged energy while connected in Wh
+	chargeTotalImport       *float64      // Charge meter energy register in kWh, nil if unavailable
 	chargeRemainingDuration time.Duration // Remaining charge duration
 	chargeRemainingEnergy   float64       // Remaining charge energy in Wh
 	progress                *Progress     // Step-wise progress indicator
@@ -176,6 +203,10 @@ func NewLoadPointFromConfig(log *util.Logger, cp configProvider, other map[strin
 		lp.log.WARN.Printf("loadpoint.onIdentify is deprecated and will be removed in a future release. Use vehicle.onIdentify instead.")
 	}
 
+	if err := lp.Plans.Validate(); err != nil {
+		return nil, fmt.Errorf("plans: %w", err)
+	}
+
 	if lp.OnDisconnect_ != nil {
 		lp.log.WARN.Printf("loadpoint.onDisconnect is deprecated and will be removed in a future release. Use loadpoint.resetOnDisconnect instead.")
 	}
@@ -197,8 +228,24 @@ func NewLoadPointFromConfig(log *util.Logger, cp configProvider, other map[strin
 		lp.log.WARN.Println("maxCurrent must be larger than minCurrent")
 	}
 
-	// store defaults
-	lp.collectDefaults()
+	// charging session history
+	if db.Instance != nil {
+		var err error
+		if lp.db, err = session.NewStore(lp.Title, db.Instance); err != nil {
+			return nil, fmt.Errorf("session history: %w", err)
+		}
+
+		if lp.plans, err = newPlanStore(lp.Title, db.Instance); err != nil {
+			return nil, fmt.Errorf("charge plans: %w", err)
+		}
+
+		// plans set at runtime take precedence
+		if plans, ok, err := lp.plans.Load(); err != nil {
+			lp.log.ERROR.Printf("charge plans: %v", err)
+		} else if ok {
+			lp.Plans = plans
+		}
+	}
 
 	if lp.MeterRef != "" {
 		lp.chargeMeter = cp.Meter(lp.MeterRef)
@@ -209,6 +256,7 @@ func NewLoadPointFromConfig(log *util.Logger, cp configProvider, other map[strin
 		lp.log.WARN.Println("meters: charge: is deprecated. Use meter: instead")
 		if lp.chargeMeter == nil {
 			lp.chargeMeter = cp.Meter(lp.Meters.ChargeMeterRef)
+			lp.MeterRef = lp.Meters.ChargeMeterRef
 		} else {
 			lp.log.ERROR.Println("must not have meter: and meters: charge: both")
 		}
@@ -229,6 +277,21 @@ func NewLoadPointFromConfig(log *util.Logger, cp configProvider, other map[strin
 		lp.vehicles = append(lp.vehicles, vehicle)
 	}
 
+	// rfid whitelist
+	if lp.Authorization {
+		lp.tokens = cp.Tokens()
+		if len(lp.tokens) == 0 {
+			return nil, errors.New("authorization requires tokens")
+		}
+
+		lp.tokenVehicle = make(map[string]api.Vehicle)
+		for _, token := range lp.tokens {
+			if token.Vehicle != "" {
+				lp.tokenVehicle[token.ID] = cp.Vehicle(token.Vehicle)
+			}
+		}
+	}
+
 	if lp.ChargerRef == "" {
 		return nil, errors.New("missing charger")
 	}
@@ -241,6 +304,9 @@ func NewLoadPointFromConfig(log *util.Logger, cp configProvider, other map[strin
 		lp.setPhases(0)
 	}
 
+	// store defaults
+	lp.collectDefaults()
+
 	// allow target charge handler to access loadpoint
 	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
 	if lp.Enable.Threshold > lp.Disable.Threshold {
@@ -260,7 +326,8 @@ func NewLoadPoint(log *util.Logger) *LoadPoint {
 	lp := &LoadPoint{
 		log:           log,   // logger
 		clock:         clock, // mockable time
-		bus:           bus,   // event bus
+		settle:        settleDuration,
+		bus:           bus, // event bus
 		Mode:          api.ModeOff,
 		Phases:        3,
 		status:        api.StatusNone,
@@ -274,6 +341,18 @@ func NewLoadPoint(log *util.Logger) *LoadPoint {
 	return lp
 }
 
+// SetClock replaces the loadpoint's clock, e.g. for running on simulated time.
+// Meters are expected to settle immediately on simulated time.
+func (lp *LoadPoint) SetClock(clock clock.Clock) {
+	lp.clock = clock
+	lp.settle = 0
+	lp.socTimer.SetClock(clock)
+
+	if lp.wakeUpTimer != nil {
+		lp.wakeUpTimer.clck = clock
+	}
+}
+
 // collectDefaults collects default values for use on disconnect
 func (lp *LoadPoint) collectDefaults() {
 	// get reference to action config
@@ -287,6 +366,12 @@ func (lp *LoadPoint) collectDefaults() {
 		*actionCfg.MaxCurrent = lp.GetMaxCurrent()
 		*actionCfg.MinSoC = lp.GetMinSoC()
 		*actionCfg.TargetSoC = lp.GetTargetSoC()
+		*actionCfg.Phases = lp.GetPhases()
+
+		// switchable chargers scale phases automatically, don't restore
+		if _, ok := lp.charger.(api.ChargePhases); ok {
+			actionCfg.Phases = nil
+		}
 	} else {
 		lp.log.ERROR.Printf("error allocating action config: %v", err)
 	}
@@ -312,8 +397,8 @@ func (lp *LoadPoint) configureChargerType(charger api.Charger) {
 			lp.chargeMeter = mt
 		} else {
 			mt := new(wrapper.ChargeMeter)
-			_ = lp.bus.Subscribe(evChargeCurrent, lp.evChargeCurrentWrappedMeterHandler)
-			_ = lp.bus.Subscribe(evChargeStop, func() { mt.SetPower(0) })
+			lp.subscribe(evChargeCurrent, lp.evChargeCurrentWrappedMeterHandler)
+			lp.subscribe(evChargeStop, func() { mt.SetPower(0) })
 			lp.chargeMeter = mt
 		}
 	}
@@ -325,10 +410,10 @@ func (lp *LoadPoint) configureChargerType(charger api.Charger) {
 		lp.chargeRater = rt
 	} else {
 		rt := wrapper.NewChargeRater(lp.log, lp.chargeMeter)
-		_ = lp.bus.Subscribe(evChargePower, rt.SetChargePower)
-		_ = lp.bus.Subscribe(evVehicleConnect, func() { rt.StartCharge(false) })
-		_ = lp.bus.Subscribe(evChargeStart, func() { rt.StartCharge(true) })
-		_ = lp.bus.Subscribe(evChargeStop, rt.StopCharge)
+		lp.subscribe(evChargePower, rt.SetChargePower)
+		lp.subscribe(evVehicleConnect, func() { rt.StartCharge(false) })
+		lp.subscribe(evChargeStart, func() { rt.StartCharge(true) })
+		lp.subscribe(evChargeStop, rt.StopCharge)
 		lp.chargeRater = rt
 	}
 
@@ -337,9 +422,9 @@ func (lp *LoadPoint) configureChargerType(charger api.Charger) {
 		lp.chargeTimer = ct
 	} else {
 		ct := wrapper.NewChargeTimer()
-		_ = lp.bus.Subscribe(evVehicleConnect, func() { ct.StartCharge(false) })
-		_ = lp.bus.Subscribe(evChargeStart, func() { ct.StartCharge(true) })
-		_ = lp.bus.Subscribe(evChargeStop, ct.StopCharge)
+		lp.subscribe(evVehicleConnect, func() { ct.StartCharge(false) })
+		lp.subscribe(evChargeStart, func() { ct.StartCharge(true) })
+		lp.subscribe(evChargeStop, ct.StopCharge)
 		lp.chargeTimer = ct
 	}
 
@@ -368,6 +453,9 @@ func (lp *LoadPoint) evChargeStartHandler() {
 
 	// soc update reset
 	lp.socUpdated = time.Time{}
+
+	// charging session when vehicle was connected at startup
+	lp.createSession()
 }
 
 // evChargeStopHandler sends external stop event
@@ -375,6 +463,8 @@ func (lp *LoadPoint) evChargeStopHandler() {
 	lp.log.INFO.Println("stop charging <-")
 	lp.pushEvent(evChargeStop)
 
+	lp.persistSession()
+
 	// soc update reset
 	lp.socUpdated = time.Time{}
 
@@ -390,7 +480,9 @@ func (lp *LoadPoint) evVehicleConnectHandler() {
 	lp.log.INFO.Printf("car connected")
 
 	// energy
+	lp.Lock()
 	lp.chargedEnergy = 0
+	lp.Unlock()
 	lp.publish("chargedEnergy", lp.chargedEnergy)
 
 	// duration
@@ -417,6 +509,13 @@ func (lp *LoadPoint) evVehicleConnectHandler() {
 	// immediately allow pv mode activity
 	lp.elapsePVTimer()
 
+	// arm charge plans for the new session
+	lp.Lock()
+	lp.planTime = time.Time{}
+	lp.Unlock()
+
+	lp.createSession()
+
 	lp.pushEvent(evVehicleConnect)
 }
 
@@ -428,8 +527,17 @@ func (lp *LoadPoint) evVehicleDisconnectHandler() {
 	lp.publish("chargedEnergy", lp.chargedEnergy)
 	lp.publish("connectedDuration", lp.clock.Since(lp.connectedTime))
 
+	// authorization ends with the session
+	lp.setAuthorized(false, "")
+
 	lp.pushEvent(evVehicleDisconnect)
 
+	lp.stopSession()
+
+	// identify and authorize again on next connect
+	lp.vehicleID = ""
+	lp.publish("vehicleIdentity", "")
+
 	// remove active vehicle if we have multiple vehicles
 	if len(lp.vehicles) > 1 {
 		lp.setActiveVehicle(nil)
@@ -443,9 +551,10 @@ func (lp *LoadPoint) evVehicleDisconnectHandler() {
 		}
 	}
 
-	// set default mode on disconnect
-	if lp.ResetOnDisconnect {
+	// set default mode on disconnect, always restore defaults if vehicle profile was applied
+	if lp.ResetOnDisconnect || lp.profileApplied {
 		lp.applyAction(lp.onDisconnect)
+		lp.profileApplied = false
 	}
 
 	// soc update reset
@@ -504,6 +613,94 @@ func (lp *LoadPoint) applyAction(actionCfg api.ActionConfig) {
 	if actionCfg.TargetSoC != nil {
 		lp.SetTargetSoC(*actionCfg.TargetSoC)
 	}
+	if actionCfg.Phases != nil && *actionCfg.Phases != lp.GetPhases() {
+		if err := lp.SetPhases(*actionCfg.Phases); err != nil {
+			lp.log.ERROR.Printf("phases: %v", err)
+		}
+	}
+	if actionCfg.Window != nil {
+		lp.setChargeWindow(*actionCfg.Window)
+	}
+	if actionCfg.TargetTime != nil && *actionCfg.TargetTime != "" {
+		if offset, err := parseTimeOfDay(*actionCfg.TargetTime); err == nil {
+			lp.SetTargetCharge(nextTimeOfDay(lp.clock.Now(), offset), lp.GetTargetSoC())
+		} else {
+			lp.log.ERROR.Printf("target time: %v", err)
+		}
+	}
+}
+
+// applyProfile applies the vehicle profile, replacing any previously applied profile
+func (lp *LoadPoint) applyProfile(actionCfg api.ActionConfig) {
+	if lp.profileApplied {
+		lp.applyAction(lp.onDisconnect)
+	}
+
+	lp.applyAction(actionCfg)
+	lp.profileApplied = !structs.IsZero(actionCfg)
+}
+
+// setChargeWindow restricts charging to the given time window, empty window removes the restriction
+func (lp *LoadPoint) setChargeWindow(window string) {
+	var tw *timeWindow
+	if window != "" {
+		var err error
+		if tw, err = parseTimeWindow(window); err != nil {
+			lp.log.ERROR.Printf("charge window: %v", err)
+			return
+		}
+	}
+
+	lp.Lock()
+	defer lp.Unlock()
+
+	lp.chargeWindow = tw
+	lp.publish("chargeWindow", window)
+}
+
+// armPlan sets the target charge from the next recurring plan unless a target has been set otherwise.
+// Plans are armed once per connection and re-armed after the armed plan's target time has passed.
+func (lp *LoadPoint) armPlan() {
+	lp.Lock()
+	plans, planTime := lp.Plans, lp.planTime
+	var target time.Time
+	if lp.socTimer != nil {
+		target = lp.socTimer.Time
+	}
+	lp.Unlock()
+
+	now := lp.clock.Now()
+
+	// plan armed and not yet due, or target set otherwise
+	if len(plans) == 0 || !planTime.IsZero() && now.Before(planTime) || !target.IsZero() && !target.Equal(planTime) {
+		return
+	}
+
+	next, soc := plans.Next(now)
+	if next.IsZero() {
+		return
+	}
+
+	lp.Lock()
+	lp.planTime = next
+	lp.Unlock()
+
+	if lp.vehicle != nil && lp.vehicleSoc >= float64(soc) {
+		lp.log.DEBUG.Printf("charge plan: %d%% @ %v already reached", soc, next.Round(time.Second))
+		return
+	}
+
+	lp.log.DEBUG.Printf("charge plan: %d%% @ %v", soc, next.Round(time.Second))
+
+	lp.SetTargetCharge(next, soc)
+}
+
+// chargeWindowClosed returns true if charging is restricted to a time window and outside of it
+func (lp *LoadPoint) chargeWindowClosed() bool {
+	lp.Lock()
+	defer lp.Unlock()
+
+	return lp.chargeWindow != nil && !lp.chargeWindow.Contains(lp.clock.Now())
 }
 
 // Name returns the human-readable loadpoint title
@@ -533,8 +730,13 @@ func (lp *LoadPoint) Prepare(uiChan chan<- util.Param, pushChan chan<- push.Even
 	lp.publish("minCurrent", lp.MinCurrent)
 	lp.publish("maxCurrent", lp.MaxCurrent)
 	lp.publish("phases", lp.Phases)
+	lp.publish("priority", lp.Priority)
+	lp.publish("plans", lp.Plans)
 	lp.publish("activePhases", lp.activePhases)
 	lp.publish("hasVehicle", len(lp.vehicles) > 0)
+	lp.publish("authorization", lp.Authorization)
+	lp.publish("authorized", lp.authorized)
+	lp.publish("user", lp.user)
 
 	lp.Lock()
 	lp.publish("mode", lp.Mode)
@@ -588,8 +790,119 @@ func (lp *LoadPoint) syncCharger() {
 	}
 }
 
+// setCurrentLimit sets the maximum current assigned by site load management
+func (lp *LoadPoint) setCurrentLimit(current float64) {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if !lp.currentLimited || lp.currentLimit != current {
+		lp.log.DEBUG.Printf("site current limit: %.3gA", current)
+		lp.publish("currentLimit", current)
+	}
+
+	lp.currentLimit = current
+	lp.currentLimited = true
+}
+
+// clearCurrentLimit removes the maximum current assigned by site load management
+func (lp *LoadPoint) clearCurrentLimit() {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.currentLimited {
+		lp.log.DEBUG.Println("site current limit: none")
+		lp.currentLimited = false
+	}
+}
+
+// enforceCurrentLimit reduces the charge current outside the regular update cycle
+// if it exceeds the limit assigned by site load management
+func (lp *LoadPoint) enforceCurrentLimit() {
+	if limit, ok := lp.siteCurrentLimit(); ok && lp.enabled && lp.chargeCurrent > limit {
+		if err := lp.setLimit(lp.chargeCurrent, true); err != nil {
+			lp.log.ERROR.Println(err)
+		}
+	}
+}
+
+// siteCurrentLimit returns the maximum current assigned by site load management
+func (lp *LoadPoint) siteCurrentLimit() (float64, bool) {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.currentLimit, lp.currentLimited
+}
+
+// effectiveMaxCurrent returns the max current capped by the remote current limit
+func (lp *LoadPoint) effectiveMaxCurrent() float64 {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.remoteLimit > 0 && lp.remoteLimit < lp.MaxCurrent {
+		return lp.remoteLimit
+	}
+
+	return lp.MaxCurrent
+}
+
+// currentPhases returns the number of phases the loadpoint is expected to use
+func (lp *LoadPoint) currentPhases() int {
+	if lp.activePhases > 0 {
+		return lp.activePhases
+	}
+
+	if phases := lp.GetPhases(); phases > 0 {
+		return phases
+	}
+
+	// unknown phase state of switchable charger
+	return 3
+}
+
+// phaseCurrents returns the loadpoint's per-phase currents, either measured or estimated from charge power
+func (lp *LoadPoint) phaseCurrents(chargePower float64) phaseCurrents {
+	var res phaseCurrents
+
+	if len(lp.chargeCurrents) == len(res) {
+		copy(res[:], lp.chargeCurrents)
+		return res
+	}
+
+	phases := lp.currentPhases()
+	for p := 0; p < phases && p < len(res); p++ {
+		res[p] = powerToCurrent(chargePower, phases)
+	}
+
+	return res
+}
+
+// pvPowerRange returns the loadpoint's min and max charge power for pv surplus distribution
+func (lp *LoadPoint) pvPowerRange() (float64, float64) {
+	minPhases, maxPhases := lp.currentPhases(), lp.currentPhases()
+	if _, ok := lp.charger.(api.ChargePhases); ok {
+		minPhases, maxPhases = 1, 3
+	}
+
+	maxCurrent := lp.effectiveMaxCurrent()
+	if limit, ok := lp.siteCurrentLimit(); ok {
+		maxCurrent = math.Min(maxCurrent, limit)
+	}
+
+	return lp.GetMinCurrent() * float64(minPhases) * Voltage, maxCurrent * float64(maxPhases) * Voltage
+}
+
 // setLimit applies charger current limits and enables/disables accordingly
 func (lp *LoadPoint) setLimit(chargeCurrent float64, force bool) error {
+	// cap at site load management limit
+	if limit, ok := lp.siteCurrentLimit(); ok && chargeCurrent > limit {
+		lp.log.DEBUG.Printf("charge current %.3gA limited to %.3gA by site", chargeCurrent, limit)
+		chargeCurrent = limit
+
+		// protect main fuse regardless of contactor delay
+		if chargeCurrent < lp.GetMinCurrent() {
+			force = true
+		}
+	}
+
 	// set current
 	if chargeCurrent != lp.chargeCurrent && chargeCurrent >= lp.GetMinCurrent() {
 		var err error
@@ -663,6 +976,19 @@ func (lp *LoadPoint) connected() bool {
 	return status == api.StatusB || status == api.StatusC
 }
 
+// chargerConnected reads the EVs connection state from the charger without handling status changes
+func (lp *LoadPoint) chargerConnected() bool {
+	var status api.ChargeStatus
+	if err := metrics.Observe(lp.ChargerRef, func() (err error) {
+		status, err = lp.charger.Status()
+		return err
+	})(); err != nil {
+		return lp.connected()
+	}
+
+	return status == api.StatusB || status == api.StatusC
+}
+
 // charging returns the EVs charging state
 func (lp *LoadPoint) charging() bool {
 	return lp.GetStatus() == api.StatusC
@@ -745,6 +1071,10 @@ func (lp *LoadPoint) identifyVehicle() {
 	}
 
 	if lp.vehicleID == id {
+		// retry failed authorization of whitelisted token while pending
+		if _, ok := lp.tokens.Lookup(id); ok && lp.authorizationPending() {
+			lp.activateIdentifiedVehicle(id)
+		}
 		return
 	}
 
@@ -754,11 +1084,30 @@ func (lp *LoadPoint) identifyVehicle() {
 	lp.log.DEBUG.Println("charger vehicle id:", id)
 	lp.publish("vehicleIdentity", id)
 
+	lp.updateSessionVehicle()
+
 	if id != "" {
-		if vehicle := lp.selectVehicleByID(id); vehicle != nil {
+		lp.activateIdentifiedVehicle(id)
+	}
+}
+
+// activateIdentifiedVehicle authorizes the identified token if required and activates the associated vehicle
+func (lp *LoadPoint) activateIdentifiedVehicle(id string) {
+	if lp.Authorization {
+		token, ok := lp.authorize(id)
+		if !ok {
+			return
+		}
+
+		if vehicle, ok := lp.tokenVehicle[token.ID]; ok {
 			lp.setActiveVehicle(vehicle)
+			return
 		}
 	}
+
+	if vehicle := lp.selectVehicleByID(id); vehicle != nil {
+		lp.setActiveVehicle(vehicle)
+	}
 }
 
 // selectVehicleByID selects the vehicle with the given ID
@@ -788,6 +1137,33 @@ func (lp *LoadPoint) selectVehicleByID(id string) api.Vehicle {
 	return nil
 }
 
+// selectVehicleByName selects the vehicle by its configured name or title
+func (lp *LoadPoint) selectVehicleByName(name string) api.Vehicle {
+	refs := lp.VehiclesRef
+	if lp.VehicleRef != "" {
+		refs = []string{lp.VehicleRef}
+	}
+
+	for i, vehicle := range lp.vehicles {
+		if i < len(refs) && refs[i] == name || strings.EqualFold(vehicle.Title(), name) {
+			return vehicle
+		}
+	}
+
+	return nil
+}
+
+// selectedVehicle returns and clears the vehicle selected via api
+func (lp *LoadPoint) selectedVehicle() api.Vehicle {
+	lp.Lock()
+	defer lp.Unlock()
+
+	vehicle := lp.selected
+	lp.selected = nil
+
+	return vehicle
+}
+
 // setActiveVehicle assigns currently active vehicle and configures soc estimator
 func (lp *LoadPoint) setActiveVehicle(vehicle api.Vehicle) {
 	if lp.vehicle == vehicle {
@@ -813,11 +1189,13 @@ func (lp *LoadPoint) setActiveVehicle(vehicle api.Vehicle) {
 		lp.publish("vehicleTitle", lp.vehicle.Title())
 		lp.publish("vehicleCapacity", lp.vehicle.Capacity())
 
-		lp.applyAction(vehicle.OnIdentified())
+		lp.applyProfile(vehicle.OnIdentified())
 
 		lp.setVehiclePhases()
 
 		lp.progress.Reset()
+
+		lp.updateSessionVehicle()
 	} else {
 		lp.socEstimator = nil
 
@@ -902,8 +1280,11 @@ func (lp *LoadPoint) identifyVehicleByStatus() {
 
 // updateChargerStatus updates charger status and detects car connected/disconnected events
 func (lp *LoadPoint) updateChargerStatus() error {
-	status, err := lp.charger.Status()
-	if err != nil {
+	var status api.ChargeStatus
+	if err := metrics.Observe(lp.ChargerRef, func() (err error) {
+		status, err = lp.charger.Status()
+		return err
+	})(); err != nil {
 		return err
 	}
 
@@ -1178,11 +1559,36 @@ func (lp *LoadPoint) publishTimer(name string, delay time.Duration, action strin
 	}
 }
 
+// surplusPredicted returns false if the forecast predicts less than the required pv surplus during the enable delay
+func (lp *LoadPoint) surplusPredicted(power float64) bool {
+	if lp.forecast == nil {
+		return true
+	}
+
+	slots, err := lp.forecast.Forecast()
+	if err != nil {
+		lp.log.WARN.Printf("forecast: %v", err)
+		return true
+	}
+
+	now := lp.clock.Now()
+	end := now.Add(lp.Enable.Delay)
+
+	for _, slot := range slots {
+		if !slot.Start.After(end) && slot.End.After(now) && slot.Power < power {
+			lp.log.DEBUG.Printf("predicted surplus %.0fW < min power %.0fW", slot.Power, power)
+			return false
+		}
+	}
+
+	return true
+}
+
 // pvMaxCurrent calculates the maximum target current for PV mode
 func (lp *LoadPoint) pvMaxCurrent(mode api.ChargeMode, sitePower float64, batteryBuffered bool) float64 {
 	// read only once to simplify testing
 	minCurrent := lp.GetMinCurrent()
-	maxCurrent := lp.GetMaxCurrent()
+	maxCurrent := lp.effectiveMaxCurrent()
 
 	// switch phases up/down
 	if _, ok := lp.charger.(api.ChargePhases); ok {
@@ -1238,9 +1644,10 @@ func (lp *LoadPoint) pvMaxCurrent(mode api.ChargeMode, sitePower float64, batter
 	}
 
 	if mode == api.ModePV && !lp.enabled {
-		// kick off enable sequence
-		if (lp.Enable.Threshold == 0 && targetCurrent >= minCurrent) ||
-			(lp.Enable.Threshold != 0 && sitePower <= lp.Enable.Threshold) {
+		// kick off enable sequence unless predicted surplus won't last
+		if ((lp.Enable.Threshold == 0 && targetCurrent >= minCurrent) ||
+			(lp.Enable.Threshold != 0 && sitePower <= lp.Enable.Threshold)) &&
+			lp.surplusPredicted(minCurrent*float64(lp.activePhases)*Voltage) {
 			lp.log.DEBUG.Printf("site power %.0fW < enable threshold %.0fW", sitePower, lp.Enable.Threshold)
 
 			if lp.pvTimer.IsZero() {
@@ -1280,7 +1687,9 @@ func (lp *LoadPoint) pvMaxCurrent(mode api.ChargeMode, sitePower float64, batter
 
 // updateChargePower updates charge meter power
 func (lp *LoadPoint) updateChargePower() {
-	err := retry.Do(func() error {
+	device := lp.chargeMeterRef()
+
+	err := retry.Do(metrics.Observe(device, func() error {
 		value, err := lp.chargeMeter.CurrentPower()
 		if err != nil {
 			return err
@@ -1299,13 +1708,48 @@ func (lp *LoadPoint) updateChargePower() {
 		}
 
 		return nil
-	}, retryOptions...)
+	}), deviceRetryOptions(device)...)
 
 	if err != nil {
 		lp.log.ERROR.Printf("charge meter: %v", err)
 	}
 }
 
+// publishDevices publishes the values read from the loadpoint's charger and charge meter
+func (lp *LoadPoint) publishDevices() {
+	res := make(deviceValues)
+
+	setDeviceValue(res, "charger", lp.ChargerRef, "status", lp.status)
+	setDeviceValue(res, "charger", lp.ChargerRef, "enabled", lp.enabled)
+
+	// estimated power is no meter value
+	if _, ok := lp.chargeMeter.(*wrapper.ChargeMeter); !ok {
+		class, name := "meter", lp.MeterRef
+		if name == "" {
+			class, name = "charger", lp.ChargerRef
+		}
+
+		setDeviceValue(res, class, name, "power", lp.chargePower)
+		if lp.chargeCurrents != nil {
+			setDeviceValue(res, class, name, "currents", lp.chargeCurrents)
+		}
+		if lp.chargeTotalImport != nil {
+			setDeviceValue(res, class, name, "energy", *lp.chargeTotalImport)
+		}
+	}
+
+	lp.publish("devices", res)
+}
+
+// chargeMeterRef returns the charge meter's reference, the charger's if integrated
+func (lp *LoadPoint) chargeMeterRef() string {
+	if lp.MeterRef != "" {
+		return lp.MeterRef
+	}
+
+	return lp.ChargerRef
+}
+
 // updateChargeCurrents uses MeterCurrent interface to count phases with current >=1A
 func (lp *LoadPoint) updateChargeCurrents() {
 	lp.chargeCurrents = nil
@@ -1344,11 +1788,21 @@ func (lp *LoadPoint) updateChargeCurrents() {
 // publish charged energy and duration
 func (lp *LoadPoint) publishChargeProgress() {
 	if f, err := lp.chargeRater.ChargedEnergy(); err == nil {
+		lp.Lock()
 		lp.chargedEnergy = 1e3 * f // convert to Wh
+		lp.Unlock()
 	} else {
 		lp.log.ERROR.Printf("charge rater: %v", err)
 	}
 
+	if f := lp.chargeMeterTotal(); f != nil {
+		lp.Lock()
+		lp.chargeTotalImport = f
+		lp.Unlock()
+
+		lp.publish("chargeTotalImport", *f)
+	}
+
 	if d, err := lp.chargeTimer.ChargingTime(); err == nil {
 		lp.chargeDuration = d.Round(time.Second)
 	} else {
@@ -1463,6 +1917,13 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 	lp.publish("charging", lp.charging())
 	lp.publish("enabled", lp.enabled)
 
+	lp.publishDevices()
+
+	// activate vehicle selected via api
+	if vehicle := lp.selectedVehicle(); vehicle != nil {
+		lp.setActiveVehicle(vehicle)
+	}
+
 	// identify connected vehicle
 	if lp.connected() {
 		// read identity and run associated action
@@ -1474,6 +1935,11 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 		}
 	}
 
+	// re-arm recurring charge plans
+	if lp.connected() {
+		lp.armPlan()
+	}
+
 	// publish soc after updating charger status to make sure
 	// initial update of connected state matches charger status
 	lp.publishSoCAndRange()
@@ -1497,6 +1963,10 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 		// https://github.com/evcc-io/evcc/issues/105
 		err = lp.setLimit(0, false)
 
+	case lp.authorizationPending():
+		lp.log.DEBUG.Println("waiting for authorization")
+		err = lp.setLimit(0, true)
+
 	case lp.targetSocReached():
 		lp.log.DEBUG.Printf("targetSoC reached: %.1f > %d", lp.vehicleSoc, lp.SoC.Target)
 		var targetCurrent float64 // zero disables
@@ -1515,17 +1985,21 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 	case mode == api.ModeOff:
 		err = lp.setLimit(0, true)
 
+	case lp.chargeWindowClosed():
+		lp.log.DEBUG.Println("outside charge window")
+		err = lp.setLimit(0, true)
+
 	case lp.minSocNotReached():
 		// 3p if available
 		if err = lp.scalePhasesIfAvailable(3); err == nil {
-			err = lp.setLimit(lp.GetMaxCurrent(), true)
+			err = lp.setLimit(lp.effectiveMaxCurrent(), true)
 		}
 		lp.elapsePVTimer() // let PV mode disable immediately afterwards
 
 	case mode == api.ModeNow:
 		// 3p if available
 		if err = lp.scalePhasesIfAvailable(3); err == nil {
-			err = lp.setLimit(lp.GetMaxCurrent(), true)
+			err = lp.setLimit(lp.effectiveMaxCurrent(), true)
 		}
 
 	// target charging
@@ -1548,7 +2022,7 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 
 		// tariff
 		if cheap {
-			targetCurrent = lp.GetMaxCurrent()
+			targetCurrent = lp.effectiveMaxCurrent()
 			lp.log.DEBUG.Printf("cheap tariff: %.3gA", targetCurrent)
 			required = true
 		}
@@ -1581,7 +2055,7 @@ func (lp *LoadPoint) Update(sitePower float64, cheap bool, batteryBuffered bool)
 
 	// read and publish meters after settings are applied
 	if err == nil {
-		time.Sleep(settleDuration)
+		time.Sleep(lp.settle)
 		lp.updateChargePower()
 		lp.updateChargeCurrents()
 	} else {
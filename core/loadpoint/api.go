@@ -33,6 +33,10 @@ type API interface {
 	GetMode() api.ChargeMode
 	// SetMode sets the charge mode
 	SetMode(api.ChargeMode)
+	// GetPriority returns the priority
+	GetPriority() int
+	// SetPriority sets the priority
+	SetPriority(int)
 	// GetTargetSoC returns the charge target soc
 	GetTargetSoC() int
 	// SetTargetSoC sets the charge target soc
@@ -48,8 +52,20 @@ type API interface {
 
 	// SetTargetCharge sets the charge targetSoC
 	SetTargetCharge(time.Time, int)
+	// GetTargetTime returns the target charge finish time, zero if not set
+	GetTargetTime() time.Time
+	// GetPlans returns the recurring target charge plans
+	GetPlans() Plans
+	// SetPlans sets the recurring target charge plans
+	SetPlans(Plans) error
 	// RemoteControl sets remote status demand
 	RemoteControl(string, RemoteDemand)
+	// RemoteCurrentLimit caps the max current by a remote limit, zero removes the limit
+	RemoteCurrentLimit(string, float64)
+	// HasVehicle returns true if the vehicle can be selected by its configured name or title
+	HasVehicle(string) bool
+	// SetVehicle selects the active vehicle by its configured name or title
+	SetVehicle(string) error
 
 	//
 	// power and energy
@@ -57,6 +73,10 @@ type API interface {
 
 	// GetChargePower returns the current charging power
 	GetChargePower() float64
+	// GetChargedEnergy returns the energy charged while connected in Wh
+	GetChargedEnergy() float64
+	// GetChargeTotalImport returns the charge meter's energy register in kWh if available
+	GetChargeTotalImport() (float64, bool)
 	// GetMinCurrent returns the min charging current
 	GetMinCurrent() float64
 	// SetMinCurrent sets the min charging current
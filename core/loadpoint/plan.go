@@ -0,0 +1,105 @@
+package loadpoint
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Plan is a recurring weekly target charge plan
+type Plan struct {
+	Days []string `json:"days"` // Weekdays (mon, tue, ...), empty for every day
+	Time string   `json:"time"` // Time of day (hh:mm)
+	SoC  int      `json:"soc"`  // Target SoC
+}
+
+// Validate checks the plan for valid days, time and soc
+func (p Plan) Validate() error {
+	for _, day := range p.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day: %s", day)
+		}
+	}
+
+	if _, err := time.Parse("15:04", p.Time); err != nil {
+		return fmt.Errorf("invalid time: %s", p.Time)
+	}
+
+	if p.SoC <= 0 || p.SoC > 100 {
+		return fmt.Errorf("invalid soc: %d", p.SoC)
+	}
+
+	return nil
+}
+
+// Next returns the plan's next target time after t
+func (p Plan) Next(t time.Time) time.Time {
+	tod, err := time.Parse("15:04", p.Time)
+	if err != nil {
+		return time.Time{}
+	}
+
+	y, m, d := t.Date()
+	for i := 0; i <= 7; i++ {
+		next := time.Date(y, m, d+i, tod.Hour(), tod.Minute(), 0, 0, t.Location())
+		if next.After(t) && p.active(next.Weekday()) {
+			return next
+		}
+	}
+
+	return time.Time{}
+}
+
+func (p Plan) active(weekday time.Weekday) bool {
+	if len(p.Days) == 0 {
+		return true
+	}
+
+	for _, day := range p.Days {
+		if weekdays[strings.ToLower(day)] == weekday {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Plans is a list of plans
+type Plans []Plan
+
+// Validate checks all plans
+func (p Plans) Validate() error {
+	for _, plan := range p {
+		if err := plan.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Next returns the earliest next target time and soc of all plans after t
+func (p Plans) Next(t time.Time) (time.Time, int) {
+	var (
+		res time.Time
+		soc int
+	)
+
+	for _, plan := range p {
+		if next := plan.Next(t); !next.IsZero() && (res.IsZero() || next.Before(res)) {
+			res, soc = next, plan.SoC
+		}
+	}
+
+	return res, soc
+}
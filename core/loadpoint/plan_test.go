@@ -0,0 +1,53 @@
+package loadpoint
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlansNext(t *testing.T) {
+	plans := Plans{
+		{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Time: "07:00", SoC: 80},
+		{Days: []string{"sat"}, Time: "10:00", SoC: 60},
+	}
+
+	if err := plans.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2022-03-07 is a Monday
+	tc := []struct {
+		now  time.Time
+		next time.Time
+		soc  int
+	}{
+		{time.Date(2022, 3, 7, 6, 0, 0, 0, time.UTC), time.Date(2022, 3, 7, 7, 0, 0, 0, time.UTC), 80},
+		{time.Date(2022, 3, 7, 7, 0, 0, 0, time.UTC), time.Date(2022, 3, 8, 7, 0, 0, 0, time.UTC), 80},
+		{time.Date(2022, 3, 11, 8, 0, 0, 0, time.UTC), time.Date(2022, 3, 12, 10, 0, 0, 0, time.UTC), 60},
+		{time.Date(2022, 3, 12, 11, 0, 0, 0, time.UTC), time.Date(2022, 3, 14, 7, 0, 0, 0, time.UTC), 80},
+	}
+
+	for _, tc := range tc {
+		next, soc := plans.Next(tc.now)
+		if !next.Equal(tc.next) || soc != tc.soc {
+			t.Errorf("%v: expected %v @ %d%%, got %v @ %d%%", tc.now, tc.next, tc.soc, next, soc)
+		}
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	for _, plan := range []Plan{
+		{Days: []string{"foo"}, Time: "07:00", SoC: 80},
+		{Time: "7", SoC: 80},
+		{Time: "07:00", SoC: 0},
+		{Time: "07:00", SoC: 101},
+	} {
+		if err := plan.Validate(); err == nil {
+			t.Errorf("%v: expected error", plan)
+		}
+	}
+
+	if err := (Plan{Time: "07:00", SoC: 80}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/evcc-io/evcc/api"
@@ -48,6 +49,66 @@ func (lp *LoadPoint) SetMode(mode api.ChargeMode) {
 	}
 }
 
+// GetPriority returns the loadpoint priority
+func (lp *LoadPoint) GetPriority() int {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.Priority
+}
+
+// SetPriority sets the loadpoint priority
+func (lp *LoadPoint) SetPriority(priority int) {
+	lp.Lock()
+	defer lp.Unlock()
+
+	lp.log.DEBUG.Println("set priority:", priority)
+
+	if lp.Priority != priority {
+		lp.Priority = priority
+		lp.publish("priority", priority)
+		lp.requestUpdate()
+	}
+}
+
+// GetPlans returns the recurring target charge plans
+func (lp *LoadPoint) GetPlans() loadpoint.Plans {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.Plans
+}
+
+// SetPlans sets and persists the recurring target charge plans
+func (lp *LoadPoint) SetPlans(plans loadpoint.Plans) error {
+	if err := plans.Validate(); err != nil {
+		return err
+	}
+
+	lp.Lock()
+
+	lp.log.DEBUG.Println("set plans:", plans)
+
+	lp.Plans = plans
+	lp.publish("plans", plans)
+
+	// remove target armed by previous plans
+	if !lp.planTime.IsZero() && lp.socTimer.Time.Equal(lp.planTime) {
+		lp.socTimer.Reset()
+	}
+	lp.planTime = time.Time{}
+
+	lp.Unlock()
+
+	if lp.plans != nil {
+		if err := lp.plans.Save(plans); err != nil {
+			lp.log.ERROR.Printf("charge plans: %v", err)
+		}
+	}
+
+	lp.requestUpdate()
+
+	return nil
+}
+
 // GetTargetSoC returns loadpoint charge target soc
 func (lp *LoadPoint) GetTargetSoC() int {
 	lp.Lock()
@@ -133,6 +194,13 @@ func (lp *LoadPoint) SetTargetCharge(finishAt time.Time, soc int) {
 	}
 }
 
+// GetTargetTime returns the target charge finish time, zero if not set
+func (lp *LoadPoint) GetTargetTime() time.Time {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.socTimer.Time
+}
+
 // RemoteControl sets remote status demand
 func (lp *LoadPoint) RemoteControl(source string, demand loadpoint.RemoteDemand) {
 	lp.Lock()
@@ -151,6 +219,48 @@ func (lp *LoadPoint) RemoteControl(source string, demand loadpoint.RemoteDemand)
 	}
 }
 
+// RemoteCurrentLimit caps the max current by a remote limit, zero removes the limit
+func (lp *LoadPoint) RemoteCurrentLimit(source string, current float64) {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.remoteLimit != current {
+		lp.log.DEBUG.Printf("remote current limit: %.3gA (%s)", current, source)
+		lp.remoteLimit = current
+
+		lp.publish("remoteCurrentLimit", current)
+		lp.publish("remoteCurrentLimitSource", source)
+
+		lp.requestUpdate()
+	}
+}
+
+// HasVehicle returns true if the vehicle can be selected by its configured name or title
+func (lp *LoadPoint) HasVehicle(name string) bool {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.selectVehicleByName(name) != nil
+}
+
+// SetVehicle selects the active vehicle by its configured name or title
+func (lp *LoadPoint) SetVehicle(name string) error {
+	lp.Lock()
+	defer lp.Unlock()
+
+	vehicle := lp.selectVehicleByName(name)
+	if vehicle == nil {
+		return fmt.Errorf("unknown vehicle: %s", name)
+	}
+
+	lp.log.DEBUG.Println("set vehicle:", vehicle.Title())
+
+	// activated by the update loop since vehicle profiles apply settings
+	lp.selected = vehicle
+	lp.requestUpdate()
+
+	return nil
+}
+
 // HasChargeMeter determines if a physical charge meter is attached
 func (lp *LoadPoint) HasChargeMeter() bool {
 	_, isWrapped := lp.chargeMeter.(*wrapper.ChargeMeter)
@@ -164,6 +274,25 @@ func (lp *LoadPoint) GetChargePower() float64 {
 	return lp.chargePower
 }
 
+// GetChargedEnergy returns the energy charged while connected in Wh
+func (lp *LoadPoint) GetChargedEnergy() float64 {
+	lp.Lock()
+	defer lp.Unlock()
+	return lp.chargedEnergy
+}
+
+// GetChargeTotalImport returns the charge meter's energy register in kWh if available
+func (lp *LoadPoint) GetChargeTotalImport() (float64, bool) {
+	lp.Lock()
+	defer lp.Unlock()
+
+	if lp.chargeTotalImport == nil {
+		return 0, false
+	}
+
+	return *lp.chargeTotalImport, true
+}
+
 // GetMinCurrent returns the min loadpoint current
 func (lp *LoadPoint) GetMinCurrent() float64 {
 	lp.Lock()
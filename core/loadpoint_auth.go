@@ -0,0 +1,42 @@
+package core
+
+import (
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/rfid"
+)
+
+// authorize validates the identified token against the whitelist and authorizes it at the charger
+func (lp *LoadPoint) authorize(id string) (rfid.Token, bool) {
+	token, ok := lp.tokens.Lookup(id)
+	if !ok {
+		lp.log.WARN.Printf("authorization refused: unknown token %s", id)
+		return token, false
+	}
+
+	if authorizer, ok := lp.charger.(api.Authorizer); ok {
+		if err := authorizer.Authorize(id); err != nil {
+			lp.log.ERROR.Printf("authorize %s: %v", id, err)
+			return token, false
+		}
+	}
+
+	lp.log.INFO.Printf("authorized: %s (%s)", token.User, id)
+	lp.setAuthorized(true, token.User)
+	lp.updateSessionVehicle()
+
+	return token, true
+}
+
+// setAuthorized sets the session's authorization and user
+func (lp *LoadPoint) setAuthorized(authorized bool, user string) {
+	lp.authorized = authorized
+	lp.user = user
+
+	lp.publish("authorized", authorized)
+	lp.publish("user", user)
+}
+
+// authorizationPending returns true if charging requires an authorized token that has not been presented yet
+func (lp *LoadPoint) authorizationPending() bool {
+	return lp.Authorization && !lp.authorized
+}
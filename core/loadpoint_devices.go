@@ -0,0 +1,121 @@
+package core
+
+import (
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/soc"
+)
+
+// subscription is an event bus handler
+type subscription struct {
+	topic string
+	fn    interface{}
+}
+
+// subscribe attaches a charger helper's handler to the event bus. The handler is released when the charger is replaced.
+func (lp *LoadPoint) subscribe(topic string, fn interface{}) {
+	_ = lp.bus.Subscribe(topic, fn)
+	lp.chargerSubs = append(lp.chargerSubs, subscription{topic, fn})
+}
+
+// configuredMeter returns the charge meter unless it is provided by the charger
+func (lp *LoadPoint) configuredMeter() api.Meter {
+	if lp.MeterRef == "" {
+		return nil
+	}
+	return lp.chargeMeter
+}
+
+// setChargerAndMeter replaces charger and charge meter. If meter is nil, the charger's meter is used.
+// The loadpoint's expected charger state and current are re-applied on the next update.
+func (lp *LoadPoint) setChargerAndMeter(charger api.Charger, meter api.Meter) {
+	lp.Lock()
+
+	for _, s := range lp.chargerSubs {
+		_ = lp.bus.Unsubscribe(s.topic, s.fn)
+	}
+	lp.chargerSubs = nil
+
+	lp.charger = charger
+	lp.chargeMeter, lp.chargeRater, lp.chargeTimer = meter, nil, nil
+	lp.configureChargerType(charger)
+
+	if lp.vehicle != nil {
+		lp.socEstimator = soc.NewEstimator(lp.log, charger, lp.vehicle, lp.SoC.Estimate)
+	}
+
+	// force setting current on the new charger
+	lp.chargeCurrent = 0
+
+	lp.Unlock()
+
+	if _, ok := charger.(api.ChargePhases); ok && lp.GetPhases() != 0 {
+		lp.log.WARN.Printf("ignoring phases config (%dp) for switchable charger", lp.GetPhases())
+		lp.setPhases(0)
+	}
+
+	if ctrl, ok := charger.(loadpoint.Controller); ok {
+		ctrl.LoadpointControl(lp)
+	}
+
+	lp.publish("chargeConfigured", lp.HasChargeMeter())
+}
+
+// setVehicles replaces the loadpoint's vehicles
+func (lp *LoadPoint) setVehicles(refs []string, vehicles []api.Vehicle) {
+	lp.Lock()
+	lp.VehicleRef, lp.VehiclesRef, lp.vehicles = "", refs, vehicles
+	lp.Unlock()
+
+	lp.publish("hasVehicle", len(vehicles) > 0)
+
+	// always treat single vehicle as attached
+	if len(vehicles) == 1 {
+		lp.setActiveVehicle(vehicles[0])
+		return
+	}
+
+	for _, v := range vehicles {
+		if v == lp.vehicle {
+			return
+		}
+	}
+
+	lp.setActiveVehicle(nil)
+
+	if len(vehicles) > 1 && lp.connected() {
+		lp.startVehicleDetection()
+	}
+}
+
+// replaceVehicle replaces the named vehicle instance
+func (lp *LoadPoint) replaceVehicle(name string, vehicle api.Vehicle) {
+	lp.Lock()
+
+	var active bool
+	replace := func(i int) {
+		active = active || lp.vehicles[i] == lp.vehicle
+		lp.vehicles[i] = vehicle
+	}
+
+	if lp.VehicleRef == name && len(lp.vehicles) > 0 {
+		replace(0)
+	}
+	for i, ref := range lp.VehiclesRef {
+		if ref == name && i < len(lp.vehicles) {
+			replace(i)
+		}
+	}
+
+	for _, token := range lp.tokens {
+		if token.Vehicle == name {
+			lp.tokenVehicle[token.ID] = vehicle
+		}
+	}
+
+	lp.Unlock()
+
+	if active {
+		lp.setActiveVehicle(vehicle)
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"gorm.io/gorm"
+)
+
+// planRecord is the database row holding a loadpoint's charge plans
+type planRecord struct {
+	Loadpoint string `gorm:"primarykey"`
+	Plans     string // json encoded
+}
+
+func (planRecord) TableName() string {
+	return "plans"
+}
+
+// planStore persists the charge plans of a single loadpoint set at runtime
+type planStore struct {
+	db   *gorm.DB
+	name string
+}
+
+// newPlanStore creates a plan store for the named loadpoint
+func newPlanStore(name string, db *gorm.DB) (*planStore, error) {
+	err := db.AutoMigrate(new(planRecord))
+
+	return &planStore{
+		db:   db,
+		name: name,
+	}, err
+}
+
+// Load returns the persisted plans. The result is false if nothing has been persisted yet.
+func (s *planStore) Load() (loadpoint.Plans, bool, error) {
+	var res planRecord
+
+	err := s.db.First(&res, "loadpoint = ?", s.name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var plans loadpoint.Plans
+	if err := json.Unmarshal([]byte(res.Plans), &plans); err != nil {
+		return nil, false, err
+	}
+
+	return plans, true, plans.Validate()
+}
+
+// Save persists the plans
+func (s *planStore) Save(plans loadpoint.Plans) error {
+	b, err := json.Marshal(plans)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Save(&planRecord{Loadpoint: s.name, Plans: string(b)}).Error
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"github.com/evcc-io/evcc/api"
+)
+
+// chargeMeterTotal returns the charge meter's total energy reading in kWh if available
+func (lp *LoadPoint) chargeMeterTotal() *float64 {
+	m, ok := lp.chargeMeter.(api.MeterEnergy)
+	if !ok {
+		return nil
+	}
+
+	f, err := m.TotalEnergy()
+	if err != nil {
+		lp.log.ERROR.Printf("charge total import: %v", err)
+		return nil
+	}
+
+	lp.log.DEBUG.Printf("charge total import: %.3fkWh", f)
+
+	return &f
+}
+
+// createSession creates a charging session if session history is enabled
+func (lp *LoadPoint) createSession() {
+	if lp.db == nil || lp.session != nil {
+		return
+	}
+
+	lp.session = lp.db.New(lp.clock.Now(), lp.chargeMeterTotal())
+	lp.updateSessionVehicle()
+}
+
+// updateSessionVehicle records the identified vehicle on the charging session
+func (lp *LoadPoint) updateSessionVehicle() {
+	if lp.session == nil {
+		return
+	}
+
+	lp.session.Identifier = lp.vehicleID
+	lp.session.User = lp.user
+	if lp.vehicle != nil {
+		lp.session.Vehicle = lp.vehicle.Title()
+	}
+}
+
+// accountSession adds charged energy, solar share and price to the charging session
+func (lp *LoadPoint) accountSession(solarShare, gridPrice, feedinPrice float64) {
+	if lp.session == nil {
+		return
+	}
+
+	lp.session.Account(lp.chargedEnergy/1e3, solarShare, gridPrice, feedinPrice)
+}
+
+// persistSession writes the charging session to the database
+func (lp *LoadPoint) persistSession() {
+	if lp.session == nil {
+		return
+	}
+
+	// skip sessions without any energy that have never been written
+	if lp.session.ID == 0 && lp.chargedEnergy == 0 {
+		return
+	}
+
+	lp.session.ChargedEnergy = lp.chargedEnergy / 1e3
+	lp.session.MeterStop = lp.chargeMeterTotal()
+
+	if err := lp.db.Persist(lp.session); err != nil {
+		lp.log.ERROR.Printf("persist charging session: %v", err)
+	}
+}
+
+// stopSession finalizes and persists the charging session
+func (lp *LoadPoint) stopSession() {
+	if lp.session == nil {
+		return
+	}
+
+	lp.session.Finished = lp.clock.Now()
+	lp.persistSession()
+
+	lp.session = nil
+}
@@ -1,15 +1,21 @@
 package core
 
 import (
+	"errors"
+	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 
 	evbus "github.com/asaskevich/EventBus"
 	"github.com/benbjohnson/clock"
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/rfid"
 	"github.com/evcc-io/evcc/core/soc"
 	"github.com/evcc-io/evcc/mock"
 	"github.com/evcc-io/evcc/push"
+	"github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/util"
 	"github.com/golang/mock/gomock"
 )
@@ -337,6 +343,53 @@ func TestPVHysteresis(t *testing.T) {
 	}
 }
 
+func TestPVEnableForecast(t *testing.T) {
+	dt := time.Minute
+
+	tc := []struct {
+		forecast float64
+		current  float64
+	}{
+		{6 * 100 * 10, minA}, // predicted surplus lasts
+		{5 * 100 * 10, 0},    // clouds coming, keep disabled
+	}
+
+	for _, tc := range tc {
+		clck := clock.NewMock()
+		ctrl := gomock.NewController(t)
+
+		Voltage = 100
+		lp := &LoadPoint{
+			log:          util.NewLogger("foo"),
+			clock:        clck,
+			charger:      mock.NewMockCharger(ctrl),
+			MinCurrent:   minA,
+			MaxCurrent:   maxA,
+			Phases:       10,
+			activePhases: 10,
+			Enable:       ThresholdConfig{Delay: dt},
+			status:       api.StatusB,
+			forecast: testForecast{
+				{Start: clck.Now(), End: clck.Now().Add(time.Hour), Power: tc.forecast},
+			},
+		}
+
+		start := clck.Now()
+
+		var current float64
+		for _, delay := range []time.Duration{0, dt + 1} {
+			clck.Set(start.Add(delay))
+			current = lp.pvMaxCurrent(api.ModePV, -6*100*10, false)
+		}
+
+		if current != tc.current {
+			t.Errorf("forecast %.0fW: wanted %.1f, got %.1f", tc.forecast, tc.current, current)
+		}
+
+		ctrl.Finish()
+	}
+}
+
 func TestPVHysteresisForStatusOtherThanC(t *testing.T) {
 	clck := clock.NewMock()
 	ctrl := gomock.NewController(t)
@@ -818,6 +871,45 @@ func TestVehicleDetectByID(t *testing.T) {
 	}
 }
 
+func TestVehicleSelectByName(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	v1 := mock.NewMockVehicle(ctrl)
+	v2 := mock.NewMockVehicle(ctrl)
+
+	v1.EXPECT().Title().Return("Model 3").AnyTimes()
+	v2.EXPECT().Title().Return("e-Golf").AnyTimes()
+
+	lp := &LoadPoint{
+		log:         util.NewLogger("foo"),
+		VehiclesRef: []string{"tesla", "golf"},
+		vehicles:    []api.Vehicle{v1, v2},
+		lpChan:      make(chan *LoadPoint, 1),
+	}
+
+	for _, tc := range []struct {
+		name string
+		res  api.Vehicle
+	}{
+		{"tesla", v1},
+		{"golf", v2},
+		{"E-GOLF", v2},
+		{"zoe", nil},
+	} {
+		if res := lp.selectVehicleByName(tc.name); tc.res != res {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.res, res)
+		}
+	}
+
+	if err := lp.SetVehicle("zoe"); err == nil {
+		t.Error("expected unknown vehicle error")
+	}
+
+	if err := lp.SetVehicle("golf"); err != nil || lp.selectedVehicle() != v2 || lp.selectedVehicle() != nil {
+		t.Errorf("expected golf selected once (%v)", err)
+	}
+}
+
 func TestScalePhases(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	charger := &struct {
@@ -984,3 +1076,321 @@ func TestVehiclePhases(t *testing.T) {
 		}
 	}
 }
+
+func TestVehicleProfile(t *testing.T) {
+	clock := clock.NewMock()
+	ctrl := gomock.NewController(t)
+	charger := mock.NewMockCharger(ctrl)
+
+	lp := &LoadPoint{
+		log:         util.NewLogger("foo"),
+		bus:         evbus.New(),
+		clock:       clock,
+		charger:     charger,
+		chargeMeter: &Null{}, // silence nil panics
+		chargeRater: &Null{}, // silence nil panics
+		chargeTimer: &Null{}, // silence nil panics
+		wakeUpTimer: NewTimer(),
+		MinCurrent:  minA,
+		MaxCurrent:  maxA,
+		Phases:      3,
+		Mode:        api.ModePV,
+		SoC: SoCConfig{
+			Target: 100,
+		},
+	}
+
+	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
+	clock.Set(time.Date(2022, 3, 1, 12, 0, 0, 0, time.Local))
+
+	attachListeners(t, lp)
+	lp.collectDefaults()
+
+	mode := api.ModeNow
+	targetSoC := 80
+	phases := 1
+	window := "22:00-06:00"
+
+	lp.applyProfile(api.ActionConfig{
+		Mode:      &mode,
+		TargetSoC: &targetSoC,
+		Phases:    &phases,
+		Window:    &window,
+	})
+
+	if lp.Mode != api.ModeNow || lp.SoC.Target != 80 || lp.Phases != 1 {
+		t.Errorf("profile not applied: mode %s, target soc %d, phases %d", lp.Mode, lp.SoC.Target, lp.Phases)
+	}
+
+	if !lp.chargeWindowClosed() {
+		t.Error("expected charge window closed")
+	}
+
+	lp.evVehicleDisconnectHandler()
+
+	if lp.Mode != api.ModePV || lp.SoC.Target != 100 || lp.Phases != 3 {
+		t.Errorf("profile not restored: mode %s, target soc %d, phases %d", lp.Mode, lp.SoC.Target, lp.Phases)
+	}
+
+	if lp.chargeWindowClosed() {
+		t.Error("expected charge window removed")
+	}
+
+	ctrl.Finish()
+}
+
+func TestDefaultsSwitchablePhases(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	charger := &struct {
+		*mock.MockCharger
+		*mock.MockChargePhases
+	}{
+		mock.NewMockCharger(ctrl),
+		mock.NewMockChargePhases(ctrl),
+	}
+
+	lp := &LoadPoint{
+		log:         util.NewLogger("foo"),
+		bus:         evbus.New(),
+		clock:       clock.NewMock(),
+		charger:     charger,
+		chargeMeter: &Null{}, // silence nil panics
+		chargeRater: &Null{}, // silence nil panics
+		chargeTimer: &Null{}, // silence nil panics
+		wakeUpTimer: NewTimer(),
+		MinCurrent:  minA,
+		MaxCurrent:  maxA,
+		Phases:      0,
+		Mode:        api.ModePV,
+		SoC: SoCConfig{
+			Target: 100,
+		},
+		ResetOnDisconnect: true,
+	}
+
+	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
+
+	charger.MockCharger.EXPECT().Enabled().Return(false, nil)
+
+	attachListeners(t, lp)
+	lp.collectDefaults()
+
+	if lp.onDisconnect.Phases != nil {
+		t.Errorf("expected no phases default, got %d", *lp.onDisconnect.Phases)
+	}
+
+	// phases scaled while charging are not restored on disconnect
+	lp.Phases = 1
+	lp.evVehicleDisconnectHandler()
+
+	if lp.Phases != 1 {
+		t.Errorf("expected phases unchanged, got %d", lp.Phases)
+	}
+
+	ctrl.Finish()
+}
+
+func TestChargePlans(t *testing.T) {
+	clock := clock.NewMock()
+
+	lp := &LoadPoint{
+		log:   util.NewLogger("foo"),
+		clock: clock,
+		SoC: SoCConfig{
+			Target: 100,
+		},
+		Plans: loadpoint.Plans{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, Time: "07:00", SoC: 80},
+		},
+	}
+
+	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
+
+	// Monday evening
+	clock.Set(time.Date(2022, 3, 7, 20, 0, 0, 0, time.Local))
+
+	lp.armPlan()
+	if expect := time.Date(2022, 3, 8, 7, 0, 0, 0, time.Local); !lp.socTimer.Time.Equal(expect) || lp.SoC.Target != 80 {
+		t.Errorf("expected plan armed at %v @ 80%%, got %v @ %d%%", expect, lp.socTimer.Time, lp.SoC.Target)
+	}
+
+	// target reached early, plan must not be re-armed while connected
+	clock.Set(time.Date(2022, 3, 8, 5, 0, 0, 0, time.Local))
+	lp.socTimer.Reset()
+
+	lp.armPlan()
+	if !lp.socTimer.Time.IsZero() {
+		t.Errorf("expected plan not re-armed, got %v", lp.socTimer.Time)
+	}
+
+	// plan time passed, next plan is armed
+	clock.Set(time.Date(2022, 3, 8, 7, 0, 0, 0, time.Local))
+
+	lp.armPlan()
+	if expect := time.Date(2022, 3, 9, 7, 0, 0, 0, time.Local); !lp.socTimer.Time.Equal(expect) {
+		t.Errorf("expected plan re-armed at %v, got %v", expect, lp.socTimer.Time)
+	}
+
+	// target already reached, plan is skipped until next connect
+	lp.socTimer.Reset()
+	lp.Lock()
+	lp.planTime = time.Time{}
+	lp.Unlock()
+
+	lp.vehicle = mock.NewMockVehicle(gomock.NewController(t))
+	lp.vehicleSoc = 85
+
+	lp.armPlan()
+	if !lp.socTimer.Time.IsZero() {
+		t.Errorf("expected reached plan skipped, got %v", lp.socTimer.Time)
+	}
+
+	lp.vehicleSoc = 50
+
+	lp.armPlan()
+	if !lp.socTimer.Time.IsZero() {
+		t.Errorf("expected reached plan not re-armed, got %v", lp.socTimer.Time)
+	}
+
+	// plans replaced
+	if err := lp.SetPlans(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !lp.socTimer.Time.IsZero() {
+		t.Errorf("expected plan target removed, got %v", lp.socTimer.Time)
+	}
+}
+
+func TestChargePlansPersisted(t *testing.T) {
+	gdb, err := db.New("sqlite", filepath.Join(t.TempDir(), "evcc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := newPlanStore("Garage", gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lp := &LoadPoint{
+		log:   util.NewLogger("foo"),
+		clock: clock.NewMock(),
+		plans: store,
+	}
+
+	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
+
+	if _, ok, err := store.Load(); ok || err != nil {
+		t.Errorf("expected no plans persisted: %v", err)
+	}
+
+	plans := loadpoint.Plans{{Days: []string{"sat"}, Time: "10:00", SoC: 60}}
+	if err := lp.SetPlans(plans); err != nil {
+		t.Fatal(err)
+	}
+
+	if res, ok, err := store.Load(); !ok || err != nil || !reflect.DeepEqual(res, plans) {
+		t.Errorf("expected plans persisted, got %v (%v)", res, err)
+	}
+}
+
+// authCharger is a charger supporting RFID identification and authorization
+type authCharger struct {
+	*mock.MockCharger
+	id         string
+	err        error
+	authorized []string
+}
+
+func (c *authCharger) Identify() (string, error) {
+	return c.id, nil
+}
+
+func (c *authCharger) Authorize(key string) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.authorized = append(c.authorized, key)
+	return nil
+}
+
+func TestAuthorization(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	charger := &authCharger{MockCharger: mock.NewMockCharger(ctrl)}
+
+	lp := &LoadPoint{
+		log:           util.NewLogger("foo"),
+		bus:           evbus.New(),
+		clock:         clock.NewMock(),
+		charger:       charger,
+		chargeMeter:   &Null{}, // silence nil panics
+		chargeRater:   &Null{}, // silence nil panics
+		chargeTimer:   &Null{}, // silence nil panics
+		wakeUpTimer:   NewTimer(),
+		MinCurrent:    minA,
+		MaxCurrent:    maxA,
+		Phases:        3,
+		Mode:          api.ModeNow,
+		Authorization: true,
+		tokens: rfid.Tokens{
+			{ID: "04A2B3C4", User: "alice"},
+		},
+	}
+
+	lp.socTimer = soc.NewTimer(lp.log, &adapter{LoadPoint: lp})
+
+	charger.EXPECT().Enabled().Return(false, nil)
+
+	attachListeners(t, lp)
+	lp.collectDefaults()
+
+	if !lp.authorizationPending() {
+		t.Error("expected authorization pending")
+	}
+
+	// unknown token is refused
+	charger.id = "deadbeef"
+	lp.identifyVehicle()
+
+	if !lp.authorizationPending() || len(charger.authorized) > 0 {
+		t.Errorf("expected unknown token refused, authorized %v", charger.authorized)
+	}
+
+	// failed charger authorization is retried while pending
+	charger.id = "04a2b3c4"
+	charger.err = errors.New("offline")
+	lp.identifyVehicle()
+
+	if !lp.authorizationPending() {
+		t.Error("expected authorization pending")
+	}
+
+	// whitelisted token is authorized at the charger
+	charger.err = nil
+	lp.identifyVehicle()
+
+	if lp.authorizationPending() || lp.user != "alice" {
+		t.Errorf("expected authorized user alice, got %q", lp.user)
+	}
+
+	if len(charger.authorized) != 1 || charger.authorized[0] != "04a2b3c4" {
+		t.Errorf("expected charger authorization, got %v", charger.authorized)
+	}
+
+	// authorization ends on disconnect
+	lp.evVehicleDisconnectHandler()
+
+	if !lp.authorizationPending() || lp.user != "" {
+		t.Error("expected authorization reset")
+	}
+
+	// same token is authorized again for the next session
+	lp.identifyVehicle()
+
+	if lp.authorizationPending() || len(charger.authorized) != 2 {
+		t.Errorf("expected token authorized again, got %v", charger.authorized)
+	}
+
+	ctrl.Finish()
+}
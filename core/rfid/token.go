@@ -0,0 +1,48 @@
+package rfid
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Token is an RFID token authorized to charge
+type Token struct {
+	ID      string `json:"id"`
+	User    string `json:"user"`
+	Vehicle string `json:"vehicle,omitempty"` // vehicle name
+}
+
+// Tokens is the whitelist of authorized tokens
+type Tokens []Token
+
+// Validate validates the whitelist
+func (t Tokens) Validate() error {
+	ids := make(map[string]bool)
+
+	for _, token := range t {
+		if token.ID == "" {
+			return errors.New("missing token id")
+		}
+
+		id := strings.ToLower(token.ID)
+		if ids[id] {
+			return fmt.Errorf("duplicate token: %s", token.ID)
+		}
+
+		ids[id] = true
+	}
+
+	return nil
+}
+
+// Lookup returns the token matching id. Token ids are case-insensitive.
+func (t Tokens) Lookup(id string) (Token, bool) {
+	for _, token := range t {
+		if strings.EqualFold(token.ID, id) {
+			return token, true
+		}
+	}
+
+	return Token{}, false
+}
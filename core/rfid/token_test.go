@@ -0,0 +1,31 @@
+package rfid
+
+import "testing"
+
+func TestTokens(t *testing.T) {
+	tokens := Tokens{
+		{ID: "04A2B3C4", User: "alice", Vehicle: "ev1"},
+		{ID: "04d5e6f7", User: "bob"},
+	}
+
+	if err := tokens.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if token, ok := tokens.Lookup("04a2b3c4"); !ok || token.User != "alice" {
+		t.Errorf("expected alice, got %v (%v)", token, ok)
+	}
+
+	if _, ok := tokens.Lookup("deadbeef"); ok {
+		t.Error("expected unknown token")
+	}
+
+	for _, tokens := range []Tokens{
+		{{User: "alice"}},
+		{{ID: "04A2B3C4"}, {ID: "04a2b3c4"}},
+	} {
+		if err := tokens.Validate(); err == nil {
+			t.Errorf("%v: expected error", tokens)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package core
 
 import (
 	"math"
+	"sync"
 	"time"
 
 	"github.com/benbjohnson/clock"
 	"github.com/evcc-io/evcc/tariff"
+	"github.com/evcc-io/evcc/util"
 )
 
 const DefaultGridPrice = 0.30
@@ -18,21 +20,27 @@ type publisher interface {
 
 // Site is the main configuration container. A site can host multiple loadpoints.
 type Savings struct {
+	sync.Mutex
+	log                            *util.Logger
 	clock                          clock.Clock
 	tariffs                        tariff.Tariffs
-	started                        time.Time // Boot time
-	updated                        time.Time // Time of last charged value update
-	gridCharged                    float64   // Grid energy charged since startup (kWh)
-	gridCost                       float64   // Running total of charged grid energy cost (e.g. EUR)
-	gridSavedCost                  float64   // Running total of saved cost from self consumption (e.g. EUR)
-	selfConsumptionCharged         float64   // Self-produced energy charged since startup (kWh)
-	selfConsumptionCost            float64   // Running total of charged self-produced energy cost (e.g. EUR)
-	lastGridPrice, lastFeedInPrice float64   // Stores the last published grid price. Needed to detect price changes (Awattar, ..)
+	store                          SavingsStore  // Persistence
+	interval                       time.Duration // Persistence checkpoint interval
+	saved                          time.Time     // Time of last checkpoint
+	started                        time.Time     // Boot or reset time
+	updated                        time.Time     // Time of last charged value update
+	gridCharged                    float64       // Grid energy charged since startup (kWh)
+	gridCost                       float64       // Running total of charged grid energy cost (e.g. EUR)
+	gridSavedCost                  float64       // Running total of saved cost from self consumption (e.g. EUR)
+	selfConsumptionCharged         float64       // Self-produced energy charged since startup (kWh)
+	selfConsumptionCost            float64       // Running total of charged self-produced energy cost (e.g. EUR)
+	lastGridPrice, lastFeedInPrice float64       // Stores the last published grid price. Needed to detect price changes (Awattar, ..)
 }
 
 func NewSavings(tariffs tariff.Tariffs) *Savings {
 	clock := clock.New()
 	savings := &Savings{
+		log:     util.NewLogger("savings"),
 		clock:   clock,
 		tariffs: tariffs,
 		started: clock.Now(),
@@ -43,9 +51,90 @@ func NewSavings(tariffs tariff.Tariffs) *Savings {
 }
 
 func (s *Savings) Since() time.Time {
+	s.Lock()
+	defer s.Unlock()
 	return s.started
 }
 
+// SetClock sets the clock and restarts counting from its current time
+func (s *Savings) SetClock(clock clock.Clock) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.clock = clock
+	s.started = clock.Now()
+	s.updated = clock.Now()
+	s.saved = clock.Now()
+}
+
+// Restore attaches the persistence store, restores persisted totals and enables periodic checkpoints
+func (s *Savings) Restore(store SavingsStore, interval time.Duration) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.store = store
+	s.interval = interval
+	s.saved = s.clock.Now()
+
+	state, err := store.Load()
+	if err != nil || state == nil {
+		return err
+	}
+
+	s.started = state.Since
+	s.gridCharged = state.GridCharged
+	s.gridCost = state.GridCost
+	s.gridSavedCost = state.GridSavedCost
+	s.selfConsumptionCharged = state.SelfConsumptionCharged
+	s.selfConsumptionCost = state.SelfConsumptionCost
+
+	return nil
+}
+
+// Persist writes the current totals to the store
+func (s *Savings) Persist() {
+	s.Lock()
+	defer s.Unlock()
+	s.persist()
+}
+
+func (s *Savings) persist() {
+	if s.store == nil {
+		return
+	}
+
+	s.saved = s.clock.Now()
+
+	if err := s.store.Save(SavingsState{
+		Since:                  s.started,
+		GridCharged:            s.gridCharged,
+		GridCost:               s.gridCost,
+		GridSavedCost:          s.gridSavedCost,
+		SelfConsumptionCharged: s.selfConsumptionCharged,
+		SelfConsumptionCost:    s.selfConsumptionCost,
+	}); err != nil {
+		s.log.ERROR.Printf("persist: %v", err)
+	}
+}
+
+// Reset clears all totals and restarts counting from now
+func (s *Savings) Reset(p publisher) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.started = s.clock.Now()
+	s.gridCharged = 0
+	s.gridCost = 0
+	s.gridSavedCost = 0
+	s.selfConsumptionCharged = 0
+	s.selfConsumptionCost = 0
+
+	s.persist()
+
+	p.publish("savingsSince", s.started.Unix())
+	s.publish(p)
+}
+
 func (s *Savings) SelfConsumptionPercent() float64 {
 	if s.TotalCharged() == 0 {
 		return 0
@@ -123,7 +212,19 @@ func (s *Savings) updatePrices(p publisher) (float64, float64) {
 	return gridPrice, feedinPrice
 }
 
+func (s *Savings) publish(p publisher) {
+	p.publish("savingsTotalCharged", s.TotalCharged())
+	p.publish("savingsGridCharged", s.gridCharged)
+	p.publish("savingsSelfConsumptionCharged", s.selfConsumptionCharged)
+	p.publish("savingsSelfConsumptionPercent", s.SelfConsumptionPercent())
+	p.publish("savingsEffectivePrice", s.EffectivePrice())
+	p.publish("savingsAmount", s.SavingsAmount())
+}
+
 func (s *Savings) Update(p publisher, gridPower, pvPower, batteryPower, chargePower float64) {
+	s.Lock()
+	defer s.Unlock()
+
 	gridPrice, feedinPrice := s.updatePrices(p)
 	defer func() { s.updated = s.clock.Now() }()
 
@@ -145,10 +246,10 @@ func (s *Savings) Update(p publisher, gridPower, pvPower, batteryPower, chargePo
 	s.selfConsumptionCharged += addedSelfConsumption
 	s.selfConsumptionCost += addedSelfConsumption * feedinPrice
 
-	p.publish("savingsTotalCharged", s.TotalCharged())
-	p.publish("savingsGridCharged", s.gridCharged)
-	p.publish("savingsSelfConsumptionCharged", s.selfConsumptionCharged)
-	p.publish("savingsSelfConsumptionPercent", s.SelfConsumptionPercent())
-	p.publish("savingsEffectivePrice", s.EffectivePrice())
-	p.publish("savingsAmount", s.SavingsAmount())
+	s.publish(p)
+
+	// periodic checkpoint
+	if s.store != nil && s.clock.Since(s.saved) >= s.interval {
+		s.persist()
+	}
 }
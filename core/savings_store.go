@@ -0,0 +1,108 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SavingsState is the persisted state of the savings totals
+type SavingsState struct {
+	Since                  time.Time `json:"since"`
+	GridCharged            float64   `json:"gridCharged"`
+	GridCost               float64   `json:"gridCost"`
+	GridSavedCost          float64   `json:"gridSavedCost"`
+	SelfConsumptionCharged float64   `json:"selfConsumptionCharged"`
+	SelfConsumptionCost    float64   `json:"selfConsumptionCost"`
+}
+
+// SavingsStore persists savings totals across restarts
+type SavingsStore interface {
+	// Load returns the persisted state. The state is nil if nothing has been persisted yet.
+	Load() (*SavingsState, error)
+	Save(SavingsState) error
+}
+
+// savingsFileStore persists savings as json file
+type savingsFileStore struct {
+	file string
+}
+
+// NewSavingsFileStore creates a file-based savings store
+func NewSavingsFileStore(file string) SavingsStore {
+	return &savingsFileStore{file: file}
+}
+
+func (s *savingsFileStore) Load() (*SavingsState, error) {
+	b, err := os.ReadFile(s.file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var res SavingsState
+	err = json.Unmarshal(b, &res)
+
+	return &res, err
+}
+
+func (s *savingsFileStore) Save(state SavingsState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.file), os.ModePerm); err != nil {
+		return err
+	}
+
+	// write atomically to prevent corruption on crash
+	tmp := s.file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.file)
+}
+
+// savingsRecord is the single database row holding the savings state
+type savingsRecord struct {
+	ID           uint `gorm:"primarykey"`
+	SavingsState `gorm:"embedded"`
+}
+
+func (savingsRecord) TableName() string {
+	return "savings"
+}
+
+// savingsDBStore persists savings in the database
+type savingsDBStore struct {
+	db *gorm.DB
+}
+
+// NewSavingsDBStore creates a database-backed savings store
+func NewSavingsDBStore(db *gorm.DB) (SavingsStore, error) {
+	err := db.AutoMigrate(new(savingsRecord))
+	return &savingsDBStore{db: db}, err
+}
+
+func (s *savingsDBStore) Load() (*SavingsState, error) {
+	var res savingsRecord
+
+	err := s.db.First(&res, 1).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+
+	return &res.SavingsState, err
+}
+
+func (s *savingsDBStore) Save(state SavingsState) error {
+	return s.db.Save(&savingsRecord{ID: 1, SavingsState: state}).Error
+}
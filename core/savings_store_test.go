@@ -0,0 +1,78 @@
+package core
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/util"
+)
+
+func TestSavingsPersistence(t *testing.T) {
+	p := StubPublisher{}
+	store := NewSavingsFileStore(filepath.Join(t.TempDir(), "savings.json"))
+
+	clck := clock.NewMock()
+	s := &Savings{
+		log:     util.NewLogger("foo"),
+		clock:   clck,
+		started: clck.Now(),
+		updated: clck.Now(),
+	}
+
+	if err := s.Restore(store, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	// no checkpoint before interval has passed
+	clck.Add(time.Minute)
+	s.Update(p, 2500, 2500, 0, 5000)
+
+	if state, err := store.Load(); err != nil || state != nil {
+		t.Fatalf("unexpected state: %v %v", state, err)
+	}
+
+	// checkpoint after interval
+	clck.Add(time.Hour)
+	s.Update(p, 2500, 2500, 0, 5000)
+
+	state, err := store.Load()
+	if err != nil || state == nil {
+		t.Fatalf("missing state: %v", err)
+	}
+
+	if !compareWithTolerane(state.GridCharged+state.SelfConsumptionCharged, s.TotalCharged()) {
+		t.Errorf("persisted total was incorrect, got: %.3f, want: %.3f", state.GridCharged+state.SelfConsumptionCharged, s.TotalCharged())
+	}
+
+	// restore into new instance
+	restored := &Savings{
+		log:     util.NewLogger("foo"),
+		clock:   clck,
+		started: clck.Now(),
+		updated: clck.Now(),
+	}
+
+	if err := restored.Restore(store, 5*time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	if !restored.Since().Equal(s.Since()) {
+		t.Errorf("since was incorrect, got: %v, want: %v", restored.Since(), s.Since())
+	}
+	assertEnergy(t, restored, s.TotalCharged(), s.selfConsumptionCharged, s.SelfConsumptionPercent())
+	assertPrices(t, restored, s.EffectivePrice(), s.SavingsAmount())
+
+	// reset
+	clck.Add(time.Hour)
+	restored.Reset(p)
+
+	if state, err = store.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !state.Since.Equal(clck.Now()) || state.GridCharged != 0 || state.SelfConsumptionCharged != 0 {
+		t.Errorf("reset state not persisted: %+v", state)
+	}
+}
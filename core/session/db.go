@@ -0,0 +1,59 @@
+package session
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DB is a SQL database storage service for charging sessions of a single loadpoint
+type DB struct {
+	db   *gorm.DB
+	name string
+}
+
+// NewStore creates a session store for the named loadpoint
+func NewStore(name string, db *gorm.DB) (*DB, error) {
+	err := db.AutoMigrate(new(Session))
+
+	return &DB{
+		db:   db,
+		name: name,
+	}, err
+}
+
+// New creates a new session
+func (s *DB) New(created time.Time, meterStart *float64) *Session {
+	return &Session{
+		Created:    created,
+		Loadpoint:  s.name,
+		MeterStart: meterStart,
+	}
+}
+
+// Persist creates or updates a session
+func (s *DB) Persist(session *Session) error {
+	return s.db.Save(session).Error
+}
+
+// Filter restricts sessions returned by Sessions
+type Filter struct {
+	From, To time.Time // created within [From, To)
+}
+
+// All returns all sessions of all loadpoints matching the filter, ordered by creation time
+func All(db *gorm.DB, filter Filter) (Sessions, error) {
+	var res Sessions
+
+	tx := db.Order("created")
+	if !filter.From.IsZero() {
+		tx = tx.Where("created >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		tx = tx.Where("created < ?", filter.To)
+	}
+
+	err := tx.Find(&res).Error
+
+	return res, err
+}
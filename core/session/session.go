@@ -0,0 +1,107 @@
+package session
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Session is a single charging session
+type Session struct {
+	ID              uint      `json:"id" gorm:"primarykey"`
+	Created         time.Time `json:"created"`
+	Finished        time.Time `json:"finished"`
+	Loadpoint       string    `json:"loadpoint"`
+	Identifier      string    `json:"identifier"`
+	User            string    `json:"user"`
+	Vehicle         string    `json:"vehicle"`
+	MeterStart      *float64  `json:"meterStart" gorm:"column:meter_start_kwh"`
+	MeterStop       *float64  `json:"meterStop" gorm:"column:meter_end_kwh"`
+	ChargedEnergy   float64   `json:"chargedEnergy" gorm:"column:charged_kwh"`
+	SolarPercentage float64   `json:"solarPercentage"`
+	Price           float64   `json:"price"`
+
+	solarEnergy     float64 // self-produced share of charged energy in kWh
+	accountedEnergy float64 // charged energy already accounted for in kWh
+}
+
+// Account adds the charged energy in kWh to solar share and price
+func (s *Session) Account(chargedEnergy, solarShare, gridPrice, feedinPrice float64) {
+	added := chargedEnergy - s.accountedEnergy
+	if added <= 0 {
+		return
+	}
+
+	s.accountedEnergy = chargedEnergy
+	s.ChargedEnergy = chargedEnergy
+
+	solar := added * solarShare
+	s.solarEnergy += solar
+	s.Price += solar*feedinPrice + (added-solar)*gridPrice
+
+	if s.ChargedEnergy > 0 {
+		s.SolarPercentage = 100 * s.solarEnergy / s.ChargedEnergy
+	}
+}
+
+// Sessions is a list of sessions
+type Sessions []Session
+
+var csvHeader = []string{
+	"Created", "Finished", "Loadpoint", "Identifier", "User", "Vehicle",
+	"Meter Start (kWh)", "Meter Stop (kWh)", "Charged Energy (kWh)", "Solar (%)", "Price",
+}
+
+// WriteCsv writes sessions as csv including header
+func (t Sessions) WriteCsv(w io.Writer) error {
+	ww := csv.NewWriter(w)
+
+	if err := ww.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, s := range t {
+		if err := ww.Write(s.csvRecord()); err != nil {
+			return err
+		}
+	}
+
+	ww.Flush()
+
+	return ww.Error()
+}
+
+func (s Session) csvRecord() []string {
+	formatTime := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Local().Format("2006-01-02 15:04:05")
+	}
+
+	formatFloat := func(f float64, prec int) string {
+		return strconv.FormatFloat(f, 'f', prec, 64)
+	}
+
+	formatMeter := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return formatFloat(*f, 3)
+	}
+
+	return []string{
+		formatTime(s.Created),
+		formatTime(s.Finished),
+		s.Loadpoint,
+		s.Identifier,
+		s.User,
+		s.Vehicle,
+		formatMeter(s.MeterStart),
+		formatMeter(s.MeterStop),
+		formatFloat(s.ChargedEnergy, 3),
+		formatFloat(s.SolarPercentage, 1),
+		formatFloat(s.Price, 2),
+	}
+}
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccount(t *testing.T) {
+	s := new(Session)
+
+	s.Account(10, 1, 0.3, 0.1) // 10kWh solar
+	s.Account(20, 0, 0.3, 0.1) // 10kWh grid
+	s.Account(20, 0, 0.3, 0.1) // no change
+
+	if s.ChargedEnergy != 20 {
+		t.Errorf("charged energy: expected 20, got %.3f", s.ChargedEnergy)
+	}
+
+	if s.SolarPercentage != 50 {
+		t.Errorf("solar percentage: expected 50, got %.3f", s.SolarPercentage)
+	}
+
+	if price := 10*0.1 + 10*0.3; s.Price != price {
+		t.Errorf("price: expected %.3f, got %.3f", price, s.Price)
+	}
+}
+
+func TestWriteCsv(t *testing.T) {
+	start := 1.5
+	sessions := Sessions{{
+		Created:       time.Date(2022, 3, 1, 8, 0, 0, 0, time.Local),
+		Loadpoint:     "Garage",
+		User:          "alice",
+		Vehicle:       "e-Golf",
+		MeterStart:    &start,
+		ChargedEnergy: 12.3456,
+		Price:         3.456,
+	}}
+
+	var b bytes.Buffer
+	if err := sessions.WriteCsv(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected header and one record, got %v", lines)
+	}
+
+	if expect := "2022-03-01 08:00:00,,Garage,,alice,e-Golf,1.500,,12.346,0.0,3.46"; lines[1] != expect {
+		t.Errorf("expected %s, got %s", expect, lines[1])
+	}
+}
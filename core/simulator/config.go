@@ -0,0 +1,151 @@
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Config is the simulation scenario
+type Config struct {
+	Start      time.Time              // simulation start, defaults to today's midnight
+	Duration   time.Duration          // simulated duration, defaults to 24h
+	Interval   time.Duration          // control cycle interval, defaults to 30s
+	Site       map[string]interface{} // site configuration, meters are provided by the simulation
+	PV         ProfileConfig
+	Home       ProfileConfig
+	Battery    *BatteryConfig
+	LoadPoints []LoadPointConfig
+}
+
+// ProfileConfig defines a power profile by csv file, constant power or synthetic clear sky curve
+type ProfileConfig struct {
+	File    string        // csv profile
+	Power   float64       // constant power (W)
+	Peak    float64       // clear sky peak power (W)
+	Sunrise time.Duration // clear sky sunrise time of day, defaults to 6h
+	Sunset  time.Duration // clear sky sunset time of day, defaults to 20h
+}
+
+// BatteryConfig is the home battery
+type BatteryConfig struct {
+	Capacity float64 // kWh
+	SoC      float64 // initial soc (%)
+	Power    float64 // max charge and discharge power (W)
+}
+
+// LoadPointConfig is a loadpoint with its charger and vehicle
+type LoadPointConfig struct {
+	LoadPoint map[string]interface{} // loadpoint configuration, charger and vehicle are provided by the simulation
+	Charger   ChargerConfig
+	Vehicle   *VehicleConfig
+}
+
+// ChargerConfig is the charger hardware
+type ChargerConfig struct {
+	Phases      int           // installed phases, defaults to 3
+	Switchable  bool          // supports 1p3p switching
+	SwitchDelay time.Duration // charging pause while switching phases, defaults to 1m
+}
+
+// VehicleConfig is the vehicle and its connection times
+type VehicleConfig struct {
+	Title      string
+	Capacity   float64       // kWh
+	SoC        float64       // initial soc (%)
+	Phases     int           // phases supported by the onboard charger, defaults to 3
+	MaxCurrent float64       // max current per phase (A), defaults to 16
+	Taper      float64       // soc above which charging power decreases (%), defaults to 80
+	Arrive     time.Duration // connected after start
+	Depart     time.Duration // disconnected after start, zero if remaining connected
+}
+
+// profile creates the configured profile
+func (c ProfileConfig) profile(start time.Time) (Profile, error) {
+	switch {
+	case c.File != "":
+		f, err := os.Open(c.File)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		res, err := ReadCSV(f, start)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.File, err)
+		}
+		return res, nil
+
+	case c.Peak > 0:
+		p := ClearSky{Peak: c.Peak, Sunrise: 6 * time.Hour, Sunset: 20 * time.Hour}
+		if c.Sunrise > 0 {
+			p.Sunrise = c.Sunrise
+		}
+		if c.Sunset > 0 {
+			p.Sunset = c.Sunset
+		}
+		if p.Sunset <= p.Sunrise {
+			return nil, errors.New("sunset must be after sunrise")
+		}
+		return p, nil
+
+	default:
+		return Constant(c.Power), nil
+	}
+}
+
+// defaults applies the scenario's default values
+func (c *Config) defaults() error {
+	if c.Start.IsZero() {
+		c.Start = midnight(time.Now())
+	}
+	if c.Duration == 0 {
+		c.Duration = 24 * time.Hour
+	}
+	if c.Interval == 0 {
+		c.Interval = 30 * time.Second
+	}
+
+	if len(c.LoadPoints) == 0 {
+		return errors.New("missing loadpoints")
+	}
+
+	if c.Battery != nil && (c.Battery.Capacity <= 0 || c.Battery.Power <= 0) {
+		return errors.New("battery: capacity and power must be positive")
+	}
+
+	for i := range c.LoadPoints {
+		lp := &c.LoadPoints[i]
+
+		if lp.Charger.Phases == 0 {
+			lp.Charger.Phases = 3
+		}
+		if lp.Charger.Phases != 1 && lp.Charger.Phases != 3 {
+			return fmt.Errorf("loadpoint %d: invalid charger phases: %d", i+1, lp.Charger.Phases)
+		}
+		if lp.Charger.SwitchDelay == 0 {
+			lp.Charger.SwitchDelay = time.Minute
+		}
+
+		if v := lp.Vehicle; v != nil {
+			if v.Capacity <= 0 {
+				return fmt.Errorf("loadpoint %d: vehicle capacity must be positive", i+1)
+			}
+			if v.Phases == 0 {
+				v.Phases = 3
+			}
+			if v.MaxCurrent == 0 {
+				v.MaxCurrent = 16
+			}
+			if v.Taper == 0 {
+				v.Taper = 80
+			}
+			if v.Title == "" {
+				v.Title = fmt.Sprintf("Vehicle %d", i+1)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,308 @@
+package simulator
+
+import (
+	"math"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core"
+)
+
+// efficiency is the share of charged energy stored in the vehicle battery
+const efficiency = 0.9
+
+// Vehicle models the vehicle battery and its charging curve
+type Vehicle struct {
+	conf VehicleConfig
+	soc  float64
+}
+
+// Title implements the api.Vehicle interface
+func (v *Vehicle) Title() string {
+	return v.conf.Title
+}
+
+// Capacity implements the api.Vehicle interface
+func (v *Vehicle) Capacity() int64 {
+	return int64(math.Round(v.conf.Capacity))
+}
+
+// Identifiers implements the api.Vehicle interface
+func (v *Vehicle) Identifiers() []string {
+	return nil
+}
+
+// OnIdentified implements the api.Vehicle interface
+func (v *Vehicle) OnIdentified() api.ActionConfig {
+	return api.ActionConfig{}
+}
+
+// SoC implements the api.Battery interface
+func (v *Vehicle) SoC() (float64, error) {
+	return math.Round(v.soc), nil
+}
+
+// maxPower is the power accepted by the vehicle. It decreases linearly to 10% above the taper soc.
+func (v *Vehicle) maxPower(phases int) float64 {
+	if v.soc >= 100 {
+		return 0
+	}
+
+	power := v.conf.MaxCurrent * float64(phases) * core.Voltage
+	if v.soc > v.conf.Taper {
+		power *= 0.1 + 0.9*(100-v.soc)/(100-v.conf.Taper)
+	}
+
+	return power
+}
+
+// Charger models the charger and the connected vehicle's charging
+type Charger struct {
+	id       int
+	conf     ChargerConfig
+	vehicle  *Vehicle
+	timeline *timeline
+
+	connected bool
+	enabled   bool
+	current   float64 // A
+	phases    int     // active phases
+	switching int     // pending phases
+	switched  time.Time
+
+	power, energy float64 // W, kWh
+	currents      [3]float64
+	charged       float64 // session energy (kWh)
+	duration      time.Duration
+}
+
+func newCharger(id int, conf ChargerConfig, vehicle *Vehicle, timeline *timeline) *Charger {
+	return &Charger{
+		id:       id,
+		conf:     conf,
+		vehicle:  vehicle,
+		timeline: timeline,
+		phases:   conf.Phases,
+	}
+}
+
+// advance integrates the past interval and updates the charger state
+func (c *Charger) advance(now time.Time, elapsed, dt time.Duration) {
+	energy := c.power * dt.Hours() / 1e3
+	c.energy += energy
+	c.charged += energy
+	if c.power > 0 {
+		c.duration += dt
+	}
+
+	if c.vehicle != nil && c.connected {
+		c.vehicle.soc = math.Min(100, c.vehicle.soc+energy*efficiency/c.vehicle.conf.Capacity*100)
+	}
+
+	// vehicle arrival and departure
+	if v := c.vehicle; v != nil {
+		connected := elapsed >= v.conf.Arrive && (v.conf.Depart == 0 || elapsed < v.conf.Depart)
+		if connected != c.connected {
+			c.connected = connected
+			if connected {
+				c.charged, c.duration = 0, 0
+				c.timeline.event(c.id, "%s connected (%.0f%%)", v.Title(), v.soc)
+			} else {
+				c.timeline.event(c.id, "%s disconnected (%.0f%%)", v.Title(), v.soc)
+			}
+		}
+	}
+
+	// phase switching completed
+	if c.switching > 0 && now.Sub(c.switched) >= c.conf.SwitchDelay {
+		c.phases, c.switching = c.switching, 0
+		c.timeline.event(c.id, "switched to %dp", c.phases)
+	}
+
+	c.power, c.currents = 0, [3]float64{}
+	if !c.connected || !c.enabled || c.switching > 0 {
+		return
+	}
+
+	phases := c.phases
+	if c.vehicle.conf.Phases < phases {
+		phases = c.vehicle.conf.Phases
+	}
+
+	current := math.Min(c.current, c.vehicle.maxPower(phases)/(float64(phases)*core.Voltage))
+	for i := 0; i < phases; i++ {
+		c.currents[i] = current
+	}
+
+	c.power = current * float64(phases) * core.Voltage
+}
+
+// Status implements the api.ChargeState interface
+func (c *Charger) Status() (api.ChargeStatus, error) {
+	switch {
+	case !c.connected:
+		return api.StatusA, nil
+	case c.power > 0:
+		return api.StatusC, nil
+	default:
+		return api.StatusB, nil
+	}
+}
+
+// Enabled implements the api.Charger interface
+func (c *Charger) Enabled() (bool, error) {
+	return c.enabled, nil
+}
+
+// Enable implements the api.Charger interface
+func (c *Charger) Enable(enable bool) error {
+	if enable != c.enabled {
+		c.enabled = enable
+		c.timeline.event(c.id, "charger %s", map[bool]string{false: "disabled", true: "enabled"}[enable])
+	}
+	return nil
+}
+
+// MaxCurrent implements the api.Charger interface
+func (c *Charger) MaxCurrent(current int64) error {
+	return c.MaxCurrentMillis(float64(current))
+}
+
+// MaxCurrentMillis implements the api.ChargerEx interface
+func (c *Charger) MaxCurrentMillis(current float64) error {
+	c.current = current
+	return nil
+}
+
+// CurrentPower implements the api.Meter interface
+func (c *Charger) CurrentPower() (float64, error) {
+	return c.power, nil
+}
+
+// TotalEnergy implements the api.MeterEnergy interface
+func (c *Charger) TotalEnergy() (float64, error) {
+	return c.energy, nil
+}
+
+// Currents implements the api.MeterCurrent interface
+func (c *Charger) Currents() (float64, float64, float64, error) {
+	return c.currents[0], c.currents[1], c.currents[2], nil
+}
+
+// ChargedEnergy implements the api.ChargeRater interface
+func (c *Charger) ChargedEnergy() (float64, error) {
+	return c.charged, nil
+}
+
+// ChargingTime implements the api.ChargeTimer interface
+func (c *Charger) ChargingTime() (time.Duration, error) {
+	return c.duration, nil
+}
+
+// switchableCharger is a charger supporting 1p3p switching
+type switchableCharger struct {
+	*Charger
+}
+
+// Phases1p3p implements the api.ChargePhases interface
+func (c *switchableCharger) Phases1p3p(phases int) error {
+	if phases != c.phases || c.switching > 0 {
+		c.switching = phases
+		c.switched = c.timeline.clock.Now()
+		c.timeline.event(c.id, "switching to %dp", phases)
+	}
+	return nil
+}
+
+// battery models the home battery
+type battery struct {
+	conf BatteryConfig
+	soc  float64
+}
+
+// world models the site's power flows
+type world struct {
+	pv, home Profile
+	battery  *battery
+	chargers []*Charger
+
+	pvPower, homePower, batteryPower, gridPower float64
+	gridCurrents                                [3]float64
+}
+
+// advance integrates the past interval and updates the power flows
+func (w *world) advance(now time.Time, dt time.Duration) {
+	if b := w.battery; b != nil {
+		b.soc -= w.batteryPower * dt.Hours() / 1e3 / b.conf.Capacity * 100
+		b.soc = math.Max(0, math.Min(100, b.soc))
+	}
+
+	w.pvPower = w.pv.Power(now)
+	w.homePower = w.home.Power(now)
+
+	var chargePower float64
+	var chargeCurrents [3]float64
+	for _, c := range w.chargers {
+		chargePower += c.power
+		for i := range chargeCurrents {
+			chargeCurrents[i] += c.currents[i]
+		}
+	}
+
+	// battery balances the site, discharging is positive
+	demand := w.homePower + chargePower - w.pvPower
+
+	w.batteryPower = 0
+	if b := w.battery; b != nil {
+		if demand > 0 && b.soc > 0 || demand < 0 && b.soc < 100 {
+			w.batteryPower = math.Max(-b.conf.Power, math.Min(b.conf.Power, demand))
+		}
+	}
+
+	w.gridPower = demand - w.batteryPower
+
+	// site power is assumed balanced across phases
+	for i := range w.gridCurrents {
+		w.gridCurrents[i] = (w.gridPower-chargePower)/3/core.Voltage + chargeCurrents[i]
+	}
+}
+
+// meter is a site meter reading from the world
+type meter struct {
+	power func() float64
+}
+
+// CurrentPower implements the api.Meter interface
+func (m *meter) CurrentPower() (float64, error) {
+	return m.power(), nil
+}
+
+// gridMeter is the grid meter
+type gridMeter struct {
+	*world
+}
+
+// CurrentPower implements the api.Meter interface
+func (m *gridMeter) CurrentPower() (float64, error) {
+	return m.gridPower, nil
+}
+
+// Currents implements the api.MeterCurrent interface
+func (m *gridMeter) Currents() (float64, float64, float64, error) {
+	return m.gridCurrents[0], m.gridCurrents[1], m.gridCurrents[2], nil
+}
+
+// batteryMeter is the home battery meter
+type batteryMeter struct {
+	*world
+}
+
+// CurrentPower implements the api.Meter interface
+func (m *batteryMeter) CurrentPower() (float64, error) {
+	return m.batteryPower, nil
+}
+
+// SoC implements the api.Battery interface
+func (m *batteryMeter) SoC() (float64, error) {
+	return m.battery.soc, nil
+}
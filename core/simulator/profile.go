@@ -0,0 +1,154 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile provides the power at the given time
+type Profile interface {
+	Power(ts time.Time) float64
+}
+
+// Constant is a profile with constant power
+type Constant float64
+
+// Power implements the Profile interface
+func (p Constant) Power(time.Time) float64 {
+	return float64(p)
+}
+
+// ClearSky is a synthetic, daily repeating pv profile following a sine curve between sunrise and sunset
+type ClearSky struct {
+	Peak            float64       // W
+	Sunrise, Sunset time.Duration // time of day
+}
+
+// Power implements the Profile interface
+func (p ClearSky) Power(ts time.Time) float64 {
+	tod := ts.Sub(midnight(ts))
+	if tod <= p.Sunrise || tod >= p.Sunset {
+		return 0
+	}
+
+	return p.Peak * math.Sin(math.Pi*float64(tod-p.Sunrise)/float64(p.Sunset-p.Sunrise))
+}
+
+type point struct {
+	ts    time.Time
+	power float64
+}
+
+// Series is a profile linearly interpolating between recorded points.
+// Before the first and after the last point the respective point's power is used.
+type Series []point
+
+// Power implements the Profile interface
+func (p Series) Power(ts time.Time) float64 {
+	if len(p) == 0 {
+		return 0
+	}
+
+	i := sort.Search(len(p), func(i int) bool { return !p[i].ts.Before(ts) })
+
+	switch {
+	case i == 0:
+		return p[0].power
+	case i == len(p):
+		return p[len(p)-1].power
+	}
+
+	prev, next := p[i-1], p[i]
+	frac := float64(ts.Sub(prev.ts)) / float64(next.ts.Sub(prev.ts))
+
+	return prev.power + frac*(next.power-prev.power)
+}
+
+func midnight(ts time.Time) time.Time {
+	return time.Date(ts.Year(), ts.Month(), ts.Day(), 0, 0, 0, 0, ts.Location())
+}
+
+// parseOffset parses a duration (1h30m) or time of day (13:30) as offset from start
+func parseOffset(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return d, nil
+	}
+
+	tod, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %s", s)
+	}
+
+	return time.Duration(tod.Hour())*time.Hour + time.Duration(tod.Minute())*time.Minute, nil
+}
+
+// ReadCSV reads a profile of time and power (W) columns. Times are either
+// offsets from the simulation start (1h30m or 13:30) or RFC3339 timestamps.
+// Recorded timestamps are replayed on the start's day, keeping their time of day.
+// A header line and lines starting with # are ignored.
+func ReadCSV(r io.Reader, start time.Time) (Series, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var (
+		res   Series
+		shift time.Duration
+	)
+
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("line %d: expected time and power", line)
+		}
+
+		power, err := strconv.ParseFloat(strings.TrimSpace(rec[1]), 64)
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("line %d: invalid power: %s", line, rec[1])
+		}
+
+		var ts time.Time
+		if ts, err = time.Parse(time.RFC3339, strings.TrimSpace(rec[0])); err == nil {
+			if len(res) == 0 {
+				shift = midnight(start).Sub(midnight(ts.In(start.Location())))
+			}
+			ts = ts.Add(shift)
+		} else {
+			offset, err := parseOffset(strings.TrimSpace(rec[0]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			ts = start.Add(offset)
+		}
+
+		if len(res) > 0 && !ts.After(res[len(res)-1].ts) {
+			return nil, fmt.Errorf("line %d: time not ascending", line)
+		}
+
+		res = append(res, point{ts: ts, power: power})
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("empty profile")
+	}
+
+	return res, nil
+}
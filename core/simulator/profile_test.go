@@ -0,0 +1,59 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadCSV(t *testing.T) {
+	start := time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC)
+
+	p, err := ReadCSV(strings.NewReader("time,power\n# night\n06:00,0\n7h,1000\n08:00,3000\n"), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		ts    time.Time
+		power float64
+	}{
+		{start, 0},
+		{start.Add(6*time.Hour + 30*time.Minute), 500},
+		{start.Add(7*time.Hour + 30*time.Minute), 2000},
+		{start.Add(12 * time.Hour), 3000},
+	} {
+		if power := p.Power(tc.ts); power != tc.power {
+			t.Errorf("%v: expected %.0f, got %.0f", tc.ts, tc.power, power)
+		}
+	}
+
+	// recorded profiles are replayed on the start day
+	p, err = ReadCSV(strings.NewReader("2021-03-01T10:00:00Z,100\n2021-03-01T11:00:00Z,200\n"), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if power := p.Power(start.Add(10*time.Hour + 30*time.Minute)); power != 150 {
+		t.Errorf("expected 150, got %.0f", power)
+	}
+
+	for _, csv := range []string{"", "06:00,0\n05:00,100\n", "06:00\n", "06:00,0\nfoo,100\n"} {
+		if _, err := ReadCSV(strings.NewReader(csv), start); err == nil {
+			t.Errorf("%q: expected error", csv)
+		}
+	}
+}
+
+func TestClearSky(t *testing.T) {
+	p := ClearSky{Peak: 1000, Sunrise: 6 * time.Hour, Sunset: 20 * time.Hour}
+	day := time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC)
+
+	if power := p.Power(day.Add(13 * time.Hour)); power != 1000 {
+		t.Errorf("expected peak at noon, got %.0f", power)
+	}
+
+	if power := p.Power(day.Add(22 * time.Hour)); power != 0 {
+		t.Errorf("expected no power at night, got %.0f", power)
+	}
+}
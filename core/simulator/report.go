@@ -0,0 +1,192 @@
+package simulator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+)
+
+// Event is a control or vehicle change
+type Event struct {
+	Time      time.Time
+	LoadPoint int // loadpoint id starting at 1
+	Message   string
+}
+
+// LoadPointSample is the loadpoint state
+type LoadPointSample struct {
+	Mode    api.ChargeMode
+	Status  api.ChargeStatus
+	Enabled bool
+	Current float64 // A
+	Phases  int
+	Power   float64 // W
+	SoC     float64 // vehicle soc (%)
+}
+
+// Sample is the site state at the end of a control cycle
+type Sample struct {
+	Time       time.Time
+	PV         float64 // W
+	Home       float64 // W
+	Battery    float64 // W, positive when discharging
+	BatterySoC float64 // %
+	Grid       float64 // W, positive when importing
+	LoadPoints []LoadPointSample
+}
+
+// Summary is the energy balance of the simulation in kWh
+type Summary struct {
+	PV, Home, GridImport, GridExport float64
+	Charged, ChargedGrid             float64
+}
+
+// Report is the simulation timeline
+type Report struct {
+	Samples []Sample
+	Events  []Event
+	Summary Summary
+}
+
+// timeline records events using the simulation clock
+type timeline struct {
+	clock  clock.Clock
+	events []Event
+}
+
+func (t *timeline) event(lp int, format string, v ...interface{}) {
+	t.events = append(t.events, Event{
+		Time:      t.clock.Now(),
+		LoadPoint: lp,
+		Message:   fmt.Sprintf(format, v...),
+	})
+}
+
+// account adds the sample's energy over the interval to the summary
+func (s *Summary) account(sample Sample, dt time.Duration) {
+	h := dt.Hours() / 1e3
+
+	var charge float64
+	for _, lp := range sample.LoadPoints {
+		charge += lp.Power
+	}
+
+	s.PV += sample.PV * h
+	s.Home += sample.Home * h
+	s.GridImport += math.Max(0, sample.Grid) * h
+	s.GridExport += math.Max(0, -sample.Grid) * h
+	s.Charged += charge * h
+	s.ChargedGrid += math.Min(charge, math.Max(0, sample.Grid)) * h
+}
+
+// WriteEvents writes the events
+func (r *Report) WriteEvents(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tLP\tEVENT")
+
+	for _, e := range r.Events {
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", e.Time.Format("2006-01-02 15:04:05"), e.LoadPoint, e.Message)
+	}
+
+	return tw.Flush()
+}
+
+// WriteTimeline writes samples at the given resolution as table
+func (r *Report) WriteTimeline(w io.Writer, resolution time.Duration) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+
+	header := "TIME\tPV\tHOME\tBATTERY\tSOC\tGRID\t"
+	if len(r.Samples) > 0 {
+		for i := range r.Samples[0].LoadPoints {
+			header += fmt.Sprintf("LP%d\tSTATUS\tCURRENT\tPHASES\tPOWER\tSOC\t", i+1)
+		}
+	}
+	fmt.Fprintln(tw, header)
+
+	var next time.Time
+	for _, s := range r.Samples {
+		if s.Time.Before(next) {
+			continue
+		}
+		next = s.Time.Truncate(resolution).Add(resolution)
+
+		fmt.Fprintf(tw, "%s\t%.0f\t%.0f\t%.0f\t%.0f%%\t%.0f\t", s.Time.Format("01-02 15:04"), s.PV, s.Home, s.Battery, s.BatterySoC, s.Grid)
+		for _, lp := range s.LoadPoints {
+			current := "-"
+			if lp.Enabled {
+				current = fmt.Sprintf("%.1fA", lp.Current)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%dp\t%.0f\t%.0f%%\t", lp.Mode, lp.Status, current, lp.Phases, lp.Power, lp.SoC)
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+// WriteSummary writes the energy balance
+func (r *Report) WriteSummary(w io.Writer) error {
+	s := r.Summary
+
+	selfConsumption, solarShare := 0.0, 0.0
+	if s.PV > 0 {
+		selfConsumption = 100 * (s.PV - s.GridExport) / s.PV
+	}
+	if s.Charged > 0 {
+		solarShare = 100 * (s.Charged - s.ChargedGrid) / s.Charged
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "PV\t%.1fkWh\n", s.PV)
+	fmt.Fprintf(tw, "Home\t%.1fkWh\n", s.Home)
+	fmt.Fprintf(tw, "Grid import\t%.1fkWh\n", s.GridImport)
+	fmt.Fprintf(tw, "Grid export\t%.1fkWh\n", s.GridExport)
+	fmt.Fprintf(tw, "Charged\t%.1fkWh (%.0f%% solar)\n", s.Charged, solarShare)
+	fmt.Fprintf(tw, "Self-consumption\t%.0f%%\n", selfConsumption)
+
+	return tw.Flush()
+}
+
+// WriteCSV writes all samples as csv
+func (r *Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"time", "pv", "home", "battery", "batterySoC", "grid"}
+	if len(r.Samples) > 0 {
+		for i := range r.Samples[0].LoadPoints {
+			for _, col := range []string{"mode", "status", "enabled", "current", "phases", "power", "soc"} {
+				header = append(header, fmt.Sprintf("lp%d.%s", i+1, col))
+			}
+		}
+	}
+
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+
+	f := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	for _, s := range r.Samples {
+		rec := []string{s.Time.Format(time.RFC3339), f(s.PV), f(s.Home), f(s.Battery), f(s.BatterySoC), f(s.Grid)}
+		for _, lp := range s.LoadPoints {
+			rec = append(rec, string(lp.Mode), lp.Status.String(), strconv.FormatBool(lp.Enabled),
+				f(lp.Current), strconv.Itoa(lp.Phases), f(lp.Power), f(lp.SoC))
+		}
+
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,221 @@
+// Package simulator runs the site's control loop against modelled meters, chargers and vehicles on a simulated clock
+package simulator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core"
+	"github.com/evcc-io/evcc/core/rfid"
+	"github.com/evcc-io/evcc/push"
+	"github.com/evcc-io/evcc/tariff"
+	"github.com/evcc-io/evcc/util"
+)
+
+// Simulation is a site running on simulated time
+type Simulation struct {
+	conf     Config
+	clock    *clock.Mock
+	timeline *timeline
+	world    *world
+	site     *core.Site
+	lps      []*core.LoadPoint
+}
+
+// devices provides the simulated devices to site and loadpoints by name
+type devices struct {
+	meters   map[string]api.Meter
+	chargers map[string]api.Charger
+	vehicles map[string]api.Vehicle
+}
+
+func (d *devices) Meter(name string) api.Meter     { return d.meters[name] }
+func (d *devices) Charger(name string) api.Charger { return d.chargers[name] }
+func (d *devices) Vehicle(name string) api.Vehicle { return d.vehicles[name] }
+func (d *devices) Tokens() rfid.Tokens             { return nil }
+
+// set sets the key unless configured, ignoring case like the config decoder
+func set(conf map[string]interface{}, key string, val interface{}) {
+	for k := range conf {
+		if strings.EqualFold(k, key) {
+			return
+		}
+	}
+	conf[key] = val
+}
+
+// New creates a simulation from the scenario
+func New(conf Config) (*Simulation, error) {
+	if err := conf.defaults(); err != nil {
+		return nil, err
+	}
+
+	clck := clock.NewMock()
+	clck.Set(conf.Start)
+
+	s := &Simulation{
+		conf:     conf,
+		clock:    clck,
+		timeline: &timeline{clock: clck},
+		world:    new(world),
+	}
+
+	var err error
+	if s.world.pv, err = conf.PV.profile(conf.Start); err != nil {
+		return nil, fmt.Errorf("pv: %w", err)
+	}
+	if s.world.home, err = conf.Home.profile(conf.Start); err != nil {
+		return nil, fmt.Errorf("home: %w", err)
+	}
+
+	d := &devices{
+		meters: map[string]api.Meter{
+			"grid": &gridMeter{s.world},
+			"pv":   &meter{func() float64 { return s.world.pvPower }},
+		},
+		chargers: make(map[string]api.Charger),
+		vehicles: make(map[string]api.Vehicle),
+	}
+
+	meters := map[string]interface{}{"grid": "grid", "pv": "pv"}
+	if conf.Battery != nil {
+		s.world.battery = &battery{conf: *conf.Battery, soc: conf.Battery.SoC}
+		d.meters["battery"] = &batteryMeter{s.world}
+		meters["battery"] = "battery"
+	}
+
+	for i, lc := range conf.LoadPoints {
+		id := i + 1
+		name := fmt.Sprintf("lp%d", id)
+
+		other := make(map[string]interface{})
+		for k, v := range lc.LoadPoint {
+			other[k] = v
+		}
+		other["charger"] = name
+		set(other, "title", fmt.Sprintf("Loadpoint %d", id))
+
+		var vehicle *Vehicle
+		if lc.Vehicle != nil {
+			vehicle = &Vehicle{conf: *lc.Vehicle, soc: lc.Vehicle.SoC}
+			d.vehicles[name] = vehicle
+			other["vehicle"] = name
+		}
+
+		charger := newCharger(id, lc.Charger, vehicle, s.timeline)
+		s.world.chargers = append(s.world.chargers, charger)
+
+		d.chargers[name] = charger
+		if lc.Charger.Switchable {
+			d.chargers[name] = &switchableCharger{charger}
+		} else {
+			set(other, "phases", lc.Charger.Phases)
+		}
+
+		lp, err := core.NewLoadPointFromConfig(util.NewLogger(fmt.Sprintf("lp-%d", id)), d, other)
+		if err != nil {
+			return nil, fmt.Errorf("loadpoint %d: %w", id, err)
+		}
+
+		lp.SetClock(clck)
+		s.lps = append(s.lps, lp)
+	}
+
+	other := map[string]interface{}{"title": "Simulation"}
+	for k, v := range conf.Site {
+		other[k] = v
+	}
+	other["meters"] = meters
+
+	if s.site, err = core.NewSiteFromConfig(util.NewLogger("site"), d, other, s.lps, nil, tariff.Tariffs{}, nil); err != nil {
+		return nil, fmt.Errorf("site: %w", err)
+	}
+
+	s.site.SetClock(clck)
+
+	// discard published values
+	uiChan := make(chan util.Param)
+	pushChan := make(chan push.Event)
+	go func() {
+		for {
+			select {
+			case <-uiChan:
+			case <-pushChan:
+			}
+		}
+	}()
+
+	for _, c := range s.world.chargers {
+		c.advance(conf.Start, 0, 0)
+	}
+	s.world.advance(conf.Start, 0)
+
+	s.site.Prepare(uiChan, pushChan)
+
+	return s, nil
+}
+
+// Run runs the simulation and returns its report
+func (s *Simulation) Run() *Report {
+	res := new(Report)
+
+	for elapsed := s.conf.Interval; elapsed <= s.conf.Duration; elapsed += s.conf.Interval {
+		now := s.conf.Start.Add(elapsed)
+		s.clock.Set(now)
+
+		for _, c := range s.world.chargers {
+			c.advance(now, elapsed, s.conf.Interval)
+		}
+		s.world.advance(now, s.conf.Interval)
+
+		s.site.Step()
+
+		sample := s.sample(now)
+		res.Samples = append(res.Samples, sample)
+		res.Summary.account(sample, s.conf.Interval)
+	}
+
+	res.Events = s.timeline.events
+
+	return res
+}
+
+// sample records the state at the end of the control cycle
+func (s *Simulation) sample(now time.Time) Sample {
+	res := Sample{
+		Time:    now,
+		PV:      s.world.pvPower,
+		Home:    s.world.homePower,
+		Battery: s.world.batteryPower,
+		Grid:    s.world.gridPower,
+	}
+
+	if s.world.battery != nil {
+		res.BatterySoC = s.world.battery.soc
+	}
+
+	for i, lp := range s.lps {
+		c := s.world.chargers[i]
+
+		lps := LoadPointSample{
+			Mode:    lp.GetMode(),
+			Status:  lp.GetStatus(),
+			Enabled: c.enabled,
+			Current: c.current,
+			Phases:  c.phases,
+			Power:   c.power,
+		}
+
+		if c.vehicle != nil {
+			lps.SoC = math.Round(c.vehicle.soc*10) / 10
+		}
+
+		res.LoadPoints = append(res.LoadPoints, lps)
+	}
+
+	return res
+}
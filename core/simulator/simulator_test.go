@@ -0,0 +1,121 @@
+package simulator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+)
+
+var start = time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC)
+
+func hasEvent(r *Report, msg string) bool {
+	for _, e := range r.Events {
+		if e.Message == msg {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSimulatePV(t *testing.T) {
+	s, err := New(Config{
+		Start:    start,
+		Duration: 20 * time.Hour,
+		Interval: time.Minute,
+		PV:       ProfileConfig{Peak: 8000},
+		Home:     ProfileConfig{Power: 500},
+		LoadPoints: []LoadPointConfig{{
+			LoadPoint: map[string]interface{}{"mode": "pv"},
+			Charger:   ChargerConfig{Switchable: true},
+			Vehicle:   &VehicleConfig{Title: "e-Golf", Capacity: 40, SoC: 20, Arrive: 7 * time.Hour, Depart: 18 * time.Hour},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.Run()
+
+	for _, msg := range []string{"e-Golf connected (20%)", "charger enabled", "switching to 1p", "switched to 3p"} {
+		if !hasEvent(r, msg) {
+			t.Errorf("missing event: %s", msg)
+		}
+	}
+
+	var charged bool
+	for _, s := range r.Samples {
+		if lp := s.LoadPoints[0]; lp.Status == api.StatusC {
+			charged = true
+
+			// no grid charging in pv mode beyond the control loop's tolerance
+			if s.Grid > 1000 {
+				t.Errorf("%v: unexpected grid import %.0fW", s.Time, s.Grid)
+			}
+		}
+	}
+
+	if !charged {
+		t.Error("vehicle not charged")
+	}
+
+	if soc := r.Samples[len(r.Samples)-1].LoadPoints[0].SoC; soc <= 50 {
+		t.Errorf("expected soc rise, got %.0f%%", soc)
+	}
+
+	if sum := r.Summary; sum.Charged == 0 || sum.ChargedGrid > sum.Charged/10 {
+		t.Errorf("unexpected summary: %+v", sum)
+	}
+}
+
+func TestSimulateNow(t *testing.T) {
+	s, err := New(Config{
+		Start:    start,
+		Duration: 2 * time.Hour,
+		Interval: time.Minute,
+		Home:     ProfileConfig{Power: 500},
+		Battery:  &BatteryConfig{Capacity: 10, SoC: 50, Power: 3000},
+		LoadPoints: []LoadPointConfig{{
+			LoadPoint: map[string]interface{}{"mode": "now", "maxCurrent": 16},
+			Charger:   ChargerConfig{Phases: 3},
+			Vehicle:   &VehicleConfig{Capacity: 50, SoC: 50, Phases: 1, Arrive: time.Hour},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := s.Run()
+
+	last := r.Samples[len(r.Samples)-1]
+	if lp := last.LoadPoints[0]; lp.Status != api.StatusC || lp.Power != 16*230 {
+		t.Errorf("expected single phase vehicle charging at 16A, got %+v", lp)
+	}
+
+	// battery covers the home and the charging power up to its limit
+	if last.Battery != 3000 || last.Grid != 500+16*230-3000 {
+		t.Errorf("unexpected power flows: %+v", last)
+	}
+
+	var sb strings.Builder
+	if err := r.WriteTimeline(&sb, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if lines := strings.Split(strings.TrimSpace(sb.String()), "\n"); len(lines) != 4 {
+		t.Errorf("expected header and hourly samples, got:\n%s", sb.String())
+	}
+}
+
+func TestConfigDefaults(t *testing.T) {
+	for _, conf := range []Config{
+		{},
+		{LoadPoints: []LoadPointConfig{{Charger: ChargerConfig{Phases: 2}}}},
+		{LoadPoints: []LoadPointConfig{{Vehicle: &VehicleConfig{}}}},
+	} {
+		if _, err := New(conf); err == nil {
+			t.Errorf("%+v: expected error", conf)
+		}
+	}
+}
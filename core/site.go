@@ -8,11 +8,17 @@ import (
 	"time"
 
 	"github.com/avast/retry-go/v3"
+	"github.com/benbjohnson/clock"
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/curtailment"
 	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/soc"
+	"github.com/evcc-io/evcc/provider"
 	"github.com/evcc-io/evcc/push"
+	"github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/tariff"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/metrics"
 )
 
 // Updater abstracts the LoadPoint implementation for testing
@@ -22,13 +28,16 @@ type Updater interface {
 
 // Site is the main configuration container. A site can host multiple loadpoints.
 type Site struct {
-	uiChan       chan<- util.Param // client push messages
-	lpUpdateChan chan *LoadPoint
+	uiChan          chan<- util.Param // client push messages
+	lpUpdateChan    chan *LoadPoint
+	reconfigureChan chan func()   // device changes applied between updates
+	stopped         chan struct{} // closed once the control loop has exited
 
 	*Health
 
 	sync.Mutex
-	log *util.Logger
+	log   *util.Logger
+	clock clock.Clock
 
 	// configuration
 	Title         string       `mapstructure:"title"`         // UI title
@@ -38,20 +47,35 @@ type Site struct {
 	PrioritySoC   float64      `mapstructure:"prioritySoC"` // prefer battery up to this SoC
 	BufferSoC     float64      `mapstructure:"bufferSoC"`   // ignore battery above this SoC
 
+	MaxGridCurrent float64           `mapstructure:"maxGridCurrent"` // Main fuse per-phase current limit
+	MaxPower       float64           `mapstructure:"maxPower"`       // Grid import power limit
+	Curtailment    CurtailmentConfig // Grid operator curtailment
+
 	// meters
 	gridMeter     api.Meter   // Grid usage meter
 	pvMeters      []api.Meter // PV generation meters
 	batteryMeters []api.Meter // Battery charging meters
 
-	tariffs    tariff.Tariffs // Tariff
-	loadpoints []*LoadPoint   // Loadpoints
-	savings    *Savings       // Savings
+	tariffs  tariff.Tariffs // Tariff
+	forecast api.Forecast   // Solar forecast
+
+	curtailmentG     func() (bool, error) // Grid operator curtailment signal
+	curtailmentStore *curtailment.DB      // Curtailment audit trail
+	curtailment      *curtailment.Period  // Active curtailment period
+	loadpoints       []*LoadPoint         // Loadpoints
+	consumers        []*Consumer          // Controllable loads
+	savings          *Savings             // Savings
+	next             int                  // Next loadpoint updated by Step
 
 	// cached state
-	gridPower       float64 // Grid power
-	pvPower         float64 // PV power
-	batteryPower    float64 // Battery charge power
-	batteryBuffered bool    // Battery buffer active
+	gridPower       float64         // Grid power
+	gridCurrents    []float64       // Grid phase currents
+	pvPower         float64         // PV power
+	batteryPower    float64         // Battery charge power
+	batteryBuffered bool            // Battery buffer active
+	homePower       float64         // Home consumption
+	devices         deviceValues    // Values read from meters during the update cycle
+	batteryMode     api.BatteryMode // Battery operation mode
 }
 
 // MetersConfig contains the loadpoint's meter configuration
@@ -69,7 +93,9 @@ func NewSiteFromConfig(
 	cp configProvider,
 	other map[string]interface{},
 	loadpoints []*LoadPoint,
+	consumers []*Consumer,
 	tariffs tariff.Tariffs,
+	forecast api.Forecast,
 ) (*Site, error) {
 	site := NewSite()
 	if err := util.DecodeOther(other, &site); err != nil {
@@ -78,13 +104,43 @@ func NewSiteFromConfig(
 
 	Voltage = site.Voltage
 	site.loadpoints = loadpoints
+	site.consumers = consumers
 	site.tariffs = tariffs
+	site.forecast = forecast
 	site.savings = NewSavings(tariffs)
 
+	// plan target charging using dynamic grid tariff and solar forecast
+	rates, _ := tariffs.Grid.(api.TariffRates)
+	if rates != nil || forecast != nil {
+		for _, lp := range loadpoints {
+			planner := soc.NewPlanner(lp.log, rates)
+			if forecast != nil {
+				lp.forecast = &surplusForecast{site: site}
+				planner.WithForecast(lp.forecast)
+			}
+
+			lp.socTimer.SetPlanner(planner)
+		}
+	}
+
 	if site.Meters.GridMeterRef != "" {
 		site.gridMeter = cp.Meter(site.Meters.GridMeterRef)
 	}
 
+	// grid operator curtailment
+	if site.Curtailment.Signal != nil {
+		var err error
+		if site.curtailmentG, err = provider.NewBoolGetterFromConfig(*site.Curtailment.Signal); err != nil {
+			return nil, fmt.Errorf("curtailment: %w", err)
+		}
+
+		if db.Instance != nil {
+			if site.curtailmentStore, err = curtailment.NewStore(db.Instance); err != nil {
+				return nil, fmt.Errorf("curtailment: %w", err)
+			}
+		}
+	}
+
 	// multiple pv
 	for _, ref := range site.Meters.PVMetersRef {
 		pv := cp.Meter(ref)
@@ -98,6 +154,7 @@ func NewSiteFromConfig(
 		}
 		pv := cp.Meter(site.Meters.PVMeterRef)
 		site.pvMeters = append(site.pvMeters, pv)
+		site.Meters.PVMetersRef = []string{site.Meters.PVMeterRef}
 	}
 
 	// multiple batteries
@@ -113,6 +170,7 @@ func NewSiteFromConfig(
 		}
 		battery := cp.Meter(site.Meters.BatteryMeterRef)
 		site.batteryMeters = append(site.batteryMeters, battery)
+		site.Meters.BatteryMetersRef = []string{site.Meters.BatteryMeterRef}
 	}
 
 	// configure meter from references
@@ -127,12 +185,26 @@ func NewSiteFromConfig(
 func NewSite() *Site {
 	lp := &Site{
 		log:     util.NewLogger("site"),
+		clock:   clock.New(),
 		Voltage: 230, // V
 	}
 
 	return lp
 }
 
+// SetClock sets the clock used by the site, its savings and health checker, e.g. for simulated time
+func (site *Site) SetClock(clock clock.Clock) {
+	site.clock = clock
+
+	if site.savings != nil {
+		site.savings.SetClock(clock)
+	}
+
+	if site.Health != nil {
+		site.Health.clock = clock
+	}
+}
+
 // LoadPoints returns the array of associated loadpoints
 func (site *Site) LoadPoints() []loadpoint.API {
 	res := make([]loadpoint.API, len(site.loadpoints))
@@ -165,6 +237,10 @@ func (site *Site) DumpConfig() {
 		presence[len(site.batteryMeters) > 0],
 	)
 
+	if site.loadManagement() {
+		site.log.INFO.Printf("  limits:      grid current %.0fA power %.0fW", site.MaxGridCurrent, site.MaxPower)
+	}
+
 	if site.gridMeter != nil {
 		site.log.INFO.Println(meterCapabilities("grid", site.gridMeter))
 	}
@@ -185,6 +261,11 @@ func (site *Site) DumpConfig() {
 		}
 	}
 
+	for _, c := range site.consumers {
+		minPower, maxPower := c.powerRange()
+		c.log.INFO.Printf("consumer %s: %s %.0f-%.0fW priority %d meter %s", c.Title, c.Type, minPower, maxPower, c.Priority, presence[c.meter != nil])
+	}
+
 	for i, lp := range site.loadpoints {
 		lp.log.INFO.Printf("loadpoint %d:", i+1)
 		lp.log.INFO.Printf("  mode:        %s", lp.GetMode())
@@ -236,25 +317,28 @@ func (site *Site) publish(key string, val interface{}) {
 }
 
 // updateMeter updates and publishes single meter
-func (site *Site) updateMeter(meter api.Meter, power *float64) func() error {
-	return func() error {
+func (site *Site) updateMeter(device string, meter api.Meter, power *float64) func() error {
+	return metrics.Observe(device, func() error {
 		value, err := meter.CurrentPower()
 		if err == nil {
 			*power = value // update value if no error
 		}
 
 		return err
-	}
+	})
 }
 
 // updateMeter updates and publishes single meter
 func (site *Site) updateMeters() error {
+	site.devices = make(deviceValues)
+
 	retryMeter := func(name string, meter api.Meter, power *float64) error {
 		if meter == nil {
 			return nil
 		}
 
-		err := retry.Do(site.updateMeter(meter, power), retryOptions...)
+		device := site.Meters.GridMeterRef
+		err := retry.Do(site.updateMeter(device, meter, power), deviceRetryOptions(device)...)
 
 		if err == nil {
 			site.log.DEBUG.Printf("%s power: %.0fW", name, *power)
@@ -268,16 +352,21 @@ func (site *Site) updateMeters() error {
 	}
 
 	err := retryMeter("grid", site.gridMeter, &site.gridPower)
+	if err == nil && site.gridMeter != nil {
+		setDeviceValue(site.devices, "meter", site.Meters.GridMeterRef, "power", site.gridPower)
+	}
 
 	if len(site.pvMeters) > 0 {
 		site.pvPower = 0
 
 		for id, meter := range site.pvMeters {
 			var power float64
-			err := retry.Do(site.updateMeter(meter, &power), retryOptions...)
+			device := site.Meters.PVMetersRef[id]
+			err := retry.Do(site.updateMeter(device, meter, &power), deviceRetryOptions(device)...)
 
 			if err == nil {
 				site.pvPower += power
+				setDeviceValue(site.devices, "meter", device, "power", power)
 				if power < -1000 {
 					site.log.WARN.Printf("pv %d power: %.0fW is negative - check configuration if sign is correct", id, power)
 				}
@@ -296,10 +385,12 @@ func (site *Site) updateMeters() error {
 
 		for id, meter := range site.batteryMeters {
 			var power float64
-			err := retry.Do(site.updateMeter(meter, &power), retryOptions...)
+			device := site.Meters.BatteryMetersRef[id]
+			err := retry.Do(site.updateMeter(device, meter, &power), deviceRetryOptions(device)...)
 
 			if err == nil {
 				site.batteryPower += power
+				setDeviceValue(site.devices, "meter", device, "power", power)
 			} else {
 				site.log.ERROR.Println(fmt.Errorf("updating battery meter %d: %v", id, err))
 			}
@@ -310,11 +401,14 @@ func (site *Site) updateMeters() error {
 	}
 
 	// currents
+	site.gridCurrents = nil
 	if phaseMeter, ok := site.gridMeter.(api.MeterCurrent); err == nil && ok {
 		i1, i2, i3, err := phaseMeter.Currents()
 		if err == nil {
-			site.log.DEBUG.Printf("grid currents: %.3gA", []float64{i1, i2, i3})
-			site.publish("gridCurrents", []float64{i1, i2, i3})
+			site.gridCurrents = []float64{i1, i2, i3}
+			setDeviceValue(site.devices, "meter", site.Meters.GridMeterRef, "currents", site.gridCurrents)
+			site.log.DEBUG.Printf("grid currents: %.3gA", site.gridCurrents)
+			site.publish("gridCurrents", site.gridCurrents)
 		} else {
 			site.log.ERROR.Println(fmt.Errorf("updating grid meter currents: %v", err))
 		}
@@ -325,6 +419,7 @@ func (site *Site) updateMeters() error {
 		val, err := energyMeter.TotalEnergy()
 		if err == nil {
 			site.publish("gridEnergy", val)
+			setDeviceValue(site.devices, "meter", site.Meters.GridMeterRef, "energy", val)
 		} else {
 			site.log.ERROR.Println(fmt.Errorf("updating grid meter energy: %v", err))
 		}
@@ -361,6 +456,7 @@ func (site *Site) sitePower() (float64, error) {
 				site.log.ERROR.Println(err)
 			} else {
 				site.log.DEBUG.Printf("battery soc %d: %.0f%%", id, soc)
+				setDeviceValue(site.devices, "meter", site.Meters.BatteryMetersRef[id], "soc", soc)
 				socs += soc / float64(len(site.batteryMeters))
 			}
 		}
@@ -369,6 +465,12 @@ func (site *Site) sitePower() (float64, error) {
 		site.Lock()
 		defer site.Unlock()
 
+		// battery charging from grid is no surplus
+		if site.batteryMode == api.BatteryCharge && batteryPower < 0 {
+			site.log.DEBUG.Printf("ignoring battery grid charging: %.0fW", batteryPower)
+			batteryPower = 0
+		}
+
 		// if battery is charging below prioritySoC give it priority
 		if socs < site.PrioritySoC && batteryPower < 0 {
 			site.log.DEBUG.Printf("giving priority to battery charging at soc: %.0f", socs)
@@ -402,20 +504,213 @@ func (site *Site) update(lp Updater) {
 		totalChargePower += lp.GetChargePower()
 	}
 
+	site.updateCurtailment(totalChargePower)
+
 	if sitePower, err := site.sitePower(); err == nil {
+		connected := site.connected(lp)
+
+		// distribute main fuse budget before updating the loadpoint
+		site.allocateCurrents(lp, connected)
+
+		// hand the loadpoint its share of pv surplus
+		sitePower = site.distributeSurplus(lp, connected, sitePower)
+
 		lp.Update(sitePower, cheap, site.batteryBuffered)
 
+		site.updateBatteryMode(cheap)
+
 		// ignore negative pvPower values as that means it is not an energy source but consumption
 		homePower := site.gridPower + math.Max(0, site.pvPower) + site.batteryPower - totalChargePower
 		homePower = math.Max(homePower, 0)
 		site.publish("homePower", homePower)
+		site.homePower = homePower
 
 		site.Health.Update()
 	}
 
+	site.publish("devices", site.devices)
+
+	site.publishForecast()
+
 	// update savings
 	// TODO: use energy instead of current power for better results
 	site.savings.Update(site, site.gridPower, site.pvPower, site.batteryPower, totalChargePower)
+
+	// update charging sessions
+	share := site.savings.shareOfSelfProducedEnergy(site.gridPower, site.pvPower, site.batteryPower)
+	gridPrice, feedinPrice := site.savings.currentGridPrice(), site.savings.currentFeedInPrice()
+	for _, lp := range site.loadpoints {
+		lp.accountSession(share, gridPrice, feedinPrice)
+	}
+}
+
+// loadManagement returns true if site-wide grid limits are configured
+func (site *Site) loadManagement() bool {
+	return site.MaxGridCurrent > 0 || site.MaxPower > 0
+}
+
+// connected returns the connection status of all loadpoints. The updated loadpoint's status
+// is read from its charger since the loadpoint refreshes it only after allocation.
+func (site *Site) connected(updated Updater) []bool {
+	res := make([]bool, 0, len(site.loadpoints))
+
+	for _, lp := range site.loadpoints {
+		if lp == updated {
+			res = append(res, lp.chargerConnected())
+		} else {
+			res = append(res, lp.connected())
+		}
+	}
+
+	return res
+}
+
+// allocateCurrents divides the site's grid current and power budget across all loadpoints.
+// Limits of loadpoints that are not updated in this cycle are enforced immediately.
+func (site *Site) allocateCurrents(updated Updater, connected []bool) {
+	if !site.loadManagement() && !site.curtailed() {
+		for _, lp := range site.loadpoints {
+			lp.clearCurrentLimit()
+		}
+
+		return
+	}
+
+	var budget phaseCurrents
+	var lpCurrents []phaseCurrents
+	var totalChargePower float64
+
+	demands := make([]currentDemand, 0, len(site.loadpoints))
+
+	for i, lp := range site.loadpoints {
+		chargePower := lp.GetChargePower()
+		totalChargePower += chargePower
+
+		currents := lp.phaseCurrents(chargePower)
+		lpCurrents = append(lpCurrents, currents)
+
+		demands = append(demands, currentDemand{
+			active:     connected[i] && lp.GetMode() != api.ModeOff,
+			priority:   lp.GetPriority(),
+			phases:     lp.currentPhases(),
+			minCurrent: lp.GetMinCurrent(),
+			maxCurrent: lp.effectiveMaxCurrent(),
+		})
+	}
+
+	// per-phase budget is the main fuse current minus the non-charging household consumption
+	for p := range budget {
+		budget[p] = math.Inf(1)
+
+		if site.MaxGridCurrent > 0 {
+			var base float64
+			if len(site.gridCurrents) == len(budget) {
+				// grid currents are unsigned, exporting reduces the phase load
+				base = site.gridCurrents[p]
+				if site.gridPower < 0 {
+					base = -base
+				}
+
+				for _, currents := range lpCurrents {
+					base -= currents[p]
+				}
+			} else {
+				// estimate household consumption evenly distributed across phases
+				base = powerToCurrent(site.gridPower-totalChargePower, len(budget))
+			}
+
+			budget[p] = math.Max(site.MaxGridCurrent-base, 0)
+		}
+	}
+
+	power := math.Inf(1)
+	if site.MaxPower > 0 {
+		power = math.Max(site.MaxPower-(site.gridPower-totalChargePower), 0)
+	}
+
+	// grid operator curtailment caps total charge power
+	if site.curtailed() {
+		power = math.Min(power, site.Curtailment.Limit)
+	}
+
+	site.log.DEBUG.Printf("load management budget: %.3gA %.0fW", budget, power)
+
+	for i, current := range allocateCurrents(budget, power, demands) {
+		lp := site.loadpoints[i]
+		lp.setCurrentLimit(current)
+
+		if lp != updated {
+			lp.enforceCurrentLimit()
+		}
+	}
+}
+
+// distributeSurplus divides the available pv surplus across all loadpoints in pv mode and all consumers by priority.
+// Consumers are updated with their share, the site power as seen by the updated loadpoint is returned.
+func (site *Site) distributeSurplus(updated Updater, connected []bool, sitePower float64) float64 {
+	id := -1
+	available := -sitePower
+	demands := make([]powerDemand, 0, len(site.loadpoints))
+
+	for i, lp := range site.loadpoints {
+		mode := lp.GetMode()
+		active := connected[i] && (mode == api.ModePV || mode == api.ModeMinPV)
+
+		minPower, maxPower := lp.pvPowerRange()
+		demands = append(demands, powerDemand{
+			active:   active,
+			enabled:  lp.enabled,
+			priority: lp.GetPriority(),
+			minPower: minPower,
+			maxPower: maxPower,
+		})
+
+		// power consumed by pv loadpoints is available for redistribution
+		if active {
+			available += lp.GetChargePower()
+		}
+
+		if lp == updated {
+			id = i
+		}
+	}
+
+	// power consumed by consumers is available for redistribution
+	for _, c := range site.consumers {
+		demands = append(demands, c.demand())
+		available += c.consumption()
+	}
+
+	shares := allocatePower(available, demands)
+
+	if len(site.consumers) > 0 {
+		status := make([]consumerStatus, 0, len(site.consumers))
+
+		for i, c := range site.consumers {
+			c.Update(shares[len(site.loadpoints)+i])
+			status = append(status, c.status())
+		}
+
+		site.publish("consumers", status)
+	}
+
+	// nothing to share
+	var active int
+	for _, d := range demands {
+		if d.active {
+			active++
+		}
+	}
+
+	if id < 0 || !demands[id].active || active < 2 {
+		return sitePower
+	}
+
+	share := shares[id]
+	lp := site.loadpoints[id]
+	site.log.DEBUG.Printf("pv surplus share: %.0fW of %.0fW (lp-%d, priority %d)", share, available, id+1, lp.GetPriority())
+
+	return lp.GetChargePower() - share
 }
 
 // prepare publishes initial values
@@ -426,15 +721,19 @@ func (site *Site) prepare() {
 	site.publish("pvConfigured", len(site.pvMeters) > 0)
 	site.publish("batteryConfigured", len(site.batteryMeters) > 0)
 	site.publish("prioritySoC", site.PrioritySoC)
+	site.publish("curtailmentConfigured", site.curtailmentG != nil)
 
 	site.publish("currency", site.tariffs.Currency.String())
 	site.publish("savingsSince", site.savings.Since().Unix())
+	site.savings.publish(site)
 }
 
 // Prepare attaches communication channels to site and loadpoints
 func (site *Site) Prepare(uiChan chan<- util.Param, pushChan chan<- push.Event) {
 	site.uiChan = uiChan
 	site.lpUpdateChan = make(chan *LoadPoint, 1) // 1 capacity to avoid deadlock
+	site.reconfigureChan = make(chan func())
+	site.stopped = make(chan struct{})
 
 	site.prepare()
 
@@ -472,10 +771,39 @@ func (site *Site) loopLoadpoints(next chan<- Updater) {
 	}
 }
 
+// Step runs a single control cycle synchronously instead of the ticker driven Run loop.
+// Pending loadpoint update requests are served first, followed by the next loadpoint in turn.
+func (site *Site) Step() {
+	if site.Health == nil {
+		site.Health = NewHealth(time.Minute)
+		site.Health.clock = site.clock
+	}
+
+	select {
+	case fn := <-site.reconfigureChan:
+		fn()
+	default:
+	}
+
+	select {
+	case lp := <-site.lpUpdateChan:
+		site.update(lp)
+	default:
+	}
+
+	if len(site.loadpoints) > 0 {
+		site.update(site.loadpoints[site.next])
+		site.next = (site.next + 1) % len(site.loadpoints)
+	}
+}
+
 // Run is the main control loop. It reacts to trigger events by
 // updating measurements and executing control logic.
 func (site *Site) Run(stopC chan struct{}, interval time.Duration) {
 	site.Health = NewHealth(time.Minute + interval)
+	site.Health.clock = site.clock
+
+	defer close(site.stopped)
 
 	loadpointChan := make(chan Updater)
 	go site.loopLoadpoints(loadpointChan)
@@ -489,7 +817,10 @@ func (site *Site) Run(stopC chan struct{}, interval time.Duration) {
 			site.update(<-loadpointChan)
 		case lp := <-site.lpUpdateChan:
 			site.update(lp)
+		case fn := <-site.reconfigureChan:
+			fn()
 		case <-stopC:
+			site.setBatteryMode(api.BatteryNormal)
 			return
 		}
 	}
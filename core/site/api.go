@@ -1,10 +1,17 @@
 package site
 
-import "github.com/evcc-io/evcc/core/loadpoint"
+import (
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/tariff"
+)
 
 // API is the external site API
 type API interface {
 	Healthy() bool
+	GetTitle() string
 	LoadPoints() []loadpoint.API
+	GetPrioritySoC() float64
 	SetPrioritySoC(float64) error
+	GetTariffs() tariff.Tariffs
+	ResetSavings()
 }
@@ -2,12 +2,19 @@ package core
 
 import (
 	"errors"
+	"time"
 
 	"github.com/evcc-io/evcc/core/site"
+	"github.com/evcc-io/evcc/tariff"
 )
 
 var _ site.API = (*Site)(nil)
 
+// GetTitle returns the site title
+func (site *Site) GetTitle() string {
+	return site.Title
+}
+
 // GetPrioritySoC returns the PrioritySoC
 func (site *Site) GetPrioritySoC() float64 {
 	site.Lock()
@@ -29,3 +36,23 @@ func (site *Site) SetPrioritySoC(soc float64) error {
 
 	return nil
 }
+
+// GetTariffs returns the configured tariffs
+func (site *Site) GetTariffs() tariff.Tariffs {
+	return site.tariffs
+}
+
+// ResetSavings resets the savings totals
+func (site *Site) ResetSavings() {
+	site.savings.Reset(site)
+}
+
+// RestoreSavings restores persisted savings and enables periodic checkpoints
+func (site *Site) RestoreSavings(store SavingsStore, interval time.Duration) error {
+	return site.savings.Restore(store, interval)
+}
+
+// PersistSavings writes the savings totals to the persistence store
+func (site *Site) PersistSavings() {
+	site.savings.Persist()
+}
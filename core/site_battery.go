@@ -0,0 +1,68 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/evcc-io/evcc/api"
+)
+
+// batteryControllable returns true if any battery meter can be controlled
+func (site *Site) batteryControllable() bool {
+	for _, meter := range site.batteryMeters {
+		if _, ok := meter.(api.BatteryController); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// fastCharging returns true if any loadpoint is charging in now mode
+func (site *Site) fastCharging() bool {
+	for _, lp := range site.loadpoints {
+		if lp.GetMode() == api.ModeNow && lp.GetStatus() == api.StatusC {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateBatteryMode charges the battery from grid while the tariff is cheap
+// and prevents it from discharging into vehicles while fast charging
+func (site *Site) updateBatteryMode(cheap bool) {
+	if !site.batteryControllable() {
+		return
+	}
+
+	mode := api.BatteryNormal
+
+	switch {
+	case cheap:
+		mode = api.BatteryCharge
+	case site.fastCharging():
+		mode = api.BatteryHold
+	}
+
+	site.setBatteryMode(mode)
+}
+
+// setBatteryMode applies the battery mode to all controllable batteries
+func (site *Site) setBatteryMode(mode api.BatteryMode) {
+	if mode == site.batteryMode {
+		return
+	}
+
+	for id, meter := range site.batteryMeters {
+		if battery, ok := meter.(api.BatteryController); ok {
+			if err := battery.SetBatteryMode(mode); err != nil {
+				site.log.ERROR.Println(fmt.Errorf("battery %d mode: %w", id, err))
+				return
+			}
+		}
+	}
+
+	site.log.DEBUG.Printf("battery mode: %s", mode)
+	site.batteryMode = mode
+	site.publish("batteryMode", mode.String())
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"time"
+
+	"github.com/evcc-io/evcc/core/curtailment"
+	"github.com/evcc-io/evcc/provider"
+)
+
+// CurtailmentConfig configures the grid operator curtailment input, e.g. ripple control or §14a EnWG control signal
+type CurtailmentConfig struct {
+	Limit  float64          // Total charge power limit while curtailed (W)
+	Signal *provider.Config // Bool getter, true while curtailment is requested
+}
+
+// curtailed returns true while the grid operator curtailment is active
+func (site *Site) curtailed() bool {
+	return site.curtailment != nil
+}
+
+// updateCurtailment reads the curtailment signal and records curtailment periods as audit trail
+func (site *Site) updateCurtailment(chargePower float64) {
+	if site.curtailmentG == nil {
+		return
+	}
+
+	active, err := site.curtailmentG()
+	if err != nil {
+		site.log.ERROR.Printf("curtailment: %v", err)
+		return
+	}
+
+	period := site.curtailment
+	now := site.clock.Now()
+
+	switch {
+	case active && period == nil:
+		site.log.WARN.Printf("grid operator curtailment started: limiting charge power to %.0fW", site.Curtailment.Limit)
+		period = &curtailment.Period{
+			Start: now,
+			End:   now,
+			Limit: site.Curtailment.Limit,
+		}
+		site.curtailment = period
+
+	case active:
+		// limit is applied after the period started
+		period.Update(chargePower)
+		period.End = now
+
+	case period != nil:
+		period.Update(chargePower)
+		period.End = now
+		site.curtailment = nil
+
+		site.log.WARN.Printf("grid operator curtailment ended after %v: max charge power %.0fW",
+			period.End.Sub(period.Start).Round(time.Second), period.MaxPower)
+
+	default:
+		return
+	}
+
+	// persist updates of active periods to keep the audit trail across restarts
+	if site.curtailmentStore != nil {
+		if err := site.curtailmentStore.Persist(period); err != nil {
+			site.log.ERROR.Printf("curtailment: %v", err)
+		}
+	}
+
+	// curtailment caps charge power, other consumers remain under surplus control
+	for _, c := range site.consumers {
+		c.blocked = active && c.Type == consumerSGReady
+	}
+
+	site.publish("curtailed", active)
+}
@@ -0,0 +1,164 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/evcc-io/evcc/api"
+)
+
+// LoadPointDevices are a loadpoint's devices and their references
+type LoadPointDevices struct {
+	ChargerRef  string
+	Charger     api.Charger
+	MeterRef    string
+	Meter       api.Meter // nil if the charger's meter is used
+	VehiclesRef []string
+	Vehicles    []api.Vehicle
+}
+
+// deviceValues are the values read from devices during an update cycle by "<class>/<name>", published for the api
+type deviceValues = map[string]map[string]interface{}
+
+// setDeviceValue records a value read from the named device
+func setDeviceValue(values deviceValues, class, name, key string, val interface{}) {
+	if name == "" {
+		return
+	}
+
+	id := class + "/" + name
+	if values[id] == nil {
+		values[id] = make(map[string]interface{})
+	}
+
+	values[id][key] = val
+}
+
+// reconfigure runs fn in the control loop between loadpoint updates and waits for it to complete
+func (site *Site) reconfigure(fn func()) {
+	if site.reconfigureChan == nil {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	select {
+	case site.reconfigureChan <- func() {
+		fn()
+		close(done)
+	}:
+		<-done
+	case <-site.stopped:
+		// no concurrent updates once the control loop has exited
+		fn()
+	}
+}
+
+// MeterRefs returns the names of the site's meters
+func (site *Site) MeterRefs() []string {
+	var res []string
+	if site.Meters.GridMeterRef != "" {
+		res = append(res, site.Meters.GridMeterRef)
+	}
+	res = append(res, site.Meters.PVMetersRef...)
+	return append(res, site.Meters.BatteryMetersRef...)
+}
+
+// ReplaceMeter replaces the named meter of the site and its loadpoints
+func (site *Site) ReplaceMeter(name string, meter api.Meter) {
+	site.reconfigure(func() {
+		site.Lock()
+		if site.Meters.GridMeterRef == name {
+			site.gridMeter = meter
+		}
+		for i, ref := range site.Meters.PVMetersRef {
+			if ref == name {
+				site.pvMeters[i] = meter
+			}
+		}
+		for i, ref := range site.Meters.BatteryMetersRef {
+			if ref == name {
+				site.batteryMeters[i] = meter
+			}
+		}
+		site.Unlock()
+
+		for _, lp := range site.loadpoints {
+			if lp.MeterRef == name {
+				lp.log.INFO.Printf("meter updated: %s", name)
+				lp.setChargerAndMeter(lp.charger, meter)
+			}
+		}
+	})
+}
+
+// ReplaceCharger replaces the named charger of the site's loadpoints
+func (site *Site) ReplaceCharger(name string, charger api.Charger) {
+	site.reconfigure(func() {
+		for _, lp := range site.loadpoints {
+			if lp.ChargerRef == name {
+				lp.log.INFO.Printf("charger updated: %s", name)
+				lp.setChargerAndMeter(charger, lp.configuredMeter())
+			}
+		}
+	})
+}
+
+// ReplaceVehicle replaces the named vehicle of the site's loadpoints
+func (site *Site) ReplaceVehicle(name string, vehicle api.Vehicle) {
+	site.reconfigure(func() {
+		for _, lp := range site.loadpoints {
+			lp.replaceVehicle(name, vehicle)
+		}
+	})
+}
+
+// SetLoadPointDevices assigns charger, charge meter and vehicles to the loadpoint.
+// Unchanged devices are kept.
+func (site *Site) SetLoadPointDevices(id int, devices LoadPointDevices) error {
+	if id < 0 || id >= len(site.loadpoints) {
+		return fmt.Errorf("invalid loadpoint: %d", id+1)
+	}
+
+	if devices.Charger == nil {
+		return errors.New("missing charger")
+	}
+
+	lp := site.loadpoints[id]
+
+	site.reconfigure(func() {
+		if devices.ChargerRef != lp.ChargerRef || devices.MeterRef != lp.MeterRef {
+			lp.Lock()
+			lp.ChargerRef, lp.MeterRef = devices.ChargerRef, devices.MeterRef
+			lp.Meters.ChargeMeterRef = ""
+			lp.Unlock()
+
+			lp.log.INFO.Printf("devices updated: charger %s, meter %s", devices.ChargerRef, devices.MeterRef)
+			lp.setChargerAndMeter(devices.Charger, devices.Meter)
+		}
+
+		refs := lp.VehiclesRef
+		if lp.VehicleRef != "" {
+			refs = []string{lp.VehicleRef}
+		}
+
+		if !equalStrings(refs, devices.VehiclesRef) {
+			lp.log.INFO.Printf("vehicles updated: %v", devices.VehiclesRef)
+			lp.setVehicles(devices.VehiclesRef, devices.Vehicles)
+		}
+	})
+
+	return nil
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
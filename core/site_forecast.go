@@ -0,0 +1,70 @@
+package core
+
+import (
+	"math"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+)
+
+// surplusForecast predicts pv surplus by deducting current home consumption from the solar forecast
+type surplusForecast struct {
+	site *Site
+}
+
+var _ api.Forecast = (*surplusForecast)(nil)
+
+// Forecast implements the api.Forecast interface
+func (f *surplusForecast) Forecast() (api.ForecastSlots, error) {
+	slots, err := f.site.forecast.Forecast()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(api.ForecastSlots, 0, len(slots))
+	for _, slot := range slots {
+		slot.Power = math.Max(slot.Power-f.site.homePower, 0)
+		res = append(res, slot)
+	}
+
+	return res, nil
+}
+
+// publishForecast publishes the predicted solar power and the remaining energy for today
+func (site *Site) publishForecast() {
+	if site.forecast == nil {
+		return
+	}
+
+	slots, err := site.forecast.Forecast()
+	if err != nil {
+		site.log.ERROR.Printf("forecast: %v", err)
+		return
+	}
+
+	now := site.clock.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+
+	var power, energy float64
+	for _, slot := range slots {
+		if !slot.Start.After(now) && slot.End.After(now) {
+			power = slot.Power
+		}
+
+		// remaining part of slot until midnight
+		start, end := slot.Start, slot.End
+		if start.Before(now) {
+			start = now
+		}
+		if end.After(midnight) {
+			end = midnight
+		}
+
+		if end.After(start) {
+			energy += slot.Power * end.Sub(start).Hours()
+		}
+	}
+
+	site.publish("forecastPower", power)
+	site.publish("forecastEnergy", energy)
+}
@@ -1,7 +1,18 @@
 package core
 
 import (
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/curtailment"
+	"github.com/evcc-io/evcc/mock"
+	"github.com/evcc-io/evcc/server/db"
+	"github.com/evcc-io/evcc/tariff"
+	"github.com/evcc-io/evcc/util"
+	"github.com/golang/mock/gomock"
 )
 
 func TestSitePower(t *testing.T) {
@@ -26,3 +37,342 @@ func TestSitePower(t *testing.T) {
 }
 
 // TODO add test case for battery priority charging
+
+type batteryController struct {
+	api.Meter
+	mode api.BatteryMode
+}
+
+func (b *batteryController) SetBatteryMode(mode api.BatteryMode) error {
+	b.mode = mode
+	return nil
+}
+
+func TestBatteryMode(t *testing.T) {
+	battery := &batteryController{}
+	lp := &LoadPoint{Mode: api.ModeNow, status: api.StatusB}
+
+	site := &Site{
+		log:           util.NewLogger("foo"),
+		batteryMeters: []api.Meter{battery},
+		loadpoints:    []*LoadPoint{lp},
+	}
+
+	tc := []struct {
+		status api.ChargeStatus
+		cheap  bool
+		mode   api.BatteryMode
+	}{
+		{api.StatusB, false, api.BatteryNormal},
+		{api.StatusC, false, api.BatteryHold},
+		{api.StatusC, true, api.BatteryCharge},
+		{api.StatusB, false, api.BatteryNormal},
+	}
+
+	for _, tc := range tc {
+		lp.status = tc.status
+		site.updateBatteryMode(tc.cheap)
+
+		if battery.mode != tc.mode {
+			t.Errorf("%+v: expected %s, got %s", tc, tc.mode, battery.mode)
+		}
+	}
+}
+
+// cheapTariff is a fixed tariff that is always cheap
+type cheapTariff struct{}
+
+func (cheapTariff) IsCheap() (bool, error)         { return true, nil }
+func (cheapTariff) CurrentPrice() (float64, error) { return 0.1, nil }
+
+// sitePowerUpdater records the site power handed to the loadpoint
+type sitePowerUpdater struct {
+	sitePower float64
+}
+
+func (u *sitePowerUpdater) Update(sitePower float64, _ bool, _ bool) {
+	u.sitePower = sitePower
+}
+
+func TestBatteryChargeSurplus(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	// battery charging from grid
+	grid := mock.NewMockMeter(ctrl)
+	grid.EXPECT().CurrentPower().Return(3000.0, nil).AnyTimes()
+
+	batteryMeter := mock.NewMockMeter(ctrl)
+	batteryMeter.EXPECT().CurrentPower().Return(-3000.0, nil).AnyTimes()
+
+	batterySoC := mock.NewMockBattery(ctrl)
+	batterySoC.EXPECT().SoC().Return(50.0, nil).AnyTimes()
+
+	battery := &struct {
+		*batteryController
+		api.Battery
+	}{&batteryController{Meter: batteryMeter}, batterySoC}
+
+	site := &Site{
+		log:           util.NewLogger("foo"),
+		Health:        NewHealth(time.Minute),
+		Meters:        MetersConfig{BatteryMetersRef: []string{"battery"}},
+		gridMeter:     grid,
+		batteryMeters: []api.Meter{battery},
+		tariffs:       tariff.Tariffs{Grid: cheapTariff{}},
+		savings:       NewSavings(tariff.Tariffs{}),
+	}
+
+	// first cycle enables grid charging, second cycle uses it
+	lp := new(sitePowerUpdater)
+	site.update(lp)
+	site.update(lp)
+
+	if battery.mode != api.BatteryCharge {
+		t.Errorf("expected battery charge mode, got %s", battery.mode)
+	}
+
+	if lp.sitePower != 3000 {
+		t.Errorf("expected grid import without battery surplus, got %.0fW", lp.sitePower)
+	}
+}
+
+func TestCurtailment(t *testing.T) {
+	var active bool
+	heatpump := &Consumer{Type: consumerSGReady}
+	heater := &Consumer{Type: consumerSwitch}
+
+	gdb, err := db.New("sqlite", filepath.Join(t.TempDir(), "evcc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := curtailment.NewStore(gdb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	site := &Site{
+		log:              util.NewLogger("foo"),
+		clock:            clck,
+		Curtailment:      CurtailmentConfig{Limit: 4200},
+		consumers:        []*Consumer{heatpump, heater},
+		curtailmentStore: store,
+		curtailmentG: func() (bool, error) {
+			return active, nil
+		},
+	}
+
+	site.updateCurtailment(11e3)
+	if site.curtailed() {
+		t.Fatal("unexpected curtailment")
+	}
+
+	active = true
+	site.updateCurtailment(11e3)
+	period := site.curtailment
+
+	if !site.curtailed() || !heatpump.blocked {
+		t.Fatal("expected curtailment")
+	}
+
+	if heater.blocked {
+		t.Error("unexpected blocked switch consumer")
+	}
+
+	// charge power before the limit applies is not recorded
+	clck.Add(time.Minute)
+	site.updateCurtailment(4e3)
+	clck.Add(time.Minute)
+	site.updateCurtailment(4.2e3)
+
+	// active period updates are persisted
+	if res, err := curtailment.All(gdb); err != nil || len(res) != 1 ||
+		res[0].MaxPower != 4200 || !res[0].End.Equal(clck.Now()) {
+		t.Errorf("unexpected persisted periods: %+v (%v)", res, err)
+	}
+
+	active = false
+	site.updateCurtailment(3e3)
+
+	if site.curtailed() || heatpump.blocked {
+		t.Fatal("unexpected curtailment")
+	}
+
+	if period.End.IsZero() || period.Limit != 4200 || period.MaxPower != 4200 {
+		t.Errorf("unexpected period: %+v", period)
+	}
+}
+
+func TestReplaceDevices(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	grid, charger := mock.NewMockMeter(ctrl), mock.NewMockCharger(ctrl)
+	v1, v2 := mock.NewMockVehicle(ctrl), mock.NewMockVehicle(ctrl)
+	for _, v := range []*mock.MockVehicle{v1, v2} {
+		v.EXPECT().Title().Return("car").AnyTimes()
+		v.EXPECT().Capacity().Return(int64(0)).AnyTimes()
+		v.EXPECT().OnIdentified().Return(api.ActionConfig{}).AnyTimes()
+	}
+
+	lp := NewLoadPoint(util.NewLogger("foo"))
+	lp.ChargerRef, lp.VehicleRef = "wallbox", "car"
+	lp.charger, lp.vehicles = charger, []api.Vehicle{v1}
+	lp.configureChargerType(charger)
+	lp.setActiveVehicle(v1)
+
+	subs := len(lp.chargerSubs)
+
+	site := &Site{
+		log:        util.NewLogger("foo"),
+		Meters:     MetersConfig{GridMeterRef: "grid"},
+		gridMeter:  grid,
+		loadpoints: []*LoadPoint{lp},
+	}
+
+	grid2 := mock.NewMockMeter(ctrl)
+	site.ReplaceMeter("grid", grid2)
+	if site.gridMeter != grid2 {
+		t.Error("grid meter not replaced")
+	}
+
+	charger2 := mock.NewMockCharger(ctrl)
+	site.ReplaceCharger("wallbox", charger2)
+	if lp.charger != charger2 || len(lp.chargerSubs) != subs {
+		t.Errorf("charger not replaced (%d subscriptions, expected %d)", len(lp.chargerSubs), subs)
+	}
+
+	site.ReplaceVehicle("car", v2)
+	if lp.vehicles[0] != v2 || lp.vehicle != v2 {
+		t.Error("vehicle not replaced")
+	}
+
+	charger3, meter := mock.NewMockCharger(ctrl), mock.NewMockMeter(ctrl)
+	if err := site.SetLoadPointDevices(0, LoadPointDevices{
+		ChargerRef: "other", Charger: charger3,
+		MeterRef: "charge", Meter: meter,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if lp.charger != charger3 || lp.chargeMeter != meter || lp.MeterRef != "charge" {
+		t.Error("loadpoint devices not replaced")
+	}
+
+	if len(lp.vehicles) != 0 || lp.vehicle != nil {
+		t.Error("vehicles not removed")
+	}
+	// reconfiguring doesn't block once the control loop has exited
+	site.reconfigureChan, site.stopped = make(chan func()), make(chan struct{})
+	close(site.stopped)
+
+	site.ReplaceMeter("grid", grid)
+	if site.gridMeter != grid {
+		t.Error("grid meter not replaced after exit")
+	}
+}
+
+// testForecast is a fixed solar forecast
+type testForecast api.ForecastSlots
+
+func (f testForecast) Forecast() (api.ForecastSlots, error) {
+	return api.ForecastSlots(f), nil
+}
+
+func TestPublishForecast(t *testing.T) {
+	clck := clock.NewMock()
+	clck.Set(time.Date(2022, 6, 1, 22, 30, 0, 0, time.Local))
+
+	uiChan := make(chan util.Param, 2)
+	site := &Site{
+		log:    util.NewLogger("foo"),
+		clock:  clck,
+		uiChan: uiChan,
+		forecast: testForecast{
+			{Start: clck.Now().Add(-30 * time.Minute), End: clck.Now().Add(30 * time.Minute), Power: 1000},
+			{Start: clck.Now().Add(30 * time.Minute), End: clck.Now().Add(90 * time.Minute), Power: 2000},
+		},
+	}
+
+	site.publishForecast()
+
+	// remaining half of current slot and next slot until midnight
+	for _, expect := range []util.Param{{Key: "forecastPower", Val: 1000.0}, {Key: "forecastEnergy", Val: 2500.0}} {
+		if p := <-uiChan; p.Key != expect.Key || p.Val != expect.Val {
+			t.Errorf("expected %v, got %v", expect, p)
+		}
+	}
+}
+
+func TestSiteSetClock(t *testing.T) {
+	clck := clock.NewMock()
+	clck.Set(time.Date(2022, 6, 21, 0, 0, 0, 0, time.UTC))
+
+	site := &Site{
+		savings: NewSavings(tariff.Tariffs{}),
+		Health:  NewHealth(time.Minute),
+	}
+	site.Health.Update()
+	site.SetClock(clck)
+
+	if since := site.savings.Since(); !since.Equal(clck.Now()) {
+		t.Errorf("expected savings since %v, got %v", clck.Now(), since)
+	}
+
+	// health follows the simulated time
+	site.Health.Update()
+	clck.Add(2 * time.Minute)
+	if site.Healthy() {
+		t.Error("expected unhealthy after timeout")
+	}
+}
+
+func TestAllocateCurrentsConnected(t *testing.T) {
+	ctrl := gomock.NewController(t)
+
+	loadpoint := func(status api.ChargeStatus) *LoadPoint {
+		charger := mock.NewMockCharger(ctrl)
+		charger.EXPECT().Status().Return(status, nil).AnyTimes()
+
+		lp := NewLoadPoint(util.NewLogger("foo"))
+		lp.charger = charger
+		lp.status = status
+		lp.Mode = api.ModeNow
+		lp.MinCurrent = 6
+		lp.MaxCurrent = 16
+		lp.Phases = 3
+		return lp
+	}
+
+	// connected loadpoint is charging at full current, updated loadpoint is not connected
+	charging, idle := loadpoint(api.StatusC), loadpoint(api.StatusA)
+	charging.enabled = true
+	charging.chargeCurrent = 16
+	charging.chargeCurrents = []float64{16, 16, 16}
+
+	site := &Site{
+		log:            util.NewLogger("foo"),
+		MaxGridCurrent: 16,
+		loadpoints:     []*LoadPoint{charging, idle},
+		gridPower:      11e3,
+		gridCurrents:   []float64{16, 16, 16},
+	}
+
+	site.allocateCurrents(idle, site.connected(idle))
+
+	if limit, _ := charging.siteCurrentLimit(); limit != 16 {
+		t.Errorf("expected connected loadpoint to keep 16A, got %.3gA", limit)
+	}
+
+	// exported current adds to the budget
+	charging.chargeCurrent, charging.chargeCurrents = 0, nil
+	site.MaxGridCurrent = 10
+	site.gridPower = -3e3
+	site.gridCurrents = []float64{5, 5, 5}
+
+	site.allocateCurrents(idle, site.connected(idle))
+
+	if limit, _ := charging.siteCurrentLimit(); limit != 15 {
+		t.Errorf("expected 15A while exporting, got %.3gA", limit)
+	}
+}
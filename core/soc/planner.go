@@ -0,0 +1,179 @@
+package soc
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+)
+
+// Planner plans charging into the cheapest tariff slots before the target time
+type Planner struct {
+	log      *util.Logger
+	clock    clock.Clock
+	tariff   api.TariffRates
+	forecast api.Forecast
+}
+
+// NewPlanner creates a Planner. Without tariff, all slots are priced equally.
+func NewPlanner(log *util.Logger, tariff api.TariffRates) *Planner {
+	return &Planner{
+		log:    log,
+		clock:  clock.New(),
+		tariff: tariff,
+	}
+}
+
+// WithForecast discounts slots by their predicted share of solar surplus
+func (t *Planner) WithForecast(forecast api.Forecast) *Planner {
+	t.forecast = forecast
+	return t
+}
+
+// rates returns the tariff rates discounted by predicted solar surplus at given charge power
+func (t *Planner) rates(now, targetTime time.Time, power float64) (api.Rates, error) {
+	rates := api.Rates{{Start: now, End: targetTime, Price: 1}}
+
+	if t.tariff != nil {
+		var err error
+		if rates, err = t.tariff.Rates(); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.forecast == nil || power <= 0 {
+		return rates, nil
+	}
+
+	forecast, err := t.forecast.Forecast()
+	if err != nil {
+		t.log.WARN.Printf("forecast: %v", err)
+		return rates, nil
+	}
+
+	return solarRates(rates, forecast, power), nil
+}
+
+// solarRates splits rates at forecast slot boundaries and discounts each part by its solar share.
+// Forecast slots must be ordered and non-overlapping.
+func solarRates(rates api.Rates, forecast api.ForecastSlots, power float64) api.Rates {
+	var res api.Rates
+
+	for _, r := range rates {
+		start := r.Start
+
+		for _, f := range forecast {
+			if !f.End.After(start) || !f.Start.Before(r.End) {
+				continue
+			}
+
+			// not covered by forecast
+			if f.Start.After(start) {
+				res = append(res, api.Rate{Start: start, End: f.Start, Price: r.Price})
+				start = f.Start
+			}
+
+			end := f.End
+			if end.After(r.End) {
+				end = r.End
+			}
+
+			share := math.Min(math.Max(f.Power/power, 0), 1)
+			res = append(res, api.Rate{Start: start, End: end, Price: r.Price * (1 - share)})
+
+			start = end
+		}
+
+		if start.Before(r.End) {
+			res = append(res, api.Rate{Start: start, End: r.End, Price: r.Price})
+		}
+	}
+
+	return res
+}
+
+// Plan returns the cheapest set of slots before targetTime covering the required charge duration at given power.
+// Slots are clipped to the current time and the target time and returned in chronological order.
+func (t *Planner) Plan(requiredDuration time.Duration, targetTime time.Time, power float64) (api.Rates, error) {
+	now := t.clock.Now()
+
+	rates, err := t.rates(now, targetTime, power)
+	if err != nil {
+		return nil, err
+	}
+
+	// available slots between now and target time
+	var slots api.Rates
+	for _, r := range rates {
+		if !r.End.After(now) || !r.Start.Before(targetTime) {
+			continue
+		}
+
+		if r.Start.Before(now) {
+			r.Start = now
+		}
+		if r.End.After(targetTime) {
+			r.End = targetTime
+		}
+
+		slots = append(slots, r)
+	}
+
+	// cheapest first, later slots first for equal prices to keep charging late
+	sort.SliceStable(slots, func(i, j int) bool {
+		if slots[i].Price == slots[j].Price {
+			return slots[i].Start.After(slots[j].Start)
+		}
+		return slots[i].Price < slots[j].Price
+	})
+
+	var plan api.Rates
+	for _, slot := range slots {
+		if requiredDuration <= 0 {
+			break
+		}
+
+		// use end of partially required slot
+		if duration := slot.End.Sub(slot.Start); duration > requiredDuration {
+			slot.Start = slot.End.Add(-requiredDuration)
+		}
+
+		requiredDuration -= slot.End.Sub(slot.Start)
+		plan = append(plan, slot)
+	}
+
+	if requiredDuration > 0 {
+		return nil, errors.New("insufficient tariff slots before target time")
+	}
+
+	sort.Slice(plan, func(i, j int) bool {
+		return plan[i].Start.Before(plan[j].Start)
+	})
+
+	return plan, nil
+}
+
+// Active returns true if charging is planned for the current time
+func (t *Planner) Active(requiredDuration time.Duration, targetTime time.Time, power float64) (bool, error) {
+	plan, err := t.Plan(requiredDuration, targetTime, power)
+	if err != nil {
+		return false, err
+	}
+
+	now := t.clock.Now()
+	for _, slot := range plan {
+		if !slot.Start.After(now) && slot.End.After(now) {
+			return true, nil
+		}
+	}
+
+	if len(plan) > 0 {
+		t.log.DEBUG.Printf("planned charge start: %v", plan[0].Start.Round(time.Minute))
+	}
+
+	return false, nil
+}
@@ -0,0 +1,131 @@
+package soc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+)
+
+type rates api.Rates
+
+func (r rates) Rates() (api.Rates, error) {
+	return api.Rates(r), nil
+}
+
+func hourlyRates(start time.Time, prices ...float64) rates {
+	var res rates
+	for i, price := range prices {
+		slot := start.Add(time.Duration(i) * time.Hour)
+		res = append(res, api.Rate{Start: slot, End: slot.Add(time.Hour), Price: price})
+	}
+	return res
+}
+
+func TestPlanner(t *testing.T) {
+	clck := clock.NewMock()
+	now := clck.Now()
+
+	tc := []struct {
+		name     string
+		prices   []float64
+		required time.Duration
+		target   time.Duration
+		plan     []time.Duration // planned slot start offsets from now
+		active   bool
+	}{
+		{"cheapest slot later", []float64{0.3, 0.2, 0.1, 0.3}, time.Hour, 4 * time.Hour, []time.Duration{2 * time.Hour}, false},
+		{"cheapest slot now", []float64{0.1, 0.2, 0.3, 0.3}, time.Hour, 4 * time.Hour, []time.Duration{0}, true},
+		{"two cheapest slots", []float64{0.3, 0.1, 0.3, 0.2}, 2 * time.Hour, 4 * time.Hour, []time.Duration{time.Hour, 3 * time.Hour}, false},
+		{"ignore slots after target", []float64{0.3, 0.2, 0.1, 0.1}, time.Hour, 2 * time.Hour, []time.Duration{time.Hour}, false},
+		{"partial slot at end", []float64{0.3, 0.1, 0.3}, 30 * time.Minute, 3 * time.Hour, []time.Duration{90 * time.Minute}, false},
+		{"equal prices charge late", []float64{0.1, 0.1, 0.1}, time.Hour, 3 * time.Hour, []time.Duration{2 * time.Hour}, false},
+	}
+
+	for _, tc := range tc {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPlanner(util.NewLogger("foo"), hourlyRates(now, tc.prices...))
+			p.clock = clck
+
+			plan, err := p.Plan(tc.required, now.Add(tc.target), 11e3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(plan) != len(tc.plan) {
+				t.Fatalf("expected %d slots, got %v", len(tc.plan), plan)
+			}
+
+			for i, start := range tc.plan {
+				if !plan[i].Start.Equal(now.Add(start)) {
+					t.Errorf("slot %d: expected start %v, got %v", i, now.Add(start), plan[i].Start)
+				}
+			}
+
+			active, err := p.Active(tc.required, now.Add(tc.target), 11e3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if active != tc.active {
+				t.Errorf("expected active %v, got %v", tc.active, active)
+			}
+		})
+	}
+}
+
+func TestPlannerInsufficientSlots(t *testing.T) {
+	clck := clock.NewMock()
+	now := clck.Now()
+
+	p := NewPlanner(util.NewLogger("foo"), hourlyRates(now, 0.1, 0.2))
+	p.clock = clck
+
+	if _, err := p.Plan(3*time.Hour, now.Add(4*time.Hour), 11e3); err == nil {
+		t.Error("expected error")
+	}
+}
+
+type forecast api.ForecastSlots
+
+func (f forecast) Forecast() (api.ForecastSlots, error) {
+	return api.ForecastSlots(f), nil
+}
+
+func TestPlannerForecast(t *testing.T) {
+	clck := clock.NewMock()
+	now := clck.Now()
+
+	sunny := forecast{
+		{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour), Power: 2e3},
+		{Start: now.Add(2 * time.Hour), End: now.Add(3 * time.Hour), Power: 8e3},
+	}
+
+	tc := []struct {
+		name   string
+		tariff api.TariffRates
+		plan   time.Duration
+	}{
+		{"without tariff", nil, 2 * time.Hour},
+		{"sun outweighs price", hourlyRates(now, 0.2, 0.3, 0.3, 0.3), 2 * time.Hour},
+		{"price outweighs sun", hourlyRates(now, 0.05, 0.3, 0.3, 0.3), 0},
+	}
+
+	for _, tc := range tc {
+		t.Run(tc.name, func(t *testing.T) {
+			p := NewPlanner(util.NewLogger("foo"), tc.tariff).WithForecast(sunny)
+			p.clock = clck
+
+			plan, err := p.Plan(time.Hour, now.Add(4*time.Hour), 11e3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(plan) != 1 || !plan[0].Start.Equal(now.Add(tc.plan)) {
+				t.Errorf("expected start %v, got %v", now.Add(tc.plan), plan)
+			}
+		})
+	}
+}
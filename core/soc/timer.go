@@ -4,6 +4,7 @@ import (
 	"math"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/util"
 )
@@ -16,11 +17,14 @@ const (
 type Timer struct {
 	Adapter
 	log       *util.Logger
+	clock     clock.Clock
+	planner   *Planner
 	current   float64
 	SoC       int
 	Time      time.Time
 	finishAt  time.Time
 	active    bool
+	planned   bool
 	validated bool
 }
 
@@ -28,12 +32,36 @@ type Timer struct {
 func NewTimer(log *util.Logger, api Adapter) *Timer {
 	lp := &Timer{
 		log:     log,
+		clock:   clock.New(),
 		Adapter: api,
 	}
 
 	return lp
 }
 
+// SetClock replaces the timer's and planner's clock
+func (lp *Timer) SetClock(clock clock.Clock) {
+	if lp == nil {
+		return
+	}
+
+	lp.clock = clock
+	if lp.planner != nil {
+		lp.planner.clock = clock
+	}
+}
+
+// SetPlanner enables charging in the cheapest or sunniest slots instead of the latest possible start
+func (lp *Timer) SetPlanner(planner *Planner) {
+	if lp == nil {
+		return
+	}
+
+	if lp.planner = planner; planner != nil {
+		planner.clock = lp.clock
+	}
+}
+
 // MustValidateDemand resets the flag for detecting if DemandActive has been called
 func (lp *Timer) MustValidateDemand() {
 	if lp == nil {
@@ -83,6 +111,7 @@ func (lp *Timer) Reset() {
 
 	lp.Set(time.Time{})
 	lp.Stop()
+	lp.planned = false
 }
 
 // DemandActive calculates remaining charge duration and returns true if charge start is required to achieve target soc in time
@@ -108,7 +137,7 @@ func (lp *Timer) DemandActive() bool {
 
 	// time
 	remainingDuration := time.Duration(float64(se.AssumedChargeDuration(lp.SoC, power)) / chargeEfficiency)
-	lp.finishAt = time.Now().Add(remainingDuration).Round(time.Minute)
+	lp.finishAt = lp.clock.Now().Add(remainingDuration).Round(time.Minute)
 
 	lp.log.DEBUG.Printf("estimated charge duration: %v to %d%% at %.0fW", remainingDuration.Round(time.Minute), lp.SoC, power)
 	if lp.active {
@@ -118,9 +147,38 @@ func (lp *Timer) DemandActive() bool {
 		lp.log.DEBUG.Printf("projected start: %v", lp.Time.Add(-remainingDuration))
 	}
 
+	// plan charging into cheapest slots until target time is reached
+	if lp.planner != nil && lp.clock.Now().Before(lp.Time) {
+		active, err := lp.planner.Active(remainingDuration, lp.Time, power)
+		if err == nil {
+			lp.planned = true
+
+			switch {
+			case active && !lp.active:
+				lp.active = true
+				lp.Publish("targetTimeActive", lp.active)
+
+				lp.current = lp.GetMaxCurrent()
+				lp.log.INFO.Printf("target charging active for %v: cheap tariff slot (%v remaining)", lp.Time, remainingDuration.Round(time.Minute))
+			case !active && lp.active:
+				lp.Stop()
+			}
+
+			return lp.active
+		}
+
+		lp.log.WARN.Printf("target charging: %v, falling back to latest start", err)
+	}
+
+	// switching from planned to latest start charging requires re-evaluation
+	if lp.planned {
+		lp.planned = false
+		lp.Stop()
+	}
+
 	// timer charging is already active- only deactivate once charging has stopped
 	if lp.active {
-		if time.Now().After(lp.Time) && lp.GetStatus() != api.StatusC {
+		if lp.clock.Now().After(lp.Time) && lp.GetStatus() != api.StatusC {
 			lp.Stop()
 		}
 
@@ -141,6 +199,13 @@ func (lp *Timer) DemandActive() bool {
 
 // Handle adjusts current up/down to achieve desired target time taking.
 func (lp *Timer) Handle() float64 {
+	// planned slots are charged at full power
+	if lp.planned {
+		lp.current = lp.GetMaxCurrent()
+		lp.log.DEBUG.Printf("target charging: planned (%.3gA)", lp.current)
+		return lp.current
+	}
+
 	action := "steady"
 
 	switch {
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timeWindow is a daily time window. Windows ending before they start span midnight.
+type timeWindow struct {
+	from, to time.Duration // offset from midnight
+}
+
+// parseTimeOfDay parses hh:mm into the offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day: %s", s)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseTimeWindow parses hh:mm-hh:mm into a time window
+func parseTimeWindow(s string) (*timeWindow, error) {
+	segs := strings.Split(s, "-")
+	if len(segs) != 2 {
+		return nil, fmt.Errorf("invalid time window: %s", s)
+	}
+
+	from, err := parseTimeOfDay(segs[0])
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := parseTimeOfDay(segs[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &timeWindow{from: from, to: to}, nil
+}
+
+// Contains returns true if t is inside the time window
+func (w *timeWindow) Contains(t time.Time) bool {
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.from <= w.to {
+		return offset >= w.from && offset < w.to
+	}
+
+	return offset >= w.from || offset < w.to
+}
+
+func (w *timeWindow) String() string {
+	format := func(d time.Duration) string {
+		return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+	}
+
+	return format(w.from) + "-" + format(w.to)
+}
+
+// nextTimeOfDay returns the next occurrence of the offset from midnight after t
+func nextTimeOfDay(t time.Time, offset time.Duration) time.Time {
+	y, m, d := t.Date()
+	res := time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Add(offset)
+
+	if !res.After(t) {
+		res = res.AddDate(0, 0, 1)
+	}
+
+	return res
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeWindow(t *testing.T) {
+	tc := []struct {
+		window   string
+		time     string
+		contains bool
+	}{
+		{"08:00-17:00", "07:59", false},
+		{"08:00-17:00", "08:00", true},
+		{"08:00-17:00", "16:59", true},
+		{"08:00-17:00", "17:00", false},
+		{"22:00-06:00", "21:59", false},
+		{"22:00-06:00", "23:00", true},
+		{"22:00-06:00", "05:59", true},
+		{"22:00-06:00", "06:00", false},
+	}
+
+	for _, tc := range tc {
+		w, err := parseTimeWindow(tc.window)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ts, _ := time.Parse("15:04", tc.time)
+		if res := w.Contains(ts); res != tc.contains {
+			t.Errorf("%s @ %s: expected %v, got %v", tc.window, tc.time, tc.contains, res)
+		}
+
+		if w.String() != tc.window {
+			t.Errorf("expected %s, got %s", tc.window, w.String())
+		}
+	}
+
+	for _, window := range []string{"", "08:00", "8-17", "08:00-25:00"} {
+		if _, err := parseTimeWindow(window); err == nil {
+			t.Errorf("%s: expected error", window)
+		}
+	}
+}
+
+func TestNextTimeOfDay(t *testing.T) {
+	now := time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	if res := nextTimeOfDay(now, 12*time.Hour); !res.Equal(time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next time: %v", res)
+	}
+
+	if res := nextTimeOfDay(now, 7*time.Hour); !res.Equal(time.Date(2022, 3, 2, 7, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected next time: %v", res)
+	}
+}
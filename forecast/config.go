@@ -0,0 +1,22 @@
+package forecast
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/evcc-io/evcc/api"
+)
+
+// NewFromConfig creates solar forecast from config
+func NewFromConfig(typ string, other map[string]interface{}) (f api.Forecast, err error) {
+	switch strings.ToLower(typ) {
+	case "static":
+		f, err = NewStaticFromConfig(other)
+	case "http":
+		f, err = NewHTTPFromConfig(other)
+	default:
+		return nil, errors.New("unknown forecast: " + typ)
+	}
+
+	return
+}
@@ -0,0 +1,140 @@
+package forecast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/provider"
+	"github.com/evcc-io/evcc/provider/pipeline"
+	"github.com/evcc-io/evcc/util"
+)
+
+// HTTP is a generic json solar forecast. The response, after applying the pipeline,
+// must be a list of slots with start, optional end (both RFC3339, local date time or unix seconds) and power.
+type HTTP struct {
+	get   func() (string, error)
+	scale float64
+}
+
+var _ api.Forecast = (*HTTP)(nil)
+
+// NewHTTPFromConfig creates a http forecast from generic config
+func NewHTTPFromConfig(other map[string]interface{}) (*HTTP, error) {
+	cc := struct {
+		URI, Method       string
+		Headers           map[string]string
+		pipeline.Settings `mapstructure:",squash"`
+		Scale             float64
+		Insecure          bool
+		Cache             time.Duration
+	}{
+		Headers: make(map[string]string),
+		Scale:   1,
+		Cache:   time.Hour,
+	}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	if cc.URI == "" {
+		return nil, errors.New("missing uri")
+	}
+
+	pipe, err := pipeline.New(cc.Settings)
+	if err != nil {
+		return nil, err
+	}
+
+	http := provider.NewHTTP(util.NewLogger("forecast"), cc.Method, cc.URI, cc.Insecure, 1, cc.Cache).
+		WithHeaders(cc.Headers).
+		WithPipeline(pipe)
+
+	return &HTTP{
+		get:   http.StringGetter(),
+		scale: cc.Scale,
+	}, nil
+}
+
+// Forecast implements the api.Forecast interface
+func (t *HTTP) Forecast() (api.ForecastSlots, error) {
+	s, err := t.get()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseSlots([]byte(s), t.scale)
+}
+
+// parseSlots decodes forecast slots. Missing slot ends default to the following slot's start.
+func parseSlots(b []byte, scale float64) (api.ForecastSlots, error) {
+	var data []struct {
+		Start, End interface{}
+		Power      float64
+	}
+
+	if err := json.Unmarshal(b, &data); err != nil {
+		return nil, fmt.Errorf("invalid forecast: %w", err)
+	}
+
+	res := make(api.ForecastSlots, 0, len(data))
+	for _, d := range data {
+		start, err := parseTime(d.Start)
+		if err != nil {
+			return nil, err
+		}
+
+		var end time.Time
+		if d.End != nil {
+			if end, err = parseTime(d.End); err != nil {
+				return nil, err
+			}
+		}
+
+		res = append(res, api.ForecastSlot{Start: start, End: end, Power: scale * d.Power})
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start.Before(res[j].Start)
+	})
+
+	for i := range res {
+		if !res[i].End.IsZero() {
+			continue
+		}
+
+		switch {
+		case i+1 < len(res):
+			res[i].End = res[i+1].Start
+		case i > 0:
+			res[i].End = res[i].Start.Add(res[i].Start.Sub(res[i-1].Start))
+		default:
+			res[i].End = res[i].Start.Add(time.Hour)
+		}
+	}
+
+	return res, nil
+}
+
+// parseTime parses RFC3339, local date time or unix timestamps
+func parseTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"} {
+			if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %v", v)
+}
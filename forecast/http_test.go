@@ -0,0 +1,72 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/provider/pipeline"
+)
+
+func TestParseSlots(t *testing.T) {
+	// forecast.solar style response
+	body := `{"result":{"watts":{"2022-03-07 08:00:00":100,"2022-03-07 09:00:00":1500,"2022-03-07 10:00:00":3000}}}`
+
+	pipe, err := pipeline.New(pipeline.Settings{
+		Jq: `.result.watts | to_entries | map({start: .key, power: .value})`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := pipe.Process([]byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slots, err := parseSlots(b, 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2022, 3, 7, 8, 0, 0, 0, time.Local)
+	if len(slots) != 3 {
+		t.Fatalf("expected 3 slots, got %v", slots)
+	}
+
+	for i, power := range []float64{50, 750, 1500} {
+		slot := slots[i]
+		if !slot.Start.Equal(start.Add(time.Duration(i)*time.Hour)) || slot.End.Sub(slot.Start) != time.Hour || slot.Power != power {
+			t.Errorf("slot %d: unexpected %+v", i, slot)
+		}
+	}
+
+	if _, err := parseSlots([]byte(`[{"start":"foo","power":1}]`), 1); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestStatic(t *testing.T) {
+	if _, err := NewStatic(make([]float64, 7)); err == nil {
+		t.Error("expected error")
+	}
+
+	s, err := NewStatic([]float64{0, 1000, 2000, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clck := clock.NewMock()
+	clck.Set(time.Date(2022, 3, 7, 13, 0, 0, 0, time.Local))
+	s.clock = clck
+
+	slots, err := s.Forecast()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// current and last slot of today plus tomorrow
+	if len(slots) != 6 || slots[0].Power != 2000 || !slots[0].Start.Equal(time.Date(2022, 3, 7, 12, 0, 0, 0, time.Local)) {
+		t.Errorf("unexpected slots: %v", slots)
+	}
+}
@@ -0,0 +1,70 @@
+package forecast
+
+import (
+	"errors"
+	"time"
+
+	"github.com/benbjohnson/clock"
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/util"
+)
+
+// Static is a fixed daily solar production profile
+type Static struct {
+	clock clock.Clock
+	power []float64
+}
+
+var _ api.Forecast = (*Static)(nil)
+
+// NewStaticFromConfig creates a static forecast from generic config
+func NewStaticFromConfig(other map[string]interface{}) (*Static, error) {
+	var cc struct {
+		Power []float64
+	}
+
+	if err := util.DecodeOther(other, &cc); err != nil {
+		return nil, err
+	}
+
+	return NewStatic(cc.Power)
+}
+
+// NewStatic creates a static forecast. The power profile (W) divides the day into equal slots, e.g. 24 hourly values.
+func NewStatic(power []float64) (*Static, error) {
+	if len(power) == 0 || (24*time.Hour)%time.Duration(len(power)) != 0 {
+		return nil, errors.New("power profile must divide the day into equal slots")
+	}
+
+	return &Static{
+		clock: clock.New(),
+		power: power,
+	}, nil
+}
+
+// Forecast implements the api.Forecast interface
+func (t *Static) Forecast() (api.ForecastSlots, error) {
+	now := t.clock.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	duration := 24 * time.Hour / time.Duration(len(t.power))
+
+	// today and tomorrow
+	var res api.ForecastSlots
+	for day := 0; day < 2; day++ {
+		start := midnight.AddDate(0, 0, day)
+
+		for i, power := range t.power {
+			slot := api.ForecastSlot{
+				Start: start.Add(time.Duration(i) * duration),
+				Power: power,
+			}
+			slot.End = slot.Start.Add(duration)
+
+			if slot.End.After(now) {
+				res = append(res, slot)
+			}
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,155 @@
+package ocpp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	ocppcore "github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+)
+
+// connector is a loadpoint exposed as charge point connector
+type connector struct {
+	mu sync.Mutex
+	id int
+	lp loadpoint.API
+
+	status  ocppcore.ChargePointStatus // last reported status
+	demand  loadpoint.RemoteDemand     // last requested remote demand
+	txnId   int                        // active transaction id
+	idTag   string                     // id tag of the pending or active transaction
+	stopped bool                       // transaction stopped by central system, charging disabled until vehicle disconnects
+	reason  ocppcore.Reason            // reason for stopping the transaction
+	limited bool                       // charging disabled by charging profile
+}
+
+// chargePointStatus maps the loadpoint status to the connector's OCPP status
+func (conn *connector) chargePointStatus(status api.ChargeStatus) ocppcore.ChargePointStatus {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	switch status {
+	case api.StatusA:
+		return ocppcore.ChargePointStatusAvailable
+	case api.StatusB:
+		switch {
+		case conn.txnId == 0 && conn.stopped:
+			return ocppcore.ChargePointStatusFinishing
+		case conn.txnId == 0:
+			return ocppcore.ChargePointStatusPreparing
+		case conn.stopped || conn.limited:
+			return ocppcore.ChargePointStatusSuspendedEVSE
+		default:
+			return ocppcore.ChargePointStatusSuspendedEV
+		}
+	case api.StatusC:
+		return ocppcore.ChargePointStatusCharging
+	case api.StatusE, api.StatusF:
+		return ocppcore.ChargePointStatusFaulted
+	default:
+		return ocppcore.ChargePointStatusUnavailable
+	}
+}
+
+// meterRegister returns the connector's energy register in Wh.
+// Falls back to the session's charged energy if the charge meter has no energy register.
+func (conn *connector) meterRegister() float64 {
+	if f, ok := conn.lp.GetChargeTotalImport(); ok {
+		return 1e3 * f
+	}
+
+	return conn.lp.GetChargedEnergy()
+}
+
+// remoteControl disables charging while the transaction is stopped or limited by a charging profile
+func (conn *connector) remoteControl() {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	demand := loadpoint.RemoteEnable
+	if conn.stopped || conn.limited {
+		demand = loadpoint.RemoteHardDisable
+	}
+
+	if demand != conn.demand {
+		conn.demand = demand
+		conn.lp.RemoteControl(remoteSource, demand)
+	}
+}
+
+// connector returns the connector by id
+func (s *OCPP) connector(id int) (*connector, error) {
+	if id < 1 || id > len(s.connectors) {
+		return nil, fmt.Errorf("invalid connector: %d", id)
+	}
+
+	return s.connectors[id-1], nil
+}
+
+// LoadPoint implements the profile.Connectors interface
+func (s *OCPP) LoadPoint(id int) (loadpoint.API, error) {
+	conn, err := s.connector(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return conn.lp, nil
+}
+
+// TransactionID implements the profile.Connectors interface
+func (s *OCPP) TransactionID(id int) int {
+	conn, err := s.connector(id)
+	if err != nil {
+		return 0
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+
+	return conn.txnId
+}
+
+// RemoteStart implements the profile.Connectors interface
+func (s *OCPP) RemoteStart(id int, idTag string) error {
+	conn, err := s.connector(id)
+	if err != nil {
+		return err
+	}
+
+	conn.mu.Lock()
+	if conn.txnId != 0 {
+		conn.mu.Unlock()
+		return errors.New("transaction already active")
+	}
+
+	conn.idTag = idTag
+	conn.stopped = false
+	conn.mu.Unlock()
+
+	conn.remoteControl()
+
+	return nil
+}
+
+// RemoteStop implements the profile.Connectors interface
+func (s *OCPP) RemoteStop(txnId int) error {
+	for _, conn := range s.connectors {
+		conn.mu.Lock()
+		if txnId == 0 || conn.txnId != txnId {
+			conn.mu.Unlock()
+			continue
+		}
+
+		conn.stopped = true
+		conn.reason = ocppcore.ReasonRemote
+		conn.mu.Unlock()
+
+		conn.remoteControl()
+
+		return nil
+	}
+
+	return fmt.Errorf("unknown transaction: %d", txnId)
+}
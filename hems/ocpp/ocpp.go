@@ -3,6 +3,7 @@ package ocpp
 import (
 	"fmt"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 
@@ -14,24 +15,34 @@ import (
 	"github.com/denisbrodbeck/machineid"
 	ocpp16 "github.com/lorenzodonini/ocpp-go/ocpp1.6"
 	ocppcore "github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 	"github.com/lorenzodonini/ocpp-go/ws"
 )
 
 // OCPP is an OCPP client
 type OCPP struct {
-	log  *util.Logger
-	site site.API
-	cp   ocpp16.ChargePoint
+	log           *util.Logger
+	site          site.API
+	cp            ocpp16.ChargePoint
+	idTag         string
+	connectors    []*connector
+	smartCharging *profile.SmartCharging
 }
 
-const retryTimeout = 5 * time.Second
+const (
+	retryTimeout = 5 * time.Second
+	remoteSource = "ocpp"
+)
 
 // New generates OCPP chargepoint client
 func New(conf map[string]interface{}, site site.API) (*OCPP, error) {
 	cc := struct {
 		URI       string
 		StationID string
-	}{}
+		IdTag     string
+	}{
+		IdTag: "evcc",
+	}
 
 	if err := util.DecodeOther(conf, &cc); err != nil {
 		return nil, err
@@ -53,15 +64,22 @@ func New(conf map[string]interface{}, site site.API) (*OCPP, error) {
 	cp := ocpp16.NewChargePoint(cc.StationID, nil, ws)
 
 	s := &OCPP{
-		log:  log,
-		site: site,
-		cp:   cp,
+		log:   log,
+		site:  site,
+		cp:    cp,
+		idTag: cc.IdTag,
 	}
 
+	for id, lp := range site.LoadPoints() {
+		s.connectors = append(s.connectors, &connector{id: id + 1, lp: lp})
+	}
+
+	s.smartCharging = profile.NewSmartCharging(log, s)
+
 	err := cp.Start(cc.URI)
 	if err == nil {
-		cp.SetCoreHandler(profile.NewCore(log, profile.GetDefaultConfig()))
-		cp.SetSmartChargingHandler(profile.NewSmartCharging(log))
+		cp.SetCoreHandler(profile.NewCore(log, profile.GetDefaultConfig(len(s.connectors)), s, s.smartCharging))
+		cp.SetSmartChargingHandler(s.smartCharging)
 
 		go s.errorHandler(ws.Errors())
 		go s.errorHandler(cp.Errors())
@@ -80,20 +98,129 @@ func (s *OCPP) errorHandler(errC <-chan error) {
 // Run executes the OCPP chargepoint client
 func (s *OCPP) Run() {
 	for {
-		for id, lp := range s.site.LoadPoints() {
-			connector := id + 1
-
-			status := ocppcore.ChargePointStatusAvailable
-			if lp.GetStatus() == api.StatusC {
-				status = ocppcore.ChargePointStatusCharging
-			}
-
-			s.log.DEBUG.Printf("send: lp-%d status: %+v", connector, status)
-			if _, err := s.cp.StatusNotification(connector, ocppcore.NoError, status); err != nil {
-				s.log.ERROR.Printf("lp-%d: %v", connector, err)
-			}
+		for _, conn := range s.connectors {
+			s.update(conn)
 		}
 
 		time.Sleep(retryTimeout)
 	}
 }
+
+// update reports the connector's status, transaction and meter values and applies charging profiles
+func (s *OCPP) update(conn *connector) {
+	status := conn.lp.GetStatus()
+	connected := status == api.StatusB || status == api.StatusC
+
+	limited := !s.smartCharging.Apply(conn.id, conn.lp, time.Now())
+
+	conn.mu.Lock()
+	conn.limited = limited
+	if !connected {
+		// vehicle has left, stopped transaction no longer blocks charging
+		conn.stopped = false
+	}
+	txnId, idTag, stopped, reason := conn.txnId, conn.idTag, conn.stopped, conn.reason
+	conn.mu.Unlock()
+
+	conn.remoteControl()
+
+	if cpStatus := conn.chargePointStatus(status); cpStatus != conn.status {
+		s.log.DEBUG.Printf("send: lp-%d status: %+v", conn.id, cpStatus)
+		if _, err := s.cp.StatusNotification(conn.id, ocppcore.NoError, cpStatus); err != nil {
+			s.log.ERROR.Printf("lp-%d: %v", conn.id, err)
+		} else {
+			conn.status = cpStatus
+		}
+	}
+
+	switch {
+	case txnId == 0 && connected && !stopped:
+		s.startTransaction(conn, idTag)
+
+	case txnId != 0 && !connected:
+		s.stopTransaction(conn, txnId, idTag, ocppcore.ReasonEVDisconnected)
+
+	case txnId != 0 && stopped:
+		s.stopTransaction(conn, txnId, idTag, reason)
+
+	case txnId != 0:
+		s.meterValues(conn, txnId)
+	}
+}
+
+// startTransaction starts a transaction for the connected vehicle
+func (s *OCPP) startTransaction(conn *connector, idTag string) {
+	if idTag == "" {
+		idTag = s.idTag
+	}
+
+	meterStart := int(conn.meterRegister())
+
+	s.log.DEBUG.Printf("send: lp-%d start transaction: %s", conn.id, idTag)
+	res, err := s.cp.StartTransaction(conn.id, idTag, meterStart, types.NewDateTime(time.Now()))
+	if err != nil {
+		s.log.ERROR.Printf("lp-%d: %v", conn.id, err)
+		return
+	}
+
+	conn.mu.Lock()
+	conn.txnId = res.TransactionId
+	conn.idTag = idTag
+
+	if res.IdTagInfo == nil || res.IdTagInfo.Status != types.AuthorizationStatusAccepted {
+		s.log.WARN.Printf("lp-%d: transaction %d not authorized", conn.id, res.TransactionId)
+		conn.stopped = true
+		conn.reason = ocppcore.ReasonDeAuthorized
+	}
+	conn.mu.Unlock()
+
+	conn.remoteControl()
+}
+
+// stopTransaction stops the connector's transaction
+func (s *OCPP) stopTransaction(conn *connector, txnId int, idTag string, reason ocppcore.Reason) {
+	meterStop := int(conn.meterRegister())
+
+	s.log.DEBUG.Printf("send: lp-%d stop transaction: %d (%s)", conn.id, txnId, reason)
+	if _, err := s.cp.StopTransaction(meterStop, types.NewDateTime(time.Now()), txnId, func(request *ocppcore.StopTransactionRequest) {
+		request.IdTag = idTag
+		request.Reason = reason
+	}); err != nil {
+		s.log.ERROR.Printf("lp-%d: %v", conn.id, err)
+		return
+	}
+
+	s.smartCharging.ClearTransaction(conn.id)
+
+	conn.mu.Lock()
+	conn.txnId = 0
+	conn.idTag = ""
+	conn.mu.Unlock()
+}
+
+// meterValues sends the connector's charge power and energy
+func (s *OCPP) meterValues(conn *connector, txnId int) {
+	values := []types.MeterValue{{
+		Timestamp: types.NewDateTime(time.Now()),
+		SampledValue: []types.SampledValue{
+			{
+				Value:     strconv.FormatFloat(conn.lp.GetChargePower(), 'f', 0, 64),
+				Context:   types.ReadingContextSamplePeriodic,
+				Measurand: types.MeasurandPowerActiveImport,
+				Unit:      types.UnitOfMeasureW,
+			},
+			{
+				Value:     strconv.FormatFloat(conn.meterRegister(), 'f', 0, 64),
+				Context:   types.ReadingContextSamplePeriodic,
+				Measurand: types.MeasurandEnergyActiveImportRegister,
+				Unit:      types.UnitOfMeasureWh,
+			},
+		},
+	}}
+
+	if _, err := s.cp.MeterValues(conn.id, values, func(request *ocppcore.MeterValuesRequest) {
+		request.TransactionId = &txnId
+	}); err != nil {
+		s.log.ERROR.Printf("lp-%d: %v", conn.id, err)
+	}
+}
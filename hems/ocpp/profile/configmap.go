@@ -4,6 +4,7 @@ import (
 	"strconv"
 
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/core"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
 	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 )
 
@@ -93,20 +94,20 @@ func (c ConfigMap) set(key string, readonly bool, value string) {
 	}
 }
 
-func GetDefaultConfig() ConfigMap {
+func GetDefaultConfig(connectors int) ConfigMap {
 	intBase := 10
 
 	var cfg ConfigMap = make(map[string]core.ConfigurationKey)
 
 	// readonly
-	cfg.set(SupportedFeatureProfiles, true, core.ProfileName)
+	cfg.set(SupportedFeatureProfiles, true, core.ProfileName+","+smartcharging.ProfileName)
 	cfg.set(AuthorizeRemoteTxRequests, true, strconv.FormatBool(false))
 	cfg.set(GetConfigurationMaxKeys, true, strconv.FormatInt(50, intBase))
-	cfg.set(NumberOfConnectors, true, strconv.FormatInt(1, intBase))
+	cfg.set(NumberOfConnectors, true, strconv.Itoa(connectors))
 	cfg.set(LocalAuthListMaxLength, true, strconv.FormatInt(100, intBase))
 	cfg.set(SendLocalListMaxLength, true, strconv.FormatInt(20, intBase))
 	cfg.set(ChargeProfileMaxStackLevel, true, strconv.FormatInt(10, intBase))
-	cfg.set(ChargingScheduleAllowedChargingRateUnit, true, "Power")
+	cfg.set(ChargingScheduleAllowedChargingRateUnit, true, "Current,Power")
 	cfg.set(ChargingScheduleMaxPeriods, true, strconv.FormatInt(5, intBase))
 	cfg.set(MaxChargingProfilesInstalled, true, strconv.FormatInt(10, intBase))
 
@@ -119,7 +120,7 @@ func GetDefaultConfig() ConfigMap {
 	cfg.set(LocalAuthListEnabled, false, strconv.FormatBool(true))
 	cfg.set(LocalPreAuthorize, false, strconv.FormatBool(false))
 	cfg.set(MeterValuesAlignedData, false, string(types.MeasurandEnergyActiveExportRegister))
-	cfg.set(MeterValuesSampledData, false, string(types.MeasurandEnergyActiveExportRegister))
+	cfg.set(MeterValuesSampledData, false, string(types.MeasurandPowerActiveImport)+","+string(types.MeasurandEnergyActiveImportRegister))
 	cfg.set(MeterValueSampleInterval, false, strconv.FormatInt(5, intBase))
 	cfg.set(ResetRetries, false, strconv.FormatInt(10, intBase))
 	cfg.set(StopTransactionOnEVSideDisconnect, false, strconv.FormatBool(true))
@@ -0,0 +1,15 @@
+package profile
+
+import "github.com/evcc-io/evcc/core/loadpoint"
+
+// Connectors gives the profile handlers access to the charge point's connectors
+type Connectors interface {
+	// LoadPoint returns the loadpoint of the connector
+	LoadPoint(connector int) (loadpoint.API, error)
+	// TransactionID returns the connector's active transaction id or 0
+	TransactionID(connector int) int
+	// RemoteStart starts a transaction for the given id tag once the vehicle is connected
+	RemoteStart(connector int, idTag string) error
+	// RemoteStop stops the given transaction
+	RemoteStop(txnId int) error
+}
@@ -9,12 +9,16 @@ import (
 type Core struct {
 	log           *util.Logger
 	configuration ConfigMap
+	connectors    Connectors
+	smartCharging *SmartCharging
 }
 
-func NewCore(log *util.Logger, config ConfigMap) *Core {
+func NewCore(log *util.Logger, config ConfigMap, connectors Connectors, smartCharging *SmartCharging) *Core {
 	return &Core{
 		log:           log,
 		configuration: config,
+		connectors:    connectors,
+		smartCharging: smartCharging,
 	}
 }
 
@@ -33,11 +37,35 @@ func (s *Core) OnUnlockConnector(request *core.UnlockConnectorRequest) (confirma
 // OnRemoteStartTransaction handles the CS message
 func (s *Core) OnRemoteStartTransaction(request *core.RemoteStartTransactionRequest) (confirmation *core.RemoteStartTransactionConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+
+	connector := 1
+	if request.ConnectorId != nil {
+		connector = *request.ConnectorId
+	}
+
+	if err := s.connectors.RemoteStart(connector, request.IdTag); err != nil {
+		s.log.ERROR.Printf("connector %d: %v", connector, err)
+		return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
+	// profile applies to the transaction about to start
+	if request.ChargingProfile != nil {
+		if err := s.smartCharging.install(connector, request.ChargingProfile, true); err != nil {
+			s.log.ERROR.Printf("connector %d: %v", connector, err)
+		}
+	}
+
+	return core.NewRemoteStartTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
 }
 
 // OnRemoteStopTransaction handles the CS message
 func (s *Core) OnRemoteStopTransaction(request *core.RemoteStopTransactionRequest) (confirmation *core.RemoteStopTransactionConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+
+	if err := s.connectors.RemoteStop(request.TransactionId); err != nil {
+		s.log.ERROR.Printf("transaction %d: %v", request.TransactionId, err)
+		return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusRejected), nil
+	}
+
+	return core.NewRemoteStopTransactionConfirmation(types.RemoteStartStopStatusAccepted), nil
 }
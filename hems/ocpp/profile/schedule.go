@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"time"
+
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+// Voltage is the nominal phase voltage used to convert power limits into currents
+const Voltage = 230
+
+// chargingProfile is a charging profile installed on a connector
+type chargingProfile struct {
+	*types.ChargingProfile
+	connector int
+	received  time.Time // start of relative schedules
+}
+
+// recurrence returns the schedule's recurrence interval or 0 for non-recurring profiles
+func (p chargingProfile) recurrence() time.Duration {
+	switch {
+	case p.ChargingProfileKind != types.ChargingProfileKindRecurring:
+		return 0
+	case p.RecurrencyKind == types.RecurrencyKindWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 24 * time.Hour
+	}
+}
+
+// start returns the start of the schedule occurrence relevant at time t
+func (p chargingProfile) start(t time.Time) time.Time {
+	start := p.received
+	if cs := p.ChargingSchedule; cs.StartSchedule != nil && p.ChargingProfileKind != types.ChargingProfileKindRelative {
+		start = cs.StartSchedule.Time
+	}
+
+	if recurrence := p.recurrence(); recurrence > 0 && t.After(start) {
+		start = start.Add(t.Sub(start) / recurrence * recurrence)
+	}
+
+	return start
+}
+
+// valid checks if the profile's validity interval contains t
+func (p chargingProfile) valid(t time.Time) bool {
+	return (p.ValidFrom == nil || !t.Before(p.ValidFrom.Time)) && (p.ValidTo == nil || t.Before(p.ValidTo.Time))
+}
+
+// limit returns the profile's current limit at time t. Power limits are converted using the number of phases.
+func (p chargingProfile) limit(t time.Time, phases int) (float64, bool) {
+	if !p.valid(t) {
+		return 0, false
+	}
+
+	start := p.start(t)
+	if t.Before(start) {
+		return 0, false
+	}
+
+	cs := p.ChargingSchedule
+	elapsed := int(t.Sub(start) / time.Second)
+	if cs.Duration != nil && elapsed >= *cs.Duration {
+		return 0, false
+	}
+
+	var period *types.ChargingSchedulePeriod
+	for i := range cs.ChargingSchedulePeriod {
+		if cs.ChargingSchedulePeriod[i].StartPeriod <= elapsed {
+			period = &cs.ChargingSchedulePeriod[i]
+		}
+	}
+
+	if period == nil {
+		return 0, false
+	}
+
+	limit := period.Limit
+	if cs.ChargingRateUnit == types.ChargingRateUnitWatts {
+		if period.NumberPhases != nil && *period.NumberPhases > 0 {
+			phases = *period.NumberPhases
+		}
+		limit /= Voltage * float64(phases)
+	}
+
+	return limit, true
+}
+
+// changes returns the times within [from, to) at which the profile's limit may change
+func (p chargingProfile) changes(from, to time.Time) []time.Time {
+	var res []time.Time
+
+	add := func(t time.Time) {
+		if t.After(from) && t.Before(to) {
+			res = append(res, t)
+		}
+	}
+
+	if p.ValidFrom != nil {
+		add(p.ValidFrom.Time)
+	}
+	if p.ValidTo != nil {
+		add(p.ValidTo.Time)
+	}
+
+	cs := p.ChargingSchedule
+	recurrence := p.recurrence()
+
+	for start := p.start(from); start.Before(to); start = start.Add(recurrence) {
+		for _, period := range cs.ChargingSchedulePeriod {
+			add(start.Add(time.Duration(period.StartPeriod) * time.Second))
+		}
+
+		if cs.Duration != nil {
+			add(start.Add(time.Duration(*cs.Duration) * time.Second))
+		}
+
+		if recurrence == 0 {
+			break
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,157 @@
+package profile
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/util"
+	sc "github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
+)
+
+func TestChargingProfileLimit(t *testing.T) {
+	start := time.Date(2022, 3, 7, 8, 0, 0, 0, time.UTC)
+
+	schedule := types.NewChargingSchedule(types.ChargingRateUnitAmperes,
+		types.NewChargingSchedulePeriod(0, 16),
+		types.NewChargingSchedulePeriod(3600, 6),
+	)
+	schedule.StartSchedule = types.NewDateTime(start)
+	duration := 2 * 3600
+	schedule.Duration = &duration
+
+	p := chargingProfile{
+		ChargingProfile: types.NewChargingProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRecurring, schedule),
+	}
+	p.RecurrencyKind = types.RecurrencyKindDaily
+
+	tc := []struct {
+		t     time.Time
+		limit float64
+		ok    bool
+	}{
+		{start.Add(-time.Minute), 0, false},
+		{start, 16, true},
+		{start.Add(90 * time.Minute), 6, true},
+		{start.Add(2 * time.Hour), 0, false},
+		{start.Add(24*time.Hour + time.Minute), 16, true},
+		{start.Add(25*time.Hour + time.Minute), 6, true},
+	}
+
+	for _, tc := range tc {
+		limit, ok := p.limit(tc.t, 3)
+		if limit != tc.limit || ok != tc.ok {
+			t.Errorf("%v: expected %.3g (%v), got %.3g (%v)", tc.t, tc.limit, tc.ok, limit, ok)
+		}
+	}
+
+	changes := p.changes(start.Add(-time.Hour), start.Add(23*time.Hour))
+	if len(changes) != 3 || !changes[0].Equal(start) || !changes[2].Equal(start.Add(2*time.Hour)) {
+		t.Errorf("unexpected changes: %v", changes)
+	}
+}
+
+func TestChargingProfileWatts(t *testing.T) {
+	phases := 1
+	period := types.NewChargingSchedulePeriod(0, 4140)
+	period.NumberPhases = &phases
+
+	p := chargingProfile{
+		ChargingProfile: types.NewChargingProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRelative,
+			types.NewChargingSchedule(types.ChargingRateUnitWatts, period)),
+		received: time.Now(),
+	}
+
+	if limit, ok := p.limit(time.Now(), 3); !ok || limit != 18 {
+		t.Errorf("expected 18A, got %.3g (%v)", limit, ok)
+	}
+}
+
+func TestSmartChargingPrecedence(t *testing.T) {
+	s := NewSmartCharging(util.NewLogger("foo"), nil)
+	now := time.Now()
+
+	profile := func(id, level int, purpose types.ChargingProfilePurposeType, limit float64) *types.ChargingProfile {
+		return types.NewChargingProfile(id, level, purpose, types.ChargingProfileKindRelative,
+			types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, limit)))
+	}
+
+	install := func(connector int, p *types.ChargingProfile) {
+		s.profiles = append(s.profiles, chargingProfile{ChargingProfile: p, connector: connector, received: now})
+	}
+
+	expect := func(limit float64, ok bool) {
+		t.Helper()
+		if l, o := s.limit(1, now, 3); l != limit || o != ok {
+			t.Errorf("expected %.3g (%v), got %.3g (%v)", limit, ok, l, o)
+		}
+	}
+
+	expect(0, false)
+
+	install(0, profile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, 16))
+	expect(16, true)
+
+	install(1, profile(2, 0, types.ChargingProfilePurposeTxDefaultProfile, 10))
+	install(1, profile(3, 1, types.ChargingProfilePurposeTxDefaultProfile, 12))
+	expect(12, true)
+
+	install(1, profile(4, 0, types.ChargingProfilePurposeTxProfile, 8))
+	expect(8, true)
+
+	install(0, profile(5, 0, types.ChargingProfilePurposeChargePointMaxProfile, 6))
+	expect(6, true)
+
+	s.ClearTransaction(1)
+	expect(6, true)
+
+	purpose := types.ChargingProfilePurposeChargePointMaxProfile
+	res, _ := s.OnClearChargingProfile(&sc.ClearChargingProfileRequest{ChargingProfilePurpose: purpose})
+	if res.Status != sc.ClearChargingProfileStatusAccepted {
+		t.Errorf("unexpected status: %s", res.Status)
+	}
+	expect(12, true)
+}
+
+type testLoadpoint struct {
+	loadpoint.API
+	maxCurrent float64
+	limit      float64
+}
+
+func (lp *testLoadpoint) GetPhases() int                             { return 3 }
+func (lp *testLoadpoint) GetMinCurrent() float64                     { return 6 }
+func (lp *testLoadpoint) GetMaxCurrent() float64                     { return lp.maxCurrent }
+func (lp *testLoadpoint) SetMaxCurrent(current float64)              { lp.maxCurrent = current }
+func (lp *testLoadpoint) RemoteCurrentLimit(_ string, limit float64) { lp.limit = limit }
+
+func TestSmartChargingApply(t *testing.T) {
+	s := NewSmartCharging(util.NewLogger("foo"), nil)
+	lp := &testLoadpoint{maxCurrent: 16}
+	now := time.Now()
+
+	install := func(limit float64) {
+		s.profiles = []chargingProfile{{
+			ChargingProfile: types.NewChargingProfile(1, 0, types.ChargingProfilePurposeTxDefaultProfile, types.ChargingProfileKindRelative,
+				types.NewChargingSchedule(types.ChargingRateUnitAmperes, types.NewChargingSchedulePeriod(0, limit))),
+			received: now,
+		}}
+	}
+
+	expect := func(enabled bool, limit float64) {
+		t.Helper()
+		if res := s.Apply(1, lp, now); res != enabled || lp.limit != limit || lp.maxCurrent != 16 {
+			t.Errorf("expected %v %.3gA, got %v %.3gA (max %.3gA)", enabled, limit, res, lp.limit, lp.maxCurrent)
+		}
+	}
+
+	install(10)
+	expect(true, 10)
+
+	install(4)
+	expect(false, 4)
+
+	s.profiles = nil
+	expect(true, 0)
+}
@@ -1,34 +1,273 @@
 package profile
 
 import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/evcc-io/evcc/core/loadpoint"
 	"github.com/evcc-io/evcc/util"
 	sc "github.com/lorenzodonini/ocpp-go/ocpp1.6/smartcharging"
+	"github.com/lorenzodonini/ocpp-go/ocpp1.6/types"
 )
 
+// remoteSource identifies profile limits applied to the loadpoints
+const remoteSource = "ocpp"
+
+// SmartCharging maps charging profiles onto the loadpoints' remote current limit
 type SmartCharging struct {
-	log *util.Logger
+	mu         sync.Mutex
+	log        *util.Logger
+	connectors Connectors
+	profiles   []chargingProfile
+	limits     map[int]float64 // current limit last applied per connector, zero if unlimited
 }
 
-func NewSmartCharging(log *util.Logger) *SmartCharging {
+func NewSmartCharging(log *util.Logger, connectors Connectors) *SmartCharging {
 	return &SmartCharging{
-		log: log,
+		log:        log,
+		connectors: connectors,
+		limits:     make(map[int]float64),
+	}
+}
+
+// phases returns the loadpoint's phases used for converting power limits
+func phases(lp loadpoint.API) int {
+	if phases := lp.GetPhases(); phases > 0 {
+		return phases
+	}
+	return 3
+}
+
+// install adds a charging profile to the connector, replacing profiles with same id or same purpose and stack level
+func (s *SmartCharging) install(connector int, profile *types.ChargingProfile, txn bool) error {
+	if profile == nil || profile.ChargingSchedule == nil {
+		return errors.New("missing charging schedule")
+	}
+
+	switch profile.ChargingProfilePurpose {
+	case types.ChargingProfilePurposeChargePointMaxProfile:
+		if connector != 0 {
+			return errors.New("charge point max profile requires connector 0")
+		}
+	case types.ChargingProfilePurposeTxProfile:
+		if connector == 0 {
+			return errors.New("tx profile requires connector")
+		}
+		if !txn && s.connectors.TransactionID(connector) == 0 {
+			return errors.New("tx profile requires active transaction")
+		}
+	}
+
+	if connector != 0 {
+		if _, err := s.connectors.LoadPoint(connector); err != nil {
+			return err
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var profiles []chargingProfile
+	for _, p := range s.profiles {
+		if p.ChargingProfileId == profile.ChargingProfileId ||
+			p.connector == connector && p.ChargingProfilePurpose == profile.ChargingProfilePurpose && p.StackLevel == profile.StackLevel {
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+
+	s.profiles = append(profiles, chargingProfile{
+		ChargingProfile: profile,
+		connector:       connector,
+		received:        time.Now(),
+	})
+
+	return nil
+}
+
+// active returns the limit of the highest stack level profile of given purpose active at time t
+func (s *SmartCharging) active(purpose types.ChargingProfilePurposeType, connector int, t time.Time, phases int) (float64, bool) {
+	var limit float64
+	level := -1
+
+	for _, p := range s.profiles {
+		if p.connector != connector || p.ChargingProfilePurpose != purpose || p.StackLevel <= level {
+			continue
+		}
+
+		if l, ok := p.limit(t, phases); ok {
+			limit, level = l, p.StackLevel
+		}
+	}
+
+	return limit, level >= 0
+}
+
+// limit returns the connector's current limit at time t.
+// Transaction profiles take precedence over default profiles, the charge point max profile caps both.
+func (s *SmartCharging) limit(connector int, t time.Time, phases int) (float64, bool) {
+	limit, ok := s.active(types.ChargingProfilePurposeTxProfile, connector, t, phases)
+	if !ok {
+		limit, ok = s.active(types.ChargingProfilePurposeTxDefaultProfile, connector, t, phases)
+	}
+	if !ok {
+		limit, ok = s.active(types.ChargingProfilePurposeTxDefaultProfile, 0, t, phases)
+	}
+
+	if max, maxOk := s.active(types.ChargingProfilePurposeChargePointMaxProfile, 0, t, phases); maxOk && (!ok || max < limit) {
+		limit, ok = max, true
+	}
+
+	return limit, ok
+}
+
+// Apply applies the connector's current limit at time t as remote current limit of the loadpoint.
+// The loadpoint's max current remains untouched.
+// Returns false if the limit is below the loadpoint's min current and charging must be disabled.
+func (s *SmartCharging) Apply(connector int, lp loadpoint.API, t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	limit, ok := s.limit(connector, t, phases(lp))
+	if !ok {
+		limit = 0
+	}
+
+	if applied, limited := s.limits[connector]; limit != applied || !limited {
+		s.log.DEBUG.Printf("connector %d: current limit: %.3gA", connector, limit)
+		lp.RemoteCurrentLimit(remoteSource, limit)
+		s.limits[connector] = limit
+	}
+
+	return !ok || limit >= lp.GetMinCurrent()
+}
+
+// ClearTransaction removes the connector's transaction profiles once the transaction has stopped
+func (s *SmartCharging) ClearTransaction(connector int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var profiles []chargingProfile
+	for _, p := range s.profiles {
+		if p.connector != connector || p.ChargingProfilePurpose != types.ChargingProfilePurposeTxProfile {
+			profiles = append(profiles, p)
+		}
 	}
+
+	s.profiles = profiles
 }
 
 // OnSetChargingProfile handles the CS message
 func (s *SmartCharging) OnSetChargingProfile(request *sc.SetChargingProfileRequest) (confirmation *sc.SetChargingProfileConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusRejected), nil
+
+	if err := s.install(request.ConnectorId, request.ChargingProfile, false); err != nil {
+		s.log.ERROR.Printf("connector %d: %v", request.ConnectorId, err)
+		return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusRejected), nil
+	}
+
+	return sc.NewSetChargingProfileConfirmation(sc.ChargingProfileStatusAccepted), nil
 }
 
 // OnClearChargingProfile handles the CS message
 func (s *SmartCharging) OnClearChargingProfile(request *sc.ClearChargingProfileRequest) (confirmation *sc.ClearChargingProfileConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewClearChargingProfileConfirmation(sc.ClearChargingProfileStatusUnknown), nil
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var profiles []chargingProfile
+	for _, p := range s.profiles {
+		if (request.Id == nil || *request.Id == p.ChargingProfileId) &&
+			(request.ConnectorId == nil || *request.ConnectorId == p.connector) &&
+			(request.ChargingProfilePurpose == "" || request.ChargingProfilePurpose == p.ChargingProfilePurpose) &&
+			(request.StackLevel == nil || *request.StackLevel == p.StackLevel) {
+			continue
+		}
+		profiles = append(profiles, p)
+	}
+
+	status := sc.ClearChargingProfileStatusUnknown
+	if len(profiles) < len(s.profiles) {
+		status = sc.ClearChargingProfileStatusAccepted
+	}
+
+	s.profiles = profiles
+
+	return sc.NewClearChargingProfileConfirmation(status), nil
 }
 
 // OnGetCompositeSchedule handles the CS message
 func (s *SmartCharging) OnGetCompositeSchedule(request *sc.GetCompositeScheduleRequest) (confirmation *sc.GetCompositeScheduleConfirmation, err error) {
 	s.log.TRACE.Printf("recv: %s %+v", request.GetFeatureName(), request)
-	return sc.NewGetCompositeScheduleConfirmation(sc.GetCompositeScheduleStatusRejected), nil
+
+	connector := request.ConnectorId
+	lp, err := s.connectors.LoadPoint(connector)
+	if err != nil {
+		s.log.ERROR.Printf("connector %d: %v", connector, err)
+		return sc.NewGetCompositeScheduleConfirmation(sc.GetCompositeScheduleStatusRejected), nil
+	}
+
+	unit := request.ChargingRateUnit
+	if unit == "" {
+		unit = types.ChargingRateUnitAmperes
+	}
+
+	schedule := s.compositeSchedule(connector, lp, time.Now(), request.Duration, unit)
+
+	res := sc.NewGetCompositeScheduleConfirmation(sc.GetCompositeScheduleStatusAccepted)
+	res.ConnectorId = &connector
+	res.ScheduleStart = schedule.StartSchedule
+	res.ChargingSchedule = schedule
+
+	return res, nil
+}
+
+// compositeSchedule combines the connector's profiles into a single schedule starting at time t
+func (s *SmartCharging) compositeSchedule(connector int, lp loadpoint.API, t time.Time, duration int, unit types.ChargingRateUnitType) *types.ChargingSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	phases := phases(lp)
+
+	maxCurrent := lp.GetMaxCurrent()
+
+	end := t.Add(time.Duration(duration) * time.Second)
+
+	changes := []time.Time{t}
+	for _, p := range s.profiles {
+		if p.connector == connector || p.connector == 0 {
+			changes = append(changes, p.changes(t, end)...)
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Before(changes[j])
+	})
+
+	schedule := types.NewChargingSchedule(unit)
+	schedule.StartSchedule = types.NewDateTime(t)
+	schedule.Duration = &duration
+
+	for _, ts := range changes {
+		limit, ok := s.limit(connector, ts, phases)
+		if !ok || limit > maxCurrent {
+			limit = maxCurrent
+		}
+
+		if unit == types.ChargingRateUnitWatts {
+			limit *= Voltage * float64(phases)
+		}
+
+		if n := len(schedule.ChargingSchedulePeriod); n > 0 && schedule.ChargingSchedulePeriod[n-1].Limit == limit {
+			continue
+		}
+
+		schedule.ChargingSchedulePeriod = append(schedule.ChargingSchedulePeriod,
+			types.NewChargingSchedulePeriod(int(ts.Sub(t)/time.Second), limit))
+	}
+
+	return schedule
 }
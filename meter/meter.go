@@ -13,15 +13,16 @@ func init() {
 	registry.Add(api.Custom, NewConfigurableFromConfig)
 }
 
-//go:generate go run ../cmd/tools/decorate.go -f decorateMeter -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)"
+//go:generate go run ../cmd/tools/decorate.go -f decorateMeter -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)" -t "api.BatteryController,SetBatteryMode,func(mode api.BatteryMode) error"
 
 // NewConfigurableFromConfig creates api.Meter from config
 func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error) {
 	cc := struct {
-		Power    provider.Config
-		Energy   *provider.Config  // optional
-		SoC      *provider.Config  // optional
-		Currents []provider.Config // optional
+		Power       provider.Config
+		Energy      *provider.Config  // optional
+		SoC         *provider.Config  // optional
+		BatteryMode *provider.Config  // optional
+		Currents    []provider.Config // optional
 	}{}
 
 	if err := util.DecodeOther(other, &cc); err != nil {
@@ -38,6 +39,7 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error)
 	cc.Power.Deprecate(log)
 	cc.Energy.Deprecate(log)
 	cc.SoC.Deprecate(log)
+	cc.BatteryMode.Deprecate(log)
 	for _, p := range cc.Currents {
 		p.Deprecate(log)
 	}
@@ -82,7 +84,20 @@ func NewConfigurableFromConfig(other map[string]interface{}) (api.Meter, error)
 		}
 	}
 
-	res := m.Decorate(totalEnergyG, currentsG, batterySoCG)
+	// decorate Meter with BatteryController
+	var batteryModeS func(api.BatteryMode) error
+	if cc.BatteryMode != nil {
+		set, err := provider.NewIntSetterFromConfig("batterymode", *cc.BatteryMode)
+		if err != nil {
+			return nil, fmt.Errorf("battery mode: %w", err)
+		}
+
+		batteryModeS = func(mode api.BatteryMode) error {
+			return set(int64(mode))
+		}
+	}
+
+	res := m.Decorate(totalEnergyG, currentsG, batterySoCG, batteryModeS)
 
 	return res, nil
 }
@@ -122,8 +137,9 @@ func (m *Meter) Decorate(
 	totalEnergy func() (float64, error),
 	currents func() (float64, float64, float64, error),
 	batterySoC func() (float64, error),
+	batteryMode func(api.BatteryMode) error,
 ) api.Meter {
-	return decorateMeter(m, totalEnergy, currents, batterySoC)
+	return decorateMeter(m, totalEnergy, currents, batterySoC, batteryMode)
 }
 
 // CurrentPower implements the api.Meter interface
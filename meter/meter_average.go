@@ -55,7 +55,13 @@ func NewMovingAverageFromConfig(other map[string]interface{}) (api.Meter, error)
 		currents = m.Currents
 	}
 
-	res := meter.Decorate(totalEnergy, currents, batterySoC)
+	// decorate battery control
+	var batteryMode func(api.BatteryMode) error
+	if m, ok := m.(api.BatteryController); ok {
+		batteryMode = m.SetBatteryMode
+	}
+
+	res := meter.Decorate(totalEnergy, currents, batterySoC, batteryMode)
 
 	return res, nil
 }
@@ -3,6 +3,7 @@ package meter
 import (
 	"errors"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/evcc-io/evcc/api"
@@ -26,7 +27,7 @@ func init() {
 	registry.Add("modbus", NewModbusFromConfig)
 }
 
-//go:generate go run ../cmd/tools/decorate.go -f decorateModbus -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)"
+//go:generate go run ../cmd/tools/decorate.go -f decorateModbus -b api.Meter -t "api.MeterEnergy,TotalEnergy,func() (float64, error)" -t "api.MeterCurrent,Currents,func() (float64, float64, float64, error)" -t "api.Battery,SoC,func() (float64, error)" -t "api.BatteryController,SetBatteryMode,func(mode api.BatteryMode) error"
 
 // NewModbusFromConfig creates api.Meter from config
 func NewModbusFromConfig(other map[string]interface{}) (api.Meter, error) {
@@ -146,7 +147,15 @@ func NewModbusFromConfig(other map[string]interface{}) (api.Meter, error) {
 		soc = m.soc
 	}
 
-	return decorateModbus(m, totalEnergy, currentsG, soc), nil
+	// decorate battery control if the device supports SunSpec storage controls
+	var batteryMode func(api.BatteryMode) error
+	if dev, ok := device.(*sunspec.SunSpec); ok && cc.SoC != "" {
+		if _, _, err := dev.QueryPointAny(conn, sunspecStorageModel, 0, "StorCtl_Mod"); err == nil {
+			batteryMode = m.batteryMode
+		}
+	}
+
+	return decorateModbus(m, totalEnergy, currentsG, soc, batteryMode), nil
 }
 
 // floatGetter executes configured modbus read operation and implements func() (float64, error)
@@ -194,3 +203,44 @@ func (m *Modbus) totalEnergy() (float64, error) {
 func (m *Modbus) soc() (float64, error) {
 	return m.floatGetter(m.opSoC)
 }
+
+// sunspecStorageModel is the SunSpec basic storage controls model
+const sunspecStorageModel = 124
+
+// batteryMode implements the api.BatteryController interface using SunSpec storage controls
+func (m *Modbus) batteryMode(mode api.BatteryMode) error {
+	dev := m.device.(*sunspec.SunSpec)
+
+	block, _, err := dev.QueryPointAny(m.conn, sunspecStorageModel, 0, "StorCtl_Mod")
+	if err != nil {
+		return err
+	}
+
+	// charge/discharge rates are percent of WChaMax, scaled by InOutWRte_SF
+	rate := func(pct float64) int16 {
+		sf := block.MustPoint("InOutWRte_SF").ScaleFactor()
+		return int16(pct / math.Pow10(int(sf)))
+	}
+
+	switch mode {
+	case api.BatteryNormal:
+		block.MustPoint("StorCtl_Mod").SetBitfield16(0)
+		return block.Write("StorCtl_Mod")
+
+	case api.BatteryHold:
+		// limit discharge rate to zero
+		block.MustPoint("OutWRte").SetInt16(0)
+		block.MustPoint("StorCtl_Mod").SetBitfield16(2)
+		return block.Write("OutWRte", "StorCtl_Mod")
+
+	case api.BatteryCharge:
+		// negative discharge rate charges the battery, allow charging from grid
+		block.MustPoint("ChaGriSet").SetEnum16(1)
+		block.MustPoint("OutWRte").SetInt16(rate(-100))
+		block.MustPoint("StorCtl_Mod").SetBitfield16(2)
+		return block.Write("ChaGriSet", "OutWRte", "StorCtl_Mod")
+
+	default:
+		return fmt.Errorf("invalid battery mode: %d", mode)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/provider/sma"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 	"gitlab.com/bboehmke/sunny"
 )
 
@@ -51,6 +52,10 @@ func NewSMA(uri, password, iface string, serial uint32, scale float64) (api.Mete
 		scale: scale,
 	}
 
+	if err := record.Unrecorded(record.SMA, uri); err != nil {
+		return nil, err
+	}
+
 	discoverer, err := sma.GetDiscoverer(iface)
 	if err != nil {
 		return nil, fmt.Errorf("discoverer: %w", err)
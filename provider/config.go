@@ -2,6 +2,7 @@ package provider
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/evcc-io/evcc/util"
@@ -24,6 +25,9 @@ type (
 	SetIntProvider interface {
 		IntSetter(param string) func(int64) error
 	}
+	SetFloatProvider interface {
+		FloatSetter(param string) func(float64) error
+	}
 	SetStringProvider interface {
 		StringSetter(param string) func(string) error
 	}
@@ -51,6 +55,16 @@ func (r providerRegistry) Get(name string) (func(map[string]interface{}) (IntPro
 
 var registry providerRegistry = make(map[string]func(map[string]interface{}) (IntProvider, error))
 
+// Types returns the registered plugin types
+func Types() []string {
+	res := make([]string, 0, len(registry))
+	for typ := range registry {
+		res = append(res, typ)
+	}
+	sort.Strings(res)
+	return res
+}
+
 // Config is the general provider config
 type Config struct {
 	Source string
@@ -194,3 +208,41 @@ func NewBoolSetterFromConfig(param string, config Config) (res func(bool) error,
 
 	return
 }
+
+// NewFloatSetterFromConfig creates a FloatSetter from config
+func NewFloatSetterFromConfig(param string, config Config) (res func(float64) error, err error) {
+	factory, err := registry.Get(config.PluginType())
+	if err == nil {
+		var provider IntProvider
+		provider, err = factory(config.Other)
+
+		if prov, ok := provider.(SetFloatProvider); ok {
+			res = prov.FloatSetter(param)
+		}
+	}
+
+	if err == nil && res == nil {
+		err = fmt.Errorf("invalid plugin type: %s", config.PluginType())
+	}
+
+	return
+}
+
+// NewStringSetterFromConfig creates a StringSetter from config
+func NewStringSetterFromConfig(param string, config Config) (res func(string) error, err error) {
+	factory, err := registry.Get(config.PluginType())
+	if err == nil {
+		var provider IntProvider
+		provider, err = factory(config.Other)
+
+		if prov, ok := provider.(SetStringProvider); ok {
+			res = prov.StringSetter(param)
+		}
+	}
+
+	if err == nil && res == nil {
+		err = fmt.Errorf("invalid plugin type: %s", config.PluginType())
+	}
+
+	return
+}
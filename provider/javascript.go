@@ -109,6 +109,17 @@ func (p *Javascript) IntSetter(param string) func(int64) error {
 	}
 }
 
+// FloatSetter sends float request
+func (p *Javascript) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		err := p.setParam(param, val)
+		if err == nil {
+			_, err = p.vm.Eval(p.script)
+		}
+		return err
+	}
+}
+
 // StringSetter sends string request
 func (p *Javascript) StringSetter(param string) func(string) error {
 	return func(val string) error {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ type Modbus struct {
 	conn   *modbus.Connection
 	device meters.Device
 	op     modbus.Operation
+	encode func(float64) []byte
 	scale  float64
 }
 
@@ -125,11 +127,20 @@ func NewModbusFromConfig(other map[string]interface{}) (IntProvider, error) {
 		}
 	}
 
+	var encode func(float64) []byte
+
 	// register configured
 	if cc.Register.Decode != "" {
 		if op.MBMD, err = modbus.RegisterOperation(cc.Register); err != nil {
 			return nil, err
 		}
+
+		// writing multiple registers requires encoding
+		if op.MBMD.FuncCode == gridx.FuncCodeWriteMultipleRegisters {
+			if encode, err = modbus.RegisterEncoder(cc.Register); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	mb := &Modbus{
@@ -137,6 +148,7 @@ func NewModbusFromConfig(other map[string]interface{}) (IntProvider, error) {
 		conn:   conn,
 		device: device,
 		op:     op,
+		encode: encode,
 		scale:  cc.Scale,
 	}
 	return mb, nil
@@ -261,18 +273,45 @@ func (m *Modbus) BoolGetter() func() (bool, error) {
 	}
 }
 
+// FloatSetter executes configured modbus write operation and implements SetFloatProvider
+func (m *Modbus) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		var err error
+
+		// if funccode is configured, execute the write directly
+		if op := m.op.MBMD; op.FuncCode != 0 {
+			val = m.scale * val
+
+			switch op.FuncCode {
+			case gridx.FuncCodeWriteSingleRegister:
+				_, err = m.conn.WriteSingleRegister(op.OpCode, uint16(int64(math.Round(val))))
+			case gridx.FuncCodeWriteMultipleRegisters:
+				b := m.encode(val)
+				_, err = m.conn.WriteMultipleRegisters(op.OpCode, uint16(len(b)/2), b)
+			default:
+				err = fmt.Errorf("unknown function code %d", op.FuncCode)
+			}
+		} else {
+			err = errors.New("modbus plugin does not support writing to sunspec")
+		}
+
+		return err
+	}
+}
+
 // IntSetter executes configured modbus write operation and implements SetIntProvider
 func (m *Modbus) IntSetter(param string) func(int64) error {
 	return func(val int64) error {
 		var err error
 
-		// if funccode is configured, execute the read directly
+		// if funccode is configured, execute the write directly
 		if op := m.op.MBMD; op.FuncCode != 0 {
-			uval := uint16(int64(m.scale) * val)
-
 			switch op.FuncCode {
 			case gridx.FuncCodeWriteSingleRegister:
-				_, err = m.conn.WriteSingleRegister(op.OpCode, uval)
+				_, err = m.conn.WriteSingleRegister(op.OpCode, uint16(int64(m.scale)*val))
+			case gridx.FuncCodeWriteMultipleRegisters:
+				b := m.encode(m.scale * float64(val))
+				_, err = m.conn.WriteMultipleRegisters(op.OpCode, uint16(len(b)/2), b)
 			default:
 				err = fmt.Errorf("unknown function code %d", op.FuncCode)
 			}
@@ -284,6 +323,20 @@ func (m *Modbus) IntSetter(param string) func(int64) error {
 	}
 }
 
+// StringSetter executes configured modbus write operation of the numeric string value and implements SetStringProvider
+func (m *Modbus) StringSetter(param string) func(string) error {
+	set := m.FloatSetter(param)
+
+	return func(val string) error {
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		if err != nil {
+			return fmt.Errorf("invalid value %s: %w", val, err)
+		}
+
+		return set(f)
+	}
+}
+
 // BoolSetter executes configured modbus write operation and implements SetBoolProvider
 func (m *Modbus) BoolSetter(param string) func(bool) error {
 	set := m.IntSetter(param)
@@ -9,6 +9,7 @@ import (
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 )
 
 const (
@@ -40,7 +41,7 @@ type Client struct {
 	Client   paho.Client
 	broker   string
 	Qos      byte
-	listener map[string][]func(string)
+	listener map[string][]*func(string)
 }
 
 type Option func(*paho.ClientOptions)
@@ -52,7 +53,7 @@ func NewClient(log *util.Logger, broker, user, password, clientID string, qos by
 	mc := &Client{
 		log:      log,
 		Qos:      qos,
-		listener: make(map[string][]func(string)),
+		listener: make(map[string][]*func(string)),
 	}
 
 	options := paho.NewClientOptions()
@@ -78,6 +79,13 @@ func NewClient(log *util.Logger, broker, user, password, clientID string, qos by
 	if len(or.Servers()) == 1 {
 		mc.broker = or.Servers()[0].String()
 	}
+	// replayed messages are delivered without connecting
+	if record.Replaying() {
+		log.INFO.Printf("replaying %s at %s", clientID, mc.broker)
+		mc.Client = client
+		return mc, nil
+	}
+
 	log.INFO.Printf("connecting %s at %s", clientID, mc.broker)
 
 	if token := client.Connect(); token.Wait() && token.Error() != nil {
@@ -110,6 +118,17 @@ func (m *Client) ConnectionHandler(client paho.Client) {
 // Publish synchronously publishes payload using client qos
 func (m *Client) Publish(topic string, retained bool, payload interface{}) error {
 	m.log.TRACE.Printf("send %s: '%v'", topic, payload)
+
+	if record.Replaying() {
+		return nil
+	}
+
+	record.Add(record.Entry{
+		Transport: record.MQTT,
+		Request:   m.broker + " publish " + topic,
+		Body:      fmt.Sprintf("%v", payload),
+	})
+
 	token := m.Client.Publish(topic, m.Qos, retained, payload)
 	if token.WaitTimeout(publishTimeout) {
 		return token.Error()
@@ -117,15 +136,61 @@ func (m *Client) Publish(topic string, retained bool, payload interface{}) error
 	return api.ErrTimeout
 }
 
-// Listen validates uniqueness and registers and attaches listener
+// Listen validates uniqueness and registers and attaches listener.
+// The listener is removed when the device it was created for is released.
 func (m *Client) Listen(topic string, callback func(string)) {
+	cb := &callback
+
 	m.mux.Lock()
-	m.listener[topic] = append(m.listener[topic], callback)
+	m.listener[topic] = append(m.listener[topic], cb)
 	m.mux.Unlock()
 
+	util.OnRelease(func() { m.unlisten(topic, cb) })
+
+	// deliver recorded messages in order
+	if record.Replaying() {
+		for _, e := range record.Entries(record.MQTT, m.broker+" "+topic) {
+			callback(e.Response)
+		}
+		return
+	}
+
 	m.listen(topic)
 }
 
+// unlisten removes the listener and unsubscribes the topic once unused
+func (m *Client) unlisten(topic string, cb *func(string)) {
+	m.mux.Lock()
+	var callbacks []*func(string)
+	for _, c := range m.listener[topic] {
+		if c != cb {
+			callbacks = append(callbacks, c)
+		}
+	}
+
+	unused := len(callbacks) == 0
+	if unused {
+		delete(m.listener, topic)
+	} else {
+		m.listener[topic] = callbacks
+	}
+	m.mux.Unlock()
+
+	if unused && !record.Replaying() {
+		m.log.DEBUG.Printf("%s unsubscribe %s", m.broker, topic)
+		m.WaitForToken(m.Client.Unsubscribe(topic))
+
+		// restore subscription if listened to meanwhile
+		m.mux.Lock()
+		_, ok := m.listener[topic]
+		m.mux.Unlock()
+
+		if ok {
+			m.listen(topic)
+		}
+	}
+}
+
 // ListenSetter creates a /set listener that resets the payload after handling
 func (m *Client) ListenSetter(topic string, callback func(string)) {
 	m.Listen(topic, func(payload string) {
@@ -142,12 +207,18 @@ func (m *Client) listen(topic string) {
 		payload := string(msg.Payload())
 		m.log.TRACE.Printf("recv %s: '%v'", topic, payload)
 		if len(payload) > 0 {
+			record.Add(record.Entry{
+				Transport: record.MQTT,
+				Request:   m.broker + " " + topic,
+				Response:  payload,
+			})
+
 			m.mux.Lock()
 			callbacks := m.listener[topic]
 			m.mux.Unlock()
 
 			for _, cb := range callbacks {
-				cb(payload)
+				(*cb)(payload)
 			}
 		}
 	})
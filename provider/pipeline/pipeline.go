@@ -3,6 +3,7 @@ package pipeline
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
@@ -191,7 +192,16 @@ func (p *Pipeline) Process(in []byte) ([]byte, error) {
 		if err != nil {
 			return b, err
 		}
-		b = []byte(fmt.Sprintf("%v", v))
+
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			// keep structured results as json
+			if b, err = json.Marshal(v); err != nil {
+				return b, err
+			}
+		default:
+			b = []byte(fmt.Sprintf("%v", v))
+		}
 	}
 
 	if p.unpack != "" {
@@ -13,6 +13,7 @@ import (
 
 	"github.com/evcc-io/evcc/util"
 	"github.com/evcc-io/evcc/util/jq"
+	"github.com/evcc-io/evcc/util/record"
 	"github.com/evcc-io/evcc/util/request"
 	"github.com/itchyny/gojq"
 	"github.com/kballard/go-shellquote"
@@ -109,6 +110,10 @@ func (p *Script) exec(script string) (string, error) {
 		return "", err
 	}
 
+	if err := record.Unrecorded(record.Exec, script); err != nil {
+		return "", err
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
 
@@ -202,34 +207,42 @@ func (p *Script) BoolGetter() func() (bool, error) {
 	}
 }
 
+func (p *Script) set(param string, val interface{}) error {
+	cmd, err := util.ReplaceFormatted(p.script, map[string]interface{}{
+		param: val,
+	})
+
+	if err == nil {
+		_, err = p.exec(cmd)
+	}
+
+	return err
+}
+
 // IntSetter invokes script with parameter replaced by int value
 func (p *Script) IntSetter(param string) func(int64) error {
-	// return func to access cached value
 	return func(i int64) error {
-		cmd, err := util.ReplaceFormatted(p.script, map[string]interface{}{
-			param: i,
-		})
+		return p.set(param, i)
+	}
+}
 
-		if err == nil {
-			_, err = p.exec(cmd)
-		}
+// FloatSetter invokes script with parameter replaced by float value
+func (p *Script) FloatSetter(param string) func(float64) error {
+	return func(f float64) error {
+		return p.set(param, f)
+	}
+}
 
-		return err
+// StringSetter invokes script with parameter replaced by string value
+func (p *Script) StringSetter(param string) func(string) error {
+	return func(s string) error {
+		return p.set(param, s)
 	}
 }
 
 // BoolSetter invokes script with parameter replaced by bool value
 func (p *Script) BoolSetter(param string) func(bool) error {
-	// return func to access cached value
 	return func(b bool) error {
-		cmd, err := util.ReplaceFormatted(p.script, map[string]interface{}{
-			param: b,
-		})
-
-		if err == nil {
-			_, err = p.exec(cmd)
-		}
-
-		return err
+		return p.set(param, b)
 	}
 }
@@ -6,6 +6,7 @@ import (
 
 	"github.com/evcc-io/evcc/provider/sma"
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 	"gitlab.com/bboehmke/sunny"
 )
 
@@ -36,6 +37,10 @@ func NewSMAFromConfig(other map[string]interface{}) (IntProvider, error) {
 		return nil, err
 	}
 
+	if err := record.Unrecorded(record.SMA, cc.URI); err != nil {
+		return nil, err
+	}
+
 	discoverer, err := sma.GetDiscoverer(cc.Interface)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get discoverer failed: %w", err)
@@ -1,14 +1,17 @@
 package provider
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/evcc-io/evcc/util"
 	"github.com/evcc-io/evcc/util/jq"
+	"github.com/evcc-io/evcc/util/record"
 	"github.com/evcc-io/evcc/util/request"
 	"github.com/evcc-io/evcc/util/transport"
 	"github.com/gorilla/websocket"
@@ -27,6 +30,10 @@ type Socket struct {
 	scale   float64
 	jq      *gojq.Query
 	val     interface{}
+	payload string
+	connMu  sync.Mutex // guards conn and serializes writes
+	conn    *websocket.Conn
+	closed  bool
 }
 
 func init() {
@@ -41,6 +48,7 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		Headers  map[string]string
 		Jq       string
 		Scale    float64
+		Payload  string
 		Insecure bool
 		Auth     Auth
 		Timeout  time.Duration
@@ -59,6 +67,10 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		log.WARN.Printf("missing scheme for %s, assuming ws", cc.URI)
 	}
 
+	if err := record.Unrecorded(record.Websocket, url); err != nil {
+		return nil, err
+	}
+
 	p := &Socket{
 		log:     log,
 		Helper:  request.NewHelper(log),
@@ -66,6 +78,7 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		url:     url,
 		headers: cc.Headers,
 		scale:   cc.Scale,
+		payload: cc.Payload,
 	}
 
 	// handle basic auth
@@ -90,11 +103,29 @@ func NewSocketProviderFromConfig(other map[string]interface{}) (IntProvider, err
 		p.jq = op
 	}
 
+	util.OnRelease(p.close)
 	go p.listen()
 
 	return p, nil
 }
 
+func (p *Socket) isClosed() bool {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+	return p.closed
+}
+
+// close stops listening and closes the connection
+func (p *Socket) close() {
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	p.closed = true
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+}
+
 func (p *Socket) listen() {
 	headers := make(http.Header)
 	for k, v := range p.headers {
@@ -106,7 +137,7 @@ func (p *Socket) listen() {
 		HandshakeTimeout: request.Timeout,
 	}
 
-	for {
+	for !p.isClosed() {
 		client, _, err := dialer.Dial(p.url, headers)
 		if err != nil {
 			p.log.ERROR.Println(err)
@@ -114,11 +145,25 @@ func (p *Socket) listen() {
 			continue
 		}
 
+		p.connMu.Lock()
+		if p.closed {
+			_ = client.Close()
+			p.connMu.Unlock()
+			return
+		}
+		p.conn = client
+		p.connMu.Unlock()
+
 		for {
 			_, b, err := client.ReadMessage()
 			if err != nil {
 				p.log.TRACE.Println("read:", err)
+
+				p.connMu.Lock()
+				p.conn = nil
 				_ = client.Close()
+				p.connMu.Unlock()
+
 				break
 			}
 
@@ -223,3 +268,49 @@ func (p *Socket) BoolGetter() func() (bool, error) {
 		return jq.Bool(v)
 	}
 }
+
+func (p *Socket) set(param string, val interface{}) error {
+	payload, err := setFormattedValue(p.payload, param, val)
+	if err != nil {
+		return err
+	}
+
+	p.connMu.Lock()
+	defer p.connMu.Unlock()
+
+	if p.conn == nil {
+		return errors.New("not connected")
+	}
+
+	p.log.TRACE.Printf("send: %s", payload)
+
+	return p.conn.WriteMessage(websocket.TextMessage, []byte(payload))
+}
+
+// IntSetter sends int message
+func (p *Socket) IntSetter(param string) func(int64) error {
+	return func(val int64) error {
+		return p.set(param, val)
+	}
+}
+
+// FloatSetter sends float message
+func (p *Socket) FloatSetter(param string) func(float64) error {
+	return func(val float64) error {
+		return p.set(param, val)
+	}
+}
+
+// StringSetter sends string message
+func (p *Socket) StringSetter(param string) func(string) error {
+	return func(val string) error {
+		return p.set(param, val)
+	}
+}
+
+// BoolSetter sends bool message
+func (p *Socket) BoolSetter(param string) func(bool) error {
+	return func(val bool) error {
+		return p.set(param, val)
+	}
+}
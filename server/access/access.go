@@ -0,0 +1,349 @@
+// Package access implements authentication and role-based authorization for the http server and mqtt api
+package access
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evcc-io/evcc/util"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	sessionCookie   = "evcc_session"
+	sessionLifetime = 24 * time.Hour
+)
+
+// public paths are accessible without authentication
+var public = []string{
+	"/health", // container health checks
+	"/semp",   // SEMP energy managers can't authenticate
+}
+
+// admin paths require admin role for all methods
+var admin = []string{
+	"/api/update",
+	"/api/v2/config",
+	"/debug",
+}
+
+// driverRoutes are the write routes permitted to drivers
+var driverRoutes = regexp.MustCompile(`^/api/(loadpoints/\d+/(mode|targetsoc)/[^/]+|v2/loadpoints/\d+)$`)
+
+// User is a local user account. The password should be a bcrypt hash.
+type User struct {
+	Name, Password, Role string
+}
+
+// Token is a named API token
+type Token struct {
+	Name, Token, Role string
+}
+
+// Config is the access configuration
+type Config struct {
+	Users   []User
+	Tokens  []Token
+	MQTT    string   // role granted to mqtt setters
+	Origins []string // origins permitted to send cross-origin api requests
+}
+
+type credential struct {
+	name   string
+	secret string
+	role   Role
+}
+
+// Access authenticates requests and enforces roles
+type Access struct {
+	log     *util.Logger
+	users   map[string]credential
+	tokens  []credential
+	mqtt    Role
+	origins []string
+	secret  []byte
+}
+
+type roleKey struct{}
+
+// New creates access control from config. Access control is disabled if neither users nor tokens are configured.
+func New(conf Config) (*Access, error) {
+	a := &Access{
+		log:     util.NewLogger("access"),
+		users:   make(map[string]credential),
+		mqtt:    RoleAdmin,
+		origins: conf.Origins,
+	}
+
+	for _, u := range conf.Users {
+		role, err := RoleString(u.Role)
+		if err != nil {
+			return nil, fmt.Errorf("user %s: %w", u.Name, err)
+		}
+
+		if u.Name == "" || u.Password == "" {
+			return nil, errors.New("user requires name and password")
+		}
+
+		if _, ok := a.users[u.Name]; ok {
+			return nil, fmt.Errorf("duplicate user: %s", u.Name)
+		}
+
+		if !isHash(u.Password) {
+			a.log.WARN.Printf("user %s: password is not hashed", u.Name)
+		}
+
+		a.users[u.Name] = credential{name: u.Name, secret: u.Password, role: role}
+	}
+
+	for _, t := range conf.Tokens {
+		role, err := RoleString(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("token %s: %w", t.Name, err)
+		}
+
+		if t.Token == "" {
+			return nil, fmt.Errorf("token %s: missing token", t.Name)
+		}
+
+		a.tokens = append(a.tokens, credential{name: t.Name, secret: t.Token, role: role})
+	}
+
+	if !a.Enabled() {
+		return a, nil
+	}
+
+	// mqtt setters are disabled by default once access control is enabled
+	a.mqtt = RoleViewer
+	if conf.MQTT != "" {
+		role, err := RoleString(conf.MQTT)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt: %w", err)
+		}
+		a.mqtt = role
+	}
+
+	a.secret = make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, a.secret); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Enabled returns true if access control is enabled
+func (a *Access) Enabled() bool {
+	return len(a.users) > 0 || len(a.tokens) > 0
+}
+
+// MQTTRole returns the role granted to mqtt setters
+func (a *Access) MQTTRole() Role {
+	return a.mqtt
+}
+
+// Permitted returns true if the request context's role includes role.
+// Requests that have not passed access control, i.e. with access control disabled, are permitted.
+func Permitted(ctx context.Context, role Role) bool {
+	r, ok := ctx.Value(roleKey{}).(Role)
+	return !ok || r >= role
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if path == p || strings.HasPrefix(path, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// required returns the role required for the request
+func required(r *http.Request) Role {
+	switch {
+	case hasPrefix(r.URL.Path, public):
+		return RoleNone
+	case hasPrefix(r.URL.Path, admin):
+		return RoleAdmin
+	}
+
+	switch r.Method {
+	case http.MethodOptions:
+		// CORS preflight requests don't carry credentials
+		return RoleNone
+	case http.MethodGet, http.MethodHead:
+		return RoleViewer
+	}
+
+	if driverRoutes.MatchString(r.URL.Path) {
+		return RoleDriver
+	}
+
+	return RoleAdmin
+}
+
+// crossOrigin returns true if the request originates from a foreign web page not among the permitted origins
+func (a *Access) crossOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	for _, o := range a.origins {
+		if strings.EqualFold(o, origin) {
+			return false
+		}
+	}
+
+	u, err := url.Parse(origin)
+	return err != nil || !strings.EqualFold(u.Host, r.Host)
+}
+
+// Handler is the middleware enforcing access control
+func (a *Access) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := required(r)
+		if !a.Enabled() || req == RoleNone {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		// protect cookie and basic auth sessions against cross-site requests
+		unsafe := r.Method != http.MethodGet && r.Method != http.MethodHead
+		upgrade := strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+		if (unsafe || upgrade) && a.crossOrigin(r) {
+			a.deny(w, http.StatusForbidden, "cross-origin request")
+			return
+		}
+
+		name, role := a.authenticate(w, r)
+
+		if role == RoleNone {
+			w.Header().Set("WWW-Authenticate", `Basic realm="evcc", charset="UTF-8"`)
+			a.deny(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		if role < req {
+			a.log.DEBUG.Printf("%s (%s): forbidden: %s %s", name, role, r.Method, r.URL.Path)
+			a.deny(w, http.StatusForbidden, "forbidden")
+			return
+		}
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+func (a *Access) deny(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": msg})
+}
+
+// authenticate resolves the request's session cookie, bearer token or basic auth credentials.
+// Successful basic auth starts a session to avoid password hashing on every request.
+func (a *Access) authenticate(w http.ResponseWriter, r *http.Request) (string, Role) {
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		if name, role, err := a.verifySession(c.Value); err == nil {
+			return name, role
+		}
+	}
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		for _, t := range a.tokens {
+			if subtle.ConstantTimeCompare([]byte(token), []byte(t.secret)) == 1 {
+				return t.name, t.role
+			}
+		}
+
+		a.log.WARN.Printf("invalid token from %s", r.RemoteAddr)
+		return "", RoleNone
+	}
+
+	if name, password, ok := r.BasicAuth(); ok {
+		if u, ok := a.users[name]; ok && checkPassword(u.secret, password) {
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionCookie,
+				Value:    a.session(u.name, u.role, time.Now().Add(sessionLifetime)),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteStrictMode,
+			})
+
+			return u.name, u.role
+		}
+
+		a.log.WARN.Printf("invalid login for %s from %s", name, r.RemoteAddr)
+	}
+
+	return "", RoleNone
+}
+
+func isHash(password string) bool {
+	_, err := bcrypt.Cost([]byte(password))
+	return err == nil
+}
+
+func checkPassword(secret, password string) bool {
+	if isHash(secret) {
+		return bcrypt.CompareHashAndPassword([]byte(secret), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(password)) == 1
+}
+
+func (a *Access) sign(payload string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// session creates a signed session value
+func (a *Access) session(name string, role Role, expiry time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d:%s", expiry.Unix(), role, name)))
+	return payload + "." + a.sign(payload)
+}
+
+// verifySession validates a signed session value
+func (a *Access) verifySession(value string) (string, Role, error) {
+	segs := strings.SplitN(value, ".", 2)
+	if len(segs) != 2 || !hmac.Equal([]byte(segs[1]), []byte(a.sign(segs[0]))) {
+		return "", RoleNone, errors.New("invalid session")
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(segs[0])
+	if err != nil {
+		return "", RoleNone, err
+	}
+
+	fields := strings.SplitN(string(b), ":", 3)
+	if len(fields) != 3 {
+		return "", RoleNone, errors.New("invalid session")
+	}
+
+	expiry, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil || time.Now().Unix() > expiry {
+		return "", RoleNone, errors.New("session expired")
+	}
+
+	role, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return "", RoleNone, err
+	}
+
+	return fields[2], Role(role), nil
+}
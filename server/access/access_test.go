@@ -0,0 +1,144 @@
+package access
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequired(t *testing.T) {
+	tc := []struct {
+		method, path string
+		role         Role
+	}{
+		{http.MethodGet, "/", RoleViewer},
+		{http.MethodGet, "/ws", RoleViewer},
+		{http.MethodGet, "/health", RoleNone},
+		{http.MethodPost, "/semp/", RoleNone},
+		{http.MethodOptions, "/api/loadpoints/0/minsoc/20", RoleNone},
+		{http.MethodPost, "/api/loadpoints/0/mode/pv", RoleDriver},
+		{http.MethodPost, "/api/loadpoints/1/targetsoc/80", RoleDriver},
+		{http.MethodPut, "/api/v2/loadpoints/1", RoleDriver},
+		{http.MethodPost, "/api/loadpoints/0/minsoc/20", RoleAdmin},
+		{http.MethodPut, "/api/v2/site", RoleAdmin},
+		{http.MethodGet, "/api/update", RoleAdmin},
+		{http.MethodGet, "/api/v2/config/meters", RoleAdmin},
+	}
+
+	for _, tc := range tc {
+		if role := required(httptest.NewRequest(tc.method, tc.path, nil)); role != tc.role {
+			t.Errorf("%s %s: expected %s, got %s", tc.method, tc.path, tc.role, role)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := New(Config{
+		Users: []User{{Name: "admin", Password: string(hash), Role: "admin"}},
+		Tokens: []Token{
+			{Name: "dashboard", Token: "view", Role: "viewer"},
+			{Name: "tenant", Token: "drive", Role: "driver"},
+		},
+		Origins: []string{"https://dashboard.example"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.MQTTRole() != RoleViewer {
+		t.Errorf("expected mqtt role viewer, got %s", a.MQTTRole())
+	}
+
+	h := a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(req *http.Request, status int) *httptest.ResponseRecorder {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != status {
+			t.Errorf("%s %s: expected status %d, got %d", req.Method, req.URL.Path, status, w.Code)
+		}
+
+		return w
+	}
+
+	bearer := func(method, path, token string) *http.Request {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return req
+	}
+
+	if w := serve(httptest.NewRequest(http.MethodGet, "/", nil), http.StatusUnauthorized); w.Header().Get("WWW-Authenticate") == "" {
+		t.Error("expected basic auth challenge")
+	}
+
+	serve(httptest.NewRequest(http.MethodGet, "/health", nil), http.StatusOK)
+	serve(bearer(http.MethodGet, "/api/state", "invalid"), http.StatusUnauthorized)
+	serve(bearer(http.MethodGet, "/api/state", "view"), http.StatusOK)
+	serve(bearer(http.MethodPost, "/api/loadpoints/0/mode/pv", "view"), http.StatusForbidden)
+	serve(bearer(http.MethodPost, "/api/loadpoints/0/mode/pv", "drive"), http.StatusOK)
+	serve(bearer(http.MethodPost, "/api/loadpoints/0/minsoc/20", "drive"), http.StatusForbidden)
+
+	// basic auth starts a session
+	req := httptest.NewRequest(http.MethodPost, "/api/loadpoints/0/minsoc/20", nil)
+	req.SetBasicAuth("admin", "wrong")
+	serve(req, http.StatusUnauthorized)
+
+	req.SetBasicAuth("admin", "secret")
+	cookies := serve(req, http.StatusOK).Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected session cookie, got %v", cookies)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/ws", nil)
+	req.AddCookie(cookies[0])
+	serve(req, http.StatusOK)
+
+	// cross-site requests are rejected
+	req.Header.Set("Origin", "http://tenant.example")
+	req.Header.Set("Upgrade", "websocket")
+	serve(req, http.StatusForbidden)
+
+	req = bearer(http.MethodPost, "/api/loadpoints/0/mode/pv", "drive")
+	req.Header.Set("Origin", "http://"+req.Host)
+	serve(req, http.StatusOK)
+
+	// unless permitted
+	req.Header.Set("Origin", "https://dashboard.example")
+	serve(req, http.StatusOK)
+}
+
+func TestDisabled(t *testing.T) {
+	a, err := New(Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Enabled() || a.MQTTRole() != RoleAdmin {
+		t.Errorf("expected access control disabled, got mqtt role %s", a.MQTTRole())
+	}
+
+	w := httptest.NewRecorder()
+	a.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !Permitted(r.Context(), RoleAdmin) {
+			t.Error("expected admin permission")
+		}
+	})).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/savings/reset", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", w.Code)
+	}
+
+	if _, err := New(Config{Tokens: []Token{{Name: "foo", Token: "bar", Role: "root"}}}); err == nil {
+		t.Error("expected invalid role error")
+	}
+}
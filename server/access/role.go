@@ -0,0 +1,43 @@
+package access
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Role is the access level granted to users, tokens and the mqtt api
+type Role int
+
+// Roles in ascending order of privilege
+const (
+	RoleNone   Role = iota // no access
+	RoleViewer             // read-only access
+	RoleDriver             // may additionally change loadpoint mode and target soc
+	RoleAdmin              // full access
+)
+
+var roles = map[Role]string{
+	RoleNone:   "none",
+	RoleViewer: "viewer",
+	RoleDriver: "driver",
+	RoleAdmin:  "admin",
+}
+
+// String implements the Stringer interface
+func (r Role) String() string {
+	if s, ok := roles[r]; ok {
+		return s
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// RoleString converts string to Role
+func RoleString(role string) (Role, error) {
+	for r, s := range roles {
+		if strings.EqualFold(role, s) {
+			return r, nil
+		}
+	}
+
+	return RoleNone, fmt.Errorf("invalid role: %s", role)
+}
@@ -0,0 +1,457 @@
+// Package apiv2 implements the versioned JSON api with typed site, loadpoint, device and tariff resources
+package apiv2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/configstore"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/site"
+	"github.com/evcc-io/evcc/server/access"
+	"github.com/evcc-io/evcc/util"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// Prefix is the api's path prefix
+const Prefix = "/api/v2"
+
+var log = util.NewLogger("httpd")
+
+// Devices provides the configured devices by name
+type Devices interface {
+	Meters() map[string]api.Meter
+	Chargers() map[string]api.Charger
+	Vehicles() map[string]api.Vehicle
+}
+
+// endpoint is an api route including its OpenAPI description
+type endpoint struct {
+	Method   string
+	Path     string
+	Summary  string
+	Request  interface{} // request body prototype
+	Response interface{} // response body prototype
+	Handler  http.HandlerFunc
+}
+
+type handler struct {
+	site    site.API
+	cache   *util.Cache
+	devices Devices
+	conf    Configurator
+}
+
+// Register mounts the api on the router. The runtime configuration resources and device updates are only available if conf is not nil.
+// Cross-origin requests are only permitted from the given origins.
+func Register(router *mux.Router, site site.API, cache *util.Cache, devices Devices, conf Configurator, origins []string) {
+	h := &handler{
+		site:    site,
+		cache:   cache,
+		devices: devices,
+		conf:    conf,
+	}
+
+	endpoints := h.endpoints()
+	if conf != nil {
+		endpoints = append(endpoints, h.deviceEndpoints()...)
+		endpoints = append(endpoints, h.configEndpoints()...)
+	}
+	spec := openAPI(endpoints)
+
+	r := router.PathPrefix(Prefix).Subrouter()
+	r.Use(handlers.CompressHandler)
+	if len(origins) > 0 {
+		r.Use(handlers.CORS(
+			handlers.AllowedOrigins(origins),
+			handlers.AllowedHeaders([]string{"Authorization", "Content-Type"}),
+			handlers.AllowedMethods([]string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodOptions}),
+		))
+	}
+
+	r.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, http.StatusNotFound, errors.New("not found"))
+	})
+	r.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+	})
+
+	for _, e := range endpoints {
+		methods := []string{e.Method}
+		if e.Method != http.MethodGet {
+			methods = append(methods, http.MethodOptions)
+		}
+		r.Methods(methods...).Path(e.Path).Handler(e.Handler)
+	}
+
+	r.Methods(http.MethodGet).Path("/openapi.json").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jsonWrite(w, http.StatusOK, spec)
+	})
+}
+
+func (h *handler) endpoints() []endpoint {
+	return []endpoint{
+		{http.MethodGet, "/site", "Get site", nil, Site{}, h.getSite},
+		{http.MethodPut, "/site", "Update site settings", SiteUpdate{}, Site{}, h.putSite},
+		{http.MethodGet, "/loadpoints", "List loadpoints", nil, []Loadpoint{}, h.getLoadpoints},
+		{http.MethodGet, "/loadpoints/{id}", "Get loadpoint", nil, Loadpoint{}, h.getLoadpoint},
+		{http.MethodPut, "/loadpoints/{id}", "Update loadpoint settings", LoadpointUpdate{}, Loadpoint{}, h.putLoadpoint},
+		{http.MethodGet, "/vehicles", "List vehicles", nil, []Vehicle{}, h.getVehicles},
+		{http.MethodGet, "/vehicles/{name}", "Get vehicle", nil, Vehicle{}, h.getVehicle},
+		{http.MethodGet, "/chargers", "List chargers", nil, []Charger{}, h.getChargers},
+		{http.MethodGet, "/chargers/{name}", "Get charger", nil, Charger{}, h.getCharger},
+		{http.MethodGet, "/meters", "List meters", nil, []Meter{}, h.getMeters},
+		{http.MethodGet, "/meters/{name}", "Get meter", nil, Meter{}, h.getMeter},
+		{http.MethodGet, "/tariffs", "Get tariffs", nil, Tariffs{}, h.getTariffs},
+		{http.MethodPut, "/tariffs", "Update fixed tariff prices until restart", TariffsUpdate{}, Tariffs{}, h.putTariffs},
+	}
+}
+
+// deviceEndpoints returns the endpoints replacing the running devices' configuration
+func (h *handler) deviceEndpoints() []endpoint {
+	return []endpoint{
+		{http.MethodPut, "/vehicles/{name}", "Replace vehicle configuration", DeviceConfig{}, Vehicle{}, h.putDevice(configstore.Vehicle, h.getVehicle)},
+		{http.MethodPut, "/chargers/{name}", "Replace charger configuration", DeviceConfig{}, Charger{}, h.putDevice(configstore.Charger, h.getCharger)},
+		{http.MethodPut, "/meters/{name}", "Replace meter configuration", DeviceConfig{}, Meter{}, h.putDevice(configstore.Meter, h.getMeter)},
+	}
+}
+
+// Error is the api's error object
+type Error struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func jsonWrite(w http.ResponseWriter, status int, content interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(content); err != nil {
+		log.ERROR.Printf("httpd: failed to encode JSON: %v", err)
+	}
+}
+
+func jsonError(w http.ResponseWriter, status int, err error) {
+	jsonWrite(w, status, struct {
+		Error Error `json:"error"`
+	}{
+		Error: Error{Status: status, Message: err.Error()},
+	})
+}
+
+// decode decodes the request body rejecting unknown fields
+func decode(r *http.Request, res interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(res); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+
+	return nil
+}
+
+// cached returns the cached values of the site or the given loadpoint
+func (h *handler) cached(lp *int) map[string]interface{} {
+	res := make(map[string]interface{})
+
+	for _, p := range h.cache.All() {
+		if lp == nil && p.LoadPoint == nil || lp != nil && p.LoadPoint != nil && *p.LoadPoint == *lp {
+			res[p.Key] = p.Val
+		}
+	}
+
+	return res
+}
+
+// fromCache decodes the cached values into the resource's matching fields
+func fromCache(vals map[string]interface{}, res interface{}) error {
+	b, err := json.Marshal(vals)
+	if err == nil {
+		err = json.Unmarshal(b, res)
+	}
+	return err
+}
+
+// deviceValues returns the values read from the device during the last update cycle
+func (h *handler) deviceValues(class configstore.Class, name string) map[string]interface{} {
+	id := string(class) + "/" + name
+	res := make(map[string]interface{})
+
+	for _, p := range h.cache.All() {
+		if vals, ok := p.Val.(map[string]map[string]interface{}); ok && p.Key == "devices" {
+			for k, v := range vals[id] {
+				res[k] = v
+			}
+		}
+	}
+
+	return res
+}
+
+// vehicleValues returns the vehicle's values as last read by the loadpoint using the vehicle
+func (h *handler) vehicleValues(title string) map[string]interface{} {
+	for id := range h.site.LoadPoints() {
+		if vals := h.cached(&id); title != "" && vals["vehicleTitle"] == title {
+			return map[string]interface{}{
+				"soc":      vals["vehicleSoC"],
+				"range":    vals["vehicleRange"],
+				"odometer": vals["vehicleOdometer"],
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadpoint resolves the loadpoint from the 1-based id path parameter
+func (h *handler) loadpoint(r *http.Request) (int, loadpoint.API, error) {
+	lps := h.site.LoadPoints()
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 || id > len(lps) {
+		return 0, nil, fmt.Errorf("loadpoint not found: %s", mux.Vars(r)["id"])
+	}
+
+	return id - 1, lps[id-1], nil
+}
+
+// sortedNames sorts the device names alphabetically
+func sortedNames(names []string) []string {
+	sort.Strings(names)
+	return names
+}
+
+func (h *handler) getSite(w http.ResponseWriter, r *http.Request) {
+	res, err := h.siteResource()
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) putSite(w http.ResponseWriter, r *http.Request) {
+	var req SiteUpdate
+	if err := decode(r, &req); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if req.PrioritySoC != nil {
+		if *req.PrioritySoC < 0 || *req.PrioritySoC > 100 {
+			jsonError(w, http.StatusUnprocessableEntity, fmt.Errorf("invalid prioritySoC: %g", *req.PrioritySoC))
+			return
+		}
+
+		if err := h.site.SetPrioritySoC(*req.PrioritySoC); err != nil {
+			jsonError(w, http.StatusConflict, err)
+			return
+		}
+	}
+
+	h.getSite(w, r)
+}
+
+func (h *handler) getLoadpoints(w http.ResponseWriter, r *http.Request) {
+	res := make([]Loadpoint, 0)
+
+	for id, lp := range h.site.LoadPoints() {
+		lpr, err := h.loadpointResource(id, lp)
+		if err != nil {
+			jsonError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		res = append(res, lpr)
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) getLoadpoint(w http.ResponseWriter, r *http.Request) {
+	id, lp, err := h.loadpoint(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, err)
+		return
+	}
+
+	res, err := h.loadpointResource(id, lp)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) putLoadpoint(w http.ResponseWriter, r *http.Request) {
+	_, lp, err := h.loadpoint(r)
+	if err != nil {
+		jsonError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var req LoadpointUpdate
+	if err := decode(r, &req); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if !access.Permitted(r.Context(), access.RoleAdmin) && !req.driverOnly() {
+		jsonError(w, http.StatusForbidden, errors.New("drivers may only change mode and targetSoC"))
+		return
+	}
+
+	// validate all settings before applying any
+	if err := req.validate(lp); err != nil {
+		jsonError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	if err := req.apply(lp); err != nil {
+		jsonError(w, http.StatusConflict, err)
+		return
+	}
+
+	h.getLoadpoint(w, r)
+}
+
+func (h *handler) getVehicles(w http.ResponseWriter, r *http.Request) {
+	vehicles := h.devices.Vehicles()
+
+	names := make([]string, 0, len(vehicles))
+	for name := range vehicles {
+		names = append(names, name)
+	}
+
+	res := make([]Vehicle, 0, len(names))
+	for _, name := range sortedNames(names) {
+		res = append(res, h.vehicleResource(name, vehicles[name]))
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) getVehicle(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	vehicle, ok := h.devices.Vehicles()[name]
+	if !ok {
+		jsonError(w, http.StatusNotFound, fmt.Errorf("vehicle not found: %s", name))
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, h.vehicleResource(name, vehicle))
+}
+
+func (h *handler) getChargers(w http.ResponseWriter, r *http.Request) {
+	chargers := h.devices.Chargers()
+
+	names := make([]string, 0, len(chargers))
+	for name := range chargers {
+		names = append(names, name)
+	}
+
+	res := make([]Charger, 0, len(names))
+	for _, name := range sortedNames(names) {
+		res = append(res, h.chargerResource(name))
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) getCharger(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if _, ok := h.devices.Chargers()[name]; !ok {
+		jsonError(w, http.StatusNotFound, fmt.Errorf("charger not found: %s", name))
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, h.chargerResource(name))
+}
+
+func (h *handler) getMeters(w http.ResponseWriter, r *http.Request) {
+	meters := h.devices.Meters()
+
+	names := make([]string, 0, len(meters))
+	for name := range meters {
+		names = append(names, name)
+	}
+
+	res := make([]Meter, 0, len(names))
+	for _, name := range sortedNames(names) {
+		res = append(res, h.meterResource(name))
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) getMeter(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+
+	if _, ok := h.devices.Meters()[name]; !ok {
+		jsonError(w, http.StatusNotFound, fmt.Errorf("meter not found: %s", name))
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, h.meterResource(name))
+}
+
+// putDevice replaces the device's configuration and responds with the device resource
+func (h *handler) putDevice(class configstore.Class, get http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req configstore.Device
+		if err := decode(r, &req); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.conf.UpdateDevice(class, mux.Vars(r)["name"], req); err != nil {
+			configError(w, err)
+			return
+		}
+
+		get(w, r)
+	}
+}
+
+func (h *handler) getTariffs(w http.ResponseWriter, r *http.Request) {
+	jsonWrite(w, http.StatusOK, tariffsResource(h.site.GetTariffs()))
+}
+
+func (h *handler) putTariffs(w http.ResponseWriter, r *http.Request) {
+	var req TariffsUpdate
+	if err := decode(r, &req); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	tariffs := h.site.GetTariffs()
+
+	// validate all prices before applying any
+	grid, err := priceUpdate("grid", tariffs.Grid, req.Grid)
+	if err != nil {
+		jsonError(w, http.StatusConflict, err)
+		return
+	}
+
+	feedIn, err := priceUpdate("feedIn", tariffs.FeedIn, req.FeedIn)
+	if err != nil {
+		jsonError(w, http.StatusConflict, err)
+		return
+	}
+
+	grid()
+	feedIn()
+
+	h.getTariffs(w, r)
+}
@@ -0,0 +1,413 @@
+package apiv2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/configstore"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/site"
+	"github.com/evcc-io/evcc/server/access"
+	"github.com/evcc-io/evcc/tariff"
+	"github.com/evcc-io/evcc/util"
+	"github.com/gorilla/mux"
+	"golang.org/x/text/currency"
+)
+
+type testSite struct {
+	site.API
+	lps     []loadpoint.API
+	tariffs tariff.Tariffs
+}
+
+func (s *testSite) LoadPoints() []loadpoint.API { return s.lps }
+func (s *testSite) GetTariffs() tariff.Tariffs  { return s.tariffs }
+
+type testLoadpoint struct {
+	loadpoint.API
+	mode       api.ChargeMode
+	minCurrent float64
+	maxCurrent float64
+	targetSoC  int
+	targetTime time.Time
+	vehicle    string
+}
+
+func (lp *testLoadpoint) Name() string                        { return "Garage" }
+func (lp *testLoadpoint) GetStatus() api.ChargeStatus         { return api.StatusB }
+func (lp *testLoadpoint) GetMode() api.ChargeMode             { return lp.mode }
+func (lp *testLoadpoint) SetMode(mode api.ChargeMode)         { lp.mode = mode }
+func (lp *testLoadpoint) GetPriority() int                    { return 0 }
+func (lp *testLoadpoint) GetPhases() int                      { return 3 }
+func (lp *testLoadpoint) GetMinCurrent() float64              { return lp.minCurrent }
+func (lp *testLoadpoint) SetMinCurrent(current float64)       { lp.minCurrent = current }
+func (lp *testLoadpoint) GetMaxCurrent() float64              { return lp.maxCurrent }
+func (lp *testLoadpoint) SetMaxCurrent(current float64)       { lp.maxCurrent = current }
+func (lp *testLoadpoint) GetMinSoC() int                      { return 0 }
+func (lp *testLoadpoint) GetTargetSoC() int                   { return lp.targetSoC }
+func (lp *testLoadpoint) SetTargetSoC(soc int)                { lp.targetSoC = soc }
+func (lp *testLoadpoint) GetTargetTime() time.Time            { return lp.targetTime }
+func (lp *testLoadpoint) GetPlans() loadpoint.Plans           { return nil }
+func (lp *testLoadpoint) GetChargePower() float64             { return 0 }
+func (lp *testLoadpoint) GetChargedEnergy() float64           { return 0 }
+func (lp *testLoadpoint) GetRemainingDuration() time.Duration { return 0 }
+func (lp *testLoadpoint) GetRemainingEnergy() float64         { return 0 }
+
+func (lp *testLoadpoint) HasVehicle(name string) bool { return name == "e-Golf" }
+
+func (lp *testLoadpoint) SetVehicle(name string) error {
+	if !lp.HasVehicle(name) {
+		return errors.New("unknown vehicle")
+	}
+	lp.vehicle = name
+	return nil
+}
+
+func (lp *testLoadpoint) SetPhases(phases int) error {
+	if phases != lp.GetPhases() {
+		return errors.New("switch phases: not available")
+	}
+	return nil
+}
+
+func (lp *testLoadpoint) SetTargetCharge(finishAt time.Time, soc int) {
+	lp.targetTime, lp.targetSoC = finishAt, soc
+}
+
+func testRouter(lp loadpoint.API) *mux.Router {
+	id := 0
+	cache := util.NewCache()
+	cache.Add("vehicleTitle", util.Param{LoadPoint: &id, Key: "vehicleTitle", Val: "e-Golf"})
+
+	router := mux.NewRouter()
+	Register(router, &testSite{lps: []loadpoint.API{lp}}, cache, nil, nil, nil)
+
+	return router
+}
+
+func request(t *testing.T, router *mux.Router, method, path, body string, status int, res interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, Prefix+path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != status {
+		t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, status, w.Code, w.Body.String())
+	}
+
+	if res != nil {
+		if err := json.NewDecoder(w.Body).Decode(res); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadpoint(t *testing.T) {
+	lp := &testLoadpoint{mode: api.ModeOff, minCurrent: 6, maxCurrent: 16}
+	router := testRouter(lp)
+
+	var res Loadpoint
+	request(t, router, http.MethodGet, "/loadpoints/1", "", http.StatusOK, &res)
+	if res.ID != 1 || res.Mode != api.ModeOff || res.VehicleTitle != "e-Golf" || res.TargetTime != nil {
+		t.Errorf("unexpected loadpoint: %+v", res)
+	}
+
+	request(t, router, http.MethodPut, "/loadpoints/1",
+		`{"mode":"pv","maxCurrent":32,"targetCharge":{"soc":80,"time":"2022-04-01T07:00:00Z"}}`,
+		http.StatusOK, &res)
+	if res.Mode != api.ModePV || res.MaxCurrent != 32 || res.TargetSoC != 80 || res.TargetTime == nil {
+		t.Errorf("unexpected loadpoint: %+v", res)
+	}
+
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"targetCharge":{"time":null}}`, http.StatusOK, &res)
+	if res.TargetTime != nil {
+		t.Errorf("expected target charge removed, got %v", res.TargetTime)
+	}
+
+	// invalid settings are rejected as a whole
+	var e struct {
+		Error Error `json:"error"`
+	}
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"mode":"off","minCurrent":40}`, http.StatusUnprocessableEntity, &e)
+	if e.Error.Status != http.StatusUnprocessableEntity || e.Error.Message == "" || lp.mode != api.ModePV {
+		t.Errorf("unexpected error: %+v, mode %s", e, lp.mode)
+	}
+
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"foo":1}`, http.StatusBadRequest, nil)
+	request(t, router, http.MethodGet, "/loadpoints/2", "", http.StatusNotFound, nil)
+	request(t, router, http.MethodDelete, "/loadpoints/1", "", http.StatusMethodNotAllowed, nil)
+	request(t, router, http.MethodGet, "/foo", "", http.StatusNotFound, nil)
+}
+
+func TestLoadpointVehicle(t *testing.T) {
+	lp := &testLoadpoint{minCurrent: 6, maxCurrent: 16}
+	router := testRouter(lp)
+
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"vehicle":"e-Golf"}`, http.StatusOK, nil)
+	if lp.vehicle != "e-Golf" {
+		t.Errorf("expected vehicle selected, got %q", lp.vehicle)
+	}
+
+	// unknown vehicles and failing phase switches don't apply other settings
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"mode":"now","vehicle":"foo"}`, http.StatusUnprocessableEntity, nil)
+	request(t, router, http.MethodPut, "/loadpoints/1", `{"mode":"now","phases":1,"vehicle":"e-Golf"}`, http.StatusConflict, nil)
+	if lp.mode != "" {
+		t.Errorf("expected mode unchanged, got %s", lp.mode)
+	}
+}
+
+func TestLoadpointDriver(t *testing.T) {
+	acc, err := access.New(access.Config{
+		Tokens: []access.Token{{Name: "tenant", Token: "drive", Role: "driver"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := testRouter(&testLoadpoint{minCurrent: 6, maxCurrent: 16})
+	router.Use(acc.Handler)
+
+	for _, tc := range []struct {
+		body   string
+		status int
+	}{
+		{`{"mode":"now","targetSoC":80}`, http.StatusOK},
+		{`{"vehicle":"e-Golf"}`, http.StatusForbidden},
+		{`{"maxCurrent":32}`, http.StatusForbidden},
+	} {
+		req := httptest.NewRequest(http.MethodPut, Prefix+"/loadpoints/1", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer drive")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.body, tc.status, w.Code)
+		}
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	router := mux.NewRouter()
+	Register(router, &testSite{lps: []loadpoint.API{&testLoadpoint{}}}, util.NewCache(), nil, &testConfigurator{}, nil)
+
+	var res struct {
+		Paths      map[string]map[string]interface{}
+		Components struct {
+			Schemas map[string]interface{}
+		}
+	}
+	request(t, router, http.MethodGet, "/openapi.json", "", http.StatusOK, &res)
+
+	endpoints := append(new(handler).endpoints(), new(handler).deviceEndpoints()...)
+	for _, e := range append(endpoints, new(handler).configEndpoints()...) {
+		if _, ok := res.Paths[e.Path][strings.ToLower(e.Method)]; !ok {
+			t.Errorf("missing %s %s", e.Method, e.Path)
+		}
+	}
+
+	for _, name := range []string{"Site", "Loadpoint", "LoadpointUpdate", "Plan", "Meter", "Tariffs", "ConfigStatus", "LoadpointConfig", "Error"} {
+		if _, ok := res.Components.Schemas[name]; !ok {
+			t.Errorf("missing schema %s", name)
+		}
+	}
+}
+
+type testConfigurator struct {
+	Configurator
+	meters  []configstore.Device
+	lps     []map[string]interface{}
+	restart bool
+}
+
+func (c *testConfigurator) RestartRequired() bool { return c.restart }
+
+func (c *testConfigurator) Devices(class configstore.Class) ([]configstore.Device, error) {
+	return c.meters, nil
+}
+
+func (c *testConfigurator) CreateDevice(class configstore.Class, dev configstore.Device) error {
+	for _, d := range c.meters {
+		if d.Name == dev.Name {
+			return configstore.ErrExists
+		}
+	}
+	c.meters = append(c.meters, dev)
+	return nil
+}
+
+func (c *testConfigurator) UpdateDevice(class configstore.Class, name string, dev configstore.Device) error {
+	for i, d := range c.meters {
+		if d.Name == name {
+			c.meters[i] = dev
+			return nil
+		}
+	}
+	return configstore.ErrNotFound
+}
+
+func (c *testConfigurator) DeleteDevice(class configstore.Class, name string) error {
+	return fmt.Errorf("%s: %w", name, configstore.ErrInUse)
+}
+
+func (c *testConfigurator) LoadPoint(id int) (map[string]interface{}, error) {
+	if id >= len(c.lps) {
+		return nil, configstore.ErrNotFound
+	}
+	return c.lps[id], nil
+}
+
+func (c *testConfigurator) CreateLoadPoint(conf map[string]interface{}) (int, error) {
+	return 0, configstore.ErrNotSupported
+}
+
+func TestConfig(t *testing.T) {
+	conf := &testConfigurator{lps: []map[string]interface{}{{"charger": "wallbox"}}}
+
+	router := mux.NewRouter()
+	Register(router, &testSite{}, util.NewCache(), nil, conf, nil)
+
+	request(t, router, http.MethodPost, "/config/meters", `{"name":"grid","type":"custom","power":{"source":"mqtt"}}`, http.StatusOK, nil)
+	request(t, router, http.MethodPost, "/config/meters", `{"name":"grid","type":"custom"}`, http.StatusConflict, nil)
+	request(t, router, http.MethodDelete, "/config/meters/grid", "", http.StatusConflict, nil)
+
+	var meters []map[string]interface{}
+	request(t, router, http.MethodGet, "/config/meters", "", http.StatusOK, &meters)
+	if len(meters) != 1 || meters[0]["name"] != "grid" || meters[0]["power"] == nil {
+		t.Errorf("unexpected meters: %v", meters)
+	}
+
+	request(t, router, http.MethodPost, "/config/loadpoints", `{"charger":"wallbox"}`, http.StatusNotImplemented, nil)
+
+	request(t, router, http.MethodGet, "/config/loadpoints/2", "", http.StatusNotFound, nil)
+	request(t, router, http.MethodGet, "/config/loadpoints/0", "", http.StatusNotFound, nil)
+}
+
+type testDevices struct {
+	Devices
+	vehicles map[string]api.Vehicle
+}
+
+func (d *testDevices) Meters() map[string]api.Meter { return map[string]api.Meter{"grid": nil} }
+func (d *testDevices) Chargers() map[string]api.Charger {
+	return map[string]api.Charger{"wallbox": nil}
+}
+func (d *testDevices) Vehicles() map[string]api.Vehicle { return d.vehicles }
+
+type testVehicle struct {
+	api.Vehicle
+}
+
+func (v *testVehicle) Title() string   { return "e-Golf" }
+func (v *testVehicle) Capacity() int64 { return 36 }
+
+func TestDevices(t *testing.T) {
+	id := 0
+	cache := util.NewCache()
+	cache.Add("devices", util.Param{Key: "devices", Val: map[string]map[string]interface{}{
+		"meter/grid": {"power": 1000.0, "currents": []float64{1, 2, 3}},
+	}})
+	cache.Add("lp.devices", util.Param{LoadPoint: &id, Key: "devices", Val: map[string]map[string]interface{}{
+		"charger/wallbox": {"status": api.StatusC, "enabled": true, "power": 11000.0},
+	}})
+	cache.Add("vehicleTitle", util.Param{LoadPoint: &id, Key: "vehicleTitle", Val: "e-Golf"})
+	cache.Add("vehicleSoC", util.Param{LoadPoint: &id, Key: "vehicleSoC", Val: 55.0})
+
+	router := mux.NewRouter()
+	devices := &testDevices{vehicles: map[string]api.Vehicle{"egolf": new(testVehicle)}}
+	Register(router, &testSite{lps: []loadpoint.API{new(testLoadpoint)}}, cache, devices, nil, nil)
+
+	var meter Meter
+	request(t, router, http.MethodGet, "/meters/grid", "", http.StatusOK, &meter)
+	if meter.Power == nil || *meter.Power != 1000 || len(meter.Currents) != 3 || meter.Energy != nil {
+		t.Errorf("unexpected meter: %+v", meter)
+	}
+
+	var charger Charger
+	request(t, router, http.MethodGet, "/chargers/wallbox", "", http.StatusOK, &charger)
+	if charger.Status != api.StatusC || charger.Enabled == nil || !*charger.Enabled || charger.Power == nil || *charger.Power != 11000 {
+		t.Errorf("unexpected charger: %+v", charger)
+	}
+
+	var vehicles []Vehicle
+	request(t, router, http.MethodGet, "/vehicles", "", http.StatusOK, &vehicles)
+	if len(vehicles) != 1 || vehicles[0].Name != "egolf" || vehicles[0].SoC == nil || *vehicles[0].SoC != 55 || vehicles[0].Range != nil {
+		t.Errorf("unexpected vehicles: %+v", vehicles)
+	}
+
+	request(t, router, http.MethodGet, "/meters/pv", "", http.StatusNotFound, nil)
+
+	// device updates require the runtime configuration
+	request(t, router, http.MethodPut, "/meters/grid", `{"name":"grid","type":"custom"}`, http.StatusMethodNotAllowed, nil)
+
+	conf := &testConfigurator{meters: []configstore.Device{{Name: "grid", Type: "sdm"}}}
+	router = mux.NewRouter()
+	Register(router, &testSite{}, cache, devices, conf, nil)
+
+	request(t, router, http.MethodPut, "/meters/grid", `{"name":"grid","type":"custom"}`, http.StatusOK, &meter)
+	if meter.Name != "grid" || conf.meters[0].Type != "custom" {
+		t.Errorf("unexpected meter: %+v, config %+v", meter, conf.meters)
+	}
+
+	request(t, router, http.MethodPut, "/meters/pv", `{"name":"pv","type":"custom"}`, http.StatusNotFound, nil)
+}
+
+type testTariff struct {
+	api.Tariff
+}
+
+func TestTariffs(t *testing.T) {
+	grid := &tariff.Fixed{Price: 0.3}
+	site := &testSite{tariffs: tariff.Tariffs{Currency: currency.EUR, Grid: grid}}
+
+	router := mux.NewRouter()
+	Register(router, site, util.NewCache(), nil, nil, nil)
+
+	var res Tariffs
+	request(t, router, http.MethodPut, "/tariffs", `{"grid":0.25}`, http.StatusOK, &res)
+	if res.Grid == nil || res.Grid.Price != 0.25 {
+		t.Errorf("unexpected tariffs: %+v", res)
+	}
+
+	// missing or non-fixed tariffs are rejected as a whole
+	request(t, router, http.MethodPut, "/tariffs", `{"grid":0.2,"feedIn":0.08}`, http.StatusConflict, nil)
+
+	site.tariffs.FeedIn = new(testTariff)
+	request(t, router, http.MethodPut, "/tariffs", `{"grid":0.2,"feedIn":0.08}`, http.StatusConflict, nil)
+
+	if price, _ := grid.CurrentPrice(); price != 0.25 {
+		t.Errorf("expected price unchanged, got %g", price)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	for _, tc := range []struct {
+		origins []string
+		allowed string
+	}{
+		{nil, ""},
+		{[]string{"https://dashboard.example"}, "https://dashboard.example"},
+	} {
+		router := mux.NewRouter()
+		Register(router, &testSite{}, util.NewCache(), nil, nil, tc.origins)
+
+		req := httptest.NewRequest(http.MethodOptions, Prefix+"/site", nil)
+		req.Header.Set("Origin", "https://dashboard.example")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if allowed := w.Header().Get("Access-Control-Allow-Origin"); allowed != tc.allowed {
+			t.Errorf("%v: expected allowed origin %q, got %q", tc.origins, tc.allowed, allowed)
+		}
+	}
+}
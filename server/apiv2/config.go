@@ -0,0 +1,258 @@
+package apiv2
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/evcc-io/evcc/core/configstore"
+	"github.com/gorilla/mux"
+)
+
+// ConfigPrefix is the path prefix of the runtime configuration resources
+const ConfigPrefix = "/config"
+
+// Configurator manages the runtime configuration of devices and loadpoints
+type Configurator interface {
+	RestartRequired() bool
+	Devices(class configstore.Class) ([]configstore.Device, error)
+	Device(class configstore.Class, name string) (configstore.Device, error)
+	CreateDevice(class configstore.Class, dev configstore.Device) error
+	UpdateDevice(class configstore.Class, name string, dev configstore.Device) error
+	DeleteDevice(class configstore.Class, name string) error
+	LoadPoints() []map[string]interface{}
+	LoadPoint(id int) (map[string]interface{}, error)
+	CreateLoadPoint(conf map[string]interface{}) (int, error)
+	UpdateLoadPoint(id int, conf map[string]interface{}) error
+	DeleteLoadPoint(id int) error
+}
+
+// ConfigStatus is the runtime configuration's state
+type ConfigStatus struct {
+	RestartRequired bool `json:"restartRequired" doc:"changes are persisted but only applied after restart"`
+}
+
+// DeviceConfig documents the device configuration. Further attributes depend on the device type as in the yaml configuration.
+type DeviceConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// LoadpointConfig is the loadpoint configuration resource
+type LoadpointConfig struct {
+	ID              int                    `json:"id" doc:"1-based loadpoint id"`
+	Config          map[string]interface{} `json:"config" doc:"loadpoint attributes as in the yaml configuration"`
+	RestartRequired bool                   `json:"restartRequired" doc:"changes are persisted but only applied after restart"`
+}
+
+// configEndpoints returns the runtime configuration endpoints
+func (h *handler) configEndpoints() []endpoint {
+	res := []endpoint{
+		{http.MethodGet, ConfigPrefix, "Get runtime configuration status", nil, ConfigStatus{}, h.getConfig},
+	}
+
+	for _, class := range []configstore.Class{configstore.Meter, configstore.Charger, configstore.Vehicle} {
+		path := ConfigPrefix + "/" + string(class) + "s"
+
+		res = append(res,
+			endpoint{http.MethodGet, path, fmt.Sprintf("List %s configurations", class), nil, []DeviceConfig{}, h.getDeviceConfigs(class)},
+			endpoint{http.MethodPost, path, fmt.Sprintf("Create %s", class), DeviceConfig{}, DeviceConfig{}, h.postDeviceConfig(class)},
+			endpoint{http.MethodGet, path + "/{name}", fmt.Sprintf("Get %s configuration", class), nil, DeviceConfig{}, h.getDeviceConfig(class)},
+			endpoint{http.MethodPut, path + "/{name}", fmt.Sprintf("Update %s and replace the running device", class), DeviceConfig{}, DeviceConfig{}, h.putDeviceConfig(class)},
+			endpoint{http.MethodDelete, path + "/{name}", fmt.Sprintf("Delete unused %s", class), nil, ConfigStatus{}, h.deleteDeviceConfig(class)},
+		)
+	}
+
+	path := ConfigPrefix + "/loadpoints"
+
+	return append(res,
+		endpoint{http.MethodGet, path, "List loadpoint configurations", nil, []LoadpointConfig{}, h.getLoadpointConfigs},
+		endpoint{http.MethodPost, path, "Create loadpoint, not supported at runtime", map[string]interface{}{}, LoadpointConfig{}, h.postLoadpointConfig},
+		endpoint{http.MethodGet, path + "/{id}", "Get loadpoint configuration", nil, LoadpointConfig{}, h.getLoadpointConfig},
+		endpoint{http.MethodPut, path + "/{id}", "Update loadpoint configuration, devices and settings are applied immediately", map[string]interface{}{}, LoadpointConfig{}, h.putLoadpointConfig},
+		endpoint{http.MethodDelete, path + "/{id}", "Delete loadpoint, not supported at runtime", nil, ConfigStatus{}, h.deleteLoadpointConfig},
+	)
+}
+
+// configError writes the error with the status matching its cause
+func configError(w http.ResponseWriter, err error) {
+	status := http.StatusUnprocessableEntity
+
+	switch {
+	case errors.Is(err, configstore.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, configstore.ErrExists), errors.Is(err, configstore.ErrInUse):
+		status = http.StatusConflict
+	case errors.Is(err, configstore.ErrNotSupported):
+		status = http.StatusNotImplemented
+	}
+
+	jsonError(w, status, err)
+}
+
+func (h *handler) getConfig(w http.ResponseWriter, r *http.Request) {
+	jsonWrite(w, http.StatusOK, ConfigStatus{RestartRequired: h.conf.RestartRequired()})
+}
+
+func (h *handler) getDeviceConfigs(class configstore.Class) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.conf.Devices(class)
+		if err != nil {
+			configError(w, err)
+			return
+		}
+
+		jsonWrite(w, http.StatusOK, res)
+	}
+}
+
+func (h *handler) getDeviceConfig(class configstore.Class) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res, err := h.conf.Device(class, mux.Vars(r)["name"])
+		if err != nil {
+			configError(w, err)
+			return
+		}
+
+		jsonWrite(w, http.StatusOK, res)
+	}
+}
+
+func (h *handler) postDeviceConfig(class configstore.Class) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req configstore.Device
+		if err := decode(r, &req); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.conf.CreateDevice(class, req); err != nil {
+			configError(w, err)
+			return
+		}
+
+		jsonWrite(w, http.StatusOK, req)
+	}
+}
+
+func (h *handler) putDeviceConfig(class configstore.Class) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req configstore.Device
+		if err := decode(r, &req); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := h.conf.UpdateDevice(class, mux.Vars(r)["name"], req); err != nil {
+			configError(w, err)
+			return
+		}
+
+		h.getDeviceConfig(class)(w, r)
+	}
+}
+
+func (h *handler) deleteDeviceConfig(class configstore.Class) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := h.conf.DeleteDevice(class, mux.Vars(r)["name"]); err != nil {
+			configError(w, err)
+			return
+		}
+
+		h.getConfig(w, r)
+	}
+}
+
+// loadpointID resolves the 0-based loadpoint id from the 1-based id path parameter
+func loadpointID(r *http.Request) (int, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("loadpoint %s: %w", mux.Vars(r)["id"], configstore.ErrNotFound)
+	}
+
+	return id - 1, nil
+}
+
+func (h *handler) getLoadpointConfigs(w http.ResponseWriter, r *http.Request) {
+	restart := h.conf.RestartRequired()
+
+	res := make([]LoadpointConfig, 0)
+	for id, conf := range h.conf.LoadPoints() {
+		res = append(res, LoadpointConfig{ID: id + 1, Config: conf, RestartRequired: restart})
+	}
+
+	jsonWrite(w, http.StatusOK, res)
+}
+
+func (h *handler) loadpointConfig(w http.ResponseWriter, id int) {
+	conf, err := h.conf.LoadPoint(id)
+	if err != nil {
+		configError(w, err)
+		return
+	}
+
+	jsonWrite(w, http.StatusOK, LoadpointConfig{ID: id + 1, Config: conf, RestartRequired: h.conf.RestartRequired()})
+}
+
+func (h *handler) getLoadpointConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := loadpointID(r)
+	if err != nil {
+		configError(w, err)
+		return
+	}
+
+	h.loadpointConfig(w, id)
+}
+
+func (h *handler) postLoadpointConfig(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	if err := decode(r, &req); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	id, err := h.conf.CreateLoadPoint(req)
+	if err != nil {
+		configError(w, err)
+		return
+	}
+
+	h.loadpointConfig(w, id)
+}
+
+func (h *handler) putLoadpointConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := loadpointID(r)
+	if err != nil {
+		configError(w, err)
+		return
+	}
+
+	var req map[string]interface{}
+	if err := decode(r, &req); err != nil {
+		jsonError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.conf.UpdateLoadPoint(id, req); err != nil {
+		configError(w, err)
+		return
+	}
+
+	h.loadpointConfig(w, id)
+}
+
+func (h *handler) deleteLoadpointConfig(w http.ResponseWriter, r *http.Request) {
+	id, err := loadpointID(r)
+	if err != nil {
+		configError(w, err)
+		return
+	}
+
+	if err := h.conf.DeleteLoadPoint(id); err != nil {
+		configError(w, err)
+		return
+	}
+
+	h.getConfig(w, r)
+}
@@ -0,0 +1,229 @@
+package apiv2
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schemas collects the named component schemas referenced by the api
+type schemas map[string]interface{}
+
+// ref returns the schema for t, registering named structs as components
+func (s schemas) ref(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		res := s.ref(t.Elem())
+		if _, ok := res["$ref"]; ok {
+			return map[string]interface{}{"allOf": []interface{}{res}, "nullable": true}
+		}
+		res["nullable"] = true
+		return res
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": s.ref(t.Elem())}
+
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.ref(t.Elem())}
+
+	case reflect.Struct:
+		name := t.Name()
+		if name == "" {
+			return s.object(t)
+		}
+
+		if _, ok := s[name]; !ok {
+			s[name] = s.object(t)
+		}
+
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+// object returns the object schema of struct t using its json, enum and doc tags
+func (s schemas) object(t reflect.Type) map[string]interface{} {
+	props := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := f.Name, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			tag, opts = tag[:i], tag[i+1:]
+		}
+		if tag != "" {
+			name = tag
+		}
+
+		prop := s.ref(f.Type)
+
+		if doc := f.Tag.Get("doc"); doc != "" {
+			prop["description"] = doc
+		}
+
+		if enum := f.Tag.Get("enum"); enum != "" {
+			var values []interface{}
+			for _, v := range strings.Split(enum, ",") {
+				if i, err := strconv.Atoi(v); err == nil {
+					values = append(values, i)
+				} else {
+					values = append(values, v)
+				}
+			}
+			prop["enum"] = values
+		}
+
+		props[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	res := map[string]interface{}{
+		"type":       "object",
+		"properties": props,
+	}
+
+	if len(required) > 0 {
+		res["required"] = required
+	}
+
+	return res
+}
+
+// response returns a json response description
+func response(description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"description": description,
+		"content": map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		},
+	}
+}
+
+// openAPI generates the OpenAPI document from the api's endpoints
+func openAPI(endpoints []endpoint) map[string]interface{} {
+	components := make(schemas)
+	paths := make(map[string]map[string]interface{})
+
+	errRef := components.ref(reflect.TypeOf(struct {
+		Error Error `json:"error"`
+	}{}))
+	errResponse := response("Error", errRef)
+
+	for _, e := range endpoints {
+		op := map[string]interface{}{
+			"summary": e.Summary,
+		}
+
+		responses := map[string]interface{}{
+			"200":     response("OK", components.ref(reflect.TypeOf(e.Response))),
+			"default": errResponse,
+		}
+
+		if e.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": components.ref(reflect.TypeOf(e.Request))},
+				},
+			}
+		}
+
+		op["responses"] = responses
+
+		var params []interface{}
+		for _, seg := range strings.Split(e.Path, "/") {
+			if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+				name := strings.Trim(seg, "{}")
+
+				typ := "string"
+				if name == "id" {
+					typ = "integer"
+				}
+
+				params = append(params, map[string]interface{}{
+					"name":     name,
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": typ},
+				})
+			}
+		}
+
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if _, ok := paths[e.Path]; !ok {
+			paths[e.Path] = make(map[string]interface{})
+		}
+		paths[e.Path][strings.ToLower(e.Method)] = op
+	}
+
+	paths["/openapi.json"] = map[string]interface{}{
+		strings.ToLower(http.MethodGet): map[string]interface{}{
+			"summary": "Get OpenAPI document",
+			"responses": map[string]interface{}{
+				"200": response("OK", map[string]interface{}{"type": "object"}),
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "evcc",
+			"version": "2",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": Prefix},
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": components,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+				"basic":  map[string]interface{}{"type": "http", "scheme": "basic"},
+			},
+		},
+		// applies if access control is enabled
+		"security": []interface{}{
+			map[string]interface{}{"bearer": []string{}},
+			map[string]interface{}{"basic": []string{}},
+		},
+	}
+}
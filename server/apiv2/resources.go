@@ -0,0 +1,392 @@
+package apiv2
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/configstore"
+	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/tariff"
+)
+
+// Site is the site resource
+type Site struct {
+	Title             string  `json:"title"`
+	Healthy           bool    `json:"healthy"`
+	GridConfigured    bool    `json:"gridConfigured"`
+	PVConfigured      bool    `json:"pvConfigured"`
+	BatteryConfigured bool    `json:"batteryConfigured"`
+	GridPower         float64 `json:"gridPower" doc:"W, positive when importing"`
+	PVPower           float64 `json:"pvPower" doc:"W"`
+	BatteryPower      float64 `json:"batteryPower" doc:"W, positive when discharging"`
+	BatterySoC        float64 `json:"batterySoC" doc:"%"`
+	HomePower         float64 `json:"homePower" doc:"W"`
+	PrioritySoC       float64 `json:"prioritySoC" doc:"% battery soc below which the battery takes precedence over loadpoints"`
+	Currency          string  `json:"currency"`
+}
+
+// SiteUpdate is the site's writable settings, omitted fields remain unchanged
+type SiteUpdate struct {
+	PrioritySoC *float64 `json:"prioritySoC,omitempty" doc:"%, requires a battery"`
+}
+
+func (h *handler) siteResource() (Site, error) {
+	vals := h.cached(nil)
+
+	var res Site
+	if err := fromCache(vals, &res); err != nil {
+		return res, fmt.Errorf("site: %w", err)
+	}
+
+	res.Title = h.site.GetTitle()
+	res.Healthy = h.site.Healthy()
+	res.PrioritySoC = h.site.GetPrioritySoC()
+
+	return res, nil
+}
+
+// Loadpoint is the loadpoint resource
+type Loadpoint struct {
+	ID                int              `json:"id" doc:"1-based loadpoint id"`
+	Title             string           `json:"title"`
+	Mode              api.ChargeMode   `json:"mode" enum:"off,now,minpv,pv"`
+	Priority          int              `json:"priority"`
+	Phases            int              `json:"phases"`
+	MinCurrent        float64          `json:"minCurrent" doc:"A"`
+	MaxCurrent        float64          `json:"maxCurrent" doc:"A"`
+	MinSoC            int              `json:"minSoC" doc:"%"`
+	TargetSoC         int              `json:"targetSoC" doc:"%"`
+	TargetTime        *time.Time       `json:"targetTime" doc:"target charge finish time, null if not set"`
+	Plans             loadpoint.Plans  `json:"plans"`
+	Status            api.ChargeStatus `json:"status" enum:"A,B,C,D,E,F"`
+	Connected         bool             `json:"connected"`
+	Charging          bool             `json:"charging"`
+	Enabled           bool             `json:"enabled"`
+	ChargePower       float64          `json:"chargePower" doc:"W"`
+	ChargedEnergy     float64          `json:"chargedEnergy" doc:"Wh charged while connected"`
+	RemainingDuration int64            `json:"remainingDuration" doc:"s, estimated"`
+	RemainingEnergy   float64          `json:"remainingEnergy" doc:"Wh"`
+	VehicleTitle      string           `json:"vehicleTitle"`
+	VehicleSoC        float64          `json:"vehicleSoC" doc:"%"`
+	VehicleRange      int64            `json:"vehicleRange" doc:"km"`
+}
+
+// TargetCharge is a one-time target charge
+type TargetCharge struct {
+	SoC  int        `json:"soc" doc:"%"`
+	Time *time.Time `json:"time" doc:"finish time, null removes the target charge"`
+}
+
+// LoadpointUpdate is the loadpoint's writable settings, omitted fields remain unchanged
+type LoadpointUpdate struct {
+	Mode         *api.ChargeMode `json:"mode,omitempty" enum:"off,now,minpv,pv"`
+	Vehicle      *string         `json:"vehicle,omitempty" doc:"name or title of the vehicle to activate with the next update"`
+	Priority     *int            `json:"priority,omitempty"`
+	Phases       *int            `json:"phases,omitempty" enum:"1,3"`
+	MinCurrent   *float64        `json:"minCurrent,omitempty" doc:"A"`
+	MaxCurrent   *float64        `json:"maxCurrent,omitempty" doc:"A"`
+	MinSoC       *int            `json:"minSoC,omitempty" doc:"%"`
+	TargetSoC    *int            `json:"targetSoC,omitempty" doc:"%"`
+	TargetCharge *TargetCharge   `json:"targetCharge,omitempty"`
+	Plans        loadpoint.Plans `json:"plans,omitempty" doc:"replaces all plans"`
+}
+
+// driverOnly returns true if the update only contains settings permitted to drivers
+func (u LoadpointUpdate) driverOnly() bool {
+	return u.Priority == nil && u.Phases == nil && u.MinCurrent == nil && u.MaxCurrent == nil &&
+		u.MinSoC == nil && u.TargetCharge == nil && u.Plans == nil && u.Vehicle == nil
+}
+
+func validSoC(name string, soc *int) error {
+	if soc != nil && (*soc < 0 || *soc > 100) {
+		return fmt.Errorf("invalid %s: %d", name, *soc)
+	}
+	return nil
+}
+
+// validate checks the update against the loadpoint's current settings
+func (u LoadpointUpdate) validate(lp loadpoint.API) error {
+	if u.Mode != nil {
+		mode, err := api.ChargeModeString(string(*u.Mode))
+		if err != nil || mode == api.ModeEmpty {
+			return fmt.Errorf("invalid mode: %s", *u.Mode)
+		}
+		*u.Mode = mode
+	}
+
+	if u.Priority != nil && *u.Priority < 0 {
+		return fmt.Errorf("invalid priority: %d", *u.Priority)
+	}
+
+	if u.Phases != nil && *u.Phases != 1 && *u.Phases != 3 {
+		return fmt.Errorf("invalid phases: %d", *u.Phases)
+	}
+
+	minCurrent, maxCurrent := lp.GetMinCurrent(), lp.GetMaxCurrent()
+	if u.MinCurrent != nil {
+		minCurrent = *u.MinCurrent
+	}
+	if u.MaxCurrent != nil {
+		maxCurrent = *u.MaxCurrent
+	}
+	if minCurrent <= 0 || minCurrent > maxCurrent {
+		return fmt.Errorf("invalid current range: %gA..%gA", minCurrent, maxCurrent)
+	}
+
+	if err := validSoC("minSoC", u.MinSoC); err != nil {
+		return err
+	}
+
+	if err := validSoC("targetSoC", u.TargetSoC); err != nil {
+		return err
+	}
+
+	if u.TargetCharge != nil {
+		if err := validSoC("targetCharge soc", &u.TargetCharge.SoC); err != nil {
+			return err
+		}
+
+		if u.TargetCharge.Time != nil && u.TargetCharge.Time.IsZero() {
+			return errors.New("invalid targetCharge time")
+		}
+	}
+
+	if u.Vehicle != nil && !lp.HasVehicle(*u.Vehicle) {
+		return fmt.Errorf("unknown vehicle: %s", *u.Vehicle)
+	}
+
+	return u.Plans.Validate()
+}
+
+// apply applies the validated update to the loadpoint. Switching phases may fail and is applied first,
+// the vehicle is applied last.
+func (u LoadpointUpdate) apply(lp loadpoint.API) error {
+	if u.Phases != nil {
+		if err := lp.SetPhases(*u.Phases); err != nil {
+			return err
+		}
+	}
+
+	if u.Mode != nil {
+		lp.SetMode(*u.Mode)
+	}
+
+	if u.Priority != nil {
+		lp.SetPriority(*u.Priority)
+	}
+
+	if u.MinCurrent != nil {
+		lp.SetMinCurrent(*u.MinCurrent)
+	}
+
+	if u.MaxCurrent != nil {
+		lp.SetMaxCurrent(*u.MaxCurrent)
+	}
+
+	if u.MinSoC != nil {
+		lp.SetMinSoC(*u.MinSoC)
+	}
+
+	if u.TargetSoC != nil {
+		lp.SetTargetSoC(*u.TargetSoC)
+	}
+
+	if u.TargetCharge != nil {
+		if u.TargetCharge.Time == nil {
+			lp.SetTargetCharge(time.Time{}, 0)
+		} else {
+			lp.SetTargetCharge(*u.TargetCharge.Time, u.TargetCharge.SoC)
+		}
+	}
+
+	if u.Plans != nil {
+		if err := lp.SetPlans(u.Plans); err != nil {
+			return err
+		}
+	}
+
+	if u.Vehicle != nil {
+		return lp.SetVehicle(*u.Vehicle)
+	}
+
+	return nil
+}
+
+func (h *handler) loadpointResource(id int, lp loadpoint.API) (Loadpoint, error) {
+	var res Loadpoint
+	if err := fromCache(h.cached(&id), &res); err != nil {
+		return res, fmt.Errorf("loadpoint %d: %w", id+1, err)
+	}
+
+	res.ID = id + 1
+	res.Title = lp.Name()
+	res.Mode = lp.GetMode()
+	res.Priority = lp.GetPriority()
+	res.Phases = lp.GetPhases()
+	res.MinCurrent = lp.GetMinCurrent()
+	res.MaxCurrent = lp.GetMaxCurrent()
+	res.MinSoC = lp.GetMinSoC()
+	res.TargetSoC = lp.GetTargetSoC()
+	res.Plans = lp.GetPlans()
+	res.Status = lp.GetStatus()
+	res.ChargePower = lp.GetChargePower()
+	res.ChargedEnergy = lp.GetChargedEnergy()
+	res.RemainingDuration = int64(lp.GetRemainingDuration().Seconds())
+	res.RemainingEnergy = lp.GetRemainingEnergy()
+
+	res.TargetTime = nil
+	if ts := lp.GetTargetTime(); !ts.IsZero() {
+		res.TargetTime = &ts
+	}
+
+	if res.Plans == nil {
+		res.Plans = loadpoint.Plans{}
+	}
+
+	return res, nil
+}
+
+// Vehicle is the vehicle resource, values are as last read by the loadpoint using the vehicle
+type Vehicle struct {
+	Name     string   `json:"name"`
+	Title    string   `json:"title"`
+	Capacity int64    `json:"capacity" doc:"kWh"`
+	SoC      *float64 `json:"soc,omitempty" doc:"%"`
+	Range    *int64   `json:"range,omitempty" doc:"km"`
+	Odometer *float64 `json:"odometer,omitempty" doc:"km"`
+}
+
+func (h *handler) vehicleResource(name string, vehicle api.Vehicle) Vehicle {
+	var res Vehicle
+	if err := fromCache(h.vehicleValues(vehicle.Title()), &res); err != nil {
+		log.ERROR.Printf("vehicle %s: %v", name, err)
+	}
+
+	res.Name = name
+	res.Title = vehicle.Title()
+	res.Capacity = vehicle.Capacity()
+
+	return res
+}
+
+// Charger is the charger resource, values are as last read by the loadpoint
+type Charger struct {
+	Name     string           `json:"name"`
+	Status   api.ChargeStatus `json:"status,omitempty" enum:"A,B,C,D,E,F"`
+	Enabled  *bool            `json:"enabled,omitempty"`
+	Power    *float64         `json:"power,omitempty" doc:"W, if the charger has a meter"`
+	Energy   *float64         `json:"energy,omitempty" doc:"kWh total, if the charger has a meter"`
+	Currents []float64        `json:"currents,omitempty" doc:"A per phase, if the charger has a meter"`
+}
+
+func (h *handler) chargerResource(name string) Charger {
+	var res Charger
+	if err := fromCache(h.deviceValues(configstore.Charger, name), &res); err != nil {
+		log.ERROR.Printf("charger %s: %v", name, err)
+	}
+
+	res.Name = name
+
+	return res
+}
+
+// Meter is the meter resource, values are as last read by the site or loadpoint
+type Meter struct {
+	Name     string    `json:"name"`
+	Power    *float64  `json:"power,omitempty" doc:"W"`
+	Energy   *float64  `json:"energy,omitempty" doc:"kWh total"`
+	Currents []float64 `json:"currents,omitempty" doc:"A per phase"`
+	SoC      *float64  `json:"soc,omitempty" doc:"%, battery meters only"`
+}
+
+func (h *handler) meterResource(name string) Meter {
+	var res Meter
+	if err := fromCache(h.deviceValues(configstore.Meter, name), &res); err != nil {
+		log.ERROR.Printf("meter %s: %v", name, err)
+	}
+
+	res.Name = name
+
+	return res
+}
+
+// Tariffs is the tariffs resource
+type Tariffs struct {
+	Currency string  `json:"currency"`
+	Grid     *Tariff `json:"grid,omitempty"`
+	FeedIn   *Tariff `json:"feedIn,omitempty"`
+}
+
+// Tariff is a tariff's current state, values are read from the tariff on request
+type Tariff struct {
+	Price float64   `json:"price" doc:"per kWh"`
+	Cheap bool      `json:"cheap"`
+	Rates api.Rates `json:"rates,omitempty" doc:"upcoming price slots, if provided by the tariff"`
+	Error string    `json:"error,omitempty" doc:"first error reading the tariff"`
+}
+
+func tariffResource(t api.Tariff) *Tariff {
+	if t == nil {
+		return nil
+	}
+
+	res := new(Tariff)
+
+	price, err := t.CurrentPrice()
+	res.Price = price
+
+	if cheap, e := t.IsCheap(); e == nil {
+		res.Cheap = cheap
+	} else if err == nil {
+		err = e
+	}
+
+	if tr, ok := t.(api.TariffRates); ok {
+		if rates, e := tr.Rates(); e == nil {
+			res.Rates = rates
+		} else if err == nil {
+			err = e
+		}
+	}
+
+	if err != nil {
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// TariffsUpdate is the fixed tariffs' writable prices, omitted fields remain unchanged
+type TariffsUpdate struct {
+	Grid   *float64 `json:"grid,omitempty" doc:"price per kWh, fixed tariff only"`
+	FeedIn *float64 `json:"feedIn,omitempty" doc:"price per kWh, fixed tariff only"`
+}
+
+// adjustablePrice is implemented by tariffs with a price adjustable at runtime
+type adjustablePrice interface {
+	SetPrice(float64)
+}
+
+// priceUpdate returns the update of the tariff's price, doing nothing if price is nil
+func priceUpdate(name string, t api.Tariff, price *float64) (func(), error) {
+	if price == nil {
+		return func() {}, nil
+	}
+
+	at, ok := t.(adjustablePrice)
+	if !ok {
+		return nil, fmt.Errorf("%s tariff price is not adjustable", name)
+	}
+
+	return func() { at.SetPrice(*price) }, nil
+}
+
+func tariffsResource(t tariff.Tariffs) Tariffs {
+	return Tariffs{
+		Currency: t.Currency.String(),
+		Grid:     tariffResource(t.Grid),
+		FeedIn:   tariffResource(t.FeedIn),
+	}
+}
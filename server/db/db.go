@@ -0,0 +1,42 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Instance is the shared database instance
+var Instance *gorm.DB
+
+// New creates a database connection for the given driver and data source name
+func New(driver, dsn string) (*gorm.DB, error) {
+	var dialect gorm.Dialector
+
+	switch strings.ToLower(driver) {
+	case "sqlite":
+		file, err := util.ExpandHome(dsn)
+		if err != nil {
+			return nil, err
+		}
+
+		// avoid failures due to missing folders
+		if err := os.MkdirAll(filepath.Dir(file), os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		dialect = sqlite.Open(file)
+	default:
+		return nil, fmt.Errorf("invalid database type: %s not in [sqlite]", driver)
+	}
+
+	return gorm.Open(dialect, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+}
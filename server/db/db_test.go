@@ -0,0 +1,60 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSqlite(t *testing.T) {
+	type record struct {
+		ID      uint `gorm:"primarykey"`
+		Created time.Time
+		Name    string
+		Energy  float64
+	}
+
+	file := filepath.Join(t.TempDir(), "data", "evcc.db")
+
+	db, err := New("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.AutoMigrate(new(record)); err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC)
+	if err := db.Create(&record{Created: created, Name: "Garage", Energy: 12.5}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// reopen to read back from file
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = sqlDB.Close()
+
+	if db, err = New("sqlite", file); err != nil {
+		t.Fatal(err)
+	}
+
+	var res []record
+	if err := db.Find(&res).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res) != 1 {
+		t.Fatalf("expected one record, got %v", res)
+	}
+
+	if r := res[0]; !r.Created.Equal(created) || r.Name != "Garage" || r.Energy != 12.5 {
+		t.Errorf("unexpected record: %+v", r)
+	}
+
+	if _, err := New("postgres", ""); err == nil {
+		t.Error("expected invalid database type error")
+	}
+}
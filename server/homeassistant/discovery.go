@@ -0,0 +1,183 @@
+// Package homeassistant creates Home Assistant MQTT discovery configs for the published site and loadpoint values
+package homeassistant
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/evcc-io/evcc/server/access"
+)
+
+// Entity describes the Home Assistant entity of a published value
+type Entity struct {
+	Component   string // sensor, binary_sensor, select or number
+	Name        string
+	DeviceClass string
+	StateClass  string
+	Unit        string
+	Options     []string    // select options
+	Min, Max    float64     // number range
+	Step        float64     // number step
+	Setter      access.Role // role required by the value's mqtt setter
+}
+
+func sensor(name, deviceClass, stateClass, unit string) Entity {
+	return Entity{Component: "sensor", Name: name, DeviceClass: deviceClass, StateClass: stateClass, Unit: unit}
+}
+
+func binarySensor(name, deviceClass string) Entity {
+	return Entity{Component: "binary_sensor", Name: name, DeviceClass: deviceClass}
+}
+
+func number(name, unit string, min, max, step float64, role access.Role) Entity {
+	return Entity{Component: "number", Name: name, Unit: unit, Min: min, Max: max, Step: step, Setter: role}
+}
+
+var siteEntities = map[string]Entity{
+	"gridPower":      sensor("Grid power", "power", "measurement", "W"),
+	"gridEnergy":     sensor("Grid energy", "energy", "total_increasing", "kWh"),
+	"pvPower":        sensor("PV power", "power", "measurement", "W"),
+	"batteryPower":   sensor("Battery power", "power", "measurement", "W"),
+	"batterySoC":     sensor("Battery SoC", "battery", "measurement", "%"),
+	"batteryMode":    sensor("Battery mode", "", "", ""),
+	"homePower":      sensor("Home power", "power", "measurement", "W"),
+	"forecastPower":  sensor("PV forecast", "power", "measurement", "W"),
+	"curtailed":      binarySensor("Grid curtailment", "problem"),
+	"prioritySoC":    number("Battery priority SoC", "%", 0, 100, 1, access.RoleAdmin),
+	"forecastEnergy": sensor("PV forecast energy today", "energy", "", "Wh"),
+}
+
+var loadpointEntities = map[string]Entity{
+	"mode": {
+		Component: "select", Name: "Mode",
+		Options: []string{"off", "now", "minpv", "pv"},
+		Setter:  access.RoleDriver,
+	},
+	"minSoC":                  number("Min SoC", "%", 0, 100, 5, access.RoleAdmin),
+	"targetSoC":               number("Target SoC", "%", 0, 100, 5, access.RoleDriver),
+	"minCurrent":              number("Min current", "A", 1, 63, 1, access.RoleAdmin),
+	"maxCurrent":              number("Max current", "A", 1, 63, 1, access.RoleAdmin),
+	"phases":                  number("Phases", "", 1, 3, 2, access.RoleAdmin),
+	"priority":                number("Priority", "", 0, 100, 1, access.RoleAdmin),
+	"connected":               binarySensor("Connected", "plug"),
+	"charging":                binarySensor("Charging", "battery_charging"),
+	"enabled":                 binarySensor("Enabled", "power"),
+	"chargePower":             sensor("Charge power", "power", "measurement", "W"),
+	"chargeCurrent":           sensor("Charge current", "current", "measurement", "A"),
+	"chargedEnergy":           sensor("Charged energy", "energy", "total_increasing", "Wh"),
+	"activePhases":            sensor("Active phases", "", "measurement", ""),
+	"chargeDuration":          sensor("Charge duration", "duration", "", "s"),
+	"connectedDuration":       sensor("Connected duration", "duration", "", "s"),
+	"chargeRemainingDuration": sensor("Remaining charge duration", "duration", "", "s"),
+	"chargeRemainingEnergy":   sensor("Remaining charge energy", "energy", "", "Wh"),
+	"vehicleTitle":            sensor("Vehicle", "", "", ""),
+	"vehicleSoC":              sensor("Vehicle SoC", "battery", "measurement", "%"),
+	"vehicleRange":            sensor("Vehicle range", "", "measurement", "km"),
+	"vehicleOdometer":         sensor("Vehicle odometer", "", "total_increasing", "km"),
+}
+
+var invalid = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// Discovery creates discovery configs for the values published below the root topic
+type Discovery struct {
+	prefix string
+	root   string
+	node   string
+	role   access.Role
+}
+
+// New creates Home Assistant discovery using the discovery prefix. Command entities
+// are only created if their setter is permitted to the mqtt api's role.
+func New(prefix, root string, role access.Role) *Discovery {
+	return &Discovery{
+		prefix: prefix,
+		root:   root,
+		node:   strings.Trim(invalid.ReplaceAllString(root, "_"), "_"),
+		role:   role,
+	}
+}
+
+// device returns the Home Assistant device grouping the site's or a loadpoint's entities
+func (d *Discovery) device(lp *int, title string) map[string]interface{} {
+	site := d.node + "_site"
+
+	res := map[string]interface{}{
+		"identifiers":  []string{site},
+		"name":         strings.TrimSpace("evcc " + title),
+		"manufacturer": "evcc",
+		"model":        "Site",
+	}
+
+	if lp != nil {
+		res["identifiers"] = []string{fmt.Sprintf("%s_loadpoint_%d", d.node, *lp+1)}
+		res["model"] = "Loadpoint"
+		res["via_device"] = site
+
+		if title == "" {
+			res["name"] = fmt.Sprintf("evcc Loadpoint %d", *lp+1)
+		}
+	}
+
+	return res
+}
+
+// Config returns the discovery topic and config for the site (lp == nil) or loadpoint value.
+// It returns false if no entity is defined for the key.
+func (d *Discovery) Config(lp *int, title, key string) (string, map[string]interface{}, bool) {
+	entities, object, topic := siteEntities, "site_"+key, fmt.Sprintf("%s/site/%s", d.root, key)
+	if lp != nil {
+		entities = loadpointEntities
+		object = fmt.Sprintf("loadpoint_%d_%s", *lp+1, key)
+		topic = fmt.Sprintf("%s/loadpoints/%d/%s", d.root, *lp+1, key)
+	}
+
+	e, ok := entities[key]
+	if !ok {
+		return "", nil, false
+	}
+
+	device := d.device(lp, title)
+
+	res := map[string]interface{}{
+		"name":               fmt.Sprintf("%s %s", device["name"], e.Name),
+		"unique_id":          d.node + "_" + object,
+		"state_topic":        topic,
+		"availability_topic": d.root + "/status",
+		"device":             device,
+	}
+
+	// read-only if the setter is not permitted
+	component := e.Component
+	if e.Setter != access.RoleNone {
+		if d.role >= e.Setter {
+			res["command_topic"] = topic + "/set"
+		} else {
+			component = "sensor"
+		}
+	}
+
+	switch component {
+	case "binary_sensor":
+		res["payload_on"] = "true"
+		res["payload_off"] = "false"
+	case "select":
+		res["options"] = e.Options
+	case "number":
+		res["min"] = e.Min
+		res["max"] = e.Max
+		res["step"] = e.Step
+	}
+
+	for k, v := range map[string]string{
+		"device_class":        e.DeviceClass,
+		"state_class":         e.StateClass,
+		"unit_of_measurement": e.Unit,
+	} {
+		if v != "" {
+			res[k] = v
+		}
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s/config", d.prefix, component, d.node, object), res, true
+}
@@ -0,0 +1,42 @@
+package homeassistant
+
+import (
+	"testing"
+
+	"github.com/evcc-io/evcc/server/access"
+)
+
+func TestConfig(t *testing.T) {
+	d := New("homeassistant", "home/evcc", access.RoleDriver)
+	lp := 0
+
+	topic, conf, ok := d.Config(&lp, "Garage", "mode")
+	if !ok || topic != "homeassistant/select/home_evcc/loadpoint_1_mode/config" {
+		t.Fatalf("unexpected topic: %s", topic)
+	}
+
+	if conf["command_topic"] != "home/evcc/loadpoints/1/mode/set" || conf["name"] != "evcc Garage Mode" {
+		t.Errorf("unexpected config: %v", conf)
+	}
+
+	device := conf["device"].(map[string]interface{})
+	if device["via_device"] != "home_evcc_site" {
+		t.Errorf("unexpected device: %v", device)
+	}
+
+	// setter not permitted to the mqtt role
+	topic, conf, _ = d.Config(&lp, "Garage", "maxCurrent")
+	if _, ok := conf["command_topic"]; ok || topic != "homeassistant/sensor/home_evcc/loadpoint_1_maxCurrent/config" {
+		t.Errorf("expected read-only sensor, got %s: %v", topic, conf)
+	}
+
+	topic, conf, _ = d.Config(nil, "", "gridPower")
+	if topic != "homeassistant/sensor/home_evcc/site_gridPower/config" ||
+		conf["state_topic"] != "home/evcc/site/gridPower" || conf["device_class"] != "power" || conf["unit_of_measurement"] != "W" {
+		t.Errorf("unexpected config %s: %v", topic, conf)
+	}
+
+	if _, _, ok := d.Config(nil, "", "savingsSince"); ok {
+		t.Error("expected no entity")
+	}
+}
@@ -43,8 +43,11 @@ type HTTPd struct {
 // NewHTTPd creates HTTP server with configured routes for loadpoint
 func NewHTTPd(url string, site site.API, hub *SocketHub, cache *util.Cache) *HTTPd {
 	routes := map[string]route{
-		"health": {[]string{"GET"}, "/health", healthHandler(site)},
-		"state":  {[]string{"GET"}, "/state", stateHandler(cache)},
+		"health":       {[]string{"GET"}, "/health", healthHandler(site)},
+		"state":        {[]string{"GET"}, "/state", stateHandler(cache)},
+		"sessions":     {[]string{"GET"}, "/sessions", sessionHandler},
+		"curtailments": {[]string{"GET"}, "/curtailments", curtailmentHandler},
+		"savingsreset": {[]string{"POST", "OPTIONS"}, "/savings/reset", savingsResetHandler(site)},
 	}
 
 	router := mux.NewRouter().StrictSlash(true)
@@ -85,9 +88,12 @@ func NewHTTPd(url string, site site.API, hub *SocketHub, cache *util.Cache) *HTT
 			"mincurrent":    {[]string{"POST", "OPTIONS"}, "/mincurrent/{value:[0-9]+}", minCurrentHandler(lp)},
 			"maxcurrent":    {[]string{"POST", "OPTIONS"}, "/maxcurrent/{value:[0-9]+}", maxCurrentHandler(lp)},
 			"phases":        {[]string{"POST", "OPTIONS"}, "/phases/{value:[0-9]+}", phasesHandler(lp)},
+			"priority":      {[]string{"POST", "OPTIONS"}, "/priority/{value:[0-9]+}", priorityHandler(lp)},
 			"targetcharge":  {[]string{"POST", "OPTIONS"}, "/targetcharge/{soc:[0-9]+}/{time:[0-9TZ:-]+}", targetChargeHandler(lp)},
 			"targetcharge2": {[]string{"DELETE", "OPTIONS"}, "/targetcharge", targetChargeRemoveHandler(lp)},
 			"remotedemand":  {[]string{"POST", "OPTIONS"}, "/remotedemand/{demand:[a-z]+}/{source::[0-9a-zA-Z_-]+}", remoteDemandHandler(lp)},
+			"plans":         {[]string{"GET"}, "/plans", plansHandler(lp)},
+			"plans2":        {[]string{"POST", "OPTIONS"}, "/plans", updatePlansHandler(lp)},
 		}
 
 		for _, r := range routes {
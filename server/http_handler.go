@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -11,8 +12,11 @@ import (
 	"time"
 
 	"github.com/evcc-io/evcc/api"
+	"github.com/evcc-io/evcc/core/curtailment"
 	"github.com/evcc-io/evcc/core/loadpoint"
+	"github.com/evcc-io/evcc/core/session"
 	"github.com/evcc-io/evcc/core/site"
+	"github.com/evcc-io/evcc/server/db"
 	"github.com/evcc-io/evcc/util"
 	"github.com/gorilla/mux"
 )
@@ -90,6 +94,79 @@ func stateHandler(cache *util.Cache) http.HandlerFunc {
 	}
 }
 
+// savingsResetHandler resets the savings totals
+func savingsResetHandler(site site.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		site.ResetSavings()
+		jsonResult(w, true)
+	}
+}
+
+// sessionHandler returns charging sessions, optionally filtered by month (YYYY-MM) and formatted as csv
+func sessionHandler(w http.ResponseWriter, r *http.Request) {
+	if db.Instance == nil {
+		jsonError(w, http.StatusNotFound, errors.New("session history not configured"))
+		return
+	}
+
+	var filter session.Filter
+	if month := r.URL.Query().Get("month"); month != "" {
+		from, err := time.ParseInLocation("2006-01", month, time.Local)
+		if err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		filter.From, filter.To = from, from.AddDate(0, 1, 0)
+	}
+
+	res, err := session.All(db.Instance, filter)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="sessions.csv"`)
+
+		if err := res.WriteCsv(w); err != nil {
+			log.ERROR.Printf("httpd: failed to write csv: %v", err)
+		}
+
+		return
+	}
+
+	jsonResult(w, res)
+}
+
+// curtailmentHandler returns the grid operator curtailment audit trail, optionally formatted as csv
+func curtailmentHandler(w http.ResponseWriter, r *http.Request) {
+	if db.Instance == nil {
+		jsonError(w, http.StatusNotFound, errors.New("curtailment history not configured"))
+		return
+	}
+
+	res, err := curtailment.All(db.Instance)
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=UTF-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="curtailments.csv"`)
+
+		if err := res.WriteCsv(w); err != nil {
+			log.ERROR.Printf("httpd: failed to write csv: %v", err)
+		}
+
+		return
+	}
+
+	jsonResult(w, res)
+}
+
 // chargeModeHandler updates charge mode
 func chargeModeHandler(lp loadpoint.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -141,6 +218,23 @@ func minSoCHandler(lp loadpoint.API) http.HandlerFunc {
 	}
 }
 
+// priorityHandler updates loadpoint priority
+func priorityHandler(lp loadpoint.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+
+		priority, err := strconv.ParseInt(vars["value"], 10, 32)
+		if err == nil {
+			lp.SetPriority(int(priority))
+		} else {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		jsonResult(w, lp.GetPriority())
+	}
+}
+
 // minCurrentHandler updates minimum current
 func minCurrentHandler(lp loadpoint.API) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -274,6 +368,35 @@ func targetChargeRemoveHandler(loadpoint loadpoint.API) http.HandlerFunc {
 	}
 }
 
+// plansHandler returns the recurring target charge plans
+func plansHandler(lp loadpoint.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		res := lp.GetPlans()
+		if res == nil {
+			res = loadpoint.Plans{}
+		}
+		jsonResult(w, res)
+	}
+}
+
+// updatePlansHandler replaces the recurring target charge plans
+func updatePlansHandler(lp loadpoint.API) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var plans loadpoint.Plans
+		if err := json.NewDecoder(r.Body).Decode(&plans); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		if err := lp.SetPlans(plans); err != nil {
+			jsonError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		jsonResult(w, plans)
+	}
+}
+
 // socketHandler attaches websocket handler to uri
 func socketHandler(hub *SocketHub) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
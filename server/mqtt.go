@@ -1,29 +1,48 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/core/loadpoint"
 	"github.com/evcc-io/evcc/core/site"
 	"github.com/evcc-io/evcc/provider/mqtt"
+	"github.com/evcc-io/evcc/server/access"
+	"github.com/evcc-io/evcc/server/homeassistant"
 	"github.com/evcc-io/evcc/util"
 )
 
 // MQTT is the MQTT server. It uses the MQTT client for publishing.
 type MQTT struct {
-	Handler *mqtt.Client
-	root    string
+	Handler   *mqtt.Client
+	root      string
+	role      access.Role
+	discovery *homeassistant.Discovery
+
+	mu        sync.Mutex
+	announced []string // discovery config topics
 }
 
-// NewMQTT creates MQTT server
-func NewMQTT(root string) *MQTT {
-	return &MQTT{
+// NewMQTT creates MQTT server. Setters are limited to those permitted to role.
+// Home Assistant discovery configs are published below the discovery prefix unless empty.
+func NewMQTT(root string, role access.Role, discovery string) *MQTT {
+	m := &MQTT{
 		Handler: mqtt.Instance,
 		root:    root,
+		role:    role,
+	}
+
+	if discovery != "" {
+		m.discovery = homeassistant.New(discovery, root, role)
 	}
+
+	return m
 }
 
 func (m *MQTT) encode(v interface{}) string {
@@ -38,6 +57,9 @@ func (m *MQTT) encode(v interface{}) string {
 		s = fmt.Sprintf("%s", val)
 	case float64:
 		s = fmt.Sprintf("%.5g", val)
+	case loadpoint.Plans:
+		b, _ := json.Marshal(val)
+		s = string(b)
 	default:
 		s = fmt.Sprintf("%v", val)
 	}
@@ -65,37 +87,223 @@ func (m *MQTT) publish(topic string, retained bool, payload interface{}) {
 	m.publishSingleValue(topic, retained, payload)
 }
 
+// listenSetter subscribes the setter if permitted to the mqtt api's role.
+// The setter's result or error is acknowledged on the setter's /result topic.
+func (m *MQTT) listenSetter(topic string, role access.Role, callback func(string) (interface{}, error)) {
+	if m.role < role {
+		return
+	}
+
+	m.Handler.ListenSetter(topic, func(payload string) {
+		res := make(map[string]interface{})
+		if val, err := callback(payload); err == nil {
+			res["result"] = val
+		} else {
+			res["error"] = err.Error()
+		}
+
+		if b, err := json.Marshal(res); err == nil {
+			m.publishSingleValue(topic+"/result", false, string(b))
+		}
+	})
+}
+
+func parseSoC(payload string) (int, error) {
+	soc, err := strconv.Atoi(payload)
+	if err == nil && (soc < 0 || soc > 100) {
+		err = fmt.Errorf("soc out of range: %d", soc)
+	}
+	return soc, err
+}
+
+// targetCharge is the target charge setter payload. Null or a null time removes the target charge.
+type targetCharge struct {
+	SoC  int        `json:"soc"`
+	Time *time.Time `json:"time"`
+}
+
+func parseTargetCharge(payload string) (time.Time, int, error) {
+	var res *targetCharge
+	if err := json.Unmarshal([]byte(payload), &res); err != nil {
+		return time.Time{}, 0, err
+	}
+
+	if res == nil || res.Time == nil {
+		return time.Time{}, 0, nil
+	}
+
+	if res.SoC < 0 || res.SoC > 100 {
+		return time.Time{}, 0, fmt.Errorf("soc out of range: %d", res.SoC)
+	}
+
+	if res.Time.IsZero() {
+		return time.Time{}, 0, errors.New("invalid time")
+	}
+
+	return *res.Time, res.SoC, nil
+}
+
+// remoteDemand is the remote demand setter payload
+type remoteDemand struct {
+	Demand loadpoint.RemoteDemand `json:"demand"`
+	Source string                 `json:"source"`
+}
+
+func parseRemoteDemand(payload string) (remoteDemand, error) {
+	res := remoteDemand{Source: "mqtt"}
+	if err := json.Unmarshal([]byte(payload), &res); err != nil {
+		// plain demand
+		res.Demand = loadpoint.RemoteDemand(payload)
+	}
+
+	switch demand := strings.ToLower(string(res.Demand)); demand {
+	case "", "enable":
+		res.Demand = loadpoint.RemoteEnable
+	case string(loadpoint.RemoteHardDisable), string(loadpoint.RemoteSoftDisable):
+		res.Demand = loadpoint.RemoteDemand(demand)
+	default:
+		return res, fmt.Errorf("invalid demand: %s", res.Demand)
+	}
+
+	return res, nil
+}
+
 func (m *MQTT) listenSetters(topic string, apiHandler loadpoint.API) {
-	m.Handler.ListenSetter(topic+"/mode/set", func(payload string) {
-		apiHandler.SetMode(api.ChargeMode(payload))
+	m.listenSetter(topic+"/mode/set", access.RoleDriver, func(payload string) (interface{}, error) {
+		mode, err := api.ChargeModeString(payload)
+		if err == nil && mode == api.ModeEmpty {
+			err = fmt.Errorf("invalid mode: %s", payload)
+		}
+		if err == nil {
+			apiHandler.SetMode(mode)
+		}
+		return mode, err
 	})
-	m.Handler.ListenSetter(topic+"/minSoC/set", func(payload string) {
-		if soc, err := strconv.Atoi(payload); err == nil {
+	m.listenSetter(topic+"/minSoC/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		soc, err := parseSoC(payload)
+		if err == nil {
 			apiHandler.SetMinSoC(soc)
 		}
+		return soc, err
 	})
-	m.Handler.ListenSetter(topic+"/targetSoC/set", func(payload string) {
-		if soc, err := strconv.Atoi(payload); err == nil {
+	m.listenSetter(topic+"/targetSoC/set", access.RoleDriver, func(payload string) (interface{}, error) {
+		soc, err := parseSoC(payload)
+		if err == nil {
 			apiHandler.SetTargetSoC(soc)
 		}
+		return soc, err
 	})
-	m.Handler.ListenSetter(topic+"/minCurrent/set", func(payload string) {
-		if current, err := strconv.ParseFloat(payload, 64); err == nil {
+	m.listenSetter(topic+"/minCurrent/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		current, err := strconv.ParseFloat(payload, 64)
+		if err == nil && (current <= 0 || current > apiHandler.GetMaxCurrent()) {
+			err = fmt.Errorf("min current out of range: %g", current)
+		}
+		if err == nil {
 			apiHandler.SetMinCurrent(current)
 		}
+		return current, err
 	})
-	m.Handler.ListenSetter(topic+"/maxCurrent/set", func(payload string) {
-		if current, err := strconv.ParseFloat(payload, 64); err == nil {
+	m.listenSetter(topic+"/maxCurrent/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		current, err := strconv.ParseFloat(payload, 64)
+		if err == nil && current < apiHandler.GetMinCurrent() {
+			err = fmt.Errorf("max current out of range: %g", current)
+		}
+		if err == nil {
 			apiHandler.SetMaxCurrent(current)
 		}
+		return current, err
+	})
+	m.listenSetter(topic+"/phases/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		phases, err := strconv.Atoi(payload)
+		if err == nil && phases != 1 && phases != 3 {
+			err = fmt.Errorf("invalid phases: %d", phases)
+		}
+		if err == nil {
+			err = apiHandler.SetPhases(phases)
+		}
+		return phases, err
+	})
+	m.listenSetter(topic+"/priority/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		priority, err := strconv.Atoi(payload)
+		if err == nil && priority < 0 {
+			err = fmt.Errorf("invalid priority: %d", priority)
+		}
+		if err == nil {
+			apiHandler.SetPriority(priority)
+		}
+		return priority, err
+	})
+	m.listenSetter(topic+"/plans/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		var plans loadpoint.Plans
+		err := json.Unmarshal([]byte(payload), &plans)
+		if err == nil {
+			err = apiHandler.SetPlans(plans)
+		}
+		return plans, err
 	})
-	m.Handler.ListenSetter(topic+"/phases/set", func(payload string) {
-		if phases, err := strconv.Atoi(payload); err == nil {
-			_ = apiHandler.SetPhases(phases)
+	m.listenSetter(topic+"/targetCharge/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		ts, soc, err := parseTargetCharge(payload)
+		if err != nil {
+			return nil, err
 		}
+
+		apiHandler.SetTargetCharge(ts, soc)
+
+		if ts.IsZero() {
+			return nil, nil
+		}
+		return targetCharge{SoC: soc, Time: &ts}, nil
+	})
+	m.listenSetter(topic+"/remoteDemand/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		res, err := parseRemoteDemand(payload)
+		if err == nil {
+			apiHandler.RemoteControl(res.Source, res.Demand)
+		}
+		return res, err
+	})
+	m.listenSetter(topic+"/vehicle/set", access.RoleAdmin, func(payload string) (interface{}, error) {
+		return payload, apiHandler.SetVehicle(payload)
 	})
 }
 
+// announce publishes the value's Home Assistant discovery config once
+func (m *MQTT) announce(p util.Param, title string) {
+	topic, config, ok := m.discovery.Config(p.LoadPoint, title, p.Key)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, t := range m.announced {
+		if t == topic {
+			return
+		}
+	}
+
+	b, err := json.Marshal(config)
+	if err != nil {
+		return
+	}
+
+	m.announced = append(m.announced, topic)
+	m.publishSingleValue(topic, true, string(b))
+}
+
+// Shutdown marks the MQTT API offline and removes the Home Assistant discovery configs
+func (m *MQTT) Shutdown() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, topic := range m.announced {
+		_ = m.Handler.Publish(topic, true, "")
+	}
+	m.announced = nil
+
+	_ = m.Handler.Publish(fmt.Sprintf("%s/status", m.root), true, "offline")
+}
+
 // Run starts the MQTT publisher for the MQTT API
 func (m *MQTT) Run(site site.API, in <-chan util.Param) {
 	// alive
@@ -103,10 +311,12 @@ func (m *MQTT) Run(site site.API, in <-chan util.Param) {
 	m.publish(topic, true, "online")
 
 	// site setters
-	m.Handler.ListenSetter(fmt.Sprintf("%s/site/prioritySoC/set", m.root), func(payload string) {
-		if soc, err := strconv.Atoi(payload); err == nil {
-			_ = site.SetPrioritySoC(float64(soc))
+	m.listenSetter(fmt.Sprintf("%s/site/prioritySoC/set", m.root), access.RoleAdmin, func(payload string) (interface{}, error) {
+		soc, err := parseSoC(payload)
+		if err == nil {
+			err = site.SetPrioritySoC(float64(soc))
 		}
+		return soc, err
 	})
 
 	// number of loadpoints
@@ -134,9 +344,16 @@ func (m *MQTT) Run(site site.API, in <-chan util.Param) {
 	// publish
 	for p := range in {
 		topic := fmt.Sprintf("%s/site", m.root)
+		title := site.GetTitle()
 		if p.LoadPoint != nil {
 			id := *p.LoadPoint + 1
 			topic = fmt.Sprintf("%s/loadpoints/%d", m.root, id)
+			title = site.LoadPoints()[*p.LoadPoint].Name()
+		}
+
+		// home assistant discovery
+		if m.discovery != nil {
+			m.announce(p, title)
 		}
 
 		// alive indicator
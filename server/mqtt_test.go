@@ -0,0 +1,67 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/core/loadpoint"
+)
+
+func TestParseTargetCharge(t *testing.T) {
+	tc := []struct {
+		in  string
+		soc int
+		ts  time.Time
+		err bool
+	}{
+		{`{"soc":80,"time":"2022-06-01T07:00:00Z"}`, 80, time.Date(2022, 6, 1, 7, 0, 0, 0, time.UTC), false},
+		{`{"soc":80,"time":null}`, 0, time.Time{}, false},
+		{`null`, 0, time.Time{}, false},
+		{`{"soc":0,"time":"2022-06-01T07:00:00Z"}`, 0, time.Date(2022, 6, 1, 7, 0, 0, 0, time.UTC), false},
+		{`{"soc":101,"time":"2022-06-01T07:00:00Z"}`, 0, time.Time{}, true},
+		{`{"soc":-1,"time":"2022-06-01T07:00:00Z"}`, 0, time.Time{}, true},
+		{`{"soc":80,"time":"07:00"}`, 0, time.Time{}, true},
+		{`80`, 0, time.Time{}, true},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		ts, soc, err := parseTargetCharge(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if soc != tc.soc || !ts.Equal(tc.ts) {
+			t.Errorf("expected %d @ %v, got %d @ %v", tc.soc, tc.ts, soc, ts)
+		}
+	}
+}
+
+func TestParseRemoteDemand(t *testing.T) {
+	tc := []struct {
+		in     string
+		demand loadpoint.RemoteDemand
+		source string
+		err    bool
+	}{
+		{`hard`, loadpoint.RemoteHardDisable, "mqtt", false},
+		{`Enable`, loadpoint.RemoteEnable, "mqtt", false},
+		{`{"demand":"soft","source":"hems"}`, loadpoint.RemoteSoftDisable, "hems", false},
+		{`{"source":"hems"}`, loadpoint.RemoteEnable, "hems", false},
+		{`off`, "", "", true},
+	}
+
+	for _, tc := range tc {
+		t.Logf("%+v", tc)
+
+		res, err := parseRemoteDemand(tc.in)
+		if (err != nil) != tc.err {
+			t.Errorf("unexpected error: %v", err)
+		}
+
+		if !tc.err && (res.Demand != tc.demand || res.Source != tc.source) {
+			t.Errorf("expected %s from %s, got %+v", tc.demand, tc.source, res)
+		}
+	}
+}
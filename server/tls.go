@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// TLSConfig is the https configuration
+type TLSConfig struct {
+	Enable   bool
+	Cert     string // certificate file, self-signed certificate is created if neither cert nor key exist
+	Key      string // private key file
+	Redirect string // optional http listener redirecting to https, e.g. 0.0.0.0:80
+}
+
+// RedirectHTTPS runs an http listener redirecting all requests to the https server's port
+func RedirectHTTPS(addr, httpsAddr string) error {
+	_, port, err := net.SplitHostPort(httpsAddr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, err := net.SplitHostPort(r.Host)
+			if err != nil {
+				host = r.Host
+			}
+
+			http.Redirect(w, r, "https://"+net.JoinHostPort(host, port)+r.URL.RequestURI(), http.StatusMovedPermanently)
+		}),
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		ErrorLog:     log.ERROR,
+	}
+
+	return srv.ListenAndServe()
+}
+
+// Scheme returns the server's url scheme
+func (s *HTTPd) Scheme() string {
+	if s.TLSConfig != nil {
+		return "https"
+	}
+	return "http"
+}
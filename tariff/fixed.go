@@ -1,11 +1,14 @@
 package tariff
 
 import (
+	"sync"
+
 	"github.com/evcc-io/evcc/api"
 	"github.com/evcc-io/evcc/util"
 )
 
 type Fixed struct {
+	mux   sync.Mutex
 	Price float64
 }
 
@@ -21,7 +24,16 @@ func NewFixed(other map[string]interface{}) (*Fixed, error) {
 	return &cc, nil
 }
 
+// SetPrice updates the price until restart
+func (t *Fixed) SetPrice(price float64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	t.Price = price
+}
+
 func (t *Fixed) CurrentPrice() (float64, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
 	return t.Price, nil
 }
 
@@ -25,7 +25,10 @@ type Tibber struct {
 	data   []tibber.PriceInfo
 }
 
-var _ api.Tariff = (*Tibber)(nil)
+var (
+	_ api.Tariff      = (*Tibber)(nil)
+	_ api.TariffRates = (*Tibber)(nil)
+)
 
 func NewTibber(other map[string]interface{}) (*Tibber, error) {
 	t := &Tibber{
@@ -93,7 +96,7 @@ func (t *Tibber) Run() {
 		}
 
 		t.mux.Lock()
-		t.data = res.Viewer.Home.CurrentSubscription.PriceInfo.Today
+		t.data = append(res.Viewer.Home.CurrentSubscription.PriceInfo.Today, res.Viewer.Home.CurrentSubscription.PriceInfo.Tomorrow...)
 		t.mux.Unlock()
 	}
 }
@@ -116,3 +119,24 @@ func (t *Tibber) IsCheap() (bool, error) {
 	price, err := t.CurrentPrice()
 	return price <= t.Cheap, err
 }
+
+// Rates implements the api.TariffRates interface
+func (t *Tibber) Rates() (api.Rates, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	res := make(api.Rates, 0, len(t.data))
+	for _, pi := range t.data {
+		res = append(res, api.Rate{
+			Start: pi.StartsAt,
+			End:   pi.StartsAt.Add(time.Hour),
+			Price: pi.Total,
+		})
+	}
+
+	if len(res) == 0 {
+		return nil, errors.New("unable to find tibber prices")
+	}
+
+	return res, nil
+}
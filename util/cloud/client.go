@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/evcc-io/evcc/util/record"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
@@ -44,6 +45,10 @@ func loadTLSCredentials() (*tls.Config, error) {
 func Connection(uri string) (*grpc.ClientConn, error) {
 	var err error
 	if conn == nil {
+		if err := record.Unrecorded(record.GRPC, uri); err != nil {
+			return nil, err
+		}
+
 		creds := insecure.NewCredentials()
 		if !strings.HasPrefix(uri, "localhost") {
 			var tlsConfig *tls.Config
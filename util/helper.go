@@ -2,6 +2,9 @@ package util
 
 import (
 	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 // RandomString creates random string of N integers
@@ -13,3 +16,17 @@ func RandomString(n int) string {
 	}
 	return string(s)
 }
+
+// ExpandHome replaces a leading ~ with the user's home directory
+func ExpandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~") {
+		return path, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
+}
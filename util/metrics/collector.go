@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "evcc"
+
+// Collector exports the cached site and loadpoint values as gauges
+type Collector struct {
+	log   *util.Logger
+	cache *util.Cache
+}
+
+// NewCollector creates a collector for the cached values
+func NewCollector(cache *util.Cache) *Collector {
+	return &Collector{
+		log:   util.NewLogger("metrics"),
+		cache: cache,
+	}
+}
+
+// Describe implements the prometheus.Collector interface.
+// The collector is unchecked since metrics depend on the published values.
+func (c *Collector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements the prometheus.Collector interface
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	params := c.cache.All()
+
+	vehicles := make(map[int]string)
+	for _, p := range params {
+		if p.LoadPoint != nil && p.Key == "vehicleTitle" {
+			vehicles[*p.LoadPoint], _ = p.Val.(string)
+		}
+	}
+
+	for _, p := range params {
+		val, ok := floatValue(p.Val)
+		if !ok {
+			continue
+		}
+
+		var metric prometheus.Metric
+		var err error
+
+		if p.LoadPoint == nil {
+			desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, "site", snakeCase(p.Key)), "Site "+p.Key, nil, nil)
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, val)
+		} else {
+			desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, "loadpoint", snakeCase(p.Key)), "Loadpoint "+p.Key, []string{"loadpoint", "vehicle"}, nil)
+			metric, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, val, strconv.Itoa(*p.LoadPoint+1), vehicles[*p.LoadPoint])
+		}
+
+		if err != nil {
+			c.log.ERROR.Printf("%s: %v", p.Key, err)
+			continue
+		}
+
+		ch <- metric
+	}
+}
+
+// floatValue converts numeric, boolean, duration and time values
+func floatValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Duration:
+		return v.Seconds(), true
+	case time.Time:
+		if v.IsZero() {
+			return 0, false
+		}
+		return float64(v.Unix()), true
+	default:
+		return 0, false
+	}
+}
+
+// snakeCase converts camel case keys like vehicleSoC to vehicle_soc
+func snakeCase(s string) string {
+	s = strings.ReplaceAll(s, "SoC", "Soc")
+
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteRune('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
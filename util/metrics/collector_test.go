@@ -0,0 +1,64 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evcc-io/evcc/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	lp := 0
+	cache := util.NewCache()
+
+	for _, p := range []util.Param{
+		{Key: "gridPower", Val: 1500.0},
+		{Key: "siteTitle", Val: "Home"},
+		{LoadPoint: &lp, Key: "vehicleTitle", Val: "e-Golf"},
+		{LoadPoint: &lp, Key: "vehicleSoC", Val: 42.0},
+		{LoadPoint: &lp, Key: "charging", Val: true},
+		{LoadPoint: &lp, Key: "chargeDuration", Val: 90 * time.Second},
+	} {
+		cache.Add(p.UniqueID(), p)
+	}
+
+	expected := `
+# HELP evcc_loadpoint_charge_duration Loadpoint chargeDuration
+# TYPE evcc_loadpoint_charge_duration gauge
+evcc_loadpoint_charge_duration{loadpoint="1",vehicle="e-Golf"} 90
+# HELP evcc_loadpoint_charging Loadpoint charging
+# TYPE evcc_loadpoint_charging gauge
+evcc_loadpoint_charging{loadpoint="1",vehicle="e-Golf"} 1
+# HELP evcc_loadpoint_vehicle_soc Loadpoint vehicleSoC
+# TYPE evcc_loadpoint_vehicle_soc gauge
+evcc_loadpoint_vehicle_soc{loadpoint="1",vehicle="e-Golf"} 42
+# HELP evcc_site_grid_power Site gridPower
+# TYPE evcc_site_grid_power gauge
+evcc_site_grid_power 1500
+`
+
+	if err := testutil.CollectAndCompare(NewCollector(cache), strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	err := Observe("grid", func() error {
+		return nil
+	})()
+
+	if err != nil || testutil.ToFloat64(deviceErrors.WithLabelValues("grid")) != 0 {
+		t.Error("unexpected error count")
+	}
+
+	_ = Observe("grid", func() error {
+		return errors.New("timeout")
+	})()
+
+	if testutil.ToFloat64(deviceErrors.WithLabelValues("grid")) != 1 {
+		t.Error("expected error count")
+	}
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/avast/retry-go/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	deviceErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "device_errors_total",
+		Help:      "Failed device calls",
+	}, []string{"device"})
+
+	deviceRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "device_retries_total",
+		Help:      "Retried device calls",
+	}, []string{"device"})
+
+	deviceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "device_call_duration_seconds",
+		Help:      "Device call latency",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"device"})
+)
+
+// Observe wraps a device call and records its latency and errors
+func Observe(device string, fn func() error) func() error {
+	return func() error {
+		start := time.Now()
+		err := fn()
+
+		deviceDuration.WithLabelValues(device).Observe(time.Since(start).Seconds())
+		if err != nil {
+			deviceErrors.WithLabelValues(device).Inc()
+		}
+
+		return err
+	}
+}
+
+// OnRetry returns a retry option counting the device's retries
+func OnRetry(device string) retry.Option {
+	return retry.OnRetry(func(uint, error) {
+		deviceRetries.WithLabelValues(device).Inc()
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 )
@@ -54,3 +55,53 @@ func decodeBool16(mask uint64) func(b []byte) float64 {
 		return 0
 	}
 }
+
+// encodeIeee754 converts a float to 32 bit IEEE 754 bytes
+func encodeIeee754(f float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, math.Float32bits(float32(f)))
+	return b
+}
+
+// encodeUint16 converts a float to rounded 16 bit unsigned integer bytes
+func encodeUint16(f float64) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(math.Round(f)))
+	return b
+}
+
+// encodeInt16 converts a float to rounded 16 bit signed integer bytes
+func encodeInt16(f float64) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, uint16(int16(math.Round(f))))
+	return b
+}
+
+// encodeUint32 converts a float to rounded 32 bit unsigned integer bytes
+func encodeUint32(f float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(math.Round(f)))
+	return b
+}
+
+// encodeInt32 converts a float to rounded 32 bit signed integer bytes
+func encodeInt32(f float64) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(int32(math.Round(f))))
+	return b
+}
+
+// encodeUint64 converts a float to rounded 64 bit unsigned integer bytes
+func encodeUint64(f float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(math.Round(f)))
+	return b
+}
+
+// swapped swaps the word order of the encoded 32 bit value
+func swapped(enc func(float64) []byte) func(float64) []byte {
+	return func(f float64) []byte {
+		b := enc(f)
+		return []byte{b[2], b[3], b[0], b[1]}
+	}
+}
@@ -1,6 +1,7 @@
 package modbus
 
 import (
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"strconv"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/evcc-io/evcc/util"
+	"github.com/evcc-io/evcc/util/record"
 	"github.com/grid-x/modbus"
 	"github.com/volkszaehler/mbmd/meters"
 	"github.com/volkszaehler/mbmd/meters/rs485"
@@ -35,6 +37,7 @@ type Settings struct {
 
 // Connection decorates a meters.Connection with transparent slave id and error handling
 type Connection struct {
+	name    string // uri or device
 	slaveID uint8
 	conn    meters.Connection
 	delay   time.Duration
@@ -54,6 +57,44 @@ func (mb *Connection) handle(res []byte, err error) ([]byte, error) {
 	return res, err
 }
 
+// exec executes the operation or replays it from a recording. Operations are recorded if enabled.
+func (mb *Connection) exec(op string, value []byte, fun func(modbus.Client) ([]byte, error)) ([]byte, error) {
+	request := fmt.Sprintf("%s:%d %s", mb.name, mb.slaveID, op)
+	if len(value) > 0 {
+		request += " " + hex.EncodeToString(value)
+	}
+
+	if record.Replaying() {
+		e, err := record.Lookup(record.Modbus, request)
+		if err == nil && e.Error != "" {
+			err = errors.New(e.Error)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return hex.DecodeString(e.Response)
+	}
+
+	mb.prepare()
+	res, err := mb.handle(fun(mb.conn.ModbusClient()))
+
+	if record.Recording() {
+		e := record.Entry{
+			Transport: record.Modbus,
+			Request:   request,
+			Response:  hex.EncodeToString(res),
+		}
+		if err != nil {
+			e.Error = err.Error()
+		}
+
+		record.Add(e)
+	}
+
+	return res, err
+}
+
 // Delay sets delay so use between subsequent modbus operations
 func (mb *Connection) Delay(delay time.Duration) {
 	mb.delay = delay
@@ -76,68 +117,79 @@ func (mb *Connection) Timeout(timeout time.Duration) {
 
 // ReadCoils wraps the underlying implementation
 func (mb *Connection) ReadCoils(address, quantity uint16) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadCoils(address, quantity))
+	return mb.exec(fmt.Sprintf("ReadCoils %d %d", address, quantity), nil, func(c modbus.Client) ([]byte, error) {
+		return c.ReadCoils(address, quantity)
+	})
 }
 
 // WriteSingleCoil wraps the underlying implementation
 func (mb *Connection) WriteSingleCoil(address, quantity uint16) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().WriteSingleCoil(address, quantity))
+	return mb.exec(fmt.Sprintf("WriteSingleCoil %d %d", address, quantity), nil, func(c modbus.Client) ([]byte, error) {
+		return c.WriteSingleCoil(address, quantity)
+	})
 }
 
 // ReadInputRegisters wraps the underlying implementation
 func (mb *Connection) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadInputRegisters(address, quantity))
+	return mb.exec(fmt.Sprintf("ReadInputRegisters %d %d", address, quantity), nil, func(c modbus.Client) ([]byte, error) {
+		return c.ReadInputRegisters(address, quantity)
+	})
 }
 
 // ReadHoldingRegisters wraps the underlying implementation
 func (mb *Connection) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadHoldingRegisters(address, quantity))
+	return mb.exec(fmt.Sprintf("ReadHoldingRegisters %d %d", address, quantity), nil, func(c modbus.Client) ([]byte, error) {
+		return c.ReadHoldingRegisters(address, quantity)
+	})
 }
 
 // WriteSingleRegister wraps the underlying implementation
 func (mb *Connection) WriteSingleRegister(address, value uint16) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().WriteSingleRegister(address, value))
+	return mb.exec(fmt.Sprintf("WriteSingleRegister %d %d", address, value), nil, func(c modbus.Client) ([]byte, error) {
+		return c.WriteSingleRegister(address, value)
+	})
 }
 
 // WriteMultipleRegisters wraps the underlying implementation
 func (mb *Connection) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().WriteMultipleRegisters(address, quantity, value))
+	return mb.exec(fmt.Sprintf("WriteMultipleRegisters %d %d", address, quantity), value, func(c modbus.Client) ([]byte, error) {
+		return c.WriteMultipleRegisters(address, quantity, value)
+	})
 }
 
 // ReadDiscreteInputs wraps the underlying implementation
 func (mb *Connection) ReadDiscreteInputs(address, quantity uint16) (results []byte, err error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadDiscreteInputs(address, quantity))
+	return mb.exec(fmt.Sprintf("ReadDiscreteInputs %d %d", address, quantity), nil, func(c modbus.Client) ([]byte, error) {
+		return c.ReadDiscreteInputs(address, quantity)
+	})
 }
 
 // WriteMultipleCoils wraps the underlying implementation
 func (mb *Connection) WriteMultipleCoils(address, quantity uint16, value []byte) (results []byte, err error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().WriteMultipleCoils(address, quantity, value))
+	return mb.exec(fmt.Sprintf("WriteMultipleCoils %d %d", address, quantity), value, func(c modbus.Client) ([]byte, error) {
+		return c.WriteMultipleCoils(address, quantity, value)
+	})
 }
 
 // ReadWriteMultipleRegisters wraps the underlying implementation
 func (mb *Connection) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) (results []byte, err error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value))
+	return mb.exec(fmt.Sprintf("ReadWriteMultipleRegisters %d %d %d %d", readAddress, readQuantity, writeAddress, writeQuantity), value, func(c modbus.Client) ([]byte, error) {
+		return c.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+	})
 }
 
 // MaskWriteRegister wraps the underlying implementation
 func (mb *Connection) MaskWriteRegister(address, andMask, orMask uint16) (results []byte, err error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().MaskWriteRegister(address, andMask, orMask))
+	return mb.exec(fmt.Sprintf("MaskWriteRegister %d %d %d", address, andMask, orMask), nil, func(c modbus.Client) ([]byte, error) {
+		return c.MaskWriteRegister(address, andMask, orMask)
+	})
 }
 
 // ReadFIFOQueue wraps the underlying implementation
 func (mb *Connection) ReadFIFOQueue(address uint16) (results []byte, err error) {
-	mb.prepare()
-	return mb.handle(mb.conn.ModbusClient().ReadFIFOQueue(address))
+	return mb.exec(fmt.Sprintf("ReadFIFOQueue %d", address), nil, func(c modbus.Client) ([]byte, error) {
+		return c.ReadFIFOQueue(address)
+	})
 }
 
 var connections = make(map[string]meters.Connection)
@@ -205,7 +257,13 @@ func NewConnection(uri, device, comset string, baudrate int, proto Protocol, sla
 		return nil, errors.New("invalid modbus configuration: need either uri or device")
 	}
 
+	name := device
+	if uri != "" {
+		name = uri
+	}
+
 	slaveConn := &Connection{
+		name:    name,
 		slaveID: slaveID,
 		conn:    conn,
 	}
@@ -273,6 +331,8 @@ func RegisterOperation(r Register) (rs485.Operation, error) {
 		op.FuncCode = modbus.FuncCodeReadInputRegisters
 	case "writesingle":
 		op.FuncCode = modbus.FuncCodeWriteSingleRegister
+	case "writemultiple":
+		op.FuncCode = modbus.FuncCodeWriteMultipleRegisters
 	default:
 		return rs485.Operation{}, fmt.Errorf("invalid register type: %s", r.Type)
 	}
@@ -316,6 +376,33 @@ func RegisterOperation(r Register) (rs485.Operation, error) {
 	return op, nil
 }
 
+// RegisterEncoder creates the encoder of a write operation from a register definition.
+// It is the inverse of the read operation's transform.
+func RegisterEncoder(r Register) (func(float64) []byte, error) {
+	switch strings.ToLower(r.Decode) {
+	case "float32", "ieee754":
+		return encodeIeee754, nil
+	case "float32s", "ieee754s":
+		return swapped(encodeIeee754), nil
+	case "float64", "uint64":
+		return encodeUint64, nil
+	case "uint16":
+		return encodeUint16, nil
+	case "uint32":
+		return encodeUint32, nil
+	case "uint32s":
+		return swapped(encodeUint32), nil
+	case "int16":
+		return encodeInt16, nil
+	case "int32":
+		return encodeInt32, nil
+	case "int32s":
+		return swapped(encodeInt32), nil
+	default:
+		return nil, fmt.Errorf("invalid register encoding: %s", r.Decode)
+	}
+}
+
 // SunSpecOperation is a sunspec modbus operation
 type SunSpecOperation struct {
 	Model, Block int
@@ -28,3 +28,50 @@ func TestParsePoint(t *testing.T) {
 		}
 	}
 }
+
+func TestRegisterEncoder(t *testing.T) {
+	tc := []struct {
+		decode string
+		val    float64
+	}{
+		{"float32", 230.5},
+		{"float32s", -1.25},
+		{"float64", 123456789},
+		{"uint16", 4200},
+		{"uint32", 70000},
+		{"uint32s", 70000},
+		{"uint64", 1 << 40},
+		{"int16", -16},
+		{"int32", -70000},
+		{"int32s", -70000},
+	}
+
+	for _, tc := range tc {
+		t.Log(tc)
+
+		r := Register{Type: "writemultiple", Decode: tc.decode}
+
+		enc, err := RegisterEncoder(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		op, err := RegisterOperation(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := enc(tc.val)
+		if len(b) != 2*int(op.ReadLen) {
+			t.Errorf("unexpected length: %d", len(b))
+		}
+
+		if res := op.Transform(b); res != tc.val {
+			t.Errorf("unexpected result: %v", res)
+		}
+	}
+
+	if _, err := RegisterEncoder(Register{Decode: "bool16"}); err == nil {
+		t.Error("expected error")
+	}
+}
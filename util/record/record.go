@@ -0,0 +1,238 @@
+// Package record records device traffic to a file and replays recordings in place of the devices
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+)
+
+// Transports
+const (
+	HTTP   = "http"
+	Modbus = "modbus"
+	MQTT   = "mqtt"
+)
+
+// Transports which are not recorded and fail while replaying, see Unrecorded
+const (
+	Bluetooth = "bluetooth"
+	EEBus     = "eebus"
+	Exec      = "exec"
+	GRPC      = "grpc"
+	OCPP      = "ocpp"
+	SMA       = "sma"
+	TCP       = "tcp"
+	UDP       = "udp"
+	Websocket = "websocket"
+)
+
+// Entry is a recorded request/response pair
+type Entry struct {
+	Transport string `json:"transport"`          // http, modbus or mqtt
+	Request   string `json:"request"`            // identifies the request, e.g. method and url
+	Body      string `json:"body,omitempty"`     // request body
+	Status    int    `json:"status,omitempty"`   // http status code
+	Response  string `json:"response,omitempty"` // response body
+	Error     string `json:"error,omitempty"`    // transport error
+}
+
+// ErrNotRecorded indicates that the replayed recording does not contain the request
+var ErrNotRecorded = errors.New("not recorded")
+
+var (
+	mu       sync.Mutex
+	recorder *json.Encoder
+	file     *os.File
+	replay   map[string]*entries
+)
+
+// entries are replayed in order, repeating the last entry once exhausted
+type entries struct {
+	list []Entry
+	pos  int
+}
+
+// secret json fields, query and form parameters and url user info are redacted
+var (
+	jsonSecret  = regexp.MustCompile(`(?i)("[^"]*(password|passwd|secret|token|apikey|api_key|pin|session|cookie|vin)[^"]*"\s*:\s*)"[^"]*"`)
+	paramSecret = regexp.MustCompile(`(?i)([a-z_.-]*(password|passwd|secret|token|apikey|api_key|pin|session|vin)[a-z_.-]*=)[^&;\s"]*`)
+	userInfo    = regexp.MustCompile(`(?i)([a-z][a-z0-9+.-]*://)[^/?#@\s"]+@`)
+)
+
+// Redact masks secrets in requests and responses
+func Redact(s string) string {
+	s = jsonSecret.ReplaceAllString(s, `$1"***"`)
+	s = userInfo.ReplaceAllString(s, `$1***@`)
+	return paramSecret.ReplaceAllString(s, `$1***`)
+}
+
+func key(transport, request string) string {
+	return transport + " " + Redact(request)
+}
+
+// Record starts recording to file
+func Record(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if replay != nil {
+		return errors.New("cannot record while replaying")
+	}
+
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+
+	file = f
+	recorder = json.NewEncoder(f)
+
+	return nil
+}
+
+// Replay loads a recording for replay
+func Replay(name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if recorder != nil {
+		return errors.New("cannot replay while recording")
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	res := make(map[string]*entries)
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+
+		k := key(e.Transport, e.Request)
+		if res[k] == nil {
+			res[k] = new(entries)
+		}
+		res[k].list = append(res[k].list, e)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	replay = res
+
+	return nil
+}
+
+// Offline replays an empty recording such that all device traffic fails with ErrNotRecorded
+func Offline() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if recorder != nil {
+		return errors.New("cannot go offline while recording")
+	}
+
+	replay = make(map[string]*entries)
+
+	return nil
+}
+
+// Recording returns true if traffic is recorded
+func Recording() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return recorder != nil
+}
+
+// Replaying returns true if traffic is replayed from a recording
+func Replaying() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return replay != nil
+}
+
+// Unrecorded returns ErrNotRecorded while replaying. Devices using transports which are not
+// recorded must check before touching the hardware.
+func Unrecorded(transport, target string) error {
+	if Replaying() {
+		return fmt.Errorf("%s: %w", key(transport, target), ErrNotRecorded)
+	}
+	return nil
+}
+
+// Add records the redacted entry if recording
+func Add(e Entry) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if recorder == nil {
+		return
+	}
+
+	e.Request = Redact(e.Request)
+	e.Body = Redact(e.Body)
+	e.Response = Redact(e.Response)
+
+	_ = recorder.Encode(e)
+}
+
+// Lookup returns the next recorded entry for the request
+func Lookup(transport, request string) (Entry, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	k := key(transport, request)
+
+	list, ok := replay[k]
+	if !ok {
+		return Entry{}, fmt.Errorf("%s: %w", k, ErrNotRecorded)
+	}
+
+	e := list.list[list.pos]
+	if list.pos < len(list.list)-1 {
+		list.pos++
+	}
+
+	return e, nil
+}
+
+// Entries returns all recorded entries for the request
+func Entries(transport, request string) []Entry {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if list, ok := replay[key(transport, request)]; ok {
+		return list.list
+	}
+
+	return nil
+}
+
+// Close stops recording or replaying
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var err error
+	if file != nil {
+		err = file.Close()
+	}
+
+	file, recorder, replay = nil, nil, nil
+
+	return err
+}
@@ -0,0 +1,50 @@
+package util
+
+import "sync"
+
+// release collects the functions registered by devices created within CaptureReleases
+var release struct {
+	capture sync.Mutex // serializes captures
+	mu      sync.Mutex
+	active  bool
+	funcs   []func()
+}
+
+// CaptureReleases runs fn creating a device and returns a function releasing the resources,
+// e.g. subscriptions or goroutines, the device registered using OnRelease.
+// Captures are serialized. The release function must also be called if fn fails.
+func CaptureReleases(fn func() error) (func(), error) {
+	release.capture.Lock()
+	defer release.capture.Unlock()
+
+	release.mu.Lock()
+	release.active, release.funcs = true, nil
+	release.mu.Unlock()
+
+	err := fn()
+
+	release.mu.Lock()
+	funcs := release.funcs
+	release.active, release.funcs = false, nil
+	release.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			for _, f := range funcs {
+				f()
+			}
+		})
+	}, err
+}
+
+// OnRelease registers fn releasing a resource of the device being created.
+// Resources of devices created outside of CaptureReleases are held until exit.
+func OnRelease(fn func()) {
+	release.mu.Lock()
+	defer release.mu.Unlock()
+
+	if release.active {
+		release.funcs = append(release.funcs, fn)
+	}
+}
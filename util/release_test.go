@@ -0,0 +1,29 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCaptureReleases(t *testing.T) {
+	var released int
+
+	// outside of captures resources are held
+	OnRelease(func() { released++ })
+
+	release, err := CaptureReleases(func() error {
+		OnRelease(func() { released++ })
+		OnRelease(func() { released++ })
+		return errors.New("failed")
+	})
+	if err == nil {
+		t.Error("expected error")
+	}
+
+	release()
+	release()
+
+	if released != 2 {
+		t.Errorf("expected 2 releases, got %d", released)
+	}
+}
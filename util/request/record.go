@@ -0,0 +1,80 @@
+package request
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/evcc-io/evcc/util/record"
+)
+
+// recorder records or replays requests
+type recorder struct {
+	base http.RoundTripper
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	// user info contains credentials
+	u := *req.URL
+	u.User = nil
+	request := req.Method + " " + u.String()
+
+	if record.Replaying() {
+		e, err := record.Lookup(record.HTTP, request)
+		if err == nil && e.Error != "" {
+			err = errors.New(e.Error)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", e.Status, http.StatusText(e.Status)),
+			StatusCode:    e.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        make(http.Header),
+			Body:          io.NopCloser(strings.NewReader(e.Response)),
+			ContentLength: int64(len(e.Response)),
+			Request:       req,
+		}, nil
+	}
+
+	if !record.Recording() {
+		return r.base.RoundTrip(req)
+	}
+
+	e := record.Entry{
+		Transport: record.HTTP,
+		Request:   request,
+	}
+
+	var save io.ReadCloser
+	var err error
+	if save, req.Body, err = drainBody(req.Body); err != nil {
+		return nil, err
+	}
+
+	b, _ := io.ReadAll(save)
+	e.Body = string(b)
+
+	resp, err := r.base.RoundTrip(req)
+	if err == nil {
+		e.Status = resp.StatusCode
+		if save, resp.Body, err = drainBody(resp.Body); err != nil {
+			return nil, err
+		}
+
+		b, _ := io.ReadAll(save)
+		e.Response = string(b)
+	} else {
+		e.Error = err.Error()
+	}
+
+	record.Add(e)
+
+	return resp, err
+}
@@ -51,11 +51,11 @@ func init() {
 	prometheus.MustRegister(reqMetric, resMetric)
 }
 
-// NewTripper creates a logging roundtrip handler
+// NewTripper creates a logging roundtrip handler. Requests are recorded or replayed if enabled.
 func NewTripper(log *util.Logger, base http.RoundTripper) http.RoundTripper {
 	tripper := &roundTripper{
 		log:  log,
-		base: base,
+		base: &recorder{base: base},
 	}
 
 	return tripper
@@ -0,0 +1,146 @@
+// Package selfsigned creates and persists self-signed server certificates
+package selfsigned
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Validity is the generated certificate's validity period. Apple platforms reject server certificates valid for more than 825 days.
+const Validity = 825 * 24 * time.Hour
+
+// Renewal is the remaining validity below which generated certificates are replaced
+const Renewal = 30 * 24 * time.Hour
+
+// organization identifies generated certificates
+const organization = "evcc"
+
+// Hosts returns the local host names and interface addresses the certificate should be valid for
+func Hosts() []string {
+	res := []string{"localhost"}
+
+	if hostname, err := os.Hostname(); err == nil && hostname != "localhost" {
+		res = append(res, hostname, hostname+".local")
+	}
+
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLinkLocalUnicast() {
+				res = append(res, ipnet.IP.String())
+			}
+		}
+	}
+
+	return res
+}
+
+// Generate creates a PEM-encoded self-signed certificate and private key for the given hosts
+func Generate(hosts []string) ([]byte, []byte, error) {
+	return generate(hosts, time.Now().Add(-time.Hour))
+}
+
+func generate(hosts []string, notBefore time.Time) ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{organization}, CommonName: hosts[0]},
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.Add(Validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+
+	return certPEM, keyPEM, nil
+}
+
+// renew returns true if the certificate has been generated and is expiring or exceeds the validity period
+func renew(cert tls.Certificate) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return false
+	}
+
+	generated := len(leaf.Subject.Organization) == 1 && leaf.Subject.Organization[0] == organization &&
+		leaf.IsCA && leaf.CheckSignatureFrom(leaf) == nil
+
+	return generated && (time.Until(leaf.NotAfter) < Renewal || leaf.NotAfter.Sub(leaf.NotBefore) > Validity)
+}
+
+// LoadOrCreate loads the certificate from certFile and keyFile.
+// If neither file exists, a self-signed certificate for the local hosts is created and stored.
+// Generated certificates are replaced when expiring.
+func LoadOrCreate(certFile, keyFile string) (tls.Certificate, bool, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+
+	if !errors.Is(certErr, os.ErrNotExist) || !errors.Is(keyErr, os.ErrNotExist) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil || !renew(cert) {
+			return cert, false, err
+		}
+	}
+
+	certPEM, keyPEM, err := Generate(Hosts())
+	if err != nil {
+		return tls.Certificate{}, false, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(certFile), 0o755); err != nil {
+		return tls.Certificate{}, false, err
+	}
+
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return tls.Certificate{}, false, fmt.Errorf("writing certificate: %w", err)
+	}
+
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		return tls.Certificate{}, false, fmt.Errorf("writing key: %w", err)
+	}
+
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	return cert, true, err
+}
@@ -0,0 +1,69 @@
+package selfsigned
+
+import (
+	"bytes"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadOrCreate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "evcc.crt"), filepath.Join(dir, "evcc.key")
+
+	cert, created, err := LoadOrCreate(certFile, keyFile)
+	if err != nil || !created {
+		t.Fatalf("expected certificate created, got %v (%v)", created, err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := leaf.VerifyHostname("localhost"); err != nil {
+		t.Error(err)
+	}
+
+	if validity := leaf.NotAfter.Sub(leaf.NotBefore); validity > 825*24*time.Hour {
+		t.Errorf("validity exceeds 825 days: %v", validity)
+	}
+
+	if fi, err := os.Stat(keyFile); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Errorf("unexpected key file: %v (%v)", fi, err)
+	}
+
+	// existing certificate is reused
+	reloaded, created, err := LoadOrCreate(certFile, keyFile)
+	if err != nil || created || !bytes.Equal(reloaded.Certificate[0], cert.Certificate[0]) {
+		t.Errorf("expected certificate reloaded, got %v (%v)", created, err)
+	}
+
+	// expiring certificate is replaced
+	certPEM, keyPEM, err := generate([]string{"localhost"}, time.Now().Add(Renewal-Validity))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, created, err := LoadOrCreate(certFile, keyFile); err != nil || !created {
+		t.Errorf("expected expiring certificate replaced, got %v (%v)", created, err)
+	}
+
+	// incomplete key pair is not overwritten
+	if err := os.Remove(keyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := LoadOrCreate(certFile, keyFile); err == nil {
+		t.Error("expected missing key error")
+	}
+}